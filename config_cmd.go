@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"mytheresa/internal/config"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runConfig handles the config subcommands, for now only print, writing as YAML the configuration
+// the server would start with out of the same file, environment and flags, e.g.
+//
+//	mytheresa config print -config prod.yaml -port 9090
+//
+// Secrets are redacted. It returns the exit code: 0 when the configuration is valid, 1 when
+// not, 2 on usage errors.
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: mytheresa config print [server flags]")
+		return 2
+	}
+
+	conf, err := config.Load("mytheresa config print", args[1:])
+	var invalid *config.ValidationError
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil && !errors.As(err, &invalid) {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(conf.Redacted()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if invalid != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", invalid)
+		return 1
+	}
+	return 0
+}
@@ -15,66 +15,50 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/v1/discounts": {
-            "get": {
-                "description": "Retrieve a list of all available discounts",
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Get all discounts",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/discount.GeneralDiscount"
-                            }
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "$ref": "#/definitions/apierror.ApiError"
-                        }
-                    }
-                }
-            },
+        "/graphql": {
             "post": {
-                "description": "Create a new discount with the provided details",
+                "description": "Products, categories and discounts as GraphQL, with the query depth and complexity limited.\nQueries are also accepted with GET, in the query, operationName and variables parameters.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create a new discount",
+                "summary": "Query the catalog with GraphQL",
                 "parameters": [
                     {
-                        "description": "Discount details",
-                        "name": "discount",
+                        "description": "GraphQL query",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/discount.DiscountRequest"
+                            "$ref": "#/definitions/graphql.Request"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "Data along with the errors of the fields that failed",
                         "schema": {
-                            "$ref": "#/definitions/discount.DiscountResponse"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Wrong body",
+                        "description": "Invalid query or over the limits",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "413": {
+                        "description": "Body too large",
                         "schema": {
                             "$ref": "#/definitions/apierror.ApiError"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/apierror.ApiError"
                         }
@@ -82,37 +66,51 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/products": {
+        "/v1/audit": {
             "get": {
-                "description": "Retrieve a list of products, with optional filtering by category and price range",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get who changed the entities of a kind, when, and their snapshots before and after, oldest first.\nEntries come a page at a time, the next one starting after the id of the last entry.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "List all products",
+                "summary": "Get the audit log",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "default": 5,
-                        "description": "Limit the number of products",
-                        "name": "limit",
-                        "in": "query"
+                        "enum": [
+                            "product",
+                            "category",
+                            "discount",
+                            "discount_type"
+                        ],
+                        "type": "string",
+                        "description": "Entity kind",
+                        "name": "entity",
+                        "in": "query",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Filter products by category ID",
-                        "name": "category",
+                        "description": "Only the changes of this entity, e.g. a product SKU",
+                        "name": "id",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Filter products with price less than",
-                        "name": "priceLessThan",
+                        "description": "Entries per page, 100 by default and 1000 at most",
+                        "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Filter products with price greater than",
-                        "name": "priceGreaterThan",
+                        "description": "ID of the last entry of the previous page",
+                        "name": "after",
                         "in": "query"
                     }
                 ],
@@ -122,10 +120,28 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/product.ProductResponse"
+                                "$ref": "#/definitions/audit.EntryResponse"
                             }
                         }
                     },
+                    "400": {
+                        "description": "Unknown entity, wrong limit or wrong after",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -133,24 +149,83 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/v1/cart/price": {
             "post": {
-                "description": "Create a new product with the provided details",
+                "description": "Price a list of products and quantities, applying per product discounts and basket level promotions",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create a new product",
+                "summary": "Price a cart",
                 "parameters": [
                     {
-                        "description": "Product details",
-                        "name": "product",
+                        "description": "Cart details",
+                        "name": "cart",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/product.ProductRequest"
+                            "$ref": "#/definitions/cart.CartRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/cart.CartPriceResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/coupon": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a coupon code unlocking a coupon only discount",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a new coupon",
+                "parameters": [
+                    {
+                        "description": "Coupon details",
+                        "name": "coupon",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/coupon.CouponRequest"
                         }
                     }
                 ],
@@ -158,7 +233,7 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/product.ProductResponse"
+                            "$ref": "#/definitions/coupon.CouponResponse"
                         }
                     },
                     "400": {
@@ -167,6 +242,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/apierror.ApiError"
                         }
                     },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -176,31 +263,75 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/products/{id}": {
-            "get": {
-                "description": "Get the details of a product by its SKU",
+        "/v1/coupon/{code}/redeem": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Use one of the redemptions left on a coupon for a customer",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get a product by SKU",
+                "summary": "Redeem a coupon",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Product SKU",
-                        "name": "id",
+                        "description": "Coupon code",
+                        "name": "code",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Redemption details",
+                        "name": "redemption",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/coupon.RedemptionRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/product.ProductResponse"
+                            "$ref": "#/definitions/coupon.CouponResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
                         }
                     },
                     "404": {
-                        "description": "Product not found",
+                        "description": "Coupon not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "409": {
+                        "description": "Coupon expired or exhausted",
                         "schema": {
                             "$ref": "#/definitions/apierror.ApiError"
                         }
@@ -213,14 +344,1527 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "apierror.ApiError": {
+        },
+        "/v1/discounts": {
+            "get": {
+                "description": "Retrieve a list of all available discounts, coupon only ones included",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "text/csv"
+                ],
+                "summary": "Get all discounts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ETag of the representation already held",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified of the representation already held",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/discount.GeneralDiscount"
+                            }
+                        },
+                        "headers": {
+                            "Cache-Control": {
+                                "type": "string",
+                                "description": "Caching policy, configurable"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Hash of the response body"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Last change of what the response shows"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified"
+                    },
+                    "406": {
+                        "description": "Not acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new discount with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a new discount",
+                "parameters": [
+                    {
+                        "description": "Discount details",
+                        "name": "discount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/discount.DiscountRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/discount.DiscountResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "413": {
+                        "description": "Body too large",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Server-Sent Events with every change committed from now on: product.created, product.updated,\ndiscount.created and price.changed. Each event has its ID, type and the Event as JSON data.\nReconnecting with Last-Event-ID resumes after that event, as long as it's among the latest ones kept.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream the catalog changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated event types, every type when empty",
+                        "name": "types",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last event received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/events.Event"
+                        }
+                    },
+                    "400": {
+                        "description": "Unknown event type",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "503": {
+                        "description": "Shutting down",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/export/products": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stream every product with its computed price and stock, as CSV (a row per product and variant) or JSON Lines",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "summary": "Export the catalog",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "jsonl"
+                        ],
+                        "type": "string",
+                        "default": "jsonl",
+                        "description": "File format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Catalog file",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong format",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/import/{kind}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create every row of a CSV (with header) or JSON Lines file. Failing rows are reported by line.\nWith atomic nothing is imported unless every row is valid, with dry_run rows are only validated.",
+                "consumes": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Bulk import products, categories or discounts",
+                "parameters": [
+                    {
+                        "enum": [
+                            "products",
+                            "categories",
+                            "discounts"
+                        ],
+                        "type": "string",
+                        "description": "What the file holds",
+                        "name": "kind",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "jsonl"
+                        ],
+                        "type": "string",
+                        "description": "File format, taken from the Content-Type when missing",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only validate the rows",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Import every row or none",
+                        "name": "atomic",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/catalog.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong kind, format or CSV header",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "413": {
+                        "description": "Body too large",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/inventory/{sku}": {
+            "get": {
+                "description": "Get the units available and reserved for a product or variant SKU",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the stock of a SKU",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product or variant SKU",
+                        "name": "sku",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/inventory.StockResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/inventory/{sku}/adjust": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add or remove units from the stock of a product or variant SKU",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Adjust the stock of a SKU",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product or variant SKU",
+                        "name": "sku",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Stock adjustment",
+                        "name": "adjustment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/inventory.AdjustStockRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/inventory.StockResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "409": {
+                        "description": "Not enough stock",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/products": {
+            "get": {
+                "description": "Retrieve a list of products, with optional filtering by category and price range",
+                "produces": [
+                    "application/json",
+                    "text/xml",
+                    "text/csv"
+                ],
+                "summary": "List all products",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Limit the number of products, 5 unless configured otherwise",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter products by category ID",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter products with price less than",
+                        "name": "priceLessThan",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter products with price greater than",
+                        "name": "priceGreaterThan",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Coupon code unlocking an additional discount",
+                        "name": "coupon",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only list products with units available",
+                        "name": "in_stock",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the representation already held",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/product.ProductResponse"
+                            }
+                        },
+                        "headers": {
+                            "Cache-Control": {
+                                "type": "string",
+                                "description": "Caching policy, configurable"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Hash of the versions of what the response shows"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified"
+                    },
+                    "400": {
+                        "description": "Wrong limit",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "Coupon not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "406": {
+                        "description": "Not acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "409": {
+                        "description": "Coupon expired or exhausted",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new product with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a new product",
+                "parameters": [
+                    {
+                        "description": "Product details",
+                        "name": "product",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/product.ProductRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/product.ProductResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "413": {
+                        "description": "Body too large",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/products/{id}": {
+            "get": {
+                "description": "Get the details of a product by its SKU, or of the product of a variant SKU. Clients opting in to v2,\nwith the application/vnd.mytheresa.v2+json media type or X-API-Version: 2, get the ProductResponse\npriced as in the product list instead, wrapped in an envelope, a variant SKU giving the variant alone",
+                "produces": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "summary": "Get a product by SKU",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product SKU",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Coupon code unlocking an additional discount, v2 only",
+                        "name": "coupon",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the representation already held",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified of the representation already held",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The product, or the ProductResponse in v2",
+                        "schema": {
+                            "$ref": "#/definitions/product.Product"
+                        },
+                        "headers": {
+                            "Cache-Control": {
+                                "type": "string",
+                                "description": "Caching policy, configurable"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Hash of the versions of what the response shows"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Last change of what the response shows"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified"
+                    },
+                    "404": {
+                        "description": "Product or coupon not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "406": {
+                        "description": "Not acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "409": {
+                        "description": "Coupon expired or exhausted",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/reservation": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Hold units of a SKU until the reservation is confirmed, released or expires",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Reserve stock",
+                "parameters": [
+                    {
+                        "description": "Reservation details",
+                        "name": "reservation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/inventory.ReservationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/inventory.ReservationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "409": {
+                        "description": "Not enough stock",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/reservation/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cancel a reservation, returning its units to the stock",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Release a reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/inventory.ReservationResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "Reservation not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/reservation/{id}/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Turn the reserved units into a sale",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Confirm a reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/inventory.ReservationResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "Reservation not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "409": {
+                        "description": "Reservation expired",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhook": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Register a URL getting the catalog changes as POST requests signed with the secret of the webhook,\nonly shown in this response. The X-Webhook-Signature header is \"sha256=\" and the hex HMAC-SHA256\nof the X-Webhook-Timestamp header, a dot and the body. Deliveries answered without a 2xx are retried.\nEvents are sent in the order they were published. URLs on loopback, link-local or private addresses are refused.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Register a webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook details",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhook.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhook/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stop sending events to a webhook, its delivery log is kept",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong webhook ID",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhook/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every attempt to send an event to a webhook, oldest first, with the answer of the receiver",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List the deliveries of a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhook.Delivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Wrong webhook ID",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the registered webhooks, without their secrets",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List the webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhook.WebhookResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing permission",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.ApiError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "apierror.ApiError": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "audit.Action": {
+            "type": "string",
+            "enum": [
+                "create",
+                "update",
+                "delete"
+            ],
+            "x-enum-varnames": [
+                "Create",
+                "Update",
+                "Delete"
+            ]
+        },
+        "audit.Entity": {
+            "type": "string",
+            "enum": [
+                "product",
+                "category",
+                "discount",
+                "discount_type"
+            ],
+            "x-enum-varnames": [
+                "Product",
+                "Category",
+                "Discount",
+                "DiscountType"
+            ]
+        },
+        "audit.EntryResponse": {
+            "description": "EntryResponse tells who changed an entity and its snapshots before and after the change",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/audit.Action"
+                        }
+                    ],
+                    "example": "create"
+                },
+                "actor": {
+                    "type": "string",
+                    "example": "ci"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2030-01-01T00:15:00Z"
+                },
+                "entity": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/audit.Entity"
+                        }
+                    ],
+                    "example": "product"
+                },
+                "entity_id": {
+                    "type": "string",
+                    "example": "000003"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "12"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "5b1f6c1e-3c8e-4a47-9a8e-1c2d3e4f5a6b"
+                }
+            }
+        },
+        "cart.CartLineRequest": {
+            "type": "object",
+            "properties": {
+                "quantity": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "sku": {
+                    "type": "string",
+                    "example": "000001"
+                }
+            }
+        },
+        "cart.CartLineResponse": {
+            "type": "object",
+            "properties": {
+                "line_total": {
+                    "type": "integer",
+                    "example": 124600
+                },
+                "name": {
+                    "type": "string",
+                    "example": "BV Lean leather ankle boots"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "sku": {
+                    "type": "string",
+                    "example": "000001"
+                },
+                "unit_price": {
+                    "$ref": "#/definitions/product.PriceResponse"
+                }
+            }
+        },
+        "cart.CartPriceResponse": {
+            "description": "CartPriceResponse includes the price of every line plus the basket level promotions applied to the cart",
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "EUR"
+                },
+                "discount": {
+                    "type": "integer",
+                    "example": 17800
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cart.CartLineResponse"
+                    }
+                },
+                "promotions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cart.PromotionResponse"
+                    }
+                },
+                "subtotal": {
+                    "type": "integer",
+                    "example": 178000
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 160200
+                }
+            }
+        },
+        "cart.CartRequest": {
+            "description": "CartRequest is the list of products, and their quantities, to be priced together",
+            "type": "object",
+            "properties": {
+                "coupon": {
+                    "type": "string",
+                    "example": "WELCOME20"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cart.CartLineRequest"
+                    }
+                }
+            }
+        },
+        "cart.PromotionResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "example": 17800
+                },
+                "discount_id": {
+                    "type": "string",
+                    "example": "5"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "spend_threshold"
+                }
+            }
+        },
+        "catalog.ImportResult": {
+            "description": "ImportResult counts the rows of the file and lists the ones that failed",
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "dry_run": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.RowError"
+                    }
+                },
+                "failed": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "imported": {
+                    "type": "integer",
+                    "example": 9
+                },
+                "kind": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/catalog.Kind"
+                        }
+                    ],
+                    "example": "products"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "valid": {
+                    "type": "integer",
+                    "example": 9
+                }
+            }
+        },
+        "catalog.Kind": {
+            "type": "string",
+            "enum": [
+                "products",
+                "categories",
+                "discounts"
+            ],
+            "x-enum-varnames": [
+                "Products",
+                "Categories",
+                "Discounts"
+            ]
+        },
+        "catalog.RowError": {
+            "description": "RowError tells the line of the file that failed and why",
+            "type": "object",
+            "properties": {
+                "line": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "message": {
+                    "type": "string",
+                    "example": "SKU is required"
+                }
+            }
+        },
+        "category.Category": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "coupon.CouponRequest": {
+            "description": "CouponRequest is the input for creating a new coupon. A per customer limit of 0 means no limit",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "WINTER10"
+                },
+                "discount_id": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "expires_at": {
+                    "type": "string",
+                    "example": "2030-01-01T00:00:00Z"
+                },
+                "max_redemptions": {
+                    "type": "integer",
+                    "example": 100
+                },
+                "per_customer_limit": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "coupon.CouponResponse": {
+            "description": "CouponResponse is the response structure for coupons",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "WINTER10"
+                },
+                "discount_id": {
+                    "type": "string",
+                    "example": "4"
+                },
+                "expires_at": {
+                    "type": "string",
+                    "example": "2030-01-01T00:00:00Z"
+                },
+                "max_redemptions": {
+                    "type": "integer",
+                    "example": 100
+                },
+                "per_customer_limit": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "remaining_redemptions": {
+                    "type": "integer",
+                    "example": 99
+                }
+            }
+        },
+        "coupon.RedemptionRequest": {
+            "description": "RedemptionRequest identifies the customer redeeming a coupon",
             "type": "object",
             "properties": {
-                "message": {
-                    "type": "string"
+                "customer_id": {
+                    "type": "string",
+                    "example": "customer-1"
                 }
             }
         },
@@ -228,10 +1872,30 @@ const docTemplate = `{
             "description": "DiscountRequest is the input for creating a new discount",
             "type": "object",
             "properties": {
+                "bundle_price": {
+                    "type": "integer",
+                    "example": 120000
+                },
+                "buy_quantity": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "coupon_only": {
+                    "type": "boolean",
+                    "example": false
+                },
                 "discount_type_id": {
                     "type": "integer",
                     "example": 1
                 },
+                "free_quantity": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "min_spend": {
+                    "type": "integer",
+                    "example": 50000
+                },
                 "percentage": {
                     "type": "integer",
                     "example": 10
@@ -246,13 +1910,33 @@ const docTemplate = `{
             "description": "DiscountResponse is the response structure when fetching discounts",
             "type": "object",
             "properties": {
+                "bundle_price": {
+                    "type": "integer",
+                    "example": 120000
+                },
+                "buy_quantity": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "coupon_only": {
+                    "type": "boolean",
+                    "example": false
+                },
                 "discount_type": {
                     "$ref": "#/definitions/discount.DiscountType"
                 },
+                "free_quantity": {
+                    "type": "integer",
+                    "example": 1
+                },
                 "id": {
                     "type": "string",
                     "example": "1"
                 },
+                "min_spend": {
+                    "type": "integer",
+                    "example": 50000
+                },
                 "percentage": {
                     "type": "integer",
                     "example": 10
@@ -280,6 +1964,18 @@ const docTemplate = `{
             "description": "GeneralDiscount defines the fields for a general discount, including percentage and target",
             "type": "object",
             "properties": {
+                "bundle_price": {
+                    "type": "integer",
+                    "example": 120000
+                },
+                "buy_quantity": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "coupon_only": {
+                    "type": "boolean",
+                    "example": false
+                },
                 "discount_type": {
                     "$ref": "#/definitions/discount.DiscountType"
                 },
@@ -287,10 +1983,18 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 1
                 },
+                "free_quantity": {
+                    "type": "integer",
+                    "example": 1
+                },
                 "id": {
                     "type": "integer",
                     "example": 1
                 },
+                "min_spend": {
+                    "type": "integer",
+                    "example": 50000
+                },
                 "percentage": {
                     "type": "integer",
                     "example": 10
@@ -298,11 +2002,154 @@ const docTemplate = `{
                 "target": {
                     "type": "string",
                     "example": "boots"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "events.Event": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "42"
+                },
+                "idempotency_key": {
+                    "type": "string",
+                    "example": "9b2f6c1e-3a4d-4f5e-8a7b-6c5d4e3f2a1b"
+                },
+                "occurred_at": {
+                    "type": "string",
+                    "example": "2025-01-02T15:04:05Z"
+                },
+                "request_id": {
+                    "description": "RequestID is the request making the change, empty for the ones made in the background",
+                    "type": "string",
+                    "example": "0f8fad5b-d9cb-469f-a165-70867728950e"
+                },
+                "type": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/events.Type"
+                        }
+                    ],
+                    "example": "price.changed"
+                }
+            }
+        },
+        "events.Type": {
+            "type": "string",
+            "enum": [
+                "product.created",
+                "product.updated",
+                "discount.created",
+                "price.changed"
+            ],
+            "x-enum-varnames": [
+                "ProductCreated",
+                "ProductUpdated",
+                "DiscountCreated",
+                "PriceChanged"
+            ]
+        },
+        "graphql.Request": {
+            "type": "object",
+            "properties": {
+                "operationName": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "{ products(limit: 2) { items { sku name price { final } category { name } } } }"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": true
+                }
+            }
+        },
+        "inventory.AdjustStockRequest": {
+            "description": "AdjustStockRequest adds (positive delta) or removes (negative delta) units from the stock",
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        },
+        "inventory.ReservationRequest": {
+            "description": "ReservationRequest is the input for reserving units of a SKU. TTL defaults to 15 minutes",
+            "type": "object",
+            "properties": {
+                "quantity": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "sku": {
+                    "type": "string",
+                    "example": "000005-42-WHT"
+                },
+                "ttl_seconds": {
+                    "type": "integer",
+                    "example": 900
+                }
+            }
+        },
+        "inventory.ReservationResponse": {
+            "description": "ReservationResponse is the output when reserving stock",
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string",
+                    "example": "2030-01-01T00:15:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "5b1f6c1e-3c8e-4a47-9a8e-1c2d3e4f5a6b"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "sku": {
+                    "type": "string",
+                    "example": "000005-42-WHT"
+                }
+            }
+        },
+        "inventory.StockResponse": {
+            "description": "StockResponse includes the units available for sale and the ones held by reservations",
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "in_stock": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "reserved": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "sku": {
+                    "type": "string",
+                    "example": "000005-42-WHT"
                 }
             }
         },
         "product.PriceResponse": {
-            "description": "PriceResponse includes the original and final price of a product, along with any discounts",
+            "description": "PriceResponse includes the original and final price of a product, along with any discounts. LowestPrice30d is the lowest final price of the SKU in the 30 days before its current one, as required when showing a discount.",
             "type": "object",
             "properties": {
                 "currency": {
@@ -317,12 +2164,49 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 8000
                 },
+                "lowest_price_30d": {
+                    "type": "integer",
+                    "example": 8000
+                },
                 "original": {
                     "type": "integer",
                     "example": 10000
                 }
             }
         },
+        "product.Product": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "$ref": "#/definitions/category.Category"
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/product.Variant"
+                    }
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
         "product.ProductRequest": {
             "description": "ProductRequest is the input for creating a new product",
             "type": "object",
@@ -342,6 +2226,16 @@ const docTemplate = `{
                 "sku": {
                     "type": "string",
                     "example": "000005"
+                },
+                "stock": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/product.VariantRequest"
+                    }
                 }
             }
         },
@@ -353,19 +2247,231 @@ const docTemplate = `{
                     "type": "string",
                     "example": "Boots"
                 },
+                "colour": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string",
                     "example": "Legendary boots"
                 },
+                "parent_sku": {
+                    "description": "ParentSKU, Size and Colour are only set for a variant given as a product, see ForSKU",
+                    "type": "string"
+                },
                 "price": {
                     "$ref": "#/definitions/product.PriceResponse"
                 },
+                "size": {
+                    "type": "string"
+                },
                 "sku": {
                     "type": "string",
                     "example": "000005"
+                },
+                "stock": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/product.VariantResponse"
+                    }
+                }
+            }
+        },
+        "product.Variant": {
+            "type": "object",
+            "properties": {
+                "colour": {
+                    "type": "string"
+                },
+                "parent_sku": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "string"
+                },
+                "sku": {
+                    "type": "string"
+                }
+            }
+        },
+        "product.VariantRequest": {
+            "description": "VariantRequest is the input for a product variant. Price is optional and overrides the parent price",
+            "type": "object",
+            "properties": {
+                "colour": {
+                    "type": "string",
+                    "example": "black"
+                },
+                "price": {
+                    "type": "integer",
+                    "example": 12000
+                },
+                "size": {
+                    "type": "string",
+                    "example": "42"
+                },
+                "sku": {
+                    "type": "string",
+                    "example": "000005-42-BLK"
+                },
+                "stock": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "product.VariantResponse": {
+            "description": "VariantResponse is the output for every variant nested in a product",
+            "type": "object",
+            "properties": {
+                "colour": {
+                    "type": "string",
+                    "example": "black"
+                },
+                "price": {
+                    "$ref": "#/definitions/product.PriceResponse"
+                },
+                "size": {
+                    "type": "string",
+                    "example": "42"
+                },
+                "sku": {
+                    "type": "string",
+                    "example": "000005-42-BLK"
+                },
+                "stock": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "webhook.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-01-02T15:04:05Z"
+                },
+                "delivered": {
+                    "description": "Delivered is set when the receiver answered with a 2xx",
+                    "type": "boolean",
+                    "example": true
+                },
+                "duration_ms": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "error": {
+                    "type": "string",
+                    "example": "context deadline exceeded"
+                },
+                "event_id": {
+                    "type": "string",
+                    "example": "42"
+                },
+                "event_type": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/events.Type"
+                        }
+                    ],
+                    "example": "price.changed"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "status_code": {
+                    "description": "StatusCode is the answer of the receiver, 0 when there was none",
+                    "type": "integer",
+                    "example": 200
+                },
+                "webhook_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "webhook.WebhookRequest": {
+            "description": "WebhookRequest registers a URL getting the events of the types, every type when none is given. A secret signing the payloads is generated when none is given.",
+            "type": "object",
+            "properties": {
+                "secret": {
+                    "type": "string",
+                    "example": "2f1c0b8e9a7d4c3b2a1f0e9d8c7b6a59"
+                },
+                "types": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/events.Type"
+                    },
+                    "example": [
+                        "product.created",
+                        "price.changed"
+                    ]
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://search.example.com/hooks/catalog"
+                }
+            }
+        },
+        "webhook.WebhookResponse": {
+            "description": "WebhookResponse is a registered webhook, its secret only shown when created",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-01-02T15:04:05Z"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "2f1c0b8e9a7d4c3b2a1f0e9d8c7b6a59"
+                },
+                "types": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/events.Type"
+                    },
+                    "example": [
+                        "product.created",
+                        "price.changed"
+                    ]
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://search.example.com/hooks/catalog"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key granting roles, configured with AUTH_API_KEYS",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT signed with HS256 or RS256 sent as \"Bearer \u003ctoken\u003e\", with its roles in the roles claim",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
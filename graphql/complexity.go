@@ -0,0 +1,151 @@
+package graphql
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listSize is how many items are expected in the lists without a limit, like the variants of a product
+const listSize = 10
+
+// cost is what a query takes to resolve: every field resolved counts as one, its selections
+// counting once per item of the lists. Fields with a limit argument, like products, bound the
+// lists under them.
+type cost struct {
+	schema       gql.Schema
+	fragments    map[string]*ast.FragmentDefinition
+	variables    map[string]interface{}
+	defaultLimit int
+}
+
+// measure returns the complexity and depth of the operation run out of the document, which
+// must be valid against the schema
+func measure(schema gql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}, defaultLimit int) (complexity, depth int) {
+	c := cost{schema: schema, fragments: map[string]*ast.FragmentDefinition{}, variables: variables, defaultLimit: defaultLimit}
+
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			c.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operation == nil && (operationName == "" || def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return 0, 0
+	}
+
+	return c.selections(operation.SelectionSet, schema.QueryType(), listSize, map[string]bool{})
+}
+
+// selections returns the cost and depth of a selection set on parent, where lists hold size items
+func (c cost) selections(set *ast.SelectionSet, parent *gql.Object, size int, spread map[string]bool) (complexity, depth int) {
+	if set == nil || parent == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var fieldCost, fieldDepth int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			fieldCost, fieldDepth = c.field(selection, parent, size, spread)
+		case *ast.InlineFragment:
+			fieldCost, fieldDepth = c.selections(selection.SelectionSet, parent, size, spread)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			// fragments can't spread themselves, but the query is only checked when run
+			if spread[name] || c.fragments[name] == nil {
+				continue
+			}
+			spread[name] = true
+			fieldCost, fieldDepth = c.selections(c.fragments[name].SelectionSet, parent, size, spread)
+			delete(spread, name)
+		}
+		complexity = add(complexity, fieldCost)
+		depth = max(depth, fieldDepth)
+	}
+	return complexity, depth
+}
+
+func (c cost) field(field *ast.Field, parent *gql.Object, size int, spread map[string]bool) (complexity, depth int) {
+	// introspection is left out, it doesn't reach the services
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return 0, 0
+	}
+	def, ok := parent.Fields()[field.Name.Value]
+	if !ok {
+		return 0, 0
+	}
+
+	items := 1
+	if isList(def.Type) {
+		items = size
+	}
+	childSize := listSize
+	if limit, ok := c.limit(field, def); ok {
+		childSize = limit
+	}
+
+	object, _ := gql.GetNamed(def.Type).(*gql.Object)
+	childCost, childDepth := c.selections(field.SelectionSet, object, childSize, spread)
+	return mul(items, add(1, childCost)), childDepth + 1
+}
+
+// limit returns the limit argument of the field, the default page limit when it has one but
+// it's not set
+func (c cost) limit(field *ast.Field, def *gql.FieldDefinition) (int, bool) {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		var limit int
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			limit, _ = strconv.Atoi(value.Value)
+		case *ast.Variable:
+			switch v := c.variables[value.Name.Value].(type) {
+			case float64:
+				limit = int(min(v, math.MaxInt32))
+			case int:
+				limit = v
+			}
+		}
+		if limit > 0 {
+			return limit, true
+		}
+		return c.defaultLimit, true
+	}
+	for _, arg := range def.Args {
+		if arg.Name() == "limit" {
+			return c.defaultLimit, true
+		}
+	}
+	return 0, false
+}
+
+func isList(t gql.Type) bool {
+	if nonNull, ok := t.(*gql.NonNull); ok {
+		t = nonNull.OfType
+	}
+	_, ok := t.(*gql.List)
+	return ok
+}
+
+// add and mul stop at math.MaxInt32, far over any limit, instead of overflowing
+func add(a, b int) int {
+	return min(a+b, math.MaxInt32)
+}
+
+func mul(a, b int) int {
+	if a != 0 && b > math.MaxInt32/a {
+		return math.MaxInt32
+	}
+	return a * b
+}
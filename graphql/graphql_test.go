@@ -0,0 +1,188 @@
+package graphql_test
+
+import (
+	"encoding/json"
+	"errors"
+	"mytheresa/graphql"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/config"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/category"
+	categorymocks "mytheresa/pkg/category/mocks"
+	"mytheresa/pkg/discount"
+	discountmocks "mytheresa/pkg/discount/mocks"
+	"mytheresa/pkg/product"
+	productmocks "mytheresa/pkg/product/mocks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type services struct {
+	products   *productmocks.Service
+	categories *categorymocks.Service
+	discounts  *discountmocks.Service
+}
+
+func newHandler(conf config.GraphQLConfig) (graphql.Handler, services) {
+	s := services{&productmocks.Service{}, &categorymocks.Service{}, &discountmocks.Service{}}
+	h := graphql.NewHandler(s.products, s.categories, s.discounts, &loggermocks.NoopLogger{}, conf, 2)
+	return h, s
+}
+
+func post(t *testing.T, h graphql.Handler, body string) (int, map[string]interface{}) {
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	return serve(t, h, r)
+}
+
+func serve(t *testing.T, h graphql.Handler, r *http.Request) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	h.Query(w, r)
+
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return w.Code, res
+}
+
+func boots(sku string, categoryID int, discountID string) product.ProductResponse {
+	return product.ProductResponse{
+		SKU:        sku,
+		Name:       "BV Lean leather ankle boots",
+		CategoryID: categoryID,
+		Price:      product.PriceResponse{Original: 89000, Final: 62300, Currency: "EUR", DiscountID: discountID},
+	}
+}
+
+func TestQuery_BatchesLookups(t *testing.T) {
+	h, s := newHandler(config.Default().GraphQL)
+	s.products.On("ListProducts", mock.Anything, mock.Anything).Return([]product.ProductResponse{
+		boots("000001", 1, "1"), boots("000002", 1, "1"), boots("000004", 2, "2"),
+	}, nil)
+	s.categories.On("GetCategories", mock.Anything, []int{1, 2}).Return([]category.Category{
+		{ID: 1, Name: "boots"}, {ID: 2, Name: "sandals"},
+	}, nil)
+	s.discounts.On("GetDiscounts", mock.Anything).Return([]discount.Discount{
+		discount.NewDiscount(discount.GeneralDiscount{ID: 1, DiscountTypeID: discount.CATEGORY, Target: "1", Percentage: 30}),
+		discount.NewDiscount(discount.GeneralDiscount{ID: 2, DiscountTypeID: discount.SKU, Target: "000004", Percentage: 15}),
+	}, nil)
+
+	code, res := post(t, h, `{"query": "{ products(limit: 5) { total items { sku category { name } price { final discount { id type } } } } }"}`)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, res["errors"])
+	products := res["data"].(map[string]interface{})["products"].(map[string]interface{})
+	assert.Equal(t, float64(3), products["total"])
+	items := products["items"].([]interface{})
+	require.Len(t, items, 3)
+	assert.Equal(t, map[string]interface{}{
+		"sku":      "000004",
+		"category": map[string]interface{}{"name": "sandals"},
+		"price": map[string]interface{}{
+			"final":    float64(62300),
+			"discount": map[string]interface{}{"id": "2", "type": "sku"},
+		},
+	}, items[2])
+	s.categories.AssertNumberOfCalls(t, "GetCategories", 1)
+	s.discounts.AssertNumberOfCalls(t, "GetDiscounts", 1)
+}
+
+func TestQuery_DefaultLimit(t *testing.T) {
+	h, s := newHandler(config.Default().GraphQL)
+	s.products.On("ListProducts", mock.Anything, mock.Anything).Return([]product.ProductResponse{
+		boots("000001", 1, ""), boots("000002", 1, ""),
+	}, nil)
+	s.products.On("CountProducts", mock.Anything, mock.Anything).Return(3, nil)
+
+	code, res := post(t, h, `{"query": "{ products(inStock: true) { limit total items { sku } } }"}`)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"products": map[string]interface{}{
+		"limit": float64(2),
+		"total": float64(3),
+		"items": []interface{}{map[string]interface{}{"sku": "000001"}, map[string]interface{}{"sku": "000002"}},
+	}}, res["data"])
+	s.products.AssertCalled(t, "ListProducts", mock.Anything, product.ListOptions{InStock: true, Limit: 2})
+	s.products.AssertCalled(t, "CountProducts", mock.Anything, product.ListOptions{InStock: true, Limit: 2})
+}
+
+func TestQuery_CouponDiscount(t *testing.T) {
+	h, s := newHandler(config.Default().GraphQL)
+	s.products.On("ListProducts", mock.Anything, mock.MatchedBy(func(opts product.ListOptions) bool {
+		return opts.CouponCode == "WELCOME20" && len(opts.Filters) == 1
+	})).Return([]product.ProductResponse{boots("000005", 3, "7")}, nil)
+	s.discounts.On("GetDiscounts", mock.Anything).Return([]discount.Discount{
+		discount.NewDiscount(discount.GeneralDiscount{ID: 7, DiscountTypeID: discount.GENERAL, Percentage: 20, CouponOnly: true}),
+	}, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/graphql?"+url.Values{
+		"query":     {`query ($coupon: String) { product(sku: "000005", coupon: $coupon) { sku price { discount { id couponOnly percentage } } } }`},
+		"variables": {`{"coupon": "WELCOME20"}`},
+	}.Encode(), nil)
+	code, res := serve(t, h, r)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"product": map[string]interface{}{
+		"sku": "000005",
+		"price": map[string]interface{}{
+			"discount": map[string]interface{}{"id": "7", "couponOnly": true, "percentage": float64(20)},
+		},
+	}}, res["data"])
+}
+
+func TestQuery_Errors(t *testing.T) {
+	h, s := newHandler(config.Default().GraphQL)
+	s.categories.On("GetCategories", mock.Anything, []int(nil)).Return([]category.Category(nil), errors.New("database is locked"))
+	s.discounts.On("GetDiscounts", mock.Anything).Return([]discount.Discount(nil), apierror.InternalServerError("there was an error getting the discounts"))
+
+	for query, message := range map[string]string{
+		"{ categories { name } }":              "Internal Server Error",
+		"{ discounts { id } }":                 "there was an error getting the discounts",
+		`{ category(id: \"boots\") { name } }`: "Invalid category ID",
+	} {
+		code, res := post(t, h, `{"query": "`+query+`"}`)
+
+		assert.Equal(t, http.StatusOK, code)
+		errs := res["errors"].([]interface{})
+		require.Len(t, errs, 1)
+		assert.Equal(t, message, errs[0].(map[string]interface{})["message"])
+	}
+}
+
+func TestQuery_Rejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		error string
+	}{
+		{"no query", `{}`, "query is required"},
+		{"wrong body", `{"query":`, "wrong body"},
+		{"syntax error", `{"query": "{ products {"}`, `Syntax Error GraphQL request (1:13) Expected Name, found EOF`},
+		{"unknown field", `{"query": "{ product(sku: \"1\") { price { tax } } }"}`, `Cannot query field "tax" on type "Price".`},
+		{"too deep", `{"query": "{ products { items { variants { price { discount { id } } } } } }"}`, "query depth 6 is over the limit of 5"},
+		// 2 products with 10 variants each, one for the items and every variant and 4 per variant price
+		{"too complex", `{"query": "{ products(limit: 2) { items { variants { price { final original } } } } }"}`, "query complexity 83 is over the limit of 80"},
+		{"too complex with variables", `{"query": "query ($n: Int) { products(limit: $n) { items { sku } } }", "variables": {"n": 100}}`, "query complexity 201 is over the limit of 80"},
+		{"too complex with fragments", `{"query": "{ ...q } fragment q on Query { products(limit: 40) { items { ...p } } } fragment p on Product { sku }"}`, "query complexity 81 is over the limit of 80"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, s := newHandler(config.GraphQLConfig{MaxDepth: 5, MaxComplexity: 80})
+
+			code, res := post(t, h, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, code)
+			assert.NotContains(t, res, "data")
+			errs := res["errors"].([]interface{})
+			require.Len(t, errs, 1)
+			assert.Contains(t, errs[0].(map[string]interface{})["message"], tt.error)
+			s.products.AssertNotCalled(t, "ListProducts", mock.Anything, mock.Anything)
+		})
+	}
+}
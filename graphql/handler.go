@@ -0,0 +1,200 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mytheresa/internal/config"
+	"mytheresa/internal/logger"
+	"mytheresa/pkg/category"
+	"mytheresa/pkg/discount"
+	"mytheresa/pkg/product"
+	"net/http"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type Handler interface {
+	Query(w http.ResponseWriter, r *http.Request)
+}
+
+type handler struct {
+	productService product.Service
+	categories     category.Service
+	discounts      discount.Service
+	logger         logger.Logger
+	conf           config.GraphQLConfig
+	// defaultLimit is how many products are listed when the query sets no limit
+	defaultLimit int
+	schema       gql.Schema
+}
+
+func NewHandler(ps product.Service, cs category.Service, ds discount.Service, l logger.Logger, conf config.GraphQLConfig, defaultLimit int) Handler {
+	h := &handler{
+		productService: ps,
+		categories:     cs,
+		discounts:      ds,
+		logger:         l,
+		conf:           conf,
+		defaultLimit:   defaultLimit,
+	}
+	schema, err := newSchema(h)
+	if err != nil {
+		// the schema is fixed, it can only fail when it's written wrong
+		panic(fmt.Sprintf("invalid GraphQL schema: %v", err))
+	}
+	h.schema = schema
+	return h
+}
+
+// Request is a GraphQL query, sent as the body of a POST or in the query string of a GET, the
+// variables being JSON encoded there
+type Request struct {
+	Query         string                 `json:"query" example:"{ products(limit: 2) { items { sku name price { final } category { name } } } }"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Query godoc
+// @Summary Query the catalog with GraphQL
+// @Description Products, categories and discounts as GraphQL, with the query depth and complexity limited.
+// @Description Queries are also accepted with GET, in the query, operationName and variables parameters.
+// @Accept  json
+// @Produce  json
+// @Param request body Request true "GraphQL query"
+// @Success 200 {object} map[string]interface{} "Data along with the errors of the fields that failed"
+// @Failure 400 {object} map[string]interface{} "Invalid query or over the limits"
+// @Failure 413 {object} apierror.ApiError "Body too large"
+// @Failure 429 {object} apierror.ApiError "Too many requests"
+// @Router /graphql [post]
+func (h *handler) Query(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeRequest(r)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error decoding GraphQL request")
+		rejected(w, []queryMessage{{Message: err.Error()}})
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		rejected(w, gqlerrors.FormatErrors(err))
+		return
+	}
+	if validation := gql.ValidateDocument(&h.schema, doc, nil); !validation.IsValid {
+		rejected(w, validation.Errors)
+		return
+	}
+
+	complexity, depth := measure(h.schema, doc, req.OperationName, req.Variables, h.defaultLimit)
+	if depth > h.conf.MaxDepth || complexity > h.conf.MaxComplexity {
+		h.logger.WithField("depth", depth).WithField("complexity", complexity).
+			Info(ctx, "GraphQL query over the limits")
+		message := fmt.Sprintf("query depth %d is over the limit of %d", depth, h.conf.MaxDepth)
+		if depth <= h.conf.MaxDepth {
+			message = fmt.Sprintf("query complexity %d is over the limit of %d", complexity, h.conf.MaxComplexity)
+		}
+		rejected(w, []queryMessage{{Message: message}})
+		return
+	}
+
+	result := gql.Execute(gql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(ctx, h.newLoaders()),
+	})
+	if result.HasErrors() {
+		h.logger.WithField("errors", len(result.Errors)).Info(ctx, "GraphQL query resolved with errors")
+	}
+	respond(w, http.StatusOK, result)
+}
+
+// queryMessage is an error of a request rejected before running it, with no place in the query
+type queryMessage struct {
+	Message string `json:"message"`
+}
+
+func decodeRequest(r *http.Request) (Request, error) {
+	var req Request
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if variables := q.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, fmt.Errorf("variables must be a JSON object")
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, fmt.Errorf("wrong body")
+	}
+
+	if req.Query == "" {
+		return req, fmt.Errorf("query is required")
+	}
+	return req, nil
+}
+
+// rejected answers a request that wasn't run with its errors, and no data
+func rejected[E queryMessage | gqlerrors.FormattedError](w http.ResponseWriter, errs []E) {
+	respond(w, http.StatusBadRequest, map[string][]E{"errors": errs})
+}
+
+// respond writes the body as GraphQL responses are, whatever the Accept header of the request
+func respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// loaders batch the category and discount lookups of a request
+type loaders struct {
+	categories *loader[int, category.Category]
+	discounts  *loader[string, discount.Discount]
+}
+
+func (h *handler) newLoaders() *loaders {
+	l := &loaders{}
+	l.categories = newLoader(func(ctx context.Context, ids []int) (map[int]category.Category, error) {
+		categories, err := h.categories.GetCategories(ctx, ids)
+		if err != nil {
+			return nil, queryError(err)
+		}
+		found := make(map[int]category.Category, len(categories))
+		for _, c := range categories {
+			found[c.ID] = c
+		}
+		return found, nil
+	})
+	l.discounts = newLoader(func(ctx context.Context, ids []string) (map[string]discount.Discount, error) {
+		discounts, err := h.discounts.GetDiscounts(ctx)
+		if err != nil {
+			return nil, queryError(err)
+		}
+		found := make(map[string]discount.Discount, len(discounts))
+		for _, d := range discounts {
+			found[d.ToDiscountResponse().ID] = d
+		}
+		return found, nil
+	})
+	return l
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,72 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// loader batches the lookups made while resolving a level of a query, as DataLoader does.
+// Resolvers queue their keys with load and get back a thunk, which the executor only calls once
+// every field of the level is resolved, so the first thunk called fetches all the keys queued
+// at once. Results are kept for the rest of the request.
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	queued  []K
+	pending map[K]bool
+	fetched map[K]bool
+	results map[K]V
+	errs    map[K]error
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		fetch:   fetch,
+		pending: map[K]bool{},
+		fetched: map[K]bool{},
+		results: map[K]V{},
+		errs:    map[K]error{},
+	}
+}
+
+// load queues the key and returns the thunk resolving to its value, nil when not found
+func (l *loader[K, V]) load(ctx context.Context, key K) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.fetched[key] && !l.pending[key] {
+		l.queued = append(l.queued, key)
+		l.pending[key] = true
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		v, ok, err := l.get(ctx, key)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+func (l *loader[K, V]) get(ctx context.Context, key K) (V, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.queued) > 0 {
+		keys := l.queued
+		l.queued = nil
+		found, err := l.fetch(ctx, keys)
+		for _, k := range keys {
+			delete(l.pending, k)
+			l.fetched[k] = true
+			if err != nil {
+				l.errs[k] = err
+			} else if v, ok := found[k]; ok {
+				l.results[k] = v
+			}
+		}
+	}
+
+	v, ok := l.results[key]
+	return v, ok, l.errs[key]
+}
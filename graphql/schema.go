@@ -0,0 +1,315 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/pkg/category"
+	"mytheresa/pkg/discount"
+	"mytheresa/pkg/product"
+	"net/http"
+	"strconv"
+
+	gql "github.com/graphql-go/graphql"
+)
+
+var categoryType = gql.NewObject(gql.ObjectConfig{
+	Name: "Category",
+	Fields: gql.Fields{
+		"id": &gql.Field{Type: gql.NewNonNull(gql.ID), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return strconv.Itoa(p.Source.(category.Category).ID), nil
+		}},
+		"name": &gql.Field{Type: gql.NewNonNull(gql.String), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(category.Category).Name, nil
+		}},
+		"version": &gql.Field{Type: gql.NewNonNull(gql.Int), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(category.Category).Version, nil
+		}},
+		"updatedAt": &gql.Field{Type: gql.DateTime, Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(category.Category).UpdatedAt, nil
+		}},
+	},
+})
+
+var discountType = gql.NewObject(gql.ObjectConfig{
+	Name:        "Discount",
+	Description: "A discount, with the fields of basket level promotions left at 0 for the rest",
+	Fields: gql.Fields{
+		"id": discountField(gql.NewNonNull(gql.ID), func(d discount.DiscountResponse) interface{} { return d.ID }),
+		"type": &gql.Field{Type: gql.NewNonNull(gql.String), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return discount.Kind(p.Source.(discount.Discount)), nil
+		}},
+		"target":       discountField(gql.NewNonNull(gql.String), func(d discount.DiscountResponse) interface{} { return d.Target }),
+		"percentage":   discountField(gql.NewNonNull(gql.Int), func(d discount.DiscountResponse) interface{} { return d.Percentage }),
+		"couponOnly":   discountField(gql.NewNonNull(gql.Boolean), func(d discount.DiscountResponse) interface{} { return d.CouponOnly }),
+		"buyQuantity":  discountField(gql.NewNonNull(gql.Int), func(d discount.DiscountResponse) interface{} { return d.BuyQuantity }),
+		"freeQuantity": discountField(gql.NewNonNull(gql.Int), func(d discount.DiscountResponse) interface{} { return d.FreeQuantity }),
+		"minSpend":     discountField(gql.NewNonNull(gql.Int), func(d discount.DiscountResponse) interface{} { return d.MinSpend }),
+		"bundlePrice":  discountField(gql.NewNonNull(gql.Int), func(d discount.DiscountResponse) interface{} { return d.BundlePrice }),
+		"updatedAt": &gql.Field{Type: gql.DateTime, Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(discount.Discount).LastModified(), nil
+		}},
+	},
+})
+
+func discountField(t gql.Output, value func(d discount.DiscountResponse) interface{}) *gql.Field {
+	return &gql.Field{Type: t, Resolve: func(p gql.ResolveParams) (interface{}, error) {
+		return value(p.Source.(discount.Discount).ToDiscountResponse()), nil
+	}}
+}
+
+var priceType = gql.NewObject(gql.ObjectConfig{
+	Name:        "Price",
+	Description: "Original and final price, in cents, along with the discount giving the final one",
+	Fields: gql.Fields{
+		"original": &gql.Field{Type: gql.NewNonNull(gql.Int), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.PriceResponse).Original, nil
+		}},
+		"final": &gql.Field{Type: gql.NewNonNull(gql.Int), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.PriceResponse).Final, nil
+		}},
+		"currency": &gql.Field{Type: gql.NewNonNull(gql.String), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.PriceResponse).Currency, nil
+		}},
+		"discountPercentage": &gql.Field{Type: gql.Int, Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			percentage := p.Source.(product.PriceResponse).DiscountPercentage
+			if percentage == nil {
+				return nil, nil
+			}
+			return strconv.Atoi(*percentage)
+		}},
+		"lowestPrice30d": &gql.Field{
+			Type:        gql.Int,
+			Description: "Lowest final price of the SKU in the 30 days before its current one",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				lowest := p.Source.(product.PriceResponse).LowestPrice30d
+				if lowest == nil {
+					return nil, nil
+				}
+				return *lowest, nil
+			},
+		},
+		"discount": &gql.Field{Type: discountType, Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			id := p.Source.(product.PriceResponse).DiscountID
+			if id == "" {
+				return nil, nil
+			}
+			return loadersFrom(p.Context).discounts.load(p.Context, id), nil
+		}},
+	},
+})
+
+var variantType = gql.NewObject(gql.ObjectConfig{
+	Name: "Variant",
+	Fields: gql.Fields{
+		"sku": &gql.Field{Type: gql.NewNonNull(gql.ID), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.VariantResponse).SKU, nil
+		}},
+		"size": &gql.Field{Type: gql.NewNonNull(gql.String), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.VariantResponse).Size, nil
+		}},
+		"colour": &gql.Field{Type: gql.NewNonNull(gql.String), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.VariantResponse).Colour, nil
+		}},
+		"price": &gql.Field{Type: gql.NewNonNull(priceType), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.VariantResponse).Price, nil
+		}},
+		"stock": &gql.Field{Type: gql.NewNonNull(gql.Int), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.VariantResponse).Stock, nil
+		}},
+	},
+})
+
+var productType = gql.NewObject(gql.ObjectConfig{
+	Name:        "Product",
+	Description: "A product priced with the greater discount applying to it",
+	Fields: gql.Fields{
+		"sku": &gql.Field{Type: gql.NewNonNull(gql.ID), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.ProductResponse).SKU, nil
+		}},
+		"name": &gql.Field{Type: gql.NewNonNull(gql.String), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.ProductResponse).Name, nil
+		}},
+		"category": &gql.Field{Type: categoryType, Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return loadersFrom(p.Context).categories.load(p.Context, p.Source.(product.ProductResponse).CategoryID), nil
+		}},
+		"price": &gql.Field{Type: gql.NewNonNull(priceType), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(product.ProductResponse).Price, nil
+		}},
+		"stock": &gql.Field{
+			Type:        gql.NewNonNull(gql.Int),
+			Description: "Units available of the product and its variants",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return p.Source.(product.ProductResponse).Stock, nil
+			},
+		},
+		"variants": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(variantType))), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			variants := p.Source.(product.ProductResponse).Variants
+			if variants == nil {
+				return []product.VariantResponse{}, nil
+			}
+			return variants, nil
+		}},
+	},
+})
+
+// productPage is a page of the products matching a query
+type productPage struct {
+	items []product.ProductResponse
+	limit int
+	total int
+}
+
+var productPageType = gql.NewObject(gql.ObjectConfig{
+	Name: "ProductPage",
+	Fields: gql.Fields{
+		"items": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(productType))), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(productPage).items, nil
+		}},
+		"limit": &gql.Field{Type: gql.NewNonNull(gql.Int), Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return p.Source.(productPage).limit, nil
+		}},
+		"total": &gql.Field{
+			Type:        gql.NewNonNull(gql.Int),
+			Description: "Products matching, before the limit",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return p.Source.(productPage).total, nil
+			},
+		},
+	},
+})
+
+// newSchema returns the schema of the catalog queries, resolved with the services of the handler
+func newSchema(h *handler) (gql.Schema, error) {
+	query := gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"products": &gql.Field{
+				Type:        gql.NewNonNull(productPageType),
+				Description: "Products matching the filters, as GET /v1/products lists them",
+				Args: gql.FieldConfigArgument{
+					"limit":            &gql.ArgumentConfig{Type: gql.Int, Description: "Products listed, the default page limit when not set"},
+					"categoryId":       &gql.ArgumentConfig{Type: gql.ID},
+					"priceLessThan":    &gql.ArgumentConfig{Type: gql.Int},
+					"priceGreaterThan": &gql.ArgumentConfig{Type: gql.Int},
+					"coupon":           &gql.ArgumentConfig{Type: gql.String, Description: "Coupon code unlocking an additional discount"},
+					"inStock":          &gql.ArgumentConfig{Type: gql.Boolean, Description: "Only list products with units available"},
+				},
+				Resolve: h.products,
+			},
+			"product": &gql.Field{
+				Type: productType,
+				Args: gql.FieldConfigArgument{
+					"sku":    &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)},
+					"coupon": &gql.ArgumentConfig{Type: gql.String},
+				},
+				Resolve: h.product,
+			},
+			"categories": &gql.Field{
+				Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(categoryType))),
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					categories, err := h.categories.GetCategories(p.Context, nil)
+					return categories, queryError(err)
+				},
+			},
+			"category": &gql.Field{
+				Type: categoryType,
+				Args: gql.FieldConfigArgument{
+					"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)},
+				},
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					id, err := strconv.Atoi(fmt.Sprint(p.Args["id"]))
+					if err != nil {
+						return nil, queryError(apierror.BadRequest("Invalid category ID"))
+					}
+					return loadersFrom(p.Context).categories.load(p.Context, id), nil
+				},
+			},
+			"discounts": &gql.Field{
+				Type:        gql.NewNonNull(gql.NewList(gql.NewNonNull(discountType))),
+				Description: "Every discount, coupon only ones included",
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					discounts, err := h.discounts.GetDiscounts(p.Context)
+					return discounts, queryError(err)
+				},
+			},
+		},
+	})
+
+	return gql.NewSchema(gql.SchemaConfig{Query: query})
+}
+
+func (h *handler) products(p gql.ResolveParams) (interface{}, error) {
+	limit := h.defaultLimit
+	if l, ok := p.Args["limit"].(int); ok && l > 0 {
+		limit = l
+	}
+
+	var filters []database.Filter
+	if id, ok := p.Args["categoryId"]; ok {
+		filters = append(filters, product.NewCategoryFilter(fmt.Sprint(id), "="))
+	}
+	if price, ok := p.Args["priceLessThan"].(int); ok {
+		filters = append(filters, product.NewPriceFilter(fmt.Sprint(price), "<="))
+	}
+	if price, ok := p.Args["priceGreaterThan"].(int); ok {
+		filters = append(filters, product.NewPriceFilter(fmt.Sprint(price), ">="))
+	}
+	inStock, _ := p.Args["inStock"].(bool)
+
+	opts := product.ListOptions{Filters: filters, InStock: inStock, Limit: limit}
+	products, err := h.listProducts(p.Context, opts, p.Args["coupon"])
+	if err != nil {
+		return nil, err
+	}
+	// a page not filled holds every product matching, only a full one needs counting them
+	total := len(products)
+	if total == limit {
+		if total, err = h.productService.CountProducts(p.Context, opts); err != nil {
+			return nil, queryError(err)
+		}
+	}
+	return productPage{items: products, limit: limit, total: total}, nil
+}
+
+func (h *handler) product(p gql.ResolveParams) (interface{}, error) {
+	sku := fmt.Sprint(p.Args["sku"])
+	opts := product.ListOptions{Filters: []database.Filter{product.NewSKUFilter([]string{sku})}}
+	products, err := h.listProducts(p.Context, opts, p.Args["coupon"])
+	if err != nil || len(products) == 0 {
+		return nil, err
+	}
+	return products[0], nil
+}
+
+// listProducts prices the products listed with the options, with the discount unlocked by the
+// coupon when given
+func (h *handler) listProducts(ctx context.Context, opts product.ListOptions, coupon interface{}) ([]product.ProductResponse, error) {
+	opts.CouponCode, _ = coupon.(string)
+
+	products, err := h.productService.ListProducts(ctx, opts)
+	return products, queryError(err)
+}
+
+// apiError shows the errors of the services along with their HTTP status, in the extensions of
+// the GraphQL error
+type apiError struct {
+	*apierror.ApiError
+}
+
+func (e apiError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"status": e.Code()}
+}
+
+// queryError hides the errors not meant for the client, as the HTTP API does
+func queryError(err error) error {
+	if err == nil {
+		return nil
+	}
+	apierr, ok := err.(*apierror.ApiError)
+	if !ok {
+		apierr = apierror.InternalServerError(http.StatusText(http.StatusInternalServerError)).(*apierror.ApiError)
+	}
+	return apiError{apierr}
+}
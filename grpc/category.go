@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"context"
+	"mytheresa/pkg/category"
+	catalogv1 "mytheresa/proto/catalog/v1"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type categoryServer struct {
+	catalogv1.UnimplementedCategoryServiceServer
+	service category.Service
+}
+
+func (s *categoryServer) CreateCategory(ctx context.Context, req *catalogv1.CategoryRequest) (*catalogv1.Category, error) {
+	c, err := s.service.CreateCategory(ctx, category.CategoryRequest{Name: req.GetName()})
+	if err != nil {
+		return nil, err
+	}
+	return toCategory(c), nil
+}
+
+func toCategory(c category.Category) *catalogv1.Category {
+	return &catalogv1.Category{
+		Id:        int64(c.ID),
+		Name:      c.Name,
+		Version:   int32(c.Version),
+		UpdatedAt: timestamp(c.UpdatedAt),
+	}
+}
+
+// timestamp leaves unset the times never set, e.g. of the category of a product not loaded
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"context"
+	"mytheresa/pkg/discount"
+	catalogv1 "mytheresa/proto/catalog/v1"
+)
+
+type discountServer struct {
+	catalogv1.UnimplementedDiscountServiceServer
+	service discount.Service
+}
+
+func (s *discountServer) CreateDiscountType(ctx context.Context, req *catalogv1.DiscountTypeRequest) (*catalogv1.DiscountType, error) {
+	dt, err := s.service.CreateDiscountType(ctx, discount.DiscountTypeRequest{Type: req.GetType()})
+	if err != nil {
+		return nil, err
+	}
+	return toDiscountType(dt), nil
+}
+
+func (s *discountServer) CreateDiscount(ctx context.Context, req *catalogv1.DiscountRequest) (*catalogv1.Discount, error) {
+	d, err := s.service.CreateDiscount(ctx, discount.DiscountRequest{
+		Percentage:     int(req.GetPercentage()),
+		DiscountTypeID: int(req.GetDiscountTypeId()),
+		Target:         req.GetTarget(),
+		CouponOnly:     req.GetCouponOnly(),
+		BuyQuantity:    int(req.GetBuyQuantity()),
+		FreeQuantity:   int(req.GetFreeQuantity()),
+		MinSpend:       int(req.GetMinSpend()),
+		BundlePrice:    int(req.GetBundlePrice()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toDiscount(d), nil
+}
+
+func (s *discountServer) ListDiscounts(ctx context.Context, req *catalogv1.ListDiscountsRequest) (*catalogv1.ListDiscountsResponse, error) {
+	discounts, err := s.service.GetDiscounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &catalogv1.ListDiscountsResponse{}
+	for _, d := range discounts {
+		resp.Discounts = append(resp.Discounts, toDiscount(d))
+	}
+	return resp, nil
+}
+
+func toDiscountType(dt discount.DiscountType) *catalogv1.DiscountType {
+	return &catalogv1.DiscountType{
+		Id:   int64(dt.ID),
+		Type: dt.Type,
+	}
+}
+
+func toDiscount(d discount.Discount) *catalogv1.Discount {
+	r := d.ToDiscountResponse()
+	return &catalogv1.Discount{
+		Id:           r.ID,
+		Target:       r.Target,
+		DiscountType: toDiscountType(r.DiscountType),
+		Percentage:   int32(r.Percentage),
+		CouponOnly:   r.CouponOnly,
+		BuyQuantity:  int32(r.BuyQuantity),
+		FreeQuantity: int32(r.FreeQuantity),
+		MinSpend:     int64(r.MinSpend),
+		BundlePrice:  int64(r.BundlePrice),
+		UpdatedAt:    timestamp(d.LastModified()),
+	}
+}
@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"mytheresa/internal/auth"
+	"mytheresa/internal/config"
+	"mytheresa/internal/limits"
+	"mytheresa/internal/logger"
+	"mytheresa/pkg/category"
+	"mytheresa/pkg/discount"
+	"mytheresa/pkg/product"
+	catalogv1 "mytheresa/proto/catalog/v1"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// permissions are required by the RPCs changing the catalog, the rest can be called anonymously
+// as their HTTP routes
+var permissions = map[string]auth.Permission{
+	catalogv1.ProductService_CreateProduct_FullMethodName:       auth.CatalogWrite,
+	catalogv1.CategoryService_CreateCategory_FullMethodName:     auth.CatalogWrite,
+	catalogv1.DiscountService_CreateDiscountType_FullMethodName: auth.PricingWrite,
+	catalogv1.DiscountService_CreateDiscount_FullMethodName:     auth.PricingWrite,
+}
+
+// methodGroup tells the limits of every RPC: the ones changing the catalog, which all require a
+// permission, get the write limits and the rest the read ones
+func methodGroup(method string) limits.Group {
+	if _, ok := permissions[method]; ok {
+		return limits.Write
+	}
+	return limits.Read
+}
+
+// NewGRPCServer serves the product, category and discount services over gRPC, with the same
+// request metadata, authentication, limits and errors as the HTTP API
+func NewGRPCServer(conf config.Config, l logger.Logger, ps product.Service, cs category.Service, ds discount.Service, a auth.Authenticator) *grpc.Server {
+	var failures *limits.Limiter
+	if conf.Limits.AuthFailures.PerSecond > 0 {
+		failures = limits.NewLimiter(conf.Limits.AuthFailures.PerSecond, conf.Limits.AuthFailures.Burst, time.Now)
+	}
+
+	srv := grpc.NewServer(
+		// no message is decoded past the biggest size any RPC allows
+		grpc.MaxRecvMsgSize(int(max(conf.Limits.Read.MaxBodyBytes, conf.Limits.Write.MaxBodyBytes))),
+		grpc.ChainUnaryInterceptor(
+			requestContext(conf.Catalog.Currency),
+			traced,
+			logged(l),
+			authenticated(a, l, failures, permissions),
+			limited(conf.Limits, methodGroup, time.Now),
+			apiErrors,
+		),
+	)
+
+	catalogv1.RegisterProductServiceServer(srv, &productServer{service: ps, defaultLimit: conf.Catalog.DefaultPageLimit})
+	catalogv1.RegisterCategoryServiceServer(srv, &categoryServer{service: cs})
+	catalogv1.RegisterDiscountServiceServer(srv, &discountServer{service: ds})
+	// lets clients like grpcurl list the services and describe their messages
+	reflection.Register(srv)
+
+	return srv
+}
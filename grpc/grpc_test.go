@@ -0,0 +1,249 @@
+package transport_test
+
+import (
+	"context"
+	"errors"
+	transport "mytheresa/grpc"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/auth"
+	"mytheresa/internal/config"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/internal/requestctx"
+	"mytheresa/pkg/category"
+	categorymocks "mytheresa/pkg/category/mocks"
+	"mytheresa/pkg/discount"
+	discountmocks "mytheresa/pkg/discount/mocks"
+	"mytheresa/pkg/product"
+	productmocks "mytheresa/pkg/product/mocks"
+	catalogv1 "mytheresa/proto/catalog/v1"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type services struct {
+	products   *productmocks.Service
+	categories *categorymocks.Service
+	discounts  *discountmocks.Service
+}
+
+// dial serves the services in memory and returns a connection to them
+func dial(t *testing.T) (*grpc.ClientConn, services) {
+	return dialWith(t, func(conf *config.Config) {})
+}
+
+// dialWith serves the services with the default configuration as changed by configure
+func dialWith(t *testing.T, configure func(conf *config.Config)) (*grpc.ClientConn, services) {
+	s := services{&productmocks.Service{}, &categorymocks.Service{}, &discountmocks.Service{}}
+	a, err := auth.NewAuthenticator(config.AuthConfig{APIKeys: "catalog-key=ci:catalog-admin,reader-key=bi:reader"})
+	require.NoError(t, err)
+
+	conf := config.Default()
+	conf.Catalog.DefaultPageLimit = 2
+	configure(&conf)
+	srv := transport.NewGRPCServer(conf, &loggermocks.NoopLogger{}, s.products, s.categories, s.discounts, a)
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, s
+}
+
+func TestGetProduct(t *testing.T) {
+	conn, s := dial(t)
+	s.products.On("GetProduct", mock.Anything, "000001").Return(product.Product{
+		SKU:        "000001",
+		Name:       "BV Lean leather ankle boots",
+		Category:   category.Category{ID: 1, Name: "boots"},
+		CategoryID: 1,
+		Price:      89000,
+	}, nil)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-1")
+	var header metadata.MD
+	p, err := catalogv1.NewProductServiceClient(conn).GetProduct(ctx, &catalogv1.GetProductRequest{Sku: "000001"}, grpc.Header(&header))
+
+	require.NoError(t, err)
+	assert.Equal(t, "000001", p.Sku)
+	assert.Equal(t, "boots", p.Category.Name)
+	assert.Equal(t, int64(89000), p.Price)
+	assert.Equal(t, []string{"req-1"}, header.Get("x-request-id"))
+	reqCtx := s.products.Calls[0].Arguments.Get(0).(context.Context)
+	assert.Equal(t, "req-1", requestctx.RequestID(reqCtx))
+	assert.Equal(t, "EUR", requestctx.Currency(reqCtx))
+}
+
+func TestGetProduct_Errors(t *testing.T) {
+	conn, s := dial(t)
+	s.products.On("GetProduct", mock.Anything, "000009").Return(product.Product{}, apierror.NotFound("Product not found"))
+	s.products.On("GetProduct", mock.Anything, "000010").Return(product.Product{}, errors.New("disk I/O error"))
+	client := catalogv1.NewProductServiceClient(conn)
+
+	var header metadata.MD
+	_, err := client.GetProduct(context.Background(), &catalogv1.GetProductRequest{Sku: "000009"}, grpc.Header(&header))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "Product not found", status.Convert(err).Message())
+	assert.Len(t, header.Get("x-request-id")[0], 36)
+
+	_, err = client.GetProduct(context.Background(), &catalogv1.GetProductRequest{Sku: "000010"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "Internal Server Error", status.Convert(err).Message())
+}
+
+func TestListProducts(t *testing.T) {
+	conn, s := dial(t)
+	percentage := "30"
+	s.products.On("ListProducts", mock.Anything, mock.MatchedBy(func(opts product.ListOptions) bool {
+		return len(opts.Filters) == 2 && opts.Filters[0].GetColumnName() == "category_id" &&
+			opts.Filters[1].GetOperand() == "<=" && opts.CouponCode == "WELCOME20" && opts.InStock && opts.Limit == 2
+	})).Return([]product.ProductResponse{
+		{SKU: "000001", Price: product.PriceResponse{Original: 89000, Final: 62300, DiscountPercentage: &percentage, Currency: "EUR"}, Stock: 10},
+		{SKU: "000002"},
+	}, nil)
+	s.products.On("CountProducts", mock.Anything, mock.Anything).Return(3, nil)
+
+	categoryID, lessThan := int64(1), int64(90000)
+	resp, err := catalogv1.NewProductServiceClient(conn).ListProducts(context.Background(), &catalogv1.ListProductsRequest{
+		CategoryId:    &categoryID,
+		PriceLessThan: &lessThan,
+		Coupon:        "WELCOME20",
+		InStock:       true,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), resp.Limit)
+	assert.Equal(t, int32(3), resp.Total)
+	require.Len(t, resp.Products, 2)
+	assert.Equal(t, int64(62300), resp.Products[0].Price.Final)
+	assert.Equal(t, "30", resp.Products[0].Price.GetDiscountPercentage())
+	assert.Nil(t, resp.Products[0].Price.LowestPrice_30D)
+}
+
+func TestCreateProduct_Permissions(t *testing.T) {
+	conn, s := dial(t)
+	s.products.On("CreateProduct", mock.Anything, mock.MatchedBy(func(p product.ProductRequest) bool {
+		return p.SKU == "000006" && len(p.Variants) == 1 && *p.Variants[0].Price == 12000
+	})).Return(product.Product{SKU: "000006"}, nil)
+	client := catalogv1.NewProductServiceClient(conn)
+	price := int64(12000)
+	req := &catalogv1.ProductRequest{Sku: "000006", Variants: []*catalogv1.VariantRequest{{Sku: "000006-42", Price: &price}}}
+
+	tests := map[string]struct {
+		md   []string
+		code codes.Code
+	}{
+		"anonymous":          {nil, codes.Unauthenticated},
+		"wrong key":          {[]string{"x-api-key", "nope"}, codes.Unauthenticated},
+		"missing permission": {[]string{"x-api-key", "reader-key"}, codes.PermissionDenied},
+		"allowed":            {[]string{"x-api-key", "catalog-key"}, codes.OK},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), tc.md...)
+			_, err := client.CreateProduct(ctx, req)
+			assert.Equal(t, tc.code, status.Code(err))
+		})
+	}
+
+	s.products.AssertNumberOfCalls(t, "CreateProduct", 1)
+	caller, _ := requestctx.Caller(s.products.Calls[0].Arguments.Get(0).(context.Context))
+	assert.Equal(t, "ci", caller)
+}
+
+func TestRateLimit(t *testing.T) {
+	conn, s := dialWith(t, func(conf *config.Config) {
+		conf.Limits.Read = config.RouteLimits{RequestsPerSecond: 0.001, Burst: 2, MaxBodyBytes: 1 << 20}
+	})
+	s.products.On("GetProduct", mock.Anything, "000001").Return(product.Product{SKU: "000001"}, nil)
+	client := catalogv1.NewProductServiceClient(conn)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetProduct(context.Background(), &catalogv1.GetProductRequest{Sku: "000001"})
+		require.NoError(t, err, "call %d", i)
+	}
+	var header metadata.MD
+	_, err := client.GetProduct(context.Background(), &catalogv1.GetProductRequest{Sku: "000001"}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, header.Get("retry-after"))
+
+	// authenticated callers have their own bucket
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "reader-key")
+	_, err = client.GetProduct(ctx, &catalogv1.GetProductRequest{Sku: "000001"})
+	assert.NoError(t, err)
+	s.products.AssertNumberOfCalls(t, "GetProduct", 3)
+}
+
+func TestMessageSizeLimit(t *testing.T) {
+	conn, s := dialWith(t, func(conf *config.Config) {
+		conf.Limits.Write.MaxBodyBytes = 16
+	})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "catalog-key")
+
+	_, err := catalogv1.NewProductServiceClient(conn).CreateProduct(ctx, &catalogv1.ProductRequest{Sku: "000006", Name: "BV Lean leather ankle boots"})
+
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	s.products.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+}
+
+func TestAuthFailuresLimit(t *testing.T) {
+	conn, s := dialWith(t, func(conf *config.Config) {
+		conf.Limits.AuthFailures = config.FailureLimits{PerSecond: 0.001, Burst: 2}
+	})
+	s.categories.On("CreateCategory", mock.Anything, mock.Anything).Return(category.Category{ID: 1}, nil)
+	client := catalogv1.NewCategoryServiceClient(conn)
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "nope")
+
+	for i := 0; i < 2; i++ {
+		_, err := client.CreateCategory(wrong, &catalogv1.CategoryRequest{Name: "boots"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "call %d", i)
+	}
+	// the right key is refused too once the client IP is out of tries
+	right := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "catalog-key")
+	_, err := client.CreateCategory(right, &catalogv1.CategoryRequest{Name: "boots"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	s.categories.AssertNotCalled(t, "CreateCategory", mock.Anything, mock.Anything)
+}
+
+func TestCreateCategory(t *testing.T) {
+	conn, s := dial(t)
+	s.categories.On("CreateCategory", mock.Anything, category.CategoryRequest{Name: ""}).Return(category.Category{}, apierror.BadRequest("name is required"))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "catalog-key")
+	_, err := catalogv1.NewCategoryServiceClient(conn).CreateCategory(ctx, &catalogv1.CategoryRequest{})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestListDiscounts(t *testing.T) {
+	conn, s := dial(t)
+	s.discounts.On("GetDiscounts", mock.Anything).Return([]discount.Discount{
+		&discount.CategoryDiscount{GeneralDiscount: discount.GeneralDiscount{
+			ID: 1, Percentage: 30, Target: "1", DiscountType: discount.DiscountType{ID: 1, Type: "category"},
+		}},
+	}, nil)
+
+	resp, err := catalogv1.NewDiscountServiceClient(conn).ListDiscounts(context.Background(), &catalogv1.ListDiscountsRequest{})
+
+	require.NoError(t, err)
+	require.Len(t, resp.Discounts, 1)
+	assert.Equal(t, "1", resp.Discounts[0].Id)
+	assert.Equal(t, int32(30), resp.Discounts[0].Percentage)
+	assert.Equal(t, "category", resp.Discounts[0].DiscountType.Type)
+	assert.Nil(t, resp.Discounts[0].UpdatedAt)
+}
@@ -0,0 +1,232 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/auth"
+	"mytheresa/internal/config"
+	"mytheresa/internal/limits"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/requestctx"
+	"mytheresa/internal/requestid"
+	"mytheresa/internal/tracing"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// metadata keys, the HTTP headers in lower case
+var (
+	requestIDKey = strings.ToLower(requestid.Header)
+	apiKeyKey    = strings.ToLower(auth.APIKeyHeader)
+)
+
+// requestContext fills the metadata of every call as requestid.Middleware and requestctx.Middleware
+// do for HTTP: its ID, taken from the x-request-id metadata or generated and sent back as a
+// header, the currency of the catalog and the client IP
+func requestContext(currency string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := incoming(ctx, requestIDKey)
+		if id == "" {
+			id = uuid.New().String()
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+
+		ctx = requestctx.WithRequestID(ctx, id)
+		ctx = requestctx.WithCurrency(ctx, currency)
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			ctx = requestctx.WithClientIP(ctx, clientIP(p.Addr))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// traced starts a span for every call, named after its method
+func traced(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := tracing.Start(ctx, info.FullMethod, attribute.String("rpc.system", "grpc"))
+	resp, err := handler(ctx, req)
+	tracing.End(span, err)
+	return resp, err
+}
+
+// logged logs every call with its method, status code and duration
+func logged(l logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		log := l.WithField("method", info.FullMethod).
+			WithField("code", status.Code(err).String()).
+			WithField("duration", time.Since(start).String())
+		if err != nil {
+			log.WithError(err).Error(ctx, "gRPC call failed")
+		} else {
+			log.Info(ctx, "gRPC call")
+		}
+		return resp, err
+	}
+}
+
+// authenticated keeps the principal of the calls carrying an API key or a bearer JWT in their
+// context, as auth.Middleware does, and only lets through the ones with the permission their
+// method requires. Client IPs out of failures tokens are refused before their credentials are
+// checked, failures is nil when they are not limited.
+func authenticated(a auth.Authenticator, l logger.Logger, failures *limits.Limiter, permissions map[string]auth.Permission) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := incoming(ctx, apiKeyKey)
+		token, bearer := bearerToken(incoming(ctx, "authorization"))
+		authenticated := key != "" || bearer
+
+		ip := requestctx.ClientIP(ctx)
+		if authenticated && failures != nil {
+			if wait := failures.Wait(ip); wait > 0 {
+				l.WithField("client_ip", ip).Error(ctx, "Too many failed authentications")
+				return nil, tooManyRequests(ctx, wait)
+			}
+		}
+
+		var p auth.Principal
+		var err error
+		if key != "" {
+			p, err = a.AuthenticateAPIKey(key)
+		} else if bearer {
+			p, err = a.AuthenticateJWT(token)
+		}
+		if err != nil {
+			if failures != nil {
+				failures.Allow(ip)
+			}
+			l.WithError(err).Error(ctx, "Error authenticating call")
+			return nil, status.Error(codes.Unauthenticated, "Invalid credentials")
+		}
+		if authenticated {
+			ctx = auth.NewContext(ctx, p)
+		}
+
+		if permission, ok := permissions[info.FullMethod]; ok {
+			if !authenticated {
+				return nil, status.Error(codes.Unauthenticated, "Authentication required")
+			}
+			if !p.Can(permission) {
+				return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("Missing permission %s", permission))
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// limited applies to every call the limits of its group, as told by groupOf, the way
+// limits.Middleware does for HTTP: callers over the rate get ResourceExhausted with a retry-after
+// header, and so do messages bigger than the group allows. Authenticated callers are limited on
+// their own, anonymous ones by client IP.
+func limited(conf config.LimitsConfig, groupOf func(method string) limits.Group, clock func() time.Time) grpc.UnaryServerInterceptor {
+	type groupLimits struct {
+		// limiter is nil when the group has no rate limit
+		limiter *limits.Limiter
+		maxSize int64
+	}
+	groups := map[limits.Group]groupLimits{}
+	for group, rl := range map[limits.Group]config.RouteLimits{limits.Read: conf.Read, limits.Write: conf.Write} {
+		gl := groupLimits{maxSize: rl.MaxBodyBytes}
+		if rl.RequestsPerSecond > 0 {
+			gl.limiter = limits.NewLimiter(rl.RequestsPerSecond, rl.Burst, clock)
+		}
+		groups[group] = gl
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		gl := groups[groupOf(info.FullMethod)]
+
+		if gl.limiter != nil {
+			if ok, wait := gl.limiter.Allow(limits.CallerKey(ctx)); !ok {
+				return nil, tooManyRequests(ctx, wait)
+			}
+		}
+		if m, ok := req.(proto.Message); ok && int64(proto.Size(m)) > gl.maxSize {
+			return nil, status.Error(codes.ResourceExhausted, fmt.Sprintf("Message larger than %d bytes", gl.maxSize))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// tooManyRequests is the status of the calls refused by a limiter, telling in the retry-after
+// header how many seconds until they can be made again
+func tooManyRequests(ctx context.Context, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(seconds)))
+	return status.Error(codes.ResourceExhausted, fmt.Sprintf("Too many requests, retry in %s", time.Duration(seconds)*time.Second))
+}
+
+// apiErrors turns the errors of the services into statuses with the code matching their HTTP one.
+// Other errors are not shown to the client, as the HTTP API does.
+func apiErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return nil, err
+	}
+	apierr, ok := err.(*apierror.ApiError)
+	if !ok {
+		return nil, status.Error(codes.Internal, "Internal Server Error")
+	}
+	return nil, status.Error(statusCode(apierr.Code()), apierr.Message)
+}
+
+func statusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusInternalServerError:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// incoming returns the first value of the metadata key sent by the client
+func incoming(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func clientIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
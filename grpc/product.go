@@ -0,0 +1,162 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"mytheresa/internal/database"
+	"mytheresa/pkg/product"
+	catalogv1 "mytheresa/proto/catalog/v1"
+)
+
+type productServer struct {
+	catalogv1.UnimplementedProductServiceServer
+	service      product.Service
+	defaultLimit int
+}
+
+func (s *productServer) CreateProduct(ctx context.Context, req *catalogv1.ProductRequest) (*catalogv1.Product, error) {
+	p, err := s.service.CreateProduct(ctx, toProductRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return toProduct(p), nil
+}
+
+func (s *productServer) GetProduct(ctx context.Context, req *catalogv1.GetProductRequest) (*catalogv1.Product, error) {
+	p, err := s.service.GetProduct(ctx, req.GetSku())
+	if err != nil {
+		return nil, err
+	}
+	return toProduct(p), nil
+}
+
+// ListProducts lists the products as GET /v1/products does, the total being the number of
+// products matching before the limit
+func (s *productServer) ListProducts(ctx context.Context, req *catalogv1.ListProductsRequest) (*catalogv1.ListProductsResponse, error) {
+	limit := s.defaultLimit
+	if req.GetLimit() > 0 {
+		limit = int(req.GetLimit())
+	}
+
+	var filters []database.Filter
+	if req.CategoryId != nil {
+		filters = append(filters, product.NewCategoryFilter(fmt.Sprint(req.GetCategoryId()), "="))
+	}
+	if req.PriceLessThan != nil {
+		filters = append(filters, product.NewPriceFilter(fmt.Sprint(req.GetPriceLessThan()), "<="))
+	}
+	if req.PriceGreaterThan != nil {
+		filters = append(filters, product.NewPriceFilter(fmt.Sprint(req.GetPriceGreaterThan()), ">="))
+	}
+
+	opts := product.ListOptions{
+		Filters:    filters,
+		CouponCode: req.GetCoupon(),
+		InStock:    req.GetInStock(),
+		Limit:      limit,
+	}
+	products, err := s.service.ListProducts(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	total := len(products)
+	if total == limit {
+		if total, err = s.service.CountProducts(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &catalogv1.ListProductsResponse{Limit: int32(limit), Total: int32(total)}
+	for _, p := range products {
+		resp.Products = append(resp.Products, toProductResponse(p))
+	}
+	return resp, nil
+}
+
+func toProductRequest(req *catalogv1.ProductRequest) product.ProductRequest {
+	p := product.ProductRequest{
+		SKU:        req.GetSku(),
+		Name:       req.GetName(),
+		Price:      int(req.GetPrice()),
+		CategoryID: int(req.GetCategoryId()),
+		Stock:      int(req.GetStock()),
+	}
+	for _, v := range req.GetVariants() {
+		p.Variants = append(p.Variants, product.VariantRequest{
+			SKU:    v.GetSku(),
+			Size:   v.GetSize(),
+			Colour: v.GetColour(),
+			Price:  toInt(v.Price),
+			Stock:  int(v.GetStock()),
+		})
+	}
+	return p
+}
+
+func toProduct(p product.Product) *catalogv1.Product {
+	resp := &catalogv1.Product{
+		Sku:        p.SKU,
+		Name:       p.Name,
+		Category:   toCategory(p.Category),
+		CategoryId: int64(p.CategoryID),
+		Price:      int64(p.Price),
+		Version:    int32(p.Version),
+		UpdatedAt:  timestamp(p.UpdatedAt),
+	}
+	for _, v := range p.Variants {
+		resp.Variants = append(resp.Variants, &catalogv1.Variant{
+			Sku:       v.SKU,
+			ParentSku: v.ParentSKU,
+			Size:      v.Size,
+			Colour:    v.Colour,
+			Price:     toInt64(v.Price),
+		})
+	}
+	return resp
+}
+
+func toProductResponse(p product.ProductResponse) *catalogv1.ProductResponse {
+	resp := &catalogv1.ProductResponse{
+		Sku:      p.SKU,
+		Name:     p.Name,
+		Category: p.Category,
+		Price:    toPriceResponse(p.Price),
+		Stock:    int64(p.Stock),
+	}
+	for _, v := range p.Variants {
+		resp.Variants = append(resp.Variants, &catalogv1.VariantResponse{
+			Sku:    v.SKU,
+			Size:   v.Size,
+			Colour: v.Colour,
+			Price:  toPriceResponse(v.Price),
+			Stock:  int64(v.Stock),
+		})
+	}
+	return resp
+}
+
+func toPriceResponse(p product.PriceResponse) *catalogv1.PriceResponse {
+	return &catalogv1.PriceResponse{
+		Original:           int64(p.Original),
+		Final:              int64(p.Final),
+		DiscountPercentage: p.DiscountPercentage,
+		Currency:           p.Currency,
+		LowestPrice_30D:    toInt64(p.LowestPrice30d),
+	}
+}
+
+func toInt(v *int64) *int {
+	if v == nil {
+		return nil
+	}
+	i := int(*v)
+	return &i
+}
+
+func toInt64(v *int) *int64 {
+	if v == nil {
+		return nil
+	}
+	i := int64(*v)
+	return &i
+}
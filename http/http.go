@@ -1,70 +1,143 @@
 package transport
 
 import (
-	"context"
 	"fmt"
+	"mytheresa/graphql"
+	"mytheresa/internal/auth"
+	"mytheresa/internal/config"
+	"mytheresa/internal/health"
+	"mytheresa/internal/httpcache"
+	"mytheresa/internal/limits"
 	"mytheresa/internal/logger"
+	"mytheresa/internal/metrics"
+	"mytheresa/internal/requestctx"
+	"mytheresa/internal/requestid"
+	"mytheresa/internal/response"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/audit"
+	"mytheresa/pkg/cart"
+	"mytheresa/pkg/catalog"
+	"mytheresa/pkg/category"
+	"mytheresa/pkg/coupon"
 	"mytheresa/pkg/discount"
+	"mytheresa/pkg/events"
+	"mytheresa/pkg/inventory"
 	"mytheresa/pkg/product"
+	"mytheresa/pkg/webhook"
 	"net/http"
+	"strings"
+	"time"
 
 	_ "mytheresa/docs"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func NewHTTPRouter(l logger.Logger, ps product.Service, ds discount.Service) *mux.Router {
+func NewHTTPRouter(conf config.Config, l logger.Logger, ps product.Service, cats category.Service, ds discount.Service, cs coupon.Service, cts cart.Service, is inventory.Service, cgs catalog.Service, as audit.Service, bus events.Subscriber, ws webhook.Service, a auth.Authenticator, h *health.Health) *mux.Router {
 
-	ph := product.NewHandler(ps, l)
+	ph := product.NewHandler(ps, l, conf.Catalog.DefaultPageLimit)
 	dh := discount.NewHandler(ds, l)
+	ch := coupon.NewHandler(cs, l)
+	cth := cart.NewHandler(cts, l)
+	ih := inventory.NewHandler(is, l)
+	cgh := catalog.NewHandler(cgs, l)
+	ah := audit.NewHandler(as, l)
+	eh := events.NewHandler(bus, l, conf.Events.KeepAlive.Duration)
+	wh := webhook.NewHandler(ws, l)
+	gh := graphql.NewHandler(ps, cats, ds, l, conf.GraphQL, conf.Catalog.DefaultPageLimit)
 
 	r := mux.NewRouter()
-	r.Use(requestIDMiddleware)
-	r.Use(contentTypeMiddleware)
+	r.NotFoundHandler = metrics.Middleware(http.NotFoundHandler())
+	r.Use(metrics.Middleware)
+	r.Use(tracing.Middleware)
+	r.Use(requestid.Middleware)
+	r.Use(response.Middleware)
+	r.Use(requestctx.Middleware(conf.Catalog.Currency))
+	r.Use(auth.Middleware(a, l, authFailures(conf.Limits.AuthFailures)))
 
 	r.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "pong")
 	}).Methods(http.MethodGet)
 
+	r.HandleFunc("/health/live", h.Live).Methods(http.MethodGet)
+	r.HandleFunc("/health/ready", h.Ready).Methods(http.MethodGet)
+
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+
 	//Documentation
 	r.HandleFunc("/swagger/{any:.*}", httpSwagger.WrapHandler).Methods(http.MethodGet)
 
+	// the same limits for /v1 and /graphql, so both draw from the buckets of a caller
+	limited := limits.Middleware(conf.Limits, routeGroup, time.Now)
+
+	//GraphQL endpoint
+	r.Handle("/graphql", limited(http.HandlerFunc(gh.Query))).Methods(http.MethodGet, http.MethodPost)
+
 	v1 := r.PathPrefix("/v1").Subrouter()
+	v1.Use(limited)
 
 	//Product endpoints
-	v1.HandleFunc("/product", ph.CreateProduct).Methods(http.MethodPost)
-	v1.HandleFunc("/product/{id}", ph.GetProduct).Methods(http.MethodGet)
-	v1.HandleFunc("/products", ph.ListProducts).Methods(http.MethodGet)
+	v1.Handle("/product", protect(auth.CatalogWrite, ph.CreateProduct)).Methods(http.MethodPost)
+	v1.Handle("/product/{id}", cached(conf.Cache.Product, ph.GetProduct)).Methods(http.MethodGet)
+	v1.Handle("/products", cached(conf.Cache.Products, ph.ListProducts)).Methods(http.MethodGet)
 	//Discount endpoints
-	v1.HandleFunc("/discount", dh.CreateDiscount).Methods(http.MethodPost)
-	v1.HandleFunc("/discounts", dh.GetDiscounts).Methods(http.MethodGet)
+	v1.Handle("/discount", protect(auth.PricingWrite, dh.CreateDiscount)).Methods(http.MethodPost)
+	v1.Handle("/discounts", cached(conf.Cache.Discounts, dh.GetDiscounts)).Methods(http.MethodGet)
+	//Coupon endpoints
+	v1.Handle("/coupon", protect(auth.PricingWrite, ch.CreateCoupon)).Methods(http.MethodPost)
+	v1.Handle("/coupon/{code}/redeem", protect(auth.CheckoutWrite, ch.RedeemCoupon)).Methods(http.MethodPost)
+	//Cart endpoints
+	v1.HandleFunc("/cart/price", cth.PriceCart).Methods(http.MethodPost)
+	//Inventory endpoints
+	v1.HandleFunc("/inventory/{sku}", ih.GetStock).Methods(http.MethodGet)
+	v1.Handle("/inventory/{sku}/adjust", protect(auth.CatalogWrite, ih.AdjustStock)).Methods(http.MethodPost)
+	v1.Handle("/reservation", protect(auth.CheckoutWrite, ih.Reserve)).Methods(http.MethodPost)
+	v1.Handle("/reservation/{id}/confirm", protect(auth.CheckoutWrite, ih.ConfirmReservation)).Methods(http.MethodPost)
+	v1.Handle("/reservation/{id}", protect(auth.CheckoutWrite, ih.ReleaseReservation)).Methods(http.MethodDelete)
+	//Catalog import and export endpoints
+	v1.Handle("/import/{kind:products|categories}", protect(auth.CatalogWrite, cgh.Import)).Methods(http.MethodPost)
+	v1.Handle("/import/{kind:discounts}", protect(auth.PricingWrite, cgh.Import)).Methods(http.MethodPost)
+	v1.Handle("/export/products", protect(auth.CatalogRead, cgh.Export)).Methods(http.MethodGet)
+	//Audit endpoints
+	v1.Handle("/audit", protect(auth.AuditRead, ah.GetEntries)).Methods(http.MethodGet)
+	//Event endpoints
+	v1.Handle("/events", protect(auth.CatalogRead, eh.Stream)).Methods(http.MethodGet)
+	v1.Handle("/webhook", protect(auth.WebhooksWrite, wh.CreateWebhook)).Methods(http.MethodPost)
+	v1.Handle("/webhooks", protect(auth.WebhooksWrite, wh.GetWebhooks)).Methods(http.MethodGet)
+	v1.Handle("/webhook/{id}", protect(auth.WebhooksWrite, wh.DeleteWebhook)).Methods(http.MethodDelete)
+	v1.Handle("/webhook/{id}/deliveries", protect(auth.WebhooksWrite, wh.GetDeliveries)).Methods(http.MethodGet)
 
 	return r
 }
 
-func requestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		id := r.Header.Get("X-Request-Id")
-		if id == "" {
-			// generate new version 4 uuid
-			id = uuid.New().String()
-		}
-		// set the id to the request context
-		ctx = context.WithValue(ctx, "request_id", id)
-		r = r.WithContext(ctx)
-
-		// set the response header
-		w.Header().Set("X-Request-Id", id)
-		next.ServeHTTP(w, r)
-	})
+// routeGroup tells the limits of a request: bulk for imports and exports, write for the rest of
+// the changes and read otherwise, GraphQL queries being reads whatever their method
+func routeGroup(r *http.Request) limits.Group {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/import/"), strings.HasPrefix(r.URL.Path, "/v1/export/"):
+		return limits.Bulk
+	case r.Method == http.MethodGet || r.Method == http.MethodHead, r.URL.Path == "/graphql":
+		return limits.Read
+	default:
+		return limits.Write
+	}
+}
+
+// authFailures limits the wrong credentials of every client IP, nil when not limited
+func authFailures(conf config.FailureLimits) *limits.Limiter {
+	if conf.PerSecond <= 0 {
+		return nil
+	}
+	return limits.NewLimiter(conf.PerSecond, conf.Burst, time.Now)
+}
+
+// protect only lets through the callers with the permission
+func protect(permission auth.Permission, h http.HandlerFunc) http.Handler {
+	return auth.Require(permission)(h)
 }
 
-func contentTypeMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Content-Type", "application/json; charset=UTF-8")
-		next.ServeHTTP(w, r)
-	})
+// cached sends the Cache-Control policy along with the successful responses
+func cached(policy string, h http.HandlerFunc) http.Handler {
+	return httpcache.Policy(policy)(h)
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mytheresa/internal/auth"
+	"mytheresa/internal/config"
+	"mytheresa/pkg/catalog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runImport sends an import file to a running server, e.g.
+//
+//	mytheresa import -kind products -dry-run products.csv
+//
+// It returns the exit code: 0 when every row was imported, 1 when some failed, 2 on usage errors.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	port := config.Default().Server.Port
+	if env, err := strconv.Atoi(config.GetEnvString("HTTP_PORT", "")); err == nil {
+		port = env
+	}
+	addr := fs.String("addr", fmt.Sprintf("http://localhost:%d", port), "address of the running server")
+	kind := fs.String("kind", string(catalog.Products), "what the file holds: products, categories or discounts")
+	format := fs.String("format", "", "csv or jsonl, taken from the file extension when missing")
+	dryRun := fs.Bool("dry-run", false, "only validate the rows")
+	atomic := fs.Bool("atomic", false, "import every row or none")
+	apiKey := fs.String("api-key", config.GetEnvString("API_KEY", ""), "API key with permission to write the kind, defaults to $API_KEY")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: mytheresa import [flags] <file>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	file := fs.Arg(0)
+	if *format == "" {
+		*format = string(catalog.JSONL)
+		if strings.EqualFold(filepath.Ext(file), ".csv") {
+			*format = string(catalog.CSV)
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer f.Close()
+
+	query := url.Values{}
+	query.Set("format", *format)
+	query.Set("dry_run", strconv.FormatBool(*dryRun))
+	query.Set("atomic", strconv.FormatBool(*atomic))
+	endpoint := fmt.Sprintf("%s/v1/import/%s?%s", strings.TrimRight(*addr, "/"), url.PathEscape(*kind), query.Encode())
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if *apiKey != "" {
+		req.Header.Set(auth.APIKeyHeader, *apiKey)
+	}
+
+	client := http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "import failed with status %d: %s", resp.StatusCode, body)
+		return 1
+	}
+
+	var result catalog.ImportResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
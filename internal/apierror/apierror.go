@@ -1,10 +1,14 @@
 package apierror
 
-import "net/http"
+import (
+	"encoding/xml"
+	"net/http"
+)
 
 // ApiError represents an error response from the API
 type ApiError struct {
-	Message string `json:"message"`
+	XMLName xml.Name `json:"-" xml:"error"`
+	Message string   `json:"message" xml:"message"`
 	code    int
 }
 
@@ -37,4 +41,53 @@ func InternalServerError(message string) error {
 	}
 }
 
+func Conflict(message string) error {
+	return &ApiError{
+		Message: message,
+		code:    http.StatusConflict,
+	}
+}
+
+func Unauthorized(message string) error {
+	return &ApiError{
+		Message: message,
+		code:    http.StatusUnauthorized,
+	}
+}
+
+func Forbidden(message string) error {
+	return &ApiError{
+		Message: message,
+		code:    http.StatusForbidden,
+	}
+}
+
+func PayloadTooLarge(message string) error {
+	return &ApiError{
+		Message: message,
+		code:    http.StatusRequestEntityTooLarge,
+	}
+}
+
+func TooManyRequests(message string) error {
+	return &ApiError{
+		Message: message,
+		code:    http.StatusTooManyRequests,
+	}
+}
+
+func NotAcceptable(message string) error {
+	return &ApiError{
+		Message: message,
+		code:    http.StatusNotAcceptable,
+	}
+}
+
+func ServiceUnavailable(message string) error {
+	return &ApiError{
+		Message: message,
+		code:    http.StatusServiceUnavailable,
+	}
+}
+
 //TODO: Implement any other useful function for creating apierror
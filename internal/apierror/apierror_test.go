@@ -39,3 +39,80 @@ func TestInternalServerError(t *testing.T) {
 	assert.Equal(t, "test message", apierr.Message)
 	assert.Equal(t, "test message", apierr.Error())
 }
+
+func TestConflict(t *testing.T) {
+	err := apierror.Conflict("test message")
+
+	apierr, ok := err.(*apierror.ApiError)
+
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusConflict, apierr.Code())
+	assert.Equal(t, "test message", apierr.Message)
+	assert.Equal(t, "test message", apierr.Error())
+}
+
+func TestUnauthorized(t *testing.T) {
+	err := apierror.Unauthorized("test message")
+
+	apierr, ok := err.(*apierror.ApiError)
+
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, apierr.Code())
+	assert.Equal(t, "test message", apierr.Message)
+	assert.Equal(t, "test message", apierr.Error())
+}
+
+func TestForbidden(t *testing.T) {
+	err := apierror.Forbidden("test message")
+
+	apierr, ok := err.(*apierror.ApiError)
+
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusForbidden, apierr.Code())
+	assert.Equal(t, "test message", apierr.Message)
+	assert.Equal(t, "test message", apierr.Error())
+}
+
+func TestPayloadTooLarge(t *testing.T) {
+	err := apierror.PayloadTooLarge("test message")
+
+	apierr, ok := err.(*apierror.ApiError)
+
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, apierr.Code())
+	assert.Equal(t, "test message", apierr.Message)
+	assert.Equal(t, "test message", apierr.Error())
+}
+
+func TestTooManyRequests(t *testing.T) {
+	err := apierror.TooManyRequests("test message")
+
+	apierr, ok := err.(*apierror.ApiError)
+
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, apierr.Code())
+	assert.Equal(t, "test message", apierr.Message)
+	assert.Equal(t, "test message", apierr.Error())
+}
+
+func TestNotAcceptable(t *testing.T) {
+	err := apierror.NotAcceptable("test message")
+
+	apierr, ok := err.(*apierror.ApiError)
+
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotAcceptable, apierr.Code())
+	assert.Equal(t, "test message", apierr.Message)
+	assert.Equal(t, "test message", apierr.Error())
+}
+
+func TestServiceUnavailable(t *testing.T) {
+	err := apierror.ServiceUnavailable("test message")
+
+	apierr, ok := err.(*apierror.ApiError)
+
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, apierr.Code())
+	assert.Equal(t, "test message", apierr.Message)
+	assert.Equal(t, "test message", apierr.Error())
+}
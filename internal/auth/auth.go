@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"mytheresa/internal/requestctx"
+)
+
+// Role is granted to a caller through its API key or the roles claim of its JWT
+type Role string
+
+const (
+	Reader       Role = "reader"
+	CatalogAdmin Role = "catalog-admin"
+	PricingAdmin Role = "pricing-admin"
+	Checkout     Role = "checkout"
+)
+
+// Permission is what a route requires from the caller
+type Permission string
+
+const (
+	CatalogRead   Permission = "catalog:read"
+	CatalogWrite  Permission = "catalog:write"
+	PricingWrite  Permission = "pricing:write"
+	CheckoutWrite Permission = "checkout:write"
+	AuditRead     Permission = "audit:read"
+	WebhooksWrite Permission = "webhooks:write"
+)
+
+var rolePermissions = map[Role][]Permission{
+	Reader:       {CatalogRead},
+	CatalogAdmin: {CatalogRead, CatalogWrite, AuditRead, WebhooksWrite},
+	PricingAdmin: {CatalogRead, PricingWrite, AuditRead, WebhooksWrite},
+	Checkout:     {CheckoutWrite},
+}
+
+func ParseRole(value string) (Role, error) {
+	role := Role(value)
+	if _, ok := rolePermissions[role]; !ok {
+		return "", fmt.Errorf("unknown role %s", value)
+	}
+	return role, nil
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string
+	Roles   []Role
+	// Method is how the caller authenticated, api_key or jwt
+	Method string
+}
+
+// Can tells if any of the roles of the principal grants the permission
+func (p *Principal) Can(permission Permission) bool {
+	for _, role := range p.Roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext keeps the principal in the context, its subject becoming the caller of the request
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(requestctx.WithCaller(ctx, p.Subject), principalKey{}, p)
+}
+
+// FromContext returns the principal authenticated for the request, if any
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,55 @@
+package auth_test
+
+import (
+	"context"
+	"mytheresa/internal/auth"
+	"mytheresa/internal/requestctx"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRole(t *testing.T) {
+	role, err := auth.ParseRole("pricing-admin")
+	assert.NoError(t, err)
+	assert.Equal(t, auth.PricingAdmin, role)
+
+	_, err = auth.ParseRole("root")
+	assert.EqualError(t, err, "unknown role root")
+}
+
+func TestPrincipal_Can(t *testing.T) {
+	tests := map[auth.Role]map[auth.Permission]bool{
+		auth.Reader:       {auth.CatalogRead: true, auth.CatalogWrite: false, auth.PricingWrite: false, auth.CheckoutWrite: false, auth.AuditRead: false, auth.WebhooksWrite: false},
+		auth.CatalogAdmin: {auth.CatalogRead: true, auth.CatalogWrite: true, auth.PricingWrite: false, auth.CheckoutWrite: false, auth.AuditRead: true, auth.WebhooksWrite: true},
+		auth.PricingAdmin: {auth.CatalogRead: true, auth.CatalogWrite: false, auth.PricingWrite: true, auth.CheckoutWrite: false, auth.AuditRead: true, auth.WebhooksWrite: true},
+		auth.Checkout:     {auth.CatalogRead: false, auth.CatalogWrite: false, auth.PricingWrite: false, auth.CheckoutWrite: true, auth.AuditRead: false, auth.WebhooksWrite: false},
+	}
+
+	for role, permissions := range tests {
+		p := auth.Principal{Roles: []auth.Role{role}}
+		for permission, allowed := range permissions {
+			assert.Equal(t, allowed, p.Can(permission), "%s %s", role, permission)
+		}
+	}
+
+	both := auth.Principal{Roles: []auth.Role{auth.CatalogAdmin, auth.PricingAdmin}}
+	assert.True(t, both.Can(auth.CatalogWrite))
+	assert.True(t, both.Can(auth.PricingWrite))
+	assert.False(t, (&auth.Principal{}).Can(auth.CatalogRead))
+}
+
+func TestContext(t *testing.T) {
+	_, ok := auth.FromContext(context.Background())
+	assert.False(t, ok)
+
+	p := auth.Principal{Subject: "ci", Roles: []auth.Role{auth.Reader}}
+	ctx := auth.NewContext(context.Background(), p)
+	got, ok := auth.FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, p, got)
+
+	caller, ok := requestctx.Caller(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "ci", caller)
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"mytheresa/internal/config"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator turns the credentials of a request into a principal
+type Authenticator interface {
+	AuthenticateAPIKey(key string) (Principal, error)
+	AuthenticateJWT(token string) (Principal, error)
+}
+
+type authenticator struct {
+	// apiKeys are indexed by the SHA-256 of the key, so the keys are not kept around
+	apiKeys   map[[sha256.Size]byte]Principal
+	secret    []byte
+	publicKey *rsa.PublicKey
+	options   []jwt.ParserOption
+}
+
+// claims are the ones read from the JWTs, roles holds the names of the roles granted
+type claims struct {
+	Roles []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+// NewAuthenticator builds an authenticator accepting the API keys and JWTs set in the config.
+// Without any of them every request is anonymous.
+func NewAuthenticator(conf config.AuthConfig) (Authenticator, error) {
+	a := &authenticator{apiKeys: map[[sha256.Size]byte]Principal{}}
+
+	if err := a.parseAPIKeys(conf.APIKeys); err != nil {
+		return nil, err
+	}
+
+	methods := []string{}
+	if conf.JWTSecret != "" {
+		a.secret = []byte(conf.JWTSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if conf.JWTPublicKeyFile != "" {
+		pem, err := os.ReadFile(conf.JWTPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWT public key: %w", err)
+		}
+		a.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT public key: %w", err)
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	a.options = []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if conf.JWTIssuer != "" {
+		a.options = append(a.options, jwt.WithIssuer(conf.JWTIssuer))
+	}
+	if conf.JWTAudience != "" {
+		a.options = append(a.options, jwt.WithAudience(conf.JWTAudience))
+	}
+
+	return a, nil
+}
+
+// parseAPIKeys reads comma separated entries like key=subject:role1|role2
+func (a *authenticator) parseAPIKeys(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, rest, ok := strings.Cut(entry, "=")
+		subject, roleNames, ok2 := strings.Cut(rest, ":")
+		if !ok || !ok2 || key == "" || subject == "" {
+			return fmt.Errorf("API keys must look like key=subject:role1|role2")
+		}
+
+		roles, err := parseRoles(strings.Split(roleNames, "|"))
+		if err != nil {
+			return fmt.Errorf("API key of %s: %w", subject, err)
+		}
+		a.apiKeys[sha256.Sum256([]byte(key))] = Principal{Subject: subject, Roles: roles, Method: MethodAPIKey}
+	}
+	return nil
+}
+
+func (a *authenticator) AuthenticateAPIKey(key string) (Principal, error) {
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	p, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+// AuthenticateJWT verifies the token signature, expiration and, when configured, its issuer
+// and audience. The signing method has to be one with a configured key.
+func (a *authenticator) AuthenticateJWT(token string) (Principal, error) {
+	if token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	if a.secret == nil && a.publicKey == nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, a.key, a.options...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := c.GetSubject()
+	if subject == "" {
+		return Principal{}, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	roles, err := parseRoles(c.Roles)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+	}
+
+	return Principal{Subject: subject, Roles: roles, Method: MethodJWT}, nil
+}
+
+func (a *authenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return a.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return a.publicKey, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+func parseRoles(names []string) ([]Role, error) {
+	var roles []Role
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
@@ -0,0 +1,150 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"mytheresa/internal/auth"
+	"mytheresa/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const secret = "test-secret"
+
+func token(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	signed, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "jane",
+		"roles": []string{"pricing-admin"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestNewAuthenticator_InvalidAPIKeys(t *testing.T) {
+	tests := map[string]string{
+		"API keys must look like key=subject:role1|role2": "just-a-key",
+		"API key of ci: unknown role root":                "k1=ci:root",
+	}
+
+	for message, keys := range tests {
+		_, err := auth.NewAuthenticator(config.AuthConfig{APIKeys: keys})
+		assert.EqualError(t, err, message)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	a, err := auth.NewAuthenticator(config.AuthConfig{APIKeys: "k1=ci:catalog-admin|pricing-admin, k2=dashboard:reader"})
+	assert.NoError(t, err)
+
+	p, err := a.AuthenticateAPIKey("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, auth.Principal{Subject: "ci", Roles: []auth.Role{auth.CatalogAdmin, auth.PricingAdmin}, Method: auth.MethodAPIKey}, p)
+
+	p, err = a.AuthenticateAPIKey("k2")
+	assert.NoError(t, err)
+	assert.Equal(t, "dashboard", p.Subject)
+
+	_, err = a.AuthenticateAPIKey("k3")
+	assert.Equal(t, auth.ErrInvalidCredentials, err)
+
+	_, err = a.AuthenticateAPIKey("")
+	assert.Equal(t, auth.ErrNoCredentials, err)
+}
+
+func TestAuthenticateJWT_HS256(t *testing.T) {
+	a, err := auth.NewAuthenticator(config.AuthConfig{JWTSecret: secret})
+	assert.NoError(t, err)
+
+	p, err := a.AuthenticateJWT(token(t, jwt.SigningMethodHS256, []byte(secret), validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, auth.Principal{Subject: "jane", Roles: []auth.Role{auth.PricingAdmin}, Method: auth.MethodJWT}, p)
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	noExpiration := validClaims()
+	delete(noExpiration, "exp")
+	noSubject := validClaims()
+	delete(noSubject, "sub")
+	unknownRole := validClaims()
+	unknownRole["roles"] = []string{"root"}
+
+	invalid := map[string]string{
+		"wrong secret":  token(t, jwt.SigningMethodHS256, []byte("other"), validClaims()),
+		"expired":       token(t, jwt.SigningMethodHS256, []byte(secret), expired),
+		"no expiration": token(t, jwt.SigningMethodHS256, []byte(secret), noExpiration),
+		"no subject":    token(t, jwt.SigningMethodHS256, []byte(secret), noSubject),
+		"unknown role":  token(t, jwt.SigningMethodHS256, []byte(secret), unknownRole),
+		"none alg":      token(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims()),
+		"HS512":         token(t, jwt.SigningMethodHS512, []byte(secret), validClaims()),
+		"garbage":       "not.a.token",
+	}
+	for name, tk := range invalid {
+		_, err := a.AuthenticateJWT(tk)
+		assert.True(t, errors.Is(err, auth.ErrInvalidCredentials), name)
+	}
+}
+
+func TestAuthenticateJWT_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "public.pem")
+	err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+	assert.NoError(t, err)
+
+	a, err := auth.NewAuthenticator(config.AuthConfig{
+		JWTPublicKeyFile: file,
+		JWTIssuer:        "https://id.mytheresa.com",
+		JWTAudience:      "catalog",
+	})
+	assert.NoError(t, err)
+
+	claims := validClaims()
+	claims["iss"] = "https://id.mytheresa.com"
+	claims["aud"] = "catalog"
+	p, err := a.AuthenticateJWT(token(t, jwt.SigningMethodRS256, key, claims))
+	assert.NoError(t, err)
+	assert.Equal(t, "jane", p.Subject)
+
+	otherAudience := validClaims()
+	otherAudience["iss"] = "https://id.mytheresa.com"
+	otherAudience["aud"] = "orders"
+	_, err = a.AuthenticateJWT(token(t, jwt.SigningMethodRS256, key, otherAudience))
+	assert.True(t, errors.Is(err, auth.ErrInvalidCredentials))
+
+	// HS256 is not accepted without a secret, even signed with the public key
+	_, err = a.AuthenticateJWT(token(t, jwt.SigningMethodHS256, der, claims))
+	assert.True(t, errors.Is(err, auth.ErrInvalidCredentials))
+}
+
+func TestAuthenticateJWT_NotConfigured(t *testing.T) {
+	a, err := auth.NewAuthenticator(config.AuthConfig{})
+	assert.NoError(t, err)
+
+	_, err = a.AuthenticateJWT(token(t, jwt.SigningMethodHS256, []byte(secret), validClaims()))
+	assert.Equal(t, auth.ErrInvalidCredentials, err)
+}
+
+func TestNewAuthenticator_WrongPublicKeyFile(t *testing.T) {
+	_, err := auth.NewAuthenticator(config.AuthConfig{JWTPublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "public.pem")
+	_ = os.WriteFile(file, []byte("not a key"), 0o600)
+	_, err = auth.NewAuthenticator(config.AuthConfig{JWTPublicKeyFile: file})
+	assert.Error(t, err)
+}
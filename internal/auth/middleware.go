@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/limits"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/requestctx"
+	"mytheresa/internal/response"
+	"net/http"
+	"strings"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// Middleware authenticates the requests carrying an API key or a bearer JWT and keeps the
+// principal in their context. Requests without credentials go on anonymously, it's up to
+// Require to reject them, while wrong credentials are always rejected. Every failure takes a
+// token of the client IP from failures, nil for no limit, and once out of them the credentials
+// of that IP are not even checked, they get a 429 until the bucket refills.
+func Middleware(a Authenticator, l logger.Logger, failures *limits.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			key := r.Header.Get(APIKeyHeader)
+			token, bearer := bearerToken(r)
+			if key == "" && !bearer {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := requestctx.ClientIP(ctx)
+			if failures != nil {
+				if wait := failures.Wait(ip); wait > 0 {
+					l.WithField("client_ip", ip).Error(ctx, "Too many failed authentications")
+					limits.RespondTooManyRequests(w, wait)
+					return
+				}
+			}
+
+			var p Principal
+			var err error
+			if key != "" {
+				p, err = a.AuthenticateAPIKey(key)
+			} else {
+				p, err = a.AuthenticateJWT(token)
+			}
+			if err != nil {
+				if failures != nil {
+					failures.Allow(ip)
+				}
+				l.WithError(err).Error(ctx, "Error authenticating request")
+				unauthorized(w, "Invalid credentials")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(ctx, p)))
+		})
+	}
+}
+
+// Require only lets through the principals with the permission, anonymous requests get a 401
+// and the ones whose roles don't grant it a 403
+func Require(permission Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := FromContext(r.Context())
+			if !ok {
+				unauthorized(w, "Authentication required")
+				return
+			}
+			if !p.Can(permission) {
+				response.RespondWithError(w, apierror.Forbidden(fmt.Sprintf("Missing permission %s", permission)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer, ApiKey header="`+APIKeyHeader+`"`)
+	response.RespondWithError(w, apierror.Unauthorized(message))
+}
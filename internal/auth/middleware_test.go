@@ -0,0 +1,115 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/auth"
+	"mytheresa/internal/config"
+	"mytheresa/internal/limits"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/internal/requestctx"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func protectedHandler(t *testing.T, permission auth.Permission) http.Handler {
+	return limitedHandler(t, permission, nil)
+}
+
+func limitedHandler(t *testing.T, permission auth.Permission, failures *limits.Limiter) http.Handler {
+	a, err := auth.NewAuthenticator(config.AuthConfig{APIKeys: "k1=ci:catalog-admin,k2=dashboard:reader", JWTSecret: secret})
+	assert.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.FromContext(r.Context())
+		w.Write([]byte(p.Subject))
+	})
+	return auth.Middleware(a, &loggermocks.NoopLogger{}, failures)(auth.Require(permission)(ok))
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		header  string
+		value   string
+		code    int
+		message string
+	}{
+		"api key with permission":    {auth.APIKeyHeader, "k1", http.StatusOK, ""},
+		"api key without permission": {auth.APIKeyHeader, "k2", http.StatusForbidden, "Missing permission catalog:write"},
+		"unknown api key":            {auth.APIKeyHeader, "k3", http.StatusUnauthorized, "Invalid credentials"},
+		"jwt without permission":     {"Authorization", "Bearer " + token(t, jwt.SigningMethodHS256, []byte(secret), validClaims()), http.StatusForbidden, "Missing permission catalog:write"},
+		"invalid jwt":                {"Authorization", "Bearer nope", http.StatusUnauthorized, "Invalid credentials"},
+		"no credentials":             {"", "", http.StatusUnauthorized, "Authentication required"},
+		"basic auth":                 {"Authorization", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "Authentication required"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/product", nil)
+			if test.header != "" {
+				r.Header.Set(test.header, test.value)
+			}
+			w := httptest.NewRecorder()
+
+			protectedHandler(t, auth.CatalogWrite).ServeHTTP(w, r)
+
+			assert.Equal(t, test.code, w.Code)
+			if test.code == http.StatusOK {
+				assert.Equal(t, "ci", w.Body.String())
+				return
+			}
+			var apierr apierror.ApiError
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&apierr))
+			assert.Equal(t, test.message, apierr.Error())
+			if test.code == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestMiddleware_JWTWithPermission(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/discount", nil)
+	r.Header.Set("Authorization", "Bearer "+token(t, jwt.SigningMethodHS256, []byte(secret), validClaims()))
+	w := httptest.NewRecorder()
+
+	protectedHandler(t, auth.PricingWrite).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "jane", w.Body.String())
+}
+
+func TestMiddleware_FailedAuthenticationsLimited(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := limitedHandler(t, auth.CatalogWrite, limits.NewLimiter(1.0/60, 2, func() time.Time { return now }))
+	send := func(ip, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/product", nil)
+		r = r.WithContext(requestctx.WithClientIP(r.Context(), ip))
+		r.Header.Set(auth.APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("192.0.2.10", "guess1").Code)
+	assert.Equal(t, http.StatusUnauthorized, send("192.0.2.10", "guess2").Code)
+
+	// out of failures, not even a right key is checked
+	w := send("192.0.2.10", "k1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	w = send("192.0.2.10", "guess3")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// other IPs are not affected
+	assert.Equal(t, http.StatusOK, send("192.0.2.11", "k1").Code)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, send("192.0.2.10", "k1").Code)
+	assert.Equal(t, http.StatusOK, send("192.0.2.10", "k1").Code)
+}
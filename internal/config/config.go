@@ -1,24 +1,504 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
-)
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
-const (
-	dbFile = "DB_FILE"
-	port   = "HTTP_PORT"
+	"gopkg.in/yaml.v3"
 )
 
+// configFileEnv names the configuration file when the -config flag is missing
+const configFileEnv = "CONFIG_FILE"
+
+const redacted = "[REDACTED]"
+
+// Config is loaded from, by increasing precedence, its defaults, a YAML or JSON file, the
+// environment and the command line flags
 type Config struct {
-	DbFile string
-	Port   string
+	Database DatabaseConfig `json:"database" yaml:"database"`
+	Server   ServerConfig   `json:"server" yaml:"server"`
+	Catalog  CatalogConfig  `json:"catalog" yaml:"catalog"`
+	Limits   LimitsConfig   `json:"limits" yaml:"limits"`
+	Cache    CacheConfig    `json:"cache" yaml:"cache"`
+	Auth     AuthConfig     `json:"auth" yaml:"auth"`
+	Tracing  TracingConfig  `json:"tracing" yaml:"tracing"`
+	GraphQL  GraphQLConfig  `json:"graphql" yaml:"graphql"`
+	Events   EventsConfig   `json:"events" yaml:"events"`
+}
+
+type DatabaseConfig struct {
+	// File is the SQLite database, kept across runs. A temporary one is used when empty.
+	File string `json:"file" yaml:"file"`
+}
+
+type ServerConfig struct {
+	Port         int      `json:"port" yaml:"port"`
+	ReadTimeout  Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout Duration `json:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout  Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	// ShutdownGracePeriod is how long the requests in flight have to finish on shutdown
+	ShutdownGracePeriod Duration `json:"shutdown_grace_period" yaml:"shutdown_grace_period"`
+	// ShutdownDrainDelay is how long the server keeps taking requests once it is no longer ready,
+	// for the load balancers to notice and stop sending new ones
+	ShutdownDrainDelay Duration `json:"shutdown_drain_delay" yaml:"shutdown_drain_delay"`
+	// GRPCPort serves the gRPC API next to the HTTP one
+	GRPCPort int `json:"grpc_port" yaml:"grpc_port"`
+}
+
+type CatalogConfig struct {
+	// DefaultPageLimit is how many products are listed when the request sets no limit
+	DefaultPageLimit int `json:"default_page_limit" yaml:"default_page_limit"`
+	// Currency is the ISO 4217 code of the catalog prices
+	Currency string `json:"currency" yaml:"currency"`
+}
+
+// LimitsConfig holds the limits of every route group: bulk for imports and exports, write for the
+// rest of the changes and read for everything else under /v1
+type LimitsConfig struct {
+	Read  RouteLimits `json:"read" yaml:"read"`
+	Write RouteLimits `json:"write" yaml:"write"`
+	Bulk  RouteLimits `json:"bulk" yaml:"bulk"`
+	// AuthFailures bounds the wrong credentials sent from a client IP, whatever the route
+	AuthFailures FailureLimits `json:"auth_failures" yaml:"auth_failures"`
 }
 
-func New() Config {
+// FailureLimits are applied to each client IP, every failure taking a token of its bucket
+type FailureLimits struct {
+	// PerSecond is the rate failures are forgiven at, 0 for no limit
+	PerSecond float64 `json:"per_second" yaml:"per_second"`
+	// Burst is how many failures in a row are allowed
+	Burst int `json:"burst" yaml:"burst"`
+}
+
+// RouteLimits are applied to each caller, either an authenticated one or a client IP
+type RouteLimits struct {
+	// RequestsPerSecond is the sustained rate allowed, 0 for no rate limit
+	RequestsPerSecond float64 `json:"requests_per_second" yaml:"requests_per_second"`
+	// Burst is how many requests can be made at once
+	Burst int `json:"burst" yaml:"burst"`
+	// MaxBodyBytes is the biggest request body accepted
+	MaxBodyBytes int64 `json:"max_body_bytes" yaml:"max_body_bytes"`
+}
+
+// CacheConfig holds the Cache-Control header of the catalog reads, none is sent when empty, and
+// how long the discounts are kept in memory
+type CacheConfig struct {
+	// Products is the policy of the product list
+	Products string `json:"products" yaml:"products"`
+	// Product is the policy of a single product
+	Product string `json:"product" yaml:"product"`
+	// Discounts is the policy of the discount list
+	Discounts string `json:"discounts" yaml:"discounts"`
+	// DiscountsTTL is how long the discounts are cached in memory, 0 to always read them
+	DiscountsTTL Duration `json:"discounts_ttl" yaml:"discounts_ttl"`
+}
+
+// AuthConfig holds the credentials accepted by the write endpoints
+type AuthConfig struct {
+	// APIKeys are comma separated entries like key=subject:role1|role2
+	APIKeys string `json:"api_keys" yaml:"api_keys"`
+	// JWTSecret verifies HS256 tokens
+	JWTSecret string `json:"jwt_hs256_secret" yaml:"jwt_hs256_secret"`
+	// JWTPublicKeyFile is the PEM file with the public key verifying RS256 tokens
+	JWTPublicKeyFile string `json:"jwt_rs256_public_key_file" yaml:"jwt_rs256_public_key_file"`
+	// JWTIssuer and JWTAudience, when set, must match the iss and aud claims of the tokens
+	JWTIssuer   string `json:"jwt_issuer" yaml:"jwt_issuer"`
+	JWTAudience string `json:"jwt_audience" yaml:"jwt_audience"`
+}
+
+// TracingConfig sets where the spans are exported to
+type TracingConfig struct {
+	ServiceName string `json:"service_name" yaml:"service_name"`
+	// OTLPEndpoint is the URL of the OTLP/HTTP collector, e.g. http://localhost:4318. Without it
+	// spans are not exported, but trace IDs are still propagated and logged.
+	OTLPEndpoint string `json:"otlp_endpoint" yaml:"otlp_endpoint"`
+	// SampleRatio is the share of new traces sampled, traces started upstream follow their parent
+	SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio"`
+}
+
+// GraphQLConfig bounds the queries accepted by /graphql
+type GraphQLConfig struct {
+	// MaxDepth is how deep the fields of a query can be nested
+	MaxDepth int `json:"max_depth" yaml:"max_depth"`
+	// MaxComplexity is the highest cost of a query, every field resolved counting as one
+	MaxComplexity int `json:"max_complexity" yaml:"max_complexity"`
+}
+
+// EventsConfig sets how the changes of the catalog are streamed and delivered to the webhooks
+type EventsConfig struct {
+	// KeepAlive is how often a comment is sent on idle event streams
+	KeepAlive Duration `json:"keep_alive" yaml:"keep_alive"`
+	// OutboxPollInterval is how often the outbox is checked for events left unpublished, and the
+	// webhooks for deliveries left pending
+	OutboxPollInterval Duration `json:"outbox_poll_interval" yaml:"outbox_poll_interval"`
+	// WebhookTimeout is how long a webhook has to answer a delivery
+	WebhookTimeout Duration `json:"webhook_timeout" yaml:"webhook_timeout"`
+	// WebhookMaxAttempts is how many times an event is sent to a webhook until it's accepted
+	WebhookMaxAttempts int `json:"webhook_max_attempts" yaml:"webhook_max_attempts"`
+	// WebhookBackoff is the wait before the first retry, doubled on every retry up to WebhookMaxBackoff
+	WebhookBackoff    Duration `json:"webhook_backoff" yaml:"webhook_backoff"`
+	WebhookMaxBackoff Duration `json:"webhook_max_backoff" yaml:"webhook_max_backoff"`
+	// WebhookAllowPrivateHosts lets webhooks be registered on loopback, link-local and private
+	// addresses, only meant for development
+	WebhookAllowPrivateHosts bool `json:"webhook_allow_private_hosts" yaml:"webhook_allow_private_hosts"`
+}
+
+// Duration is a time.Duration written like "15s" or "1m30s" in files, variables and flags
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Default returns the configuration used for what no source sets
+func Default() Config {
 	return Config{
-		DbFile: GetEnvString(dbFile, ""),
-		Port:   GetEnvString(port, "8080"),
+		Server: ServerConfig{
+			Port:                8080,
+			ReadTimeout:         Duration{15 * time.Second},
+			WriteTimeout:        Duration{15 * time.Second},
+			IdleTimeout:         Duration{60 * time.Second},
+			ShutdownGracePeriod: Duration{10 * time.Second},
+			ShutdownDrainDelay:  Duration{5 * time.Second},
+			GRPCPort:            9090,
+		},
+		Catalog: CatalogConfig{
+			DefaultPageLimit: 5,
+			Currency:         "EUR",
+		},
+		Limits: LimitsConfig{
+			Read:  RouteLimits{RequestsPerSecond: 20, Burst: 40, MaxBodyBytes: 1 << 20},
+			Write: RouteLimits{RequestsPerSecond: 5, Burst: 20, MaxBodyBytes: 1 << 20},
+			Bulk:  RouteLimits{RequestsPerSecond: 0.1, Burst: 3, MaxBodyBytes: 32 << 20},
+			// 10 in a row, then one a minute
+			AuthFailures: FailureLimits{PerSecond: 1.0 / 60, Burst: 10},
+		},
+		Cache: CacheConfig{
+			Products:     "public, max-age=30",
+			Product:      "public, max-age=300",
+			Discounts:    "public, max-age=30",
+			DiscountsTTL: Duration{time.Minute},
+		},
+		Tracing: TracingConfig{
+			ServiceName: "mytheresa",
+			SampleRatio: 1,
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      10,
+			MaxComplexity: 5000,
+		},
+		Events: EventsConfig{
+			KeepAlive:          Duration{15 * time.Second},
+			OutboxPollInterval: Duration{time.Second},
+			WebhookTimeout:     Duration{5 * time.Second},
+			WebhookMaxAttempts: 5,
+			WebhookBackoff:     Duration{time.Second},
+			WebhookMaxBackoff:  Duration{time.Minute},
+		},
+	}
+}
+
+// envVars maps the flags to the environment variables setting the same option
+var envVars = []struct {
+	flag string
+	env  string
+}{
+	{"db-file", "DB_FILE"},
+	{"port", "HTTP_PORT"},
+	{"read-timeout", "HTTP_READ_TIMEOUT"},
+	{"write-timeout", "HTTP_WRITE_TIMEOUT"},
+	{"idle-timeout", "HTTP_IDLE_TIMEOUT"},
+	{"shutdown-grace-period", "HTTP_SHUTDOWN_GRACE_PERIOD"},
+	{"shutdown-drain-delay", "HTTP_SHUTDOWN_DRAIN_DELAY"},
+	{"grpc-port", "GRPC_PORT"},
+	{"default-page-limit", "CATALOG_DEFAULT_PAGE_LIMIT"},
+	{"currency", "CATALOG_CURRENCY"},
+	{"rate-limit-read", "RATE_LIMIT_READ"},
+	{"rate-limit-read-burst", "RATE_LIMIT_READ_BURST"},
+	{"max-body-read", "MAX_BODY_READ"},
+	{"rate-limit-write", "RATE_LIMIT_WRITE"},
+	{"rate-limit-write-burst", "RATE_LIMIT_WRITE_BURST"},
+	{"max-body-write", "MAX_BODY_WRITE"},
+	{"rate-limit-bulk", "RATE_LIMIT_BULK"},
+	{"rate-limit-bulk-burst", "RATE_LIMIT_BULK_BURST"},
+	{"max-body-bulk", "MAX_BODY_BULK"},
+	{"auth-failures-per-second", "AUTH_FAILURES_PER_SECOND"},
+	{"auth-failures-burst", "AUTH_FAILURES_BURST"},
+	{"cache-control-products", "CACHE_CONTROL_PRODUCTS"},
+	{"cache-control-product", "CACHE_CONTROL_PRODUCT"},
+	{"cache-control-discounts", "CACHE_CONTROL_DISCOUNTS"},
+	{"discount-cache-ttl", "DISCOUNT_CACHE_TTL"},
+	{"auth-api-keys", "AUTH_API_KEYS"},
+	{"auth-jwt-hs256-secret", "AUTH_JWT_HS256_SECRET"},
+	{"auth-jwt-rs256-public-key-file", "AUTH_JWT_RS256_PUBLIC_KEY_FILE"},
+	{"auth-jwt-issuer", "AUTH_JWT_ISSUER"},
+	{"auth-jwt-audience", "AUTH_JWT_AUDIENCE"},
+	{"otel-service-name", "OTEL_SERVICE_NAME"},
+	{"otel-endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT"},
+	{"otel-sample-ratio", "OTEL_TRACES_SAMPLER_ARG"},
+	{"graphql-max-depth", "GRAPHQL_MAX_DEPTH"},
+	{"graphql-max-complexity", "GRAPHQL_MAX_COMPLEXITY"},
+	{"events-keep-alive", "EVENTS_KEEP_ALIVE"},
+	{"outbox-poll-interval", "OUTBOX_POLL_INTERVAL"},
+	{"webhook-timeout", "WEBHOOK_TIMEOUT"},
+	{"webhook-max-attempts", "WEBHOOK_MAX_ATTEMPTS"},
+	{"webhook-backoff", "WEBHOOK_BACKOFF"},
+	{"webhook-max-backoff", "WEBHOOK_MAX_BACKOFF"},
+	{"webhook-allow-private-hosts", "WEBHOOK_ALLOW_PRIVATE_HOSTS"},
+}
+
+// newFlagSet binds the flags to the fields of c, the config file one to file
+func newFlagSet(name string, c *Config, file *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(file, "config", os.Getenv(configFileEnv), "YAML or JSON configuration file ($"+configFileEnv+")")
+	fs.StringVar(&c.Database.File, "db-file", c.Database.File, "SQLite database file, a temporary one when empty")
+	fs.IntVar(&c.Server.Port, "port", c.Server.Port, "HTTP port")
+	fs.TextVar(&c.Server.ReadTimeout, "read-timeout", c.Server.ReadTimeout, "timeout reading a request")
+	fs.TextVar(&c.Server.WriteTimeout, "write-timeout", c.Server.WriteTimeout, "timeout writing a response")
+	fs.TextVar(&c.Server.IdleTimeout, "idle-timeout", c.Server.IdleTimeout, "timeout of idle keep-alive connections")
+	fs.TextVar(&c.Server.ShutdownGracePeriod, "shutdown-grace-period", c.Server.ShutdownGracePeriod, "time the requests in flight have to finish on shutdown")
+	fs.TextVar(&c.Server.ShutdownDrainDelay, "shutdown-drain-delay", c.Server.ShutdownDrainDelay, "time requests are still taken on shutdown once not ready")
+	fs.IntVar(&c.Server.GRPCPort, "grpc-port", c.Server.GRPCPort, "gRPC port")
+	fs.IntVar(&c.Catalog.DefaultPageLimit, "default-page-limit", c.Catalog.DefaultPageLimit, "products listed when the request sets no limit")
+	fs.StringVar(&c.Catalog.Currency, "currency", c.Catalog.Currency, "ISO 4217 code of the catalog prices")
+	for _, group := range []struct {
+		name   string
+		limits *RouteLimits
+	}{{"read", &c.Limits.Read}, {"write", &c.Limits.Write}, {"bulk", &c.Limits.Bulk}} {
+		fs.Float64Var(&group.limits.RequestsPerSecond, "rate-limit-"+group.name, group.limits.RequestsPerSecond, group.name+" requests per second allowed to each caller, 0 for no limit")
+		fs.IntVar(&group.limits.Burst, "rate-limit-"+group.name+"-burst", group.limits.Burst, group.name+" requests each caller can make at once")
+		fs.Int64Var(&group.limits.MaxBodyBytes, "max-body-"+group.name, group.limits.MaxBodyBytes, "biggest "+group.name+" request body in bytes")
+	}
+	fs.Float64Var(&c.Limits.AuthFailures.PerSecond, "auth-failures-per-second", c.Limits.AuthFailures.PerSecond, "failed authentications forgiven per second to each client IP, 0 for no limit")
+	fs.IntVar(&c.Limits.AuthFailures.Burst, "auth-failures-burst", c.Limits.AuthFailures.Burst, "failed authentications in a row allowed to each client IP")
+	fs.StringVar(&c.Cache.Products, "cache-control-products", c.Cache.Products, "Cache-Control of the product list, none when empty")
+	fs.StringVar(&c.Cache.Product, "cache-control-product", c.Cache.Product, "Cache-Control of a product, none when empty")
+	fs.StringVar(&c.Cache.Discounts, "cache-control-discounts", c.Cache.Discounts, "Cache-Control of the discount list, none when empty")
+	fs.TextVar(&c.Cache.DiscountsTTL, "discount-cache-ttl", c.Cache.DiscountsTTL, "time the discounts are cached in memory, 0 to always read them")
+	fs.StringVar(&c.Auth.APIKeys, "auth-api-keys", c.Auth.APIKeys, "comma separated key=subject:role1|role2 entries")
+	fs.StringVar(&c.Auth.JWTSecret, "auth-jwt-hs256-secret", c.Auth.JWTSecret, "secret verifying HS256 JWTs")
+	fs.StringVar(&c.Auth.JWTPublicKeyFile, "auth-jwt-rs256-public-key-file", c.Auth.JWTPublicKeyFile, "PEM file with the public key verifying RS256 JWTs")
+	fs.StringVar(&c.Auth.JWTIssuer, "auth-jwt-issuer", c.Auth.JWTIssuer, "iss claim the JWTs must have")
+	fs.StringVar(&c.Auth.JWTAudience, "auth-jwt-audience", c.Auth.JWTAudience, "aud claim the JWTs must have")
+	fs.StringVar(&c.Tracing.ServiceName, "otel-service-name", c.Tracing.ServiceName, "service name of the spans")
+	fs.StringVar(&c.Tracing.OTLPEndpoint, "otel-endpoint", c.Tracing.OTLPEndpoint, "OTLP/HTTP collector, spans are not exported when empty")
+	fs.Float64Var(&c.Tracing.SampleRatio, "otel-sample-ratio", c.Tracing.SampleRatio, "ratio of new traces sampled")
+	fs.IntVar(&c.GraphQL.MaxDepth, "graphql-max-depth", c.GraphQL.MaxDepth, "deepest nesting of the fields of a GraphQL query")
+	fs.IntVar(&c.GraphQL.MaxComplexity, "graphql-max-complexity", c.GraphQL.MaxComplexity, "highest cost of a GraphQL query, one per field resolved")
+	fs.TextVar(&c.Events.KeepAlive, "events-keep-alive", c.Events.KeepAlive, "how often a comment is sent on idle event streams")
+	fs.TextVar(&c.Events.OutboxPollInterval, "outbox-poll-interval", c.Events.OutboxPollInterval, "how often the outbox is checked for events left unpublished")
+	fs.TextVar(&c.Events.WebhookTimeout, "webhook-timeout", c.Events.WebhookTimeout, "time a webhook has to answer a delivery")
+	fs.IntVar(&c.Events.WebhookMaxAttempts, "webhook-max-attempts", c.Events.WebhookMaxAttempts, "times an event is sent to a webhook until it's accepted")
+	fs.TextVar(&c.Events.WebhookBackoff, "webhook-backoff", c.Events.WebhookBackoff, "wait before retrying a webhook delivery, doubled on every retry")
+	fs.TextVar(&c.Events.WebhookMaxBackoff, "webhook-max-backoff", c.Events.WebhookMaxBackoff, "longest wait between webhook delivery retries")
+	fs.BoolVar(&c.Events.WebhookAllowPrivateHosts, "webhook-allow-private-hosts", c.Events.WebhookAllowPrivateHosts, "let webhooks be sent to loopback, link-local and private addresses")
+
+	for _, v := range envVars {
+		f := fs.Lookup(v.flag)
+		f.Usage = fmt.Sprintf("%s ($%s)", f.Usage, v.env)
+	}
+	return fs
+}
+
+// Load returns the configuration out of every source, args being the command line flags. Wrong
+// options are reported together in a ValidationError, along with the configuration loaded.
+func Load(name string, args []string) (Config, error) {
+	// the flags are parsed twice, first to find the file, then to override what it sets
+	var file string
+	scratch := Default()
+	if err := newFlagSet(name, &scratch, &file).Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	c := Default()
+	if file != "" {
+		if err := loadFile(file, &c); err != nil {
+			return Config{}, err
+		}
+	}
+
+	fs := newFlagSet(name, &c, &file)
+	fs.SetOutput(io.Discard)
+	if err := applyEnv(fs); err != nil {
+		return Config{}, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	return c, c.Validate()
+}
+
+// loadFile decodes a YAML or JSON file, told apart by its extension, into c. Unknown options
+// are rejected, they are most likely typos.
+func loadFile(path string, c *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		// an empty file sets nothing
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".json":
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv sets the flags whose environment variable is not empty
+func applyEnv(fs *flag.FlagSet) error {
+	for _, v := range envVars {
+		value := os.Getenv(v.env)
+		if value == "" {
+			continue
+		}
+		if err := fs.Set(v.flag, value); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", v.env, value, err)
+		}
+	}
+	return nil
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidationError lists every wrong option of a configuration otherwise loaded
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	return errors.Join(e.Errs...).Error()
+}
+
+// Validate returns a ValidationError with every wrong option at once
+func (c Config) Validate() error {
+	var errs []error
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Server.GRPCPort < 1 || c.Server.GRPCPort > 65535 {
+		errs = append(errs, fmt.Errorf("server.grpc_port must be between 1 and 65535, got %d", c.Server.GRPCPort))
+	} else if c.Server.GRPCPort == c.Server.Port {
+		errs = append(errs, fmt.Errorf("server.grpc_port must differ from server.port, both are %d", c.Server.Port))
+	}
+	timeouts := map[string]Duration{
+		"server.read_timeout":          c.Server.ReadTimeout,
+		"server.write_timeout":         c.Server.WriteTimeout,
+		"server.idle_timeout":          c.Server.IdleTimeout,
+		"server.shutdown_grace_period": c.Server.ShutdownGracePeriod,
+	}
+	for _, name := range []string{"server.read_timeout", "server.write_timeout", "server.idle_timeout", "server.shutdown_grace_period"} {
+		if timeouts[name].Duration <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", name, timeouts[name]))
+		}
+	}
+	if c.Server.ShutdownDrainDelay.Duration < 0 {
+		errs = append(errs, fmt.Errorf("server.shutdown_drain_delay must not be negative, got %s", c.Server.ShutdownDrainDelay))
+	}
+	if c.Catalog.DefaultPageLimit < 1 {
+		errs = append(errs, fmt.Errorf("catalog.default_page_limit must be at least 1, got %d", c.Catalog.DefaultPageLimit))
+	}
+	if !currencyCode.MatchString(c.Catalog.Currency) {
+		errs = append(errs, fmt.Errorf("catalog.currency must be an ISO 4217 code like EUR, got %q", c.Catalog.Currency))
+	}
+	for _, group := range []struct {
+		name   string
+		limits RouteLimits
+	}{{"read", c.Limits.Read}, {"write", c.Limits.Write}, {"bulk", c.Limits.Bulk}} {
+		if group.limits.RequestsPerSecond < 0 {
+			errs = append(errs, fmt.Errorf("limits.%s.requests_per_second must not be negative, got %v", group.name, group.limits.RequestsPerSecond))
+		}
+		if group.limits.RequestsPerSecond > 0 && group.limits.Burst < 1 {
+			errs = append(errs, fmt.Errorf("limits.%s.burst must be at least 1, got %d", group.name, group.limits.Burst))
+		}
+		if group.limits.MaxBodyBytes < 1 {
+			errs = append(errs, fmt.Errorf("limits.%s.max_body_bytes must be at least 1, got %d", group.name, group.limits.MaxBodyBytes))
+		}
+	}
+	if c.Limits.AuthFailures.PerSecond < 0 {
+		errs = append(errs, fmt.Errorf("limits.auth_failures.per_second must not be negative, got %v", c.Limits.AuthFailures.PerSecond))
+	}
+	if c.Limits.AuthFailures.PerSecond > 0 && c.Limits.AuthFailures.Burst < 1 {
+		errs = append(errs, fmt.Errorf("limits.auth_failures.burst must be at least 1, got %d", c.Limits.AuthFailures.Burst))
+	}
+	if c.Cache.DiscountsTTL.Duration < 0 {
+		errs = append(errs, fmt.Errorf("cache.discounts_ttl must not be negative, got %s", c.Cache.DiscountsTTL))
+	}
+	if c.Tracing.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.service_name must not be empty"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio))
+	}
+	if c.GraphQL.MaxDepth < 1 {
+		errs = append(errs, fmt.Errorf("graphql.max_depth must be at least 1, got %d", c.GraphQL.MaxDepth))
+	}
+	if c.GraphQL.MaxComplexity < 1 {
+		errs = append(errs, fmt.Errorf("graphql.max_complexity must be at least 1, got %d", c.GraphQL.MaxComplexity))
+	}
+	events := map[string]Duration{
+		"events.keep_alive":           c.Events.KeepAlive,
+		"events.outbox_poll_interval": c.Events.OutboxPollInterval,
+		"events.webhook_timeout":      c.Events.WebhookTimeout,
+		"events.webhook_backoff":      c.Events.WebhookBackoff,
+		"events.webhook_max_backoff":  c.Events.WebhookMaxBackoff,
+	}
+	for _, name := range []string{"events.keep_alive", "events.outbox_poll_interval", "events.webhook_timeout", "events.webhook_backoff", "events.webhook_max_backoff"} {
+		if events[name].Duration <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", name, events[name]))
+		}
+	}
+	if c.Events.WebhookMaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("events.webhook_max_attempts must be at least 1, got %d", c.Events.WebhookMaxAttempts))
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errs: errs}
+	}
+	return nil
+}
+
+// Redacted returns a copy safe to print, with the secrets that are set masked
+func (c Config) Redacted() Config {
+	for _, secret := range []*string{&c.Auth.APIKeys, &c.Auth.JWTSecret} {
+		if *secret != "" {
+			*secret = redacted
+		}
 	}
+	return c
 }
 
 func GetEnvString(key, defaultValue string) string {
@@ -0,0 +1,178 @@
+package config_test
+
+import (
+	"errors"
+	"mytheresa/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, err := config.Load("test", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, config.Default(), c)
+	assert.Equal(t, 8080, c.Server.Port)
+	assert.Equal(t, 9090, c.Server.GRPCPort)
+	assert.Equal(t, 10*time.Second, c.Server.ShutdownGracePeriod.Duration)
+	assert.Equal(t, 5*time.Second, c.Server.ShutdownDrainDelay.Duration)
+	assert.Equal(t, 5, c.Catalog.DefaultPageLimit)
+	assert.Equal(t, "EUR", c.Catalog.Currency)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+  read_timeout: 5s
+  write_timeout: 1m30s
+catalog:
+  default_page_limit: 20
+  currency: GBP
+`)
+	t.Setenv("HTTP_PORT", "9001")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "20s")
+
+	c, err := config.Load("test", []string{"-config", file, "-write-timeout", "25s", "-default-page-limit", "50"})
+
+	assert.NoError(t, err)
+	// file over defaults
+	assert.Equal(t, 5*time.Second, c.Server.ReadTimeout.Duration)
+	assert.Equal(t, "GBP", c.Catalog.Currency)
+	assert.Equal(t, 60*time.Second, c.Server.IdleTimeout.Duration)
+	// environment over file
+	assert.Equal(t, 9001, c.Server.Port)
+	// flags over everything
+	assert.Equal(t, 25*time.Second, c.Server.WriteTimeout.Duration)
+	assert.Equal(t, 50, c.Catalog.DefaultPageLimit)
+}
+
+func TestLoad_JSONFileFromEnv(t *testing.T) {
+	file := writeFile(t, "config.json", `{"database": {"file": "app.db"}, "server": {"idle_timeout": "2m"}, "tracing": {"sample_ratio": 0.25}}`)
+	t.Setenv("CONFIG_FILE", file)
+
+	c, err := config.Load("test", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "app.db", c.Database.File)
+	assert.Equal(t, 2*time.Minute, c.Server.IdleTimeout.Duration)
+	assert.Equal(t, 0.25, c.Tracing.SampleRatio)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]struct {
+		file string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		"unknown yaml option": {
+			file: writeFile(t, "typo.yaml", "server:\n  prot: 9000\n"),
+			err:  "field prot not found",
+		},
+		"unknown json option": {
+			file: writeFile(t, "typo.json", `{"server": {"prot": 9000}}`),
+			err:  `unknown field "prot"`,
+		},
+		"unsupported file": {
+			file: writeFile(t, "config.toml", ""),
+			err:  "must be .yaml, .yml or .json",
+		},
+		"missing file": {
+			file: "/nonexistent/config.yaml",
+			err:  "reading config file",
+		},
+		"wrong duration in file": {
+			file: writeFile(t, "config.yaml", "server:\n  read_timeout: soon\n"),
+			err:  `invalid duration "soon"`,
+		},
+		"wrong env": {
+			env: map[string]string{"HTTP_READ_TIMEOUT": "15"},
+			err: `invalid HTTP_READ_TIMEOUT "15"`,
+		},
+		"unknown flag": {
+			args: []string{"-prot", "9000"},
+			err:  "flag provided but not defined: -prot",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", tt.file}, args...)
+			}
+
+			_, err := config.Load("test", args)
+
+			assert.ErrorContains(t, err, tt.err)
+			var invalid *config.ValidationError
+			assert.False(t, errors.As(err, &invalid))
+		})
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	c, err := config.Load("test", []string{
+		"-port", "70000",
+		"-grpc-port", "0",
+		"-read-timeout", "0s",
+		"-shutdown-drain-delay", "-1s",
+		"-default-page-limit", "0",
+		"-currency", "euro",
+		"-auth-failures-per-second", "-1",
+		"-discount-cache-ttl", "-1m",
+		"-otel-sample-ratio", "2",
+		"-graphql-max-complexity", "0",
+		"-webhook-backoff", "0s",
+		"-webhook-max-attempts", "0",
+	})
+
+	var invalid *config.ValidationError
+	assert.True(t, errors.As(err, &invalid))
+	assert.Equal(t, 70000, c.Server.Port)
+	assert.Equal(t, "server.port must be between 1 and 65535, got 70000\n"+
+		"server.grpc_port must be between 1 and 65535, got 0\n"+
+		"server.read_timeout must be positive, got 0s\n"+
+		"server.shutdown_drain_delay must not be negative, got -1s\n"+
+		"catalog.default_page_limit must be at least 1, got 0\n"+
+		`catalog.currency must be an ISO 4217 code like EUR, got "euro"`+"\n"+
+		"limits.auth_failures.per_second must not be negative, got -1\n"+
+		"cache.discounts_ttl must not be negative, got -1m0s\n"+
+		"tracing.sample_ratio must be between 0 and 1, got 2\n"+
+		"graphql.max_complexity must be at least 1, got 0\n"+
+		"events.webhook_backoff must be positive, got 0s\n"+
+		"events.webhook_max_attempts must be at least 1, got 0", err.Error())
+}
+
+func TestLoad_SamePorts(t *testing.T) {
+	_, err := config.Load("test", []string{"-port", "9000", "-grpc-port", "9000"})
+
+	assert.EqualError(t, err, "server.grpc_port must differ from server.port, both are 9000")
+}
+
+func TestRedacted(t *testing.T) {
+	c := config.Default()
+	c.Auth.APIKeys = "k1=ci:reader"
+	c.Auth.JWTPublicKeyFile = "jwt.pem"
+
+	redacted := c.Redacted()
+
+	assert.Equal(t, "[REDACTED]", redacted.Auth.APIKeys)
+	assert.Empty(t, redacted.Auth.JWTSecret)
+	assert.Equal(t, "jwt.pem", redacted.Auth.JWTPublicKeyFile)
+	assert.Equal(t, "k1=ci:reader", c.Auth.APIKeys)
+}
@@ -8,8 +8,36 @@ type Database interface {
 	Save(ctx context.Context, key string, value interface{}) error
 	Get(ctx context.Context, key string, here interface{}) error
 	GetWithFilters(ctx context.Context, here interface{}, filters ...Filter) error
+	// GetPage is GetWithFilters ordered and limited as the page says, in the query itself
+	GetPage(ctx context.Context, here interface{}, page Page, filters ...Filter) error
+	// Count returns how many rows of the model match the filters, without reading them
+	Count(ctx context.Context, model interface{}, filters ...Filter) (int64, error)
+	Increment(ctx context.Context, model interface{}, column string, delta int, filters ...Filter) (int64, error)
+	Delete(ctx context.Context, model interface{}, filters ...Filter) (int64, error)
+	// WithTransaction runs fn as a single unit of work: every call made with the context given to
+	// fn is committed when it returns nil and rolled back when it returns an error or panics.
+	// Calls made inside an ongoing transaction join it, so services can compose.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// AfterTransaction runs fn once the outermost transaction held by ctx ends, committed or
+	// rolled back, or right away without one. Meant for what must not see uncommitted changes.
+	AfterTransaction(ctx context.Context, fn func())
+	// AfterCommit runs fn once the outermost transaction held by ctx commits, never if what fn
+	// was registered in is rolled back, or right away without a transaction
+	AfterCommit(ctx context.Context, fn func())
 	ErrRecordNotFound() error
 	MigrateModels(models ...interface{}) error
+	// Ping checks the database can still be used
+	Ping(ctx context.Context) error
+	// CheckMigrations checks the tables of every migrated model exist, failing until a migration ran
+	CheckMigrations(ctx context.Context) error
+}
+
+// Page orders the rows by a column, descending when Desc, and keeps the first Limit of them when
+// greater than 0. Filtering the ordering column by the last row of a page gives the next one.
+type Page struct {
+	OrderBy string
+	Desc    bool
+	Limit   int
 }
 
 type Filter interface {
@@ -17,3 +45,45 @@ type Filter interface {
 	GetValue() interface{}
 	GetOperand() string
 }
+
+// AnyOf matches the rows matching any of its filters, while the filters given along with it must
+// all match
+type AnyOf []Filter
+
+func (f AnyOf) GetColumnName() string {
+	return ""
+}
+
+func (f AnyOf) GetValue() interface{} {
+	return []Filter(f)
+}
+
+func (f AnyOf) GetOperand() string {
+	return "OR"
+}
+
+// Subquery, as the value of a filter, is Column of the rows of Model matching the filters, e.g.
+// to match the rows whose column is IN the ones of another table
+type Subquery struct {
+	Model   interface{}
+	Column  string
+	Filters []Filter
+}
+
+// MaxInValues is how many values a single IN filter is given at most, far below the bind
+// variables a statement can take
+const MaxInValues = 1000
+
+// Chunk splits values into consecutive slices of at most size values, so a lookup of any number
+// of them can be made with IN filters of a bounded size
+func Chunk[T any](values []T, size int) [][]T {
+	var chunks [][]T
+	for len(values) > size {
+		chunks = append(chunks, values[:size:size])
+		values = values[size:]
+	}
+	if len(values) > 0 {
+		chunks = append(chunks, values)
+	}
+	return chunks
+}
@@ -0,0 +1,14 @@
+package database_test
+
+import (
+	"mytheresa/internal/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunk(t *testing.T) {
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, database.Chunk([]int{1, 2, 3, 4, 5}, 2))
+	assert.Equal(t, [][]int{{1, 2}}, database.Chunk([]int{1, 2}, 2))
+	assert.Empty(t, database.Chunk([]int{}, 2))
+}
@@ -26,6 +26,50 @@ func (d *Database) GetWithFilters(ctx context.Context, here interface{}, filters
 	return args.Error(0)
 }
 
+func (d *Database) GetPage(ctx context.Context, here interface{}, page database.Page, filters ...database.Filter) error {
+	args := d.Called(ctx, here, page, filters)
+	return args.Error(0)
+}
+
+func (d *Database) Count(ctx context.Context, model interface{}, filters ...database.Filter) (int64, error) {
+	args := d.Called(ctx, model, filters)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (d *Database) Increment(ctx context.Context, model interface{}, column string, delta int, filters ...database.Filter) (int64, error) {
+	args := d.Called(ctx, model, column, delta, filters)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (d *Database) Delete(ctx context.Context, model interface{}, filters ...database.Filter) (int64, error) {
+	args := d.Called(ctx, model, filters)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// WithTransaction runs fn right away. Tests only need an expectation when they want to check
+// the transaction is used or make it fail, in which case fn only runs when it returns nil.
+func (d *Database) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	for _, call := range d.ExpectedCalls {
+		if call.Method == "WithTransaction" {
+			if err := d.Called(ctx, fn).Error(0); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return fn(ctx)
+}
+
+// AfterTransaction runs fn right away, as the mocked transactions are over as soon as they return
+func (d *Database) AfterTransaction(ctx context.Context, fn func()) {
+	fn()
+}
+
+// AfterCommit runs fn right away, like AfterTransaction
+func (d *Database) AfterCommit(ctx context.Context, fn func()) {
+	fn()
+}
+
 func (d *Database) ErrRecordNotFound() error {
 	args := d.Called()
 	return args.Error(0)
@@ -35,3 +79,13 @@ func (d *Database) MigrateModels(models ...interface{}) error {
 	args := d.Called(models)
 	return args.Error(0)
 }
+
+func (d *Database) Ping(ctx context.Context) error {
+	args := d.Called(ctx)
+	return args.Error(0)
+}
+
+func (d *Database) CheckMigrations(ctx context.Context) error {
+	args := d.Called(ctx)
+	return args.Error(0)
+}
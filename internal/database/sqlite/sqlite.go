@@ -2,27 +2,61 @@ package sqlite
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mytheresa/internal/database"
 	"mytheresa/internal/logger"
+	"mytheresa/internal/metrics"
+	"mytheresa/internal/tracing"
+	"os"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
 	FOREIGNKEY_TAG = "foreignKey"
 )
 
+// txKey is the context key holding the ongoing transaction
+type txKey struct{}
+
+// hooksKey is the context key holding the functions to run once the outermost transaction ends
+type hooksKey struct{}
+
+// hook runs once the outermost transaction ends, or only if it commits
+type hook struct {
+	fn           func()
+	onCommitOnly bool
+}
+
 type sqliteDB struct {
 	*gorm.DB
 	logger logger.Logger
+
+	mu       sync.Mutex
+	migrated []interface{}
+}
+
+// DSN opens the SQLite file with transactions taking the write lock as they begin. A deferred
+// transaction reading before it writes can't upgrade its lock once another one wrote meanwhile,
+// failing with "database is locked" instead of waiting for it.
+func DSN(file string) string {
+	if strings.Contains(file, "?") {
+		return file + "&_txlock=immediate"
+	}
+	return file + "?_txlock=immediate"
 }
 
 func NewSQLiteDB(db *gorm.DB, logger logger.Logger) database.Database {
 	//Initial data from problem description
-	s := &sqliteDB{db, logger}
+	s := &sqliteDB{DB: db, logger: logger}
 
 	return s
 }
@@ -33,10 +67,14 @@ func (db *sqliteDB) ErrRecordNotFound() error {
 
 func (db *sqliteDB) Save(ctx context.Context, key string, value interface{}) error {
 	t := getActualType(value)
+	ctx, span := startSpan(ctx, "save", t)
 
 	db.logger.WithField("key", key).Info(ctx, fmt.Sprintf("creating %v ", t))
 
-	err := db.Create(value).Error
+	start := time.Now()
+	err := db.conn(ctx).Create(value).Error
+	metrics.ObserveDBOperation("save", t.Name(), start, err)
+	tracing.End(span, err)
 	if err != nil {
 		db.logger.WithError(err).Error(ctx, fmt.Sprintf("error creating %v ", t))
 		return err
@@ -47,9 +85,13 @@ func (db *sqliteDB) Save(ctx context.Context, key string, value interface{}) err
 
 func (db *sqliteDB) Get(ctx context.Context, key string, here interface{}) error {
 	t := getActualType(here)
+	ctx, span := startSpan(ctx, "get", t)
 	db.logger.WithField("key", key).Info(ctx, fmt.Sprintf("getting %v ", t))
 
-	err := preloadTables(db.DB, t).First(here, key).Error
+	start := time.Now()
+	err := preloadTables(db.conn(ctx), t).First(here, key).Error
+	metrics.ObserveDBOperation("get", t.Name(), start, err)
+	tracing.End(span, err)
 	if err != nil {
 		db.logger.WithField("key", key).WithError(err).
 			Error(ctx, fmt.Sprintf("error getting %v ", t))
@@ -60,15 +102,166 @@ func (db *sqliteDB) Get(ctx context.Context, key string, here interface{}) error
 
 func (db *sqliteDB) GetWithFilters(ctx context.Context, here interface{}, filters ...database.Filter) error {
 	t := getActualType(here)
+	ctx, span := startSpan(ctx, "get_with_filters", t)
 
-	query := applyFilters(preloadTables(db.DB, t), filters...)
+	query := applyFilters(preloadTables(db.conn(ctx), t), filters...)
 
+	start := time.Now()
 	err := query.Find(here).Error
+	metrics.ObserveDBOperation("get_with_filters", t.Name(), start, err)
+	tracing.End(span, err)
 
 	db.logger.WithField("found in db", here).Info(ctx, fmt.Sprintf("getting %v ", t))
 	return err
 }
 
+func (db *sqliteDB) GetPage(ctx context.Context, here interface{}, page database.Page, filters ...database.Filter) error {
+	t := getActualType(here)
+	ctx, span := startSpan(ctx, "get_page", t)
+
+	query := applyFilters(preloadTables(db.conn(ctx), t), filters...).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: page.OrderBy}, Desc: page.Desc})
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+
+	start := time.Now()
+	err := query.Find(here).Error
+	metrics.ObserveDBOperation("get_page", t.Name(), start, err)
+	tracing.End(span, err)
+	if err != nil {
+		db.logger.WithError(err).Error(ctx, fmt.Sprintf("error getting page of %v ", t))
+	}
+	return err
+}
+
+func (db *sqliteDB) Count(ctx context.Context, model interface{}, filters ...database.Filter) (int64, error) {
+	t := getActualType(model)
+	ctx, span := startSpan(ctx, "count", t)
+
+	var count int64
+	start := time.Now()
+	err := applyFilters(db.conn(ctx).Model(model), filters...).Count(&count).Error
+	metrics.ObserveDBOperation("count", t.Name(), start, err)
+	tracing.End(span, err)
+	if err != nil {
+		db.logger.WithError(err).Error(ctx, fmt.Sprintf("error counting %v ", t))
+		return 0, err
+	}
+	return count, nil
+}
+
+// Increment adds delta to column in a single UPDATE statement, so concurrent callers never
+// lose updates. Only rows matching the filters are touched and the number of affected rows
+// is returned, which lets callers implement conditional decrements (e.g. "stock > 0").
+// The UpdatedAt of models having one is set along.
+func (db *sqliteDB) Increment(ctx context.Context, model interface{}, column string, delta int, filters ...database.Filter) (int64, error) {
+	t := getActualType(model)
+	ctx, span := startSpan(ctx, "increment", t)
+	db.logger.WithField("column", column).WithField("delta", delta).Info(ctx, fmt.Sprintf("incrementing %v ", t))
+
+	start := time.Now()
+	result := applyFilters(db.conn(ctx).Model(model), filters...).
+		Update(column, gorm.Expr(fmt.Sprintf("%s + ?", column), delta))
+	metrics.ObserveDBOperation("increment", t.Name(), start, result.Error)
+	tracing.End(span, result.Error)
+	if result.Error != nil {
+		db.logger.WithField("column", column).WithError(result.Error).
+			Error(ctx, fmt.Sprintf("error incrementing %v ", t))
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+// Delete removes the rows matching the filters and returns how many were removed, so only one
+// of several concurrent callers deleting the same row gets to act on it.
+func (db *sqliteDB) Delete(ctx context.Context, model interface{}, filters ...database.Filter) (int64, error) {
+	t := getActualType(model)
+	ctx, span := startSpan(ctx, "delete", t)
+	db.logger.WithField("filters", filters).Info(ctx, fmt.Sprintf("deleting %v ", t))
+
+	start := time.Now()
+	result := applyFilters(db.conn(ctx), filters...).Delete(model)
+	metrics.ObserveDBOperation("delete", t.Name(), start, result.Error)
+	tracing.End(span, result.Error)
+	if result.Error != nil {
+		db.logger.WithError(result.Error).Error(ctx, fmt.Sprintf("error deleting %v ", t))
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+// WithTransaction begins a transaction, or a savepoint when ctx already holds one, and hands it
+// to fn through the context. Nested failures only roll back their own savepoint.
+func (db *sqliteDB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	hooks, nested := ctx.Value(hooksKey{}).(*[]hook)
+	if !nested {
+		hooks = &[]hook{}
+		ctx = context.WithValue(ctx, hooksKey{}, hooks)
+	}
+	registered := len(*hooks)
+
+	ctx, span := tracing.Start(ctx, "sqlite transaction", semconv.DBSystemSqlite)
+	start := time.Now()
+	err := db.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+	metrics.ObserveDBOperation("transaction", "", start, err)
+	tracing.End(span, err)
+
+	// what was rolled back, the whole transaction or a savepoint, will never commit
+	if err != nil {
+		kept := (*hooks)[:registered]
+		for _, h := range (*hooks)[registered:] {
+			if !h.onCommitOnly {
+				kept = append(kept, h)
+			}
+		}
+		*hooks = kept
+	}
+	if !nested {
+		for _, h := range *hooks {
+			h.fn()
+		}
+	}
+	return err
+}
+
+func (db *sqliteDB) AfterTransaction(ctx context.Context, fn func()) {
+	db.addHook(ctx, hook{fn: fn})
+}
+
+func (db *sqliteDB) AfterCommit(ctx context.Context, fn func()) {
+	db.addHook(ctx, hook{fn: fn, onCommitOnly: true})
+}
+
+func (db *sqliteDB) addHook(ctx context.Context, h hook) {
+	if hooks, ok := ctx.Value(hooksKey{}).(*[]hook); ok {
+		*hooks = append(*hooks, h)
+		return
+	}
+	h.fn()
+}
+
+// conn returns the transaction held by ctx, if any, or the database otherwise
+func (db *sqliteDB) conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.DB
+}
+
+// startSpan begins the span of an operation on the table of the model type
+func startSpan(ctx context.Context, operation string, t reflect.Type) (context.Context, trace.Span) {
+	return tracing.Start(ctx, fmt.Sprintf("sqlite %s %s", operation, t.Name()),
+		semconv.DBSystemSqlite,
+		semconv.DBOperationName(operation),
+		semconv.DBCollectionName(t.Name()),
+	)
+}
+
 func preloadTables(query *gorm.DB, t reflect.Type) *gorm.DB {
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
@@ -85,12 +278,40 @@ func preloadTables(query *gorm.DB, t reflect.Type) *gorm.DB {
 func applyFilters(query *gorm.DB, filters ...database.Filter) *gorm.DB {
 
 	for _, filter := range filters {
+		if anyOf, ok := filter.(database.AnyOf); ok {
+			query = query.Where(anyOfCondition(query, anyOf))
+			continue
+		}
+		if subquery, ok := filter.GetValue().(database.Subquery); ok {
+			q := fmt.Sprintf("%s %s (?)", filter.GetColumnName(), filter.GetOperand())
+			query = query.Where(q, subqueryOf(query, subquery))
+			continue
+		}
 		q := fmt.Sprintf("%s %s ?", filter.GetColumnName(), filter.GetOperand())
 		query = query.Where(q, filter.GetValue())
 	}
 	return query
 }
 
+// anyOfCondition groups the filters in a single condition, matching when any of them does
+func anyOfCondition(query *gorm.DB, anyOf database.AnyOf) *gorm.DB {
+	group := query.Session(&gorm.Session{NewDB: true})
+	for i, filter := range anyOf {
+		condition := applyFilters(query.Session(&gorm.Session{NewDB: true}), filter)
+		if i == 0 {
+			group = group.Where(condition)
+		} else {
+			group = group.Or(condition)
+		}
+	}
+	return group
+}
+
+// subqueryOf returns the query selecting the column of the subquery
+func subqueryOf(query *gorm.DB, subquery database.Subquery) *gorm.DB {
+	return applyFilters(query.Session(&gorm.Session{NewDB: true}).Model(subquery.Model).Select(subquery.Column), subquery.Filters...)
+}
+
 func getActualType(val interface{}) reflect.Type {
 	t := reflect.TypeOf(val)
 
@@ -116,7 +337,59 @@ func getActualType(val interface{}) reflect.Type {
 
 func (db *sqliteDB) MigrateModels(models ...interface{}) error {
 	// Auto-migrate the models
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		models...,
 	)
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.migrated = append(db.migrated, models...)
+	return nil
+}
+
+// Ping checks the connection works and the database file is still there, since SQLite keeps
+// serving a deleted file through the handles already open
+func (db *sqliteDB) Ping(ctx context.Context) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return err
+	}
+
+	var file string
+	err = db.WithContext(ctx).Raw("SELECT file FROM pragma_database_list WHERE name = 'main'").Row().Scan(&file)
+	if err != nil {
+		return err
+	}
+	// in memory and temporary databases have no file
+	if file == "" {
+		return nil
+	}
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("database file unavailable: %w", err)
+	}
+	return nil
+}
+
+func (db *sqliteDB) CheckMigrations(ctx context.Context) error {
+	db.mu.Lock()
+	models := db.migrated
+	db.mu.Unlock()
+
+	if len(models) == 0 {
+		return errors.New("no migrations applied")
+	}
+
+	migrator := db.WithContext(ctx).Migrator()
+	for _, model := range models {
+		if !migrator.HasTable(model) {
+			return fmt.Errorf("table of %v is missing", getActualType(model))
+		}
+	}
+	return nil
 }
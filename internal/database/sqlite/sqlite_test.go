@@ -66,6 +66,13 @@ func TestNewSQLiteDB(t *testing.T) {
 	assert.NotNil(t, sqldb)
 }
 
+func TestDSN(t *testing.T) {
+	assert.Equal(t, "catalog.db?_txlock=immediate", sqlite.DSN("catalog.db"))
+	assert.Equal(t, "catalog.db?_busy_timeout=1000&_txlock=immediate", sqlite.DSN("catalog.db?_busy_timeout=1000"))
+	// a temporary database when empty
+	assert.Equal(t, "?_txlock=immediate", sqlite.DSN(""))
+}
+
 // TestSave tests the Save method of the sqliteDB struct.
 func TestSave(t *testing.T) {
 	sqliteDB := MockDB()
@@ -115,6 +122,111 @@ func TestGetWithFilters(t *testing.T) {
 	assert.Len(t, result, 1)
 }
 
+func TestGetPage(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	for _, name := range []string{"c", "a", "d", "b"} {
+		sqliteDB.Save(context.Background(), name, &dummyModel{Name: name})
+	}
+
+	var result []dummyModel
+	err := sqliteDB.GetPage(context.Background(), &result, database.Page{OrderBy: "name", Limit: 2}, NewDummyFilter(">", "a"))
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, "b", result[0].Name)
+	assert.Equal(t, "c", result[1].Name)
+
+	result = nil
+	err = sqliteDB.GetPage(context.Background(), &result, database.Page{OrderBy: "name", Desc: true})
+	assert.NoError(t, err)
+	assert.Len(t, result, 4)
+	assert.Equal(t, "d", result[0].Name)
+}
+
+func TestCount(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	for _, name := range []string{"c", "a", "d", "b"} {
+		sqliteDB.Save(context.Background(), name, &dummyModel{Name: name})
+	}
+
+	count, err := sqliteDB.Count(context.Background(), &dummyModel{}, NewDummyFilter(">", "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	count, err = sqliteDB.Count(context.Background(), &dummyModel{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+}
+
+func TestGetWithFilters_AnyOfAndSubquery(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		sqliteDB.Save(context.Background(), name, &dummyModel{Name: name})
+	}
+
+	// the filters given along with AnyOf must match too
+	var result []dummyModel
+	err := sqliteDB.GetWithFilters(context.Background(), &result,
+		database.AnyOf{NewDummyFilter("=", "a"), NewDummyFilter("=", "d")}, NewDummyFilter("!=", "a"))
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "d", result[0].Name)
+
+	result = nil
+	err = sqliteDB.GetWithFilters(context.Background(), &result, NewDummyFilter("IN", database.Subquery{
+		Model:   &dummyModel{},
+		Column:  "name",
+		Filters: []database.Filter{NewDummyFilter(">", "b")},
+	}))
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+}
+
+func TestIncrement(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	sqliteDB.Save(context.Background(), "test_key", &dummyModel{Name: "test"})
+
+	affected, err := sqliteDB.Increment(context.Background(), &dummyModel{}, "id", 9, NewDummyFilter("=", "test"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	var result dummyModel
+	err = sqliteDB.Get(context.Background(), "10", &result)
+	assert.NoError(t, err)
+
+	affected, err = sqliteDB.Increment(context.Background(), &dummyModel{}, "id", 1, NewDummyFilter("=", "missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+}
+
+func TestDelete(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	sqliteDB.Save(context.Background(), "test_key", &dummyModel{Name: "test"})
+	sqliteDB.Save(context.Background(), "test_key", &dummyModel{Name: "other"})
+
+	affected, err := sqliteDB.Delete(context.Background(), &dummyModel{}, NewDummyFilter("=", "test"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	affected, err = sqliteDB.Delete(context.Background(), &dummyModel{}, NewDummyFilter("=", "test"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+
+	var result []dummyModel
+	err = sqliteDB.GetWithFilters(context.Background(), &result)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+}
+
 func TestErrRecordNotFound(t *testing.T) {
 	sqliteDB := MockDB()
 	defer os.Remove(dbname)
@@ -122,3 +234,184 @@ func TestErrRecordNotFound(t *testing.T) {
 	err := sqliteDB.ErrRecordNotFound()
 	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
 }
+
+func TestWithTransaction_Commit(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	err := sqliteDB.WithTransaction(context.Background(), func(ctx context.Context) error {
+		if err := sqliteDB.Save(ctx, "test_key", &dummyModel{Name: "test"}); err != nil {
+			return err
+		}
+		_, err := sqliteDB.Increment(ctx, &dummyModel{}, "id", 9, NewDummyFilter("=", "test"))
+		return err
+	})
+	assert.NoError(t, err)
+
+	var result dummyModel
+	err = sqliteDB.Get(context.Background(), "10", &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", result.Name)
+}
+
+func TestWithTransaction_Rollback(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	sqliteDB.Save(context.Background(), "test_key", &dummyModel{Name: "kept"})
+
+	failure := errors.New("something failed")
+	err := sqliteDB.WithTransaction(context.Background(), func(ctx context.Context) error {
+		sqliteDB.Save(ctx, "test_key", &dummyModel{Name: "test"})
+		sqliteDB.Delete(ctx, &dummyModel{}, NewDummyFilter("=", "kept"))
+
+		// the transaction sees its own changes
+		var inside []dummyModel
+		sqliteDB.GetWithFilters(ctx, &inside)
+		assert.Len(t, inside, 1)
+		assert.Equal(t, "test", inside[0].Name)
+
+		return failure
+	})
+	assert.Equal(t, failure, err)
+
+	var result []dummyModel
+	err = sqliteDB.GetWithFilters(context.Background(), &result)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "kept", result[0].Name)
+}
+
+func TestWithTransaction_RollbackOnPanic(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	assert.Panics(t, func() {
+		sqliteDB.WithTransaction(context.Background(), func(ctx context.Context) error {
+			sqliteDB.Save(ctx, "test_key", &dummyModel{Name: "test"})
+			panic("something failed")
+		})
+	})
+
+	var result []dummyModel
+	err := sqliteDB.GetWithFilters(context.Background(), &result)
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestWithTransaction_NestedRollbackOnlyUndoesItsChanges(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	err := sqliteDB.WithTransaction(context.Background(), func(ctx context.Context) error {
+		sqliteDB.Save(ctx, "test_key", &dummyModel{Name: "outer"})
+
+		nestedErr := sqliteDB.WithTransaction(ctx, func(ctx context.Context) error {
+			sqliteDB.Save(ctx, "test_key", &dummyModel{Name: "inner"})
+			return errors.New("inner failed")
+		})
+		assert.Error(t, nestedErr)
+
+		return nil
+	})
+	assert.NoError(t, err)
+
+	var result []dummyModel
+	err = sqliteDB.GetWithFilters(context.Background(), &result)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "outer", result[0].Name)
+}
+
+func TestAfterTransaction(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	// without a transaction it runs right away
+	ran := false
+	sqliteDB.AfterTransaction(context.Background(), func() { ran = true })
+	assert.True(t, ran)
+
+	for _, failure := range []error{nil, errors.New("something failed")} {
+		var seen []string
+		err := sqliteDB.WithTransaction(context.Background(), func(ctx context.Context) error {
+			sqliteDB.Save(ctx, "test_key", &dummyModel{Name: "test"})
+			_ = sqliteDB.WithTransaction(ctx, func(ctx context.Context) error {
+				sqliteDB.AfterTransaction(ctx, func() {
+					// the outer transaction is over, so its outcome is visible
+					var result []dummyModel
+					sqliteDB.GetWithFilters(context.Background(), &result)
+					seen = append(seen, "nested")
+					assert.Equal(t, failure == nil, len(result) > 0)
+				})
+				return nil
+			})
+			sqliteDB.AfterTransaction(ctx, func() { seen = append(seen, "outer") })
+			assert.Empty(t, seen)
+			return failure
+		})
+		assert.Equal(t, failure, err)
+		assert.Equal(t, []string{"nested", "outer"}, seen)
+
+		sqliteDB.Delete(context.Background(), &dummyModel{}, NewDummyFilter("=", "test"))
+	}
+}
+
+func TestAfterCommit(t *testing.T) {
+	sqliteDB := MockDB()
+	defer os.Remove(dbname)
+
+	// without a transaction it runs right away
+	ran := false
+	sqliteDB.AfterCommit(context.Background(), func() { ran = true })
+	assert.True(t, ran)
+
+	for _, failure := range []error{nil, errors.New("something failed")} {
+		var seen []string
+		_ = sqliteDB.WithTransaction(context.Background(), func(ctx context.Context) error {
+			sqliteDB.AfterCommit(ctx, func() { seen = append(seen, "outer") })
+			_ = sqliteDB.WithTransaction(ctx, func(ctx context.Context) error {
+				sqliteDB.AfterCommit(ctx, func() { seen = append(seen, "committed savepoint") })
+				return nil
+			})
+			_ = sqliteDB.WithTransaction(ctx, func(ctx context.Context) error {
+				sqliteDB.AfterCommit(ctx, func() { seen = append(seen, "rolled back savepoint") })
+				sqliteDB.AfterTransaction(ctx, func() { seen = append(seen, "ended") })
+				return errors.New("savepoint failed")
+			})
+			assert.Empty(t, seen)
+			return failure
+		})
+
+		if failure == nil {
+			assert.Equal(t, []string{"outer", "committed savepoint", "ended"}, seen)
+		} else {
+			assert.Equal(t, []string{"ended"}, seen)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	sqldb := MockDB()
+	defer os.Remove(dbname)
+
+	assert.NoError(t, sqldb.Ping(context.Background()))
+
+	// the open connection keeps working, but the data would be lost
+	assert.NoError(t, os.Remove(dbname))
+	assert.ErrorContains(t, sqldb.Ping(context.Background()), "database file unavailable")
+}
+
+func TestCheckMigrations(t *testing.T) {
+	db, _ := gorm.Open(gormsqlite.Open(dbname), &gorm.Config{})
+	defer os.Remove(dbname)
+	sqldb := sqlite.NewSQLiteDB(db, &loggermocks.NoopLogger{})
+
+	assert.EqualError(t, sqldb.CheckMigrations(context.Background()), "no migrations applied")
+
+	assert.NoError(t, sqldb.MigrateModels(&dummyModel{}))
+	assert.NoError(t, sqldb.CheckMigrations(context.Background()))
+
+	assert.NoError(t, db.Migrator().DropTable(&dummyModel{}))
+	assert.EqualError(t, sqldb.CheckMigrations(context.Background()), "table of sqlite_test.dummyModel is missing")
+}
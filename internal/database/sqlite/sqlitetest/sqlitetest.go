@@ -0,0 +1,29 @@
+// Package sqlitetest opens real SQLite databases for the tests needing more than a mocked one.
+package sqlitetest
+
+import (
+	"mytheresa/internal/database"
+	"mytheresa/internal/database/sqlite"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"path/filepath"
+	"testing"
+
+	gormsqlite "gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// NewDB opens a database in a file removed once the test ends, with the models given migrated
+func NewDB(tb testing.TB, models ...interface{}) database.Database {
+	tb.Helper()
+
+	db, err := gorm.Open(gormsqlite.Open(sqlite.DSN(filepath.Join(tb.TempDir(), "test.db"))), &gorm.Config{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	sqlDB := sqlite.NewSQLiteDB(db, &loggermocks.NoopLogger{})
+	if err := sqlDB.MigrateModels(models...); err != nil {
+		tb.Fatal(err)
+	}
+	return sqlDB
+}
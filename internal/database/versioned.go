@@ -0,0 +1,15 @@
+package database
+
+import "time"
+
+// Versioned tracks the changes of a model, embed it in the ones served with HTTP caching.
+// Version starts at 1 and is meant to go up with every update, UpdatedAt is kept by the database.
+type Versioned struct {
+	Version   int       `gorm:"not null;default:1" json:"version" xml:"version" example:"1"`
+	UpdatedAt time.Time `json:"updated_at" xml:"updated_at"`
+}
+
+// LastModified is when the model last changed
+func (v Versioned) LastModified() time.Time {
+	return v.UpdatedAt
+}
@@ -0,0 +1,21 @@
+package health
+
+import (
+	"mytheresa/internal/response"
+	"net/http"
+)
+
+// Live answers as long as the process serves requests, whatever the state of its dependencies
+func (h *Health) Live(w http.ResponseWriter, r *http.Request) {
+	response.RespondWithData(w, http.StatusOK, Response{Status: Up})
+}
+
+// Ready answers 503 when any check fails, telling which ones did
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	res := h.Check(r.Context())
+	code := http.StatusOK
+	if res.Status == Down {
+		code = http.StatusServiceUnavailable
+	}
+	response.RespondWithData(w, code, res)
+}
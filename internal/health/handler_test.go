@@ -0,0 +1,45 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mytheresa/internal/health"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLive(t *testing.T) {
+	h := health.New(time.Second)
+	h.Register("database", health.CheckerFunc(func(ctx context.Context) error { return errors.New("down") }))
+
+	rec := httptest.NewRecorder()
+	h.Live(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"up"}`, rec.Body.String())
+}
+
+func TestReady(t *testing.T) {
+	seeded := health.NewFlag("initial data not inserted yet")
+	h := health.New(time.Second)
+	h.Register("seed", seeded)
+
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"down","checks":{"seed":{"status":"down","error":"initial data not inserted yet"}}}`, rec.Body.String())
+
+	seeded.Set()
+	rec = httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res health.Response
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, health.Up, res.Status)
+}
@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Status string
+
+const (
+	Up   Status = "up"
+	Down Status = "down"
+)
+
+// Checker probes a dependency the service needs to serve traffic, e.g. the database
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets a function, like a method value, be a Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// CheckResponse is the outcome of a single check
+type CheckResponse struct {
+	Status Status `json:"status" example:"down"`
+	Error  string `json:"error,omitempty" example:"no migrations applied"`
+}
+
+// Response is the outcome of every check, Up only when all of them are
+type Response struct {
+	Status Status                   `json:"status" example:"up"`
+	Checks map[string]CheckResponse `json:"checks,omitempty"`
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Health keeps the checkers packages register and runs them to tell if the service is ready
+type Health struct {
+	timeout time.Duration
+
+	mu           sync.RWMutex
+	checkers     []namedChecker
+	shuttingDown atomic.Bool
+}
+
+// New returns a Health giving up on every check after timeout
+func New(timeout time.Duration) *Health {
+	return &Health{timeout: timeout}
+}
+
+// Register adds a check to the readiness ones
+func (h *Health) Register(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkers = append(h.checkers, namedChecker{name: name, checker: checker})
+}
+
+// Shutdown makes the service not ready from now on, so no new traffic is sent to it while the
+// requests in flight finish
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Check runs every check at the same time and returns their outcome
+func (h *Health) Check(ctx context.Context) Response {
+	h.mu.RLock()
+	checkers := h.checkers
+	h.mu.RUnlock()
+
+	response := Response{Status: Up, Checks: map[string]CheckResponse{}}
+	if h.shuttingDown.Load() {
+		response.Status = Down
+		response.Checks["shutdown"] = CheckResponse{Status: Down, Error: "shutting down"}
+	}
+
+	results := make([]CheckResponse, len(checkers))
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func(i int, c namedChecker) {
+			defer wg.Done()
+			results[i] = h.run(ctx, c.checker)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, c := range checkers {
+		response.Checks[c.name] = results[i]
+		if results[i].Status == Down {
+			response.Status = Down
+		}
+	}
+	return response
+}
+
+// run gives up on checks not honouring the timeout of their context too
+func (h *Health) run(ctx context.Context, checker Checker) CheckResponse {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CheckResponse{Status: Down, Error: "timed out"}
+	}
+	if err != nil {
+		return CheckResponse{Status: Down, Error: err.Error()}
+	}
+	return CheckResponse{Status: Up}
+}
+
+// Flag is a check failing with its message until Set, e.g. for start up tasks
+type Flag struct {
+	message string
+	set     atomic.Bool
+}
+
+func NewFlag(message string) *Flag {
+	return &Flag{message: message}
+}
+
+func (f *Flag) Set() {
+	f.set.Store(true)
+}
+
+func (f *Flag) Check(ctx context.Context) error {
+	if !f.set.Load() {
+		return errors.New(f.message)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"mytheresa/internal/health"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck_AllUp(t *testing.T) {
+	h := health.New(time.Second)
+	h.Register("database", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+	h.Register("migrations", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+
+	assert.Equal(t, health.Response{
+		Status: health.Up,
+		Checks: map[string]health.CheckResponse{
+			"database":   {Status: health.Up},
+			"migrations": {Status: health.Up},
+		},
+	}, h.Check(context.Background()))
+}
+
+func TestCheck_Failing(t *testing.T) {
+	h := health.New(time.Second)
+	h.Register("database", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+	h.Register("migrations", health.CheckerFunc(func(ctx context.Context) error { return errors.New("no migrations applied") }))
+
+	res := h.Check(context.Background())
+
+	assert.Equal(t, health.Down, res.Status)
+	assert.Equal(t, health.CheckResponse{Status: health.Up}, res.Checks["database"])
+	assert.Equal(t, health.CheckResponse{Status: health.Down, Error: "no migrations applied"}, res.Checks["migrations"])
+}
+
+func TestCheck_Timeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	h := health.New(10 * time.Millisecond)
+	// ignores its context, the check still gives up on it
+	h.Register("stuck", health.CheckerFunc(func(ctx context.Context) error {
+		<-block
+		return nil
+	}))
+	h.Register("slow", health.CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	start := time.Now()
+	res := h.Check(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, health.CheckResponse{Status: health.Down, Error: "timed out"}, res.Checks["stuck"])
+	assert.Equal(t, health.CheckResponse{Status: health.Down, Error: "timed out"}, res.Checks["slow"])
+}
+
+func TestCheck_Shutdown(t *testing.T) {
+	h := health.New(time.Second)
+	h.Register("database", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+	assert.Equal(t, health.Up, h.Check(context.Background()).Status)
+
+	h.Shutdown()
+
+	res := h.Check(context.Background())
+	assert.Equal(t, health.Down, res.Status)
+	assert.Equal(t, health.CheckResponse{Status: health.Down, Error: "shutting down"}, res.Checks["shutdown"])
+}
+
+func TestFlag(t *testing.T) {
+	f := health.NewFlag("initial data not inserted yet")
+	assert.EqualError(t, f.Check(context.Background()), "initial data not inserted yet")
+
+	f.Set()
+	assert.NoError(t, f.Check(context.Background()))
+}
@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mytheresa/internal/response"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag identifies the representation negotiated for w of what the versions tell apart. Being
+// computed from the versions of the data shown, rather than from the body, it's known before the
+// response is built, and versions must change with everything the response shows.
+func ETag(w http.ResponseWriter, versions ...string) string {
+	h := sha256.New()
+	h.Write([]byte(response.Representation(w)))
+	for _, v := range versions {
+		h.Write([]byte{0})
+		h.Write([]byte(v))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// Respond writes data in the format negotiated along with its validators: the etag, given by ETag,
+// and the Last-Modified time when not zero. Clients already holding the same representation get
+// a 304 instead, without data being encoded.
+func Respond(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time, data interface{}) error {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	body, contentType, err := response.Encode(w, data)
+	if err != nil {
+		w.Header().Del("ETag")
+		w.Header().Del("Last-Modified")
+		return response.RespondWithError(w, err)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(body)
+	return err
+}
+
+// notModified evaluates the conditional headers as RFC 9110 does: If-Modified-Since is only
+// looked at without If-None-Match, as times have a one second precision
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// Latest returns the most recent of the times, zero when there are none
+func Latest(times ...time.Time) time.Time {
+	var latest time.Time
+	for _, t := range times {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
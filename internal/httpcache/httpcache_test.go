@@ -0,0 +1,158 @@
+package httpcache_test
+
+import (
+	"mytheresa/internal/httpcache"
+	"mytheresa/internal/requestid"
+	"mytheresa/internal/response"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+// respond answers with data, versioned by its name
+func respond(t *testing.T, r *http.Request, lastModified time.Time, data item) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	assert.NoError(t, httpcache.Respond(w, r, httpcache.ETag(w, data.Name), lastModified, data))
+	return w
+}
+
+func TestRespond_OK(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	w := respond(t, httptest.NewRequest(http.MethodGet, "/", nil), modified, item{Name: "boots"})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"name":"boots"}`, w.Body.String())
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, w.Header().Get("ETag"))
+	assert.Equal(t, "Fri, 01 Mar 2024 10:00:00 GMT", w.Header().Get("Last-Modified"))
+}
+
+func TestETag_FollowsVersions(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	boots := httpcache.ETag(w, "000001@1", "000002@1")
+	again := httpcache.ETag(w, "000001@1", "000002@1")
+	changed := httpcache.ETag(w, "000001@1", "000002@2")
+	joined := httpcache.ETag(w, "000001@1000002@1")
+
+	assert.Equal(t, boots, again)
+	assert.NotEqual(t, boots, changed)
+	assert.NotEqual(t, boots, joined)
+}
+
+func TestRespond_NotModifiedIsNotEncoded(t *testing.T) {
+	w := httptest.NewRecorder()
+	etag := httpcache.ETag(w, "v1")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", etag)
+
+	// channels can't be encoded, it would be a 500 otherwise
+	assert.NoError(t, httpcache.Respond(w, r, etag, time.Time{}, make(chan int)))
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestRespond_NoLastModified(t *testing.T) {
+	w := respond(t, httptest.NewRequest(http.MethodGet, "/", nil), time.Time{}, item{})
+
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+}
+
+func TestRespond_IfNoneMatch(t *testing.T) {
+	etag := respond(t, httptest.NewRequest(http.MethodGet, "/", nil), time.Time{}, item{Name: "boots"}).Header().Get("ETag")
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"same", etag, http.StatusNotModified},
+		{"weak", "W/" + etag, http.StatusNotModified},
+		{"one of many", `"other", ` + etag, http.StatusNotModified},
+		{"any", "*", http.StatusNotModified},
+		{"changed", `"other"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			w := respond(t, r, time.Time{}, item{Name: "boots"})
+
+			assert.Equal(t, tt.want, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			if tt.want == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRespond_IfModifiedSince(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 10, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name            string
+		ifModifiedSince string
+		want            int
+	}{
+		{"same second", "Fri, 01 Mar 2024 10:00:00 GMT", http.StatusNotModified},
+		{"later", "Sat, 02 Mar 2024 10:00:00 GMT", http.StatusNotModified},
+		{"earlier", "Thu, 29 Feb 2024 10:00:00 GMT", http.StatusOK},
+		{"invalid", "yesterday", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+
+			assert.Equal(t, tt.want, respond(t, r, modified, item{}).Code)
+		})
+	}
+}
+
+func TestRespond_IfNoneMatchWinsOverIfModifiedSince(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", `"other"`)
+	r.Header.Set("If-Modified-Since", "Sat, 02 Mar 2024 10:00:00 GMT")
+
+	w := respond(t, r, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), item{})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLatest(t *testing.T) {
+	first := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	assert.Equal(t, second, httpcache.Latest(first, second, time.Time{}))
+	assert.True(t, httpcache.Latest().IsZero())
+}
+
+func TestETag_FormatsApart(t *testing.T) {
+	etagFor := func(headers map[string]string) string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		requestid.Middleware(response.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, httpcache.Respond(w, r, httpcache.ETag(w, "boots"), time.Time{}, item{Name: "boots"}))
+		}))).ServeHTTP(w, r)
+		return w.Header().Get("ETag")
+	}
+	v2 := map[string]string{"X-API-Version": "2"}
+
+	// envelopes hold the request ID, but only the versions make the ETag
+	assert.Equal(t, etagFor(v2), etagFor(v2))
+	assert.NotEqual(t, etagFor(nil), etagFor(v2))
+	assert.NotEqual(t, etagFor(nil), etagFor(map[string]string{"Accept": "application/xml"}))
+}
@@ -0,0 +1,44 @@
+package httpcache
+
+import "net/http"
+
+// Policy sets the Cache-Control header of the successful and not modified responses of the
+// handler, errors are never cached. An empty policy sets nothing.
+func Policy(cacheControl string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if cacheControl == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&policyWriter{ResponseWriter: w, cacheControl: cacheControl}, r)
+		})
+	}
+}
+
+type policyWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	wroteHeader  bool
+}
+
+func (w *policyWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code == http.StatusOK || code == http.StatusNotModified {
+			w.Header().Set("Cache-Control", w.cacheControl)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *policyWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *policyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,41 @@
+package httpcache_test
+
+import (
+	"mytheresa/internal/httpcache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"ok", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("{}")) }, "public, max-age=30"},
+		{"not modified", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotModified) }, "public, max-age=30"},
+		{"not found", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }, ""},
+		{"error", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			httpcache.Policy("public, max-age=30")(tt.handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.want, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestPolicy_Empty(t *testing.T) {
+	w := httptest.NewRecorder()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	httpcache.Policy("")(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+}
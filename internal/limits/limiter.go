@@ -0,0 +1,97 @@
+package limits
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets of the clients gone quiet are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per key: every key starts with burst tokens, each request takes one
+// and they refill at rate per second up to burst again
+type Limiter struct {
+	rate  float64
+	burst float64
+	clock func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter(rate float64, burst int, clock func() time.Time) *Limiter {
+	return &Limiter{
+		rate:      rate,
+		burst:     float64(burst),
+		clock:     clock,
+		buckets:   map[string]*bucket{},
+		lastSweep: clock(),
+	}
+}
+
+// Allow takes a token of key, when there is none it returns how long until there is
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, l.wait(b.tokens)
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Wait tells how long until key has a token, without taking it
+func (l *Limiter) Wait(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return 0
+	}
+	if tokens := l.refill(b, l.clock()); tokens < 1 {
+		return l.wait(tokens)
+	}
+	return 0
+}
+
+// wait is how long a bucket with tokens takes to have one
+func (l *Limiter) wait(tokens float64) time.Duration {
+	return time.Duration(math.Ceil((1 - tokens) / l.rate * float64(time.Second)))
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+}
+
+// sweep drops the buckets refilled by now, they are no different from new ones
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package limits_test
+
+import (
+	"mytheresa/internal/limits"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestLimiter_Burst(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := limits.NewLimiter(2, 3, clock.Now)
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok, "request %d", i)
+	}
+	ok, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// other keys have their own bucket
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+}
+
+func TestLimiter_Refill(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := limits.NewLimiter(2, 3, clock.Now)
+	for i := 0; i < 3; i++ {
+		l.Allow("a")
+	}
+
+	clock.Advance(250 * time.Millisecond)
+	ok, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 250*time.Millisecond, wait)
+
+	clock.Advance(250 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+
+	// never more than the burst
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok, "request %d", i)
+	}
+	ok, _ = l.Allow("a")
+	assert.False(t, ok)
+}
+
+func TestLimiter_SweepKeepsLimitedKeys(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	// a token every 200 seconds
+	l := limits.NewLimiter(0.005, 1, clock.Now)
+	l.Allow("a")
+
+	// the sweep runs, but "a" is still short of its token
+	clock.Advance(2 * time.Minute)
+	ok, _ := l.Allow("b")
+	assert.True(t, ok)
+	ok, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 80*time.Second, wait)
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := limits.NewLimiter(2, 1, clock.Now)
+
+	assert.Zero(t, l.Wait("a"))
+	l.Allow("a")
+	assert.Equal(t, 500*time.Millisecond, l.Wait("a"))
+	// waiting takes no token
+	assert.Equal(t, 500*time.Millisecond, l.Wait("a"))
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Zero(t, l.Wait("a"))
+	ok, _ := l.Allow("a")
+	assert.True(t, ok)
+}
@@ -0,0 +1,103 @@
+package limits
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/config"
+	"mytheresa/internal/requestctx"
+	"mytheresa/internal/response"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Group is a set of routes sharing their limits
+type Group string
+
+const (
+	Read  Group = "read"
+	Write Group = "write"
+	Bulk  Group = "bulk"
+)
+
+type groupLimits struct {
+	// limiter is nil when the group has no rate limit
+	limiter *Limiter
+	maxBody int64
+}
+
+// Middleware applies to every request the limits of its group, as told by groupOf. Callers over
+// the rate get a 429 with a Retry-After header and bodies over the size a 413. Authenticated
+// callers are limited on their own, anonymous ones by client IP.
+func Middleware(conf config.LimitsConfig, groupOf func(r *http.Request) Group, clock func() time.Time) func(http.Handler) http.Handler {
+	groups := map[Group]groupLimits{}
+	for group, limits := range map[Group]config.RouteLimits{Read: conf.Read, Write: conf.Write, Bulk: conf.Bulk} {
+		gl := groupLimits{maxBody: limits.MaxBodyBytes}
+		if limits.RequestsPerSecond > 0 {
+			gl.limiter = NewLimiter(limits.RequestsPerSecond, limits.Burst, clock)
+		}
+		groups[group] = gl
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gl := groups[groupOf(r)]
+
+			if gl.limiter != nil {
+				if ok, wait := gl.limiter.Allow(CallerKey(r.Context())); !ok {
+					RespondTooManyRequests(w, wait)
+					return
+				}
+			}
+
+			body, err := limitBody(w, r, gl.maxBody)
+			if err != nil {
+				response.RespondWithError(w, err)
+				return
+			}
+			r.Body = body
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RespondTooManyRequests answers 429, telling in Retry-After when to try again
+func RespondTooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	response.RespondWithError(w, apierror.TooManyRequests(fmt.Sprintf("Too many requests, retry in %s", time.Duration(seconds)*time.Second)))
+}
+
+// CallerKey is the bucket of the caller of ctx, its principal when authenticated or else its IP
+func CallerKey(ctx context.Context) string {
+	if caller, ok := requestctx.Caller(ctx); ok {
+		return "caller:" + caller
+	}
+	return "ip:" + requestctx.ClientIP(ctx)
+}
+
+// limitBody rejects bodies declared bigger than max upfront. The ones of unknown length are read
+// here, so handlers never see a partial body and answer 400 instead of 413.
+func limitBody(w http.ResponseWriter, r *http.Request, max int64) (io.ReadCloser, error) {
+	tooLarge := apierror.PayloadTooLarge(fmt.Sprintf("Body larger than %d bytes", max))
+	if r.ContentLength > max {
+		return nil, tooLarge
+	}
+	if r.ContentLength >= 0 {
+		return http.MaxBytesReader(w, r.Body, max), nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		return nil, apierror.BadRequest("Error reading body")
+	}
+	if int64(len(data)) > max {
+		return nil, tooLarge
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,127 @@
+package limits_test
+
+import (
+	"encoding/json"
+	"io"
+	"mytheresa/internal/config"
+	"mytheresa/internal/limits"
+	"mytheresa/internal/requestctx"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testLimits = config.LimitsConfig{
+	Read:  config.RouteLimits{RequestsPerSecond: 0, MaxBodyBytes: 10},
+	Write: config.RouteLimits{RequestsPerSecond: 1, Burst: 2, MaxBodyBytes: 10},
+	Bulk:  config.RouteLimits{RequestsPerSecond: 0.1, Burst: 1, MaxBodyBytes: 100},
+}
+
+// newHandler echoes the body, the group is taken from the path
+func newHandler(clock *fakeClock) http.Handler {
+	groupOf := func(r *http.Request) limits.Group {
+		return limits.Group(strings.TrimPrefix(r.URL.Path, "/"))
+	}
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	return limits.Middleware(testLimits, groupOf, clock.Now)(echo)
+}
+
+func send(h http.Handler, req *http.Request, caller string) *httptest.ResponseRecorder {
+	ctx := requestctx.WithClientIP(req.Context(), "192.0.2.10")
+	if caller != "" {
+		ctx = requestctx.WithCaller(ctx, caller)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req.WithContext(ctx))
+	return rec
+}
+
+func message(t *testing.T, rec *httptest.ResponseRecorder) string {
+	var body map[string]string
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	return body["message"]
+}
+
+func TestMiddleware_RateLimit(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := newHandler(clock)
+
+	for i := 0; i < 2; i++ {
+		rec := send(h, httptest.NewRequest(http.MethodPost, "/write", nil), "")
+		assert.Equal(t, http.StatusOK, rec.Code, "request %d", i)
+	}
+
+	rec := send(h, httptest.NewRequest(http.MethodPost, "/write", nil), "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "Too many requests, retry in 1s", message(t, rec))
+
+	// authenticated callers are limited on their own, even from the same IP
+	rec = send(h, httptest.NewRequest(http.MethodPost, "/write", nil), "ci")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// and so are the groups
+	rec = send(h, httptest.NewRequest(http.MethodPost, "/bulk", nil), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = send(h, httptest.NewRequest(http.MethodPost, "/bulk", nil), "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+
+	clock.Advance(time.Second)
+	rec = send(h, httptest.NewRequest(http.MethodPost, "/write", nil), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestMiddleware_NoRateLimit(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := newHandler(clock)
+
+	for i := 0; i < 100; i++ {
+		rec := send(h, httptest.NewRequest(http.MethodGet, "/read", nil), "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestMiddleware_BodySize(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := newHandler(clock)
+
+	tests := map[string]struct {
+		path    string
+		body    string
+		chunked bool
+		code    int
+	}{
+		"within the limit":            {"/read", "0123456789", false, http.StatusOK},
+		"declared over the limit":     {"/read", "0123456789a", false, http.StatusRequestEntityTooLarge},
+		"chunked within the limit":    {"/read", "0123456789", true, http.StatusOK},
+		"chunked over the limit":      {"/read", "0123456789a", true, http.StatusRequestEntityTooLarge},
+		"bigger limit of the group":   {"/bulk", strings.Repeat("a", 100), false, http.StatusOK},
+		"over the limit of the group": {"/bulk", strings.Repeat("a", 101), true, http.StatusRequestEntityTooLarge},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+
+			rec := send(h, req, name)
+
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.code == http.StatusOK {
+				assert.Equal(t, tt.body, rec.Body.String())
+			} else {
+				assert.Contains(t, message(t, rec), "Body larger than")
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package logger
 
 import (
 	"context"
+	"mytheresa/internal/requestctx"
 	"runtime/debug"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -81,13 +83,23 @@ func (l *logger) WithError(err error) Logger {
 	}
 }
 
-// injectTracing enters request ID, if any
+// injectTracing enters the request ID, actor and client IP and the trace and span IDs of the
+// current span, if any
 func (l *logger) injectTracing(ctx context.Context) *zap.SugaredLogger {
 	//add our request id if present
-	rid := ctx.Value("request_id")
 	entry := l.internal
-	if rid != nil {
-		entry = entry.With("request_id", ctx.Value("request_id"))
+	if rid := requestctx.RequestID(ctx); rid != "" {
+		entry = entry.With("request_id", rid)
+	}
+	if caller, ok := requestctx.Caller(ctx); ok {
+		entry = entry.With("actor", caller)
+	}
+	if ip := requestctx.ClientIP(ctx); ip != "" {
+		entry = entry.With("client_ip", ip)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry = entry.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
 	}
 
 	return entry
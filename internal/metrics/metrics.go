@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "mytheresa"
+
+// Registry holds every metric of the service along with the Go runtime and process ones
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route template, method and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time spent handling HTTP requests, by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_operation_duration_seconds",
+		Help:      "Time spent on database operations, by operation, model and outcome.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "model", "outcome"})
+
+	discountEvaluations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "discount_evaluations_total",
+		Help:      "Discounts evaluated against a product or basket, by discount kind and whether they applied.",
+	}, []string{"kind", "applied"})
+
+	events = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_published_total",
+		Help:      "Catalog change events published, by event type.",
+	}, []string{"type"})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Attempts to deliver an event to a webhook, by whether the receiver accepted it.",
+	}, []string{"outcome"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Lookups of the in-process caches, by cache and whether they were a hit or a miss.",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbDuration,
+		discountEvaluations,
+		cacheLookups,
+		events,
+		webhookDeliveries,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveDBOperation records how long a database operation started at start took
+func ObserveDBOperation(operation, model string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	dbDuration.WithLabelValues(operation, model, outcome).Observe(time.Since(start).Seconds())
+}
+
+// CountDiscountEvaluation counts a discount of the kind checked against a product or a basket
+func CountDiscountEvaluation(kind string, applied bool) {
+	discountEvaluations.WithLabelValues(kind, strconv.FormatBool(applied)).Inc()
+}
+
+// CountCacheLookup counts a lookup of the named cache, either found in it or not
+func CountCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// CountEvent counts an event of the type published
+func CountEvent(eventType string) {
+	events.WithLabelValues(eventType).Inc()
+}
+
+// CountWebhookDelivery counts an attempt to deliver an event to a webhook
+func CountWebhookDelivery(delivered bool) {
+	outcome := "failed"
+	if delivered {
+		outcome = "delivered"
+	}
+	webhookDeliveries.WithLabelValues(outcome).Inc()
+}
@@ -0,0 +1,86 @@
+package metrics_test
+
+import (
+	"errors"
+	"io"
+	"mytheresa/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape returns the metrics exposed in the Prometheus text format
+func scrape(t *testing.T) string {
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"))
+
+	body, err := io.ReadAll(w.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestMiddleware_LabelsRouteTemplates(t *testing.T) {
+	r := mux.NewRouter()
+	r.NotFoundHandler = metrics.Middleware(http.NotFoundHandler())
+	r.Use(metrics.Middleware)
+	r.HandleFunc("/test/product/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods(http.MethodGet)
+
+	for _, path := range []string{"/test/product/000001", "/test/product/000002", "/test/product/missing", "/test/nowhere/000001"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrape(t)
+	assert.Contains(t, out, `mytheresa_http_requests_total{code="200",method="GET",route="/test/product/{id}"} 2`)
+	assert.Contains(t, out, `mytheresa_http_requests_total{code="404",method="GET",route="/test/product/{id}"} 1`)
+	assert.Contains(t, out, `mytheresa_http_requests_total{code="404",method="GET",route="unmatched"} 1`)
+	assert.Contains(t, out, `mytheresa_http_request_duration_seconds_count{method="GET",route="/test/product/{id}"} 3`)
+	assert.NotContains(t, out, "000001")
+}
+
+func TestObserveDBOperation(t *testing.T) {
+	metrics.ObserveDBOperation("save", "TestModel", time.Now(), nil)
+	metrics.ObserveDBOperation("save", "TestModel", time.Now(), errors.New("some DB error"))
+	metrics.ObserveDBOperation("save", "TestModel", time.Now(), nil)
+
+	out := scrape(t)
+	assert.Contains(t, out, `mytheresa_db_operation_duration_seconds_count{model="TestModel",operation="save",outcome="ok"} 2`)
+	assert.Contains(t, out, `mytheresa_db_operation_duration_seconds_count{model="TestModel",operation="save",outcome="error"} 1`)
+}
+
+func TestCountDiscountEvaluation(t *testing.T) {
+	metrics.CountDiscountEvaluation("test_kind", true)
+	metrics.CountDiscountEvaluation("test_kind", false)
+	metrics.CountDiscountEvaluation("test_kind", false)
+
+	out := scrape(t)
+	assert.Contains(t, out, `mytheresa_discount_evaluations_total{applied="true",kind="test_kind"} 1`)
+	assert.Contains(t, out, `mytheresa_discount_evaluations_total{applied="false",kind="test_kind"} 2`)
+}
+
+func TestCountCacheLookup(t *testing.T) {
+	metrics.CountCacheLookup("test_cache", true)
+	metrics.CountCacheLookup("test_cache", true)
+	metrics.CountCacheLookup("test_cache", false)
+
+	out := scrape(t)
+	assert.Contains(t, out, `mytheresa_cache_lookups_total{cache="test_cache",result="hit"} 2`)
+	assert.Contains(t, out, `mytheresa_cache_lookups_total{cache="test_cache",result="miss"} 1`)
+}
+
+func TestHandler_RuntimeMetrics(t *testing.T) {
+	out := scrape(t)
+
+	assert.Contains(t, out, "go_goroutines")
+	assert.Contains(t, out, "go_memstats_heap_alloc_bytes")
+}
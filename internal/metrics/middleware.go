@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"mytheresa/internal/response"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// unmatchedRoute labels the requests not matching any route, so raw paths never become labels
+const unmatchedRoute = "unmatched"
+
+// Middleware counts the requests and times them by route template, e.g. /v1/product/{id}
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := response.NewStatusRecorder(w)
+
+		next.ServeHTTP(sr, r)
+
+		route := unmatchedRoute
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(sr.Status)).Inc()
+		httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
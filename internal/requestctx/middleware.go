@@ -0,0 +1,62 @@
+package requestctx
+
+import (
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/response"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const CurrencyHeader = "X-Currency"
+
+// Middleware fills the metadata of every request: the locale from Accept-Language, the currency
+// and the client IP. Its ID is given beforehand by requestid.Middleware.
+// Prices are only given in the currency of the catalog, requests asking for another one with
+// X-Currency get a 400.
+func Middleware(currency string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return middleware(currency, next)
+	}
+}
+
+func middleware(catalogCurrency string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithClientIP(r.Context(), clientIP(r))
+		ctx = WithCurrency(ctx, catalogCurrency)
+		if locale, ok := preferredLocale(r.Header.Get("Accept-Language")); ok {
+			ctx = WithLocale(ctx, locale)
+		}
+		if currency := r.Header.Get(CurrencyHeader); currency != "" {
+			currency = strings.ToUpper(strings.TrimSpace(currency))
+			if currency != catalogCurrency {
+				response.RespondWithError(w, apierror.BadRequest(fmt.Sprintf("Unsupported currency %s", currency)))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// preferredLocale returns the first language of an Accept-Language header, e.g. de-de out of
+// "de-DE,de;q=0.9,en;q=0.8". Clients list them by preference, so weights are not compared.
+func preferredLocale(header string) (string, bool) {
+	first := strings.TrimSpace(strings.Split(header, ",")[0])
+	tag := strings.TrimSpace(strings.Split(first, ";")[0])
+	if tag == "" || tag == "*" {
+		return "", false
+	}
+	return strings.ToLower(tag), true
+}
+
+// clientIP is the address of the peer. Headers like X-Forwarded-For are not trusted, any client
+// can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,62 @@
+package requestctx_test
+
+import (
+	"encoding/json"
+	"mytheresa/internal/requestctx"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(headers map[string]string) (*httptest.ResponseRecorder, requestctx.Metadata) {
+	var md requestctx.Metadata
+	h := requestctx.Middleware("EUR")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md = requestctx.FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+	req.RemoteAddr = "192.0.2.10:51234"
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, md
+}
+
+func TestMiddleware_Metadata(t *testing.T) {
+	tests := map[string]struct {
+		headers  map[string]string
+		locale   string
+		currency string
+	}{
+		"defaults":           {nil, "en", "EUR"},
+		"preferred locale":   {map[string]string{"Accept-Language": "de-DE,de;q=0.9,en;q=0.8"}, "de-de", "EUR"},
+		"weighted locale":    {map[string]string{"Accept-Language": "fr;q=0.7"}, "fr", "EUR"},
+		"any locale":         {map[string]string{"Accept-Language": "*"}, "en", "EUR"},
+		"lowercase currency": {map[string]string{"X-Currency": "eur"}, "en", "EUR"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec, md := serve(tt.headers)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.locale, md.Locale)
+			assert.Equal(t, tt.currency, md.Currency)
+			assert.Equal(t, "192.0.2.10", md.ClientIP)
+		})
+	}
+}
+
+func TestMiddleware_UnsupportedCurrency(t *testing.T) {
+	rec, md := serve(map[string]string{"X-Currency": "USD"})
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, md.RequestID)
+	var body map[string]string
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "Unsupported currency USD", body["message"])
+}
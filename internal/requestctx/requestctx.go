@@ -0,0 +1,96 @@
+package requestctx
+
+import (
+	"context"
+	"mytheresa/internal/requestid"
+)
+
+const (
+	DefaultLocale   = "en"
+	DefaultCurrency = "EUR"
+)
+
+// key is the type of the context keys of this package, so no other package can collide with them
+type key int
+
+const (
+	callerKey key = iota
+	localeKey
+	currencyKey
+	clientIPKey
+)
+
+// Metadata is everything known about the request a context belongs to
+type Metadata struct {
+	RequestID string
+	// Caller is the subject of the authenticated principal, empty for anonymous requests
+	Caller   string
+	Locale   string
+	Currency string
+	ClientIP string
+}
+
+// FromContext returns the metadata of the request, with the defaults for what was not set
+func FromContext(ctx context.Context) Metadata {
+	caller, _ := Caller(ctx)
+	return Metadata{
+		RequestID: RequestID(ctx),
+		Caller:    caller,
+		Locale:    Locale(ctx),
+		Currency:  Currency(ctx),
+		ClientIP:  ClientIP(ctx),
+	}
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return requestid.NewContext(ctx, id)
+}
+
+// RequestID returns the ID of the request, empty outside of one
+func RequestID(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
+
+func WithCaller(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, callerKey, subject)
+}
+
+// Caller returns who made the request, if it was authenticated
+func Caller(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(callerKey).(string)
+	return subject, ok
+}
+
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+// Locale returns the language the caller prefers, DefaultLocale when unknown
+func Locale(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey).(string); ok {
+		return locale
+	}
+	return DefaultLocale
+}
+
+func WithCurrency(ctx context.Context, currency string) context.Context {
+	return context.WithValue(ctx, currencyKey, currency)
+}
+
+// Currency returns the currency prices are given in, DefaultCurrency when not chosen
+func Currency(ctx context.Context) string {
+	if currency, ok := ctx.Value(currencyKey).(string); ok {
+		return currency
+	}
+	return DefaultCurrency
+}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// ClientIP returns the address the request came from, empty outside of one
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
@@ -0,0 +1,39 @@
+package requestctx_test
+
+import (
+	"context"
+	"mytheresa/internal/requestctx"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext_Defaults(t *testing.T) {
+	md := requestctx.FromContext(context.Background())
+
+	assert.Equal(t, requestctx.Metadata{Locale: "en", Currency: "EUR"}, md)
+	_, ok := requestctx.Caller(context.Background())
+	assert.False(t, ok)
+}
+
+func TestFromContext(t *testing.T) {
+	ctx := requestctx.WithRequestID(context.Background(), "r1")
+	ctx = requestctx.WithCaller(ctx, "ci")
+	ctx = requestctx.WithLocale(ctx, "de-de")
+	ctx = requestctx.WithCurrency(ctx, "EUR")
+	ctx = requestctx.WithClientIP(ctx, "10.0.0.1")
+
+	assert.Equal(t, requestctx.Metadata{
+		RequestID: "r1",
+		Caller:    "ci",
+		Locale:    "de-de",
+		Currency:  "EUR",
+		ClientIP:  "10.0.0.1",
+	}, requestctx.FromContext(ctx))
+}
+
+func TestKeys_DoNotCollideWithStrings(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "request_id", "r1")
+
+	assert.Empty(t, requestctx.RequestID(ctx))
+}
@@ -0,0 +1,39 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Header carries the ID of a request, sent by the client or generated and echoed back
+const Header = "X-Request-Id"
+
+// key is the type of the context key of this package, so no other package can collide with it
+type key struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// FromContext returns the ID of the request, empty outside of one
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
+
+// Middleware gives every request its ID, taken from the X-Request-Id header or generated, and
+// echoes it back. It runs ahead of the rest, so every response and log of the request has it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if id == "" {
+			// generate new version 4 uuid
+			id = uuid.New().String()
+		}
+		w.Header().Set(Header, id)
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
@@ -0,0 +1,41 @@
+package requestid_test
+
+import (
+	"context"
+	"mytheresa/internal/requestid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(headers map[string]string) (*httptest.ResponseRecorder, string) {
+	var id string
+	h := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = requestid.FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, id
+}
+
+func TestMiddleware(t *testing.T) {
+	rec, id := serve(map[string]string{"X-Request-Id": "r1"})
+	assert.Equal(t, "r1", id)
+	assert.Equal(t, "r1", rec.Header().Get("X-Request-Id"))
+
+	rec, id = serve(nil)
+	assert.Len(t, id, 36)
+	assert.Equal(t, id, rec.Header().Get("X-Request-Id"))
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Empty(t, requestid.FromContext(context.Background()))
+	assert.Equal(t, "r1", requestid.FromContext(requestid.NewContext(context.Background(), "r1")))
+}
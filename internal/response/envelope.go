@@ -0,0 +1,147 @@
+package response
+
+import (
+	"mime"
+	"mytheresa/internal/requestid"
+	"net/http"
+	"strings"
+)
+
+const (
+	// EnvelopeMediaType, accepted by a client, opts in to the responses wrapped in an Envelope
+	EnvelopeMediaType = "application/vnd.mytheresa.v2+json"
+	// ProblemMediaType, accepted by a client, opts in to errors as RFC 7807 problem details
+	ProblemMediaType = "application/problem+json"
+	// VersionHeader set to 2 opts in to the Envelope too, for clients not setting Accept
+	VersionHeader = "X-API-Version"
+
+	// envelopeContentType is sent along with every Envelope, however the client opted in to it
+	envelopeContentType = EnvelopeMediaType + "; charset=UTF-8"
+)
+
+// Envelope wraps the data of the clients opting in to it along with the metadata of the response
+type Envelope struct {
+	Data   interface{} `json:"data"`
+	Meta   Meta        `json:"meta"`
+	Errors []Problem   `json:"errors,omitempty"`
+}
+
+type Meta struct {
+	RequestID  string      `json:"request_id,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination tells how much of a list was returned
+type Pagination struct {
+	Limit int `json:"limit"`
+	Total int `json:"total"`
+}
+
+// Problem is an error described as in RFC 7807. Type is always about:blank, so Title is the
+// status text and Detail tells what went wrong.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// page is a list along with its pagination, only shown in envelopes
+type page struct {
+	items      interface{}
+	pagination Pagination
+}
+
+// NewPage returns items, limit out of total, to be responded with
+func NewPage(items interface{}, limit, total int) interface{} {
+	return page{items: items, pagination: Pagination{Limit: limit, Total: total}}
+}
+
+// format is how a client asked to get its responses
+type format struct {
+	envelope  bool
+	problem   bool
+	encodings []encoding
+	instance  string
+	requestID string
+}
+
+// Middleware negotiates the format of the responses: JSON, XML or CSV as preferred in Accept,
+// with bare data and {"message": ...} errors unless the client opts in to envelopes or problem
+// details. The format is found by the functions of this package through the ResponseWriter,
+// whatever wraps it afterwards, along with the request ID given by requestid.Middleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// shared caches must keep every format apart
+		w.Header().Add("Vary", "Accept, "+VersionHeader)
+		next.ServeHTTP(&formatWriter{ResponseWriter: w, format: negotiate(r)}, r)
+	})
+}
+
+func negotiate(r *http.Request) format {
+	f := format{
+		envelope:  strings.TrimSpace(r.Header.Get(VersionHeader)) == "2",
+		encodings: acceptedEncodings(r.Header.Values("Accept")),
+		instance:  r.URL.Path,
+		requestID: requestid.FromContext(r.Context()),
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+			switch mediaType {
+			case EnvelopeMediaType:
+				f.envelope = true
+			case ProblemMediaType:
+				f.problem = true
+			}
+		}
+	}
+	return f
+}
+
+type formatWriter struct {
+	http.ResponseWriter
+	format format
+}
+
+// Unwrap gives http.ResponseController access to the wrapped writer
+func (w *formatWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Enveloped tells whether the data written to w is wrapped in an Envelope
+func Enveloped(w http.ResponseWriter) bool {
+	return formatOf(w).envelope
+}
+
+// Representation tells apart the formats negotiated, e.g. for caches keeping them apart
+func Representation(w http.ResponseWriter) string {
+	f := formatOf(w)
+	encodings := make([]string, 0, len(f.encodings))
+	for _, e := range f.encodings {
+		encodings = append(encodings, string(e))
+	}
+	if f.envelope {
+		return "envelope;" + strings.Join(encodings, ",")
+	}
+	return strings.Join(encodings, ",")
+}
+
+// formatOf returns the format negotiated for the response written to w
+func formatOf(w http.ResponseWriter) format {
+	for {
+		if fw, ok := w.(*formatWriter); ok {
+			return fw.format
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return format{encodings: []encoding{JSON}}
+		}
+		w = u.Unwrap()
+	}
+}
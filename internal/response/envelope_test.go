@@ -0,0 +1,133 @@
+package response_test
+
+import (
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/requestid"
+	"mytheresa/internal/response"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	SKU string `json:"sku"`
+}
+
+// serve runs the handler behind the middleware, as the router does, with the headers given
+func serve(h http.HandlerFunc, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+	r.Header.Set("X-Request-Id", "req-1")
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	requestid.Middleware(response.Middleware(h)).ServeHTTP(w, r)
+	return w
+}
+
+func respondPage(w http.ResponseWriter, r *http.Request) {
+	_ = response.RespondWithData(w, http.StatusOK, response.NewPage([]item{{SKU: "000001"}}, 5, 12))
+}
+
+func respondNotFound(w http.ResponseWriter, r *http.Request) {
+	_ = response.RespondWithError(w, apierror.NotFound("Product not found"))
+}
+
+func TestMiddleware_V1ByDefault(t *testing.T) {
+	w := serve(respondPage, map[string]string{"Accept": "application/json"})
+	assert.JSONEq(t, `[{"sku":"000001"}]`, w.Body.String())
+	assert.Equal(t, "Accept, X-API-Version", w.Header().Get("Vary"))
+
+	w = serve(respondNotFound, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"message":"Product not found"}`, w.Body.String())
+}
+
+func TestMiddleware_Envelope(t *testing.T) {
+	for name, headers := range map[string]map[string]string{
+		"accept":  {"Accept": "application/json;q=0.5, application/vnd.mytheresa.v2+json"},
+		"version": {"X-API-Version": "2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := serve(respondPage, headers)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/vnd.mytheresa.v2+json; charset=UTF-8", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, `{
+				"data": [{"sku":"000001"}],
+				"meta": {"request_id": "req-1", "pagination": {"limit": 5, "total": 12}}
+			}`, w.Body.String())
+
+			w = serve(respondNotFound, headers)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, "application/vnd.mytheresa.v2+json; charset=UTF-8", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, `{
+				"data": null,
+				"meta": {"request_id": "req-1"},
+				"errors": [{"type": "about:blank", "title": "Not Found", "status": 404, "detail": "Product not found", "instance": "/v1/products"}]
+			}`, w.Body.String())
+		})
+	}
+}
+
+func TestMiddleware_EnvelopeWithoutPagination(t *testing.T) {
+	w := serve(func(w http.ResponseWriter, r *http.Request) {
+		_ = response.RespondWithData(w, http.StatusCreated, item{SKU: "000001"})
+	}, map[string]string{"X-API-Version": "2"})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"data": {"sku":"000001"}, "meta": {"request_id": "req-1"}}`, w.Body.String())
+}
+
+func TestMiddleware_ProblemDetails(t *testing.T) {
+	for name, headers := range map[string]map[string]string{
+		"v1": {"Accept": "application/problem+json"},
+		"v2": {"Accept": "application/vnd.mytheresa.v2+json, application/problem+json"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := serve(respondNotFound, headers)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			var problem response.Problem
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+			assert.Equal(t, response.Problem{
+				Type:      "about:blank",
+				Title:     "Not Found",
+				Status:    http.StatusNotFound,
+				Detail:    "Product not found",
+				Instance:  "/v1/products",
+				RequestID: "req-1",
+			}, problem)
+		})
+	}
+
+	// successful responses keep their shape
+	w := serve(respondPage, map[string]string{"Accept": "application/problem+json"})
+	assert.JSONEq(t, `[{"sku":"000001"}]`, w.Body.String())
+}
+
+func TestMiddleware_ThroughOtherWriters(t *testing.T) {
+	w := serve(func(w http.ResponseWriter, r *http.Request) {
+		// wrapped by another middleware after the negotiation
+		respondPage(response.NewStatusRecorder(w), r)
+	}, map[string]string{"X-API-Version": "2"})
+
+	assert.JSONEq(t, `{
+		"data": [{"sku":"000001"}],
+		"meta": {"request_id": "req-1", "pagination": {"limit": 5, "total": 12}}
+	}`, w.Body.String())
+}
+
+func TestRespondWithError_GenericErrorAsProblem(t *testing.T) {
+	w := serve(func(w http.ResponseWriter, r *http.Request) {
+		_ = response.RespondWithError(w, assert.AnError)
+	}, map[string]string{"Accept": "application/problem+json"})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"detail":"Internal Server Error"`)
+}
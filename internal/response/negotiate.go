@@ -0,0 +1,248 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// encoding is a representation the responses can be given in
+type encoding string
+
+const (
+	JSON encoding = "json"
+	XML  encoding = "xml"
+	CSV  encoding = "csv"
+)
+
+var contentTypes = map[encoding]string{
+	JSON: "application/json; charset=UTF-8",
+	XML:  "application/xml; charset=UTF-8",
+	CSV:  "text/csv; charset=UTF-8",
+}
+
+// mediaTypes maps what clients accept to the encodings fitting it, by preference
+var mediaTypes = map[string][]encoding{
+	"*/*":              {JSON, XML, CSV},
+	"application/*":    {JSON, XML},
+	"application/json": {JSON},
+	EnvelopeMediaType:  {JSON},
+	ProblemMediaType:   {JSON},
+	"application/xml":  {XML},
+	"text/*":           {CSV, XML},
+	"text/xml":         {XML},
+	"text/csv":         {CSV},
+}
+
+// CSVRecord is implemented by the items of the lists that can be given as CSV, one row each
+type CSVRecord interface {
+	CSVHeader() []string
+	CSVRecord() []string
+}
+
+// xmlList is the root element of the lists given as XML. Every item is an element named after
+// its XMLName field, or item when it has none.
+type xmlList struct {
+	items reflect.Value
+}
+
+func (l xmlList) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = "items"
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for i := 0; i < l.items.Len(); i++ {
+		item := l.items.Index(i).Interface()
+		var err error
+		if hasXMLName(item) {
+			err = e.Encode(item)
+		} else {
+			err = e.EncodeElement(item, xml.StartElement{Name: xml.Name{Local: "item"}})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// weighted are the encodings of an accepted media range with its q value
+type weighted struct {
+	encodings []encoding
+	q         float64
+}
+
+// acceptedEncodings returns the encodings the Accept header values allow, most preferred first.
+// JSON is assumed without any, and none are returned when nothing the client accepts is supported.
+// When the most preferred ranges name no supported type, as with the text/html browsers ask for
+// first, JSON comes first whenever it is accepted at all rather than what a wildcard or a less
+// preferred range would give.
+func acceptedEncodings(accept []string) []encoding {
+	var ranges []weighted
+	topQ, topSupported := 0.0, false
+	for _, header := range accept {
+		for _, mediaRange := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			if q <= 0 {
+				continue
+			}
+			encodings, ok := mediaTypes[mediaType]
+			supported := ok && mediaType != "*/*"
+			if q > topQ {
+				topQ, topSupported = q, supported
+			} else if q == topQ {
+				topSupported = topSupported || supported
+			}
+			if ok {
+				ranges = append(ranges, weighted{encodings: encodings, q: q})
+			}
+		}
+	}
+	if len(ranges) == 0 && !hasMediaRanges(accept) {
+		return []encoding{JSON}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	encodings := []encoding{}
+	seen := map[encoding]bool{}
+	if !topSupported && accepts(ranges, JSON) {
+		seen[JSON] = true
+		encodings = append(encodings, JSON)
+	}
+	for _, r := range ranges {
+		for _, e := range r.encodings {
+			if !seen[e] {
+				seen[e] = true
+				encodings = append(encodings, e)
+			}
+		}
+	}
+	return encodings
+}
+
+func accepts(ranges []weighted, e encoding) bool {
+	for _, r := range ranges {
+		for _, accepted := range r.encodings {
+			if accepted == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasMediaRanges(accept []string) bool {
+	for _, header := range accept {
+		if strings.TrimSpace(header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// encode returns the body of data in the first of the encodings able to represent it, false when
+// none can
+func encode(data interface{}, encodings []encoding) ([]byte, encoding, bool) {
+	for _, e := range encodings {
+		var body []byte
+		var err error
+		switch e {
+		case JSON:
+			body, err = encodeJSON(data)
+		case XML:
+			body, err = encodeXML(data)
+		case CSV:
+			body, err = encodeCSV(data)
+		}
+		if err == nil {
+			return body, e, true
+		}
+	}
+	return nil, "", false
+}
+
+func encodeJSON(data interface{}) ([]byte, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(data)
+	return body.Bytes(), err
+}
+
+func hasXMLName(item interface{}) bool {
+	t := reflect.TypeOf(item)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return false
+	}
+	_, ok := t.FieldByName("XMLName")
+	return ok
+}
+
+func encodeXML(data interface{}) ([]byte, error) {
+	if v := reflect.ValueOf(data); v.Kind() == reflect.Slice {
+		data = xmlList{items: v}
+	}
+	body, err := xml.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// encodeCSV writes lists of CSVRecord items, the header taken from their type. Lists of
+// interfaces are only known to be CSVRecord items when they are not empty.
+func encodeCSV(data interface{}) ([]byte, error) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice {
+		return nil, errors.New("only lists can be given as CSV")
+	}
+
+	var header []string
+	if elem := v.Type().Elem(); elem.Kind() == reflect.Struct {
+		if zero, ok := reflect.Zero(elem).Interface().(CSVRecord); ok {
+			header = zero.CSVHeader()
+		}
+	}
+	records := make([]CSVRecord, v.Len())
+	for i := range records {
+		record, ok := v.Index(i).Interface().(CSVRecord)
+		if !ok {
+			return nil, errors.New("list items have no CSV record")
+		}
+		records[i] = record
+	}
+	if header == nil && len(records) > 0 {
+		header = records[0].CSVHeader()
+	}
+	if header == nil && v.Type().Elem().Kind() != reflect.Interface {
+		return nil, errors.New("list items have no CSV record")
+	}
+
+	var body bytes.Buffer
+	cw := csv.NewWriter(&body)
+	if header != nil {
+		_ = cw.Write(header)
+	}
+	for _, record := range records {
+		_ = cw.Write(record.CSVRecord())
+	}
+	cw.Flush()
+	return body.Bytes(), cw.Error()
+}
@@ -0,0 +1,131 @@
+package response_test
+
+import (
+	"encoding/xml"
+	"mytheresa/internal/response"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type row struct {
+	XMLName xml.Name `json:"-" xml:"row"`
+	SKU     string   `json:"sku" xml:"sku"`
+	Price   int      `json:"price" xml:"price"`
+}
+
+func (r row) CSVHeader() []string {
+	return []string{"sku", "price"}
+}
+
+func (r row) CSVRecord() []string {
+	return []string{r.SKU, "1000"}
+}
+
+func respondRows(w http.ResponseWriter, r *http.Request) {
+	_ = response.RespondWithData(w, http.StatusOK, []row{{SKU: "000001", Price: 1000}})
+}
+
+func respondRow(w http.ResponseWriter, r *http.Request) {
+	_ = response.RespondWithData(w, http.StatusOK, row{SKU: "000001", Price: 1000})
+}
+
+func TestNegotiate_JSONByDefault(t *testing.T) {
+	for _, accept := range []string{"", "*/*", "application/json", "application/*"} {
+		t.Run(accept, func(t *testing.T) {
+			w := serve(respondRows, map[string]string{"Accept": accept})
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, `[{"sku":"000001","price":1000}]`, w.Body.String())
+		})
+	}
+}
+
+func TestNegotiate_XML(t *testing.T) {
+	for _, accept := range []string{"application/xml", "text/xml", "application/json;q=0.5, application/xml"} {
+		t.Run(accept, func(t *testing.T) {
+			w := serve(respondRows, map[string]string{"Accept": accept})
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/xml; charset=UTF-8", w.Header().Get("Content-Type"))
+			assert.Equal(t, xml.Header+`<items><row><sku>000001</sku><price>1000</price></row></items>`, w.Body.String())
+		})
+	}
+
+	w := serve(respondRow, map[string]string{"Accept": "application/xml"})
+	assert.Equal(t, xml.Header+`<row><sku>000001</sku><price>1000</price></row>`, w.Body.String())
+}
+
+func TestNegotiate_XMLItemsWithoutName(t *testing.T) {
+	w := serve(respondPage, map[string]string{"Accept": "application/xml"})
+
+	assert.Equal(t, xml.Header+`<items><item><SKU>000001</SKU></item></items>`, w.Body.String())
+}
+
+func TestNegotiate_CSV(t *testing.T) {
+	w := serve(respondRows, map[string]string{"Accept": "text/csv"})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "sku,price\n000001,1000\n", w.Body.String())
+}
+
+func TestNegotiate_EmptyCSV(t *testing.T) {
+	w := serve(func(w http.ResponseWriter, r *http.Request) {
+		_ = response.RespondWithData(w, http.StatusOK, []row{})
+	}, map[string]string{"Accept": "text/csv"})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "sku,price\n", w.Body.String())
+}
+
+func TestNegotiate_NotAcceptable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		handler http.HandlerFunc
+		accept  string
+	}{
+		"unsupported":          {respondRows, "text/html"},
+		"csv of a single item": {respondRow, "text/csv"},
+		"csv without records":  {respondPage, "text/csv"},
+		"refused":              {respondRows, "application/json;q=0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := serve(tc.handler, map[string]string{"Accept": tc.accept})
+
+			assert.Equal(t, http.StatusNotAcceptable, w.Code)
+			assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), "Acceptable formats")
+		})
+	}
+}
+
+func TestNegotiate_FallsBackToTheNextAccepted(t *testing.T) {
+	w := serve(respondRow, map[string]string{"Accept": "text/csv, application/xml;q=0.8"})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/xml; charset=UTF-8", w.Header().Get("Content-Type"))
+}
+
+func TestNegotiate_BrowsersGetJSON(t *testing.T) {
+	for _, accept := range []string{
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		"text/html, */*;q=0.8",
+	} {
+		t.Run(accept, func(t *testing.T) {
+			w := serve(respondRows, map[string]string{"Accept": accept})
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestNegotiate_XMLErrors(t *testing.T) {
+	w := serve(respondNotFound, map[string]string{"Accept": "application/xml"})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/xml; charset=UTF-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, xml.Header+`<error><message>Product not found</message></error>`, w.Body.String())
+}
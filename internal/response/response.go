@@ -2,22 +2,104 @@ package response
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"mytheresa/internal/apierror"
 	"net/http"
 )
 
+// RespondWithData writes data in the format negotiated, answering 406 when it has none the client
+// accepts
 func RespondWithData(w http.ResponseWriter, statusCode int, data interface{}) error {
+	body, contentType, err := Encode(w, data)
+	if err != nil {
+		return RespondWithError(w, err)
+	}
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(statusCode)
-	return json.NewEncoder(w).Encode(data)
+	_, err = w.Write(body)
+	return err
+}
+
+// Encode returns the body of data in the format negotiated for w along with its content type, or
+// a 406 ApiError. Meant for handlers writing their responses on their own.
+func Encode(w http.ResponseWriter, data interface{}) ([]byte, string, error) {
+	f := formatOf(w)
+	if f.envelope {
+		body, err := encodeJSON(Body(w, data))
+		return body, envelopeContentType, err
+	}
+
+	body, e, ok := encode(Data(data), f.encodings)
+	if !ok {
+		return nil, "", apierror.NotAcceptable("Acceptable formats are application/json, application/xml and, for lists, text/csv")
+	}
+	return body, contentTypes[e], nil
 }
 
 func RespondWithError(w http.ResponseWriter, err error) error {
 	apierr, ok := err.(*apierror.ApiError)
-	if ok {
+	if !ok {
+		apierr = apierror.InternalServerError("Internal Server Error").(*apierror.ApiError)
+	}
+
+	f := formatOf(w)
+	if !f.envelope && !f.problem {
+		// errors have no CSV representation, so they are given as JSON to CSV clients
+		if len(f.encodings) > 0 && f.encodings[0] == XML {
+			w.Header().Set("Content-Type", contentTypes[XML])
+			w.WriteHeader(apierr.Code())
+			_, _ = w.Write([]byte(xml.Header))
+			return xml.NewEncoder(w).Encode(apierr)
+		}
+		w.Header().Set("Content-Type", contentTypes[JSON])
 		w.WriteHeader(apierr.Code())
 		return json.NewEncoder(w).Encode(apierr)
 	}
 
-	w.WriteHeader(http.StatusInternalServerError)
-	return json.NewEncoder(w).Encode(apierror.InternalServerError("Internal Server Error"))
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(apierr.Code()),
+		Status:   apierr.Code(),
+		Detail:   apierr.Message,
+		Instance: f.instance,
+	}
+	if f.problem {
+		problem.RequestID = f.requestID
+		w.Header().Set("Content-Type", ProblemMediaType)
+		w.WriteHeader(apierr.Code())
+		return json.NewEncoder(w).Encode(problem)
+	}
+
+	w.Header().Set("Content-Type", envelopeContentType)
+	w.WriteHeader(apierr.Code())
+	return json.NewEncoder(w).Encode(Envelope{
+		Meta:   Meta{RequestID: f.requestID},
+		Errors: []Problem{problem},
+	})
+}
+
+// Body returns what to encode for data in the format negotiated for w, for handlers writing
+// their responses on their own
+func Body(w http.ResponseWriter, data interface{}) interface{} {
+	f := formatOf(w)
+	if !f.envelope {
+		return Data(data)
+	}
+
+	envelope := Envelope{
+		Data: Data(data),
+		Meta: Meta{RequestID: f.requestID},
+	}
+	if p, ok := data.(page); ok {
+		envelope.Meta.Pagination = &p.pagination
+	}
+	return envelope
+}
+
+// Data returns the data itself, without the pagination of a page
+func Data(data interface{}) interface{} {
+	if p, ok := data.(page); ok {
+		return p.items
+	}
+	return data
 }
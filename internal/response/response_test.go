@@ -46,3 +46,15 @@ func TestRespondWithError_GenericErrorSent(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.Equal(t, "{\"message\":\"Internal Server Error\"}\n", w.Body.String())
 }
+
+func TestStatusRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	sr := response.NewStatusRecorder(w)
+	assert.Equal(t, http.StatusOK, sr.Status)
+
+	_ = response.RespondWithError(sr, apierror.NotFound("not found"))
+
+	assert.Equal(t, http.StatusNotFound, sr.Status)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, w, sr.Unwrap())
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"fmt"
+	"mytheresa/internal/response"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware continues the trace of the traceparent header, or starts one, with a server span
+// around the handler named after the route template, e.g. GET /v1/product/{id}
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, fmt.Sprintf("%s %s", r.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		sr := response.NewStatusRecorder(w)
+		next.ServeHTTP(sr, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sr.Status))
+		if sr.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sr.Status))
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"mytheresa/internal/config"
+	"mytheresa/internal/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// recordSpans installs a provider keeping the spans in memory, the returned function flushes
+// and returns them
+func recordSpans(t *testing.T, conf config.TracingConfig) func() tracetest.SpanStubs {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewTracerProvider(conf, exporter)
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = tp.Shutdown(context.Background())
+	})
+
+	return func() tracetest.SpanStubs {
+		assert.NoError(t, tp.ForceFlush(context.Background()))
+		return exporter.GetSpans()
+	}
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func newRouter(handler http.HandlerFunc) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
+	r.HandleFunc("/test/product/{id}", handler)
+	return r
+}
+
+func TestMiddleware_ContinuesTraceparent(t *testing.T) {
+	spans := recordSpans(t, config.TracingConfig{ServiceName: "test", SampleRatio: 1})
+
+	r := newRouter(func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "product.GetProduct")
+		span.End()
+		w.WriteHeader(http.StatusNotFound)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/test/product/000001", nil)
+	req.Header.Set("traceparent", traceparent)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := spans()
+	assert.Len(t, got, 2)
+	child, server := got[0], got[1]
+
+	assert.Equal(t, "GET /test/product/{id}", server.Name)
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.True(t, server.Parent.IsRemote())
+	assert.Equal(t, "/test/product/{id}", attributes(server)["http.route"].AsString())
+	assert.Equal(t, int64(http.StatusNotFound), attributes(server)["http.response.status_code"].AsInt64())
+	assert.Equal(t, codes.Unset, server.Status.Code)
+
+	assert.Equal(t, "product.GetProduct", child.Name)
+	assert.Equal(t, server.SpanContext.SpanID(), child.Parent.SpanID())
+	assert.Equal(t, server.SpanContext.TraceID(), child.SpanContext.TraceID())
+}
+
+func TestMiddleware_NewTraceAndServerErrors(t *testing.T) {
+	spans := recordSpans(t, config.TracingConfig{ServiceName: "test", SampleRatio: 1})
+
+	r := newRouter(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/test/product/000001", nil))
+
+	got := spans()
+	assert.Len(t, got, 1)
+	assert.False(t, got[0].Parent.IsValid())
+	assert.Equal(t, "POST /test/product/{id}", got[0].Name)
+	assert.Equal(t, codes.Error, got[0].Status.Code)
+}
+
+func TestNewTracerProvider_FollowsParentSampling(t *testing.T) {
+	spans := recordSpans(t, config.TracingConfig{ServiceName: "test", SampleRatio: 0})
+
+	r := newRouter(func(w http.ResponseWriter, r *http.Request) {})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/product/000001", nil))
+	assert.Empty(t, spans())
+
+	req := httptest.NewRequest(http.MethodGet, "/test/product/000001", nil)
+	req.Header.Set("traceparent", traceparent)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Len(t, spans(), 1)
+}
+
+func TestEnd_RecordsError(t *testing.T) {
+	spans := recordSpans(t, config.TracingConfig{ServiceName: "test", SampleRatio: 1})
+
+	_, ok := tracing.Start(context.Background(), "ok")
+	tracing.End(ok, nil)
+	_, failed := tracing.Start(context.Background(), "failed", attribute.String("sku", "000001"))
+	tracing.End(failed, errors.New("some DB error"))
+
+	got := spans()
+	assert.Len(t, got, 2)
+	assert.Equal(t, codes.Unset, got[0].Status.Code)
+	assert.Equal(t, codes.Error, got[1].Status.Code)
+	assert.Equal(t, "some DB error", got[1].Status.Description)
+	assert.Len(t, got[1].Events, 1)
+	assert.Equal(t, "000001", attributes(got[1])["sku"].AsString())
+}
+
+func TestSetup_WithoutExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	shutdown, err := tracing.Setup(context.Background(), config.TracingConfig{ServiceName: "test", SampleRatio: 1})
+	assert.NoError(t, err)
+
+	// IDs are still generated, so they reach the logs and downstream services
+	ctx, span := tracing.Start(context.Background(), "no exporter")
+	assert.True(t, trace.SpanContextFromContext(ctx).IsValid())
+	span.End()
+
+	assert.NoError(t, shutdown(context.Background()))
+}
@@ -2,70 +2,180 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	grpctransport "mytheresa/grpc"
 	transport "mytheresa/http"
+	"mytheresa/internal/auth"
 	"mytheresa/internal/config"
 	"mytheresa/internal/database/sqlite"
+	"mytheresa/internal/health"
 	"mytheresa/internal/logger"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/audit"
+	"mytheresa/pkg/cart"
+	"mytheresa/pkg/catalog"
 	"mytheresa/pkg/category"
+	"mytheresa/pkg/coupon"
 	"mytheresa/pkg/discount"
+	"mytheresa/pkg/events"
+	"mytheresa/pkg/inventory"
+	"mytheresa/pkg/pricehistory"
 	"mytheresa/pkg/product"
+	"mytheresa/pkg/webhook"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"time"
 
+	"google.golang.org/grpc"
 	gormsqlite "gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
 	_ "mytheresa/docs"
 )
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key granting roles, configured with AUTH_API_KEYS
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT signed with HS256 or RS256 sent as "Bearer <token>", with its roles in the roles claim
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
 
-	conf := config.New()
+	conf, err := config.Load("mytheresa", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	l := logger.NewLogger("mytheresa")
 	defer l.Sync()
 
-	// Cleaning DB for a fresh start everytime
-	_ = os.Remove(conf.DbFile)
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 
-	db, err := gorm.Open(gormsqlite.Open(conf.DbFile), &gorm.Config{})
+	db, err := gorm.Open(gormsqlite.Open(sqlite.DSN(conf.Database.File)), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	sql := sqlite.NewSQLiteDB(db, l)
 
+	// Readiness, failing until the initial data is in and once shutting down
+	h := health.New(2 * time.Second)
+	h.Register("database", health.CheckerFunc(sql.Ping))
+	h.Register("migrations", health.CheckerFunc(sql.CheckMigrations))
+	seeded := health.NewFlag("initial data not inserted yet")
+	h.Register("seed", seeded)
+
+	authenticator, err := auth.NewAuthenticator(conf.Auth)
+	if err != nil {
+		log.Fatalf("Failed to set up authentication: %v", err)
+	}
+
 	err = sql.MigrateModels(
 		&product.Product{},
+		&product.Variant{},
 		&category.Category{},
 		&discount.DiscountType{},
 		&discount.GeneralDiscount{},
+		&coupon.Coupon{},
+		&coupon.CustomerRedemption{},
+		&inventory.StockLevel{},
+		&inventory.Reservation{},
+		&audit.Entry{},
+		&pricehistory.CurrentPrice{},
+		&pricehistory.PricePeriod{},
+		&webhook.Webhook{},
+		&webhook.Delivery{},
+		&webhook.PendingDelivery{},
+		&events.OutboxEvent{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
-	cs := category.NewService(sql, l)
-	ds := discount.NewService(sql, l)
-	ps := product.NewService(sql, l, ds)
+	// changes are written to the outbox along with them, then streamed over /v1/events and sent
+	// to the webhooks once relayed
+	bus := events.NewBus(sql, l, time.Now)
+	ws := webhook.NewService(sql, l, conf.Events.WebhookAllowPrivateHosts)
+	bus.AddRelayListener(ws.QueueDeliveries)
+
+	as := audit.NewService(sql, l)
+	cs := category.NewService(sql, l, as)
+	// discounts are read on every product listing and rarely change
+	ds := discount.NewCachedService(discount.NewService(sql, l, as, bus), sql, conf.Cache.DiscountsTTL.Duration, time.Now)
+	cps := coupon.NewService(sql, l)
+	is := inventory.NewService(sql, l, bus)
+	phs := pricehistory.NewService(sql, l, bus, time.Now)
+	ps := product.NewService(sql, l, ds, cps, is, as, phs, bus)
+	// new discounts change the prices of the products they apply to
+	ds.AddChangeListener(ps.RefreshPriceHistory)
+	cts := cart.NewService(l, ps, ds)
+	cgs := catalog.NewService(sql, l, cs, ps, ds)
+
+	// discounts are priced by the ID of their type, whatever the database was created by
+	if err := ds.EnsureDiscountTypes(context.Background()); err != nil {
+		log.Fatalf("Failed to check discount types: %v", err)
+	}
 
-	insertInitialData(cs, ps, ds)
+	// the initial data only goes into a new database, the one of an earlier run keeps its changes
+	categories, err := cs.GetCategories(context.Background(), nil)
+	if err != nil {
+		log.Fatalf("Failed to check for initial data: %v", err)
+	}
+	if len(categories) == 0 {
+		insertInitialData(cs, ps, ds, cps)
+	}
+	seeded.Set()
+
+	// Give back the stock held by reservations that were neither confirmed nor released
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	go inventory.ReleaseExpiredReservations(workerCtx, is, l, time.Minute)
+	// Publish the events committed and deliver them to the webhooks
+	var eventWorkers sync.WaitGroup
+	eventWorkers.Add(2)
+	go func() {
+		defer eventWorkers.Done()
+		webhook.DeliverEvents(workerCtx, bus, ws, l, conf.Events)
+	}()
+	go func() {
+		defer eventWorkers.Done()
+		events.RelayOutbox(workerCtx, bus, l, conf.Events.OutboxPollInterval.Duration)
+	}()
 
-	httpTransportRouter := transport.NewHTTPRouter(l, ps, ds)
+	httpTransportRouter := transport.NewHTTPRouter(conf, l, ps, cs, ds, cps, cts, is, cgs, as, bus, ws, authenticator, h)
 
 	srv := &http.Server{
-		Addr: fmt.Sprintf("0.0.0.0:%s", conf.Port),
+		Addr: fmt.Sprintf("0.0.0.0:%d", conf.Server.Port),
 		// Good practice to set timeouts to avoid Slowloris attacks.
-		WriteTimeout: time.Second * 15,
-		ReadTimeout:  time.Second * 15,
-		IdleTimeout:  time.Second * 60,
+		WriteTimeout: conf.Server.WriteTimeout.Duration,
+		ReadTimeout:  conf.Server.ReadTimeout.Duration,
+		IdleTimeout:  conf.Server.IdleTimeout.Duration,
 		Handler:      httpTransportRouter,
 	}
+	// event streams never end on their own, Shutdown would wait for them until the deadline. The
+	// events committed from then on stay in the outbox for the next run.
+	srv.RegisterOnShutdown(bus.Close)
 
-	l.WithField("transport", "http").WithField("port", conf.Port).
+	l.WithField("transport", "http").WithField("port", conf.Server.Port).
 		Info(context.Background(), "Transport Start")
 
 	// Run our server in a goroutine so that it doesn't block.
@@ -78,6 +188,24 @@ func main() {
 		}
 	}()
 
+	grpcServer := grpctransport.NewGRPCServer(conf, l, ps, cs, ds, authenticator)
+	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", conf.Server.GRPCPort))
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %v", err)
+	}
+
+	l.WithField("transport", "grpc").WithField("port", conf.Server.GRPCPort).
+		Info(context.Background(), "Transport Start")
+
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			l.WithField(
+				"transport", "grpc").
+				WithError(err).
+				Info(context.Background(), "Transport Stopped")
+		}
+	}()
+
 	c := make(chan os.Signal, 1)
 	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
 	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
@@ -85,12 +213,32 @@ func main() {
 	// Block until we receive our signal.
 	<-c
 
+	// Stop being ready first, and keep taking requests while the load balancers notice, so no
+	// new traffic is sent once the listeners close
+	h.Shutdown()
+	time.Sleep(conf.Server.ShutdownDrainDelay.Duration)
+
 	// Create a deadline to wait for.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Server.ShutdownGracePeriod.Duration)
 	defer cancel()
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline.
 	_ = srv.Shutdown(ctx)
+	stopGRPC(ctx, grpcServer)
+	stopWorker()
+	// Webhook deliveries in flight are cancelled, to be resumed on the next start, and the relay
+	// under way finished
+	eventsStopped := make(chan struct{})
+	go func() {
+		eventWorkers.Wait()
+		close(eventsStopped)
+	}()
+	select {
+	case <-eventsStopped:
+	case <-ctx.Done():
+	}
+	// Send the spans still buffered
+	_ = shutdownTracing(ctx)
 
 	// Optionally, you could run srv.Shutdown in a goroutine and block on
 	// <-ctx.Done() if your application should wait for other services
@@ -99,7 +247,21 @@ func main() {
 	os.Exit(0)
 }
 
-func insertInitialData(cs category.Service, ps product.Service, ds discount.Service) {
+// stopGRPC waits for the calls in flight until the deadline of ctx, then cancels the ones left
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
+
+func insertInitialData(cs category.Service, ps product.Service, ds discount.Service, cps coupon.Service) {
 	ctx := context.Background()
 	c1, _ := cs.CreateCategory(ctx, category.CategoryRequest{
 		Name: "boots",
@@ -118,6 +280,7 @@ func insertInitialData(cs category.Service, ps product.Service, ds discount.Serv
 		Name:       "BV Lean leather ankle boots",
 		CategoryID: c1.ID,
 		Price:      89000,
+		Stock:      10,
 	}
 	_, _ = ps.CreateProduct(ctx, p1)
 
@@ -134,6 +297,7 @@ func insertInitialData(cs category.Service, ps product.Service, ds discount.Serv
 		Name:       "Ashlington leather ankle boots",
 		CategoryID: c1.ID,
 		Price:      71000,
+		Stock:      3,
 	}
 	_, _ = ps.CreateProduct(ctx, p3)
 
@@ -142,45 +306,76 @@ func insertInitialData(cs category.Service, ps product.Service, ds discount.Serv
 		Name:       "Naima embellished suede sandals",
 		CategoryID: c2.ID,
 		Price:      79500,
+		Stock:      8,
 	}
 	_, _ = ps.CreateProduct(ctx, p4)
 
+	limitedEditionPrice := 65000
 	p5 := product.ProductRequest{
 		SKU:        "000005",
 		Name:       "Nathane leather sneakers",
 		CategoryID: c3.ID,
 		Price:      59000,
+		Variants: []product.VariantRequest{
+			{SKU: "000005-42-WHT", Size: "42", Colour: "white", Stock: 5},
+			{SKU: "000005-43-WHT", Size: "43", Colour: "white", Stock: 2},
+			{SKU: "000005-43-BLK", Size: "43", Colour: "black", Price: &limitedEditionPrice, Stock: 1},
+		},
 	}
 	_, _ = ps.CreateProduct(ctx, p5)
 
-	dt1, _ := ds.CreateDiscountType(ctx, discount.DiscountTypeRequest{
-		Type: "category",
-	})
-
-	dt2, _ := ds.CreateDiscountType(ctx, discount.DiscountTypeRequest{
-		Type: "sku",
-	})
-
-	dt3, _ := ds.CreateDiscountType(ctx, discount.DiscountTypeRequest{
-		Type: "general",
-	})
-
 	//-----Discounts-----
 	_, _ = ds.CreateDiscount(ctx, discount.DiscountRequest{
-		DiscountTypeID: dt1.ID,
+		DiscountTypeID: discount.CATEGORY,
 		Target:         fmt.Sprint(c1.ID),
 		Percentage:     30,
 	})
 
 	_, _ = ds.CreateDiscount(ctx, discount.DiscountRequest{
-		DiscountTypeID: dt2.ID,
+		DiscountTypeID: discount.SKU,
 		Target:         p3.SKU,
 		Percentage:     15,
 	})
 
 	_, _ = ds.CreateDiscount(ctx, discount.DiscountRequest{
-		DiscountTypeID: dt3.ID,
+		DiscountTypeID: discount.GENERAL,
 		Percentage:     0,
 	})
 
+	//-----Basket promotions-----
+	_, _ = ds.CreateDiscount(ctx, discount.DiscountRequest{
+		DiscountTypeID: discount.BUY_X_GET_Y,
+		Target:         p5.SKU,
+		BuyQuantity:    2,
+		FreeQuantity:   1,
+	})
+
+	_, _ = ds.CreateDiscount(ctx, discount.DiscountRequest{
+		DiscountTypeID: discount.SPEND_THRESHOLD,
+		Percentage:     10,
+		MinSpend:       150000,
+	})
+
+	_, _ = ds.CreateDiscount(ctx, discount.DiscountRequest{
+		DiscountTypeID: discount.BUNDLE,
+		Target:         fmt.Sprintf("%s,%s", p1.SKU, p4.SKU),
+		BundlePrice:    130000,
+	})
+
+	//-----Coupons-----
+	couponDiscount, err := ds.CreateDiscount(ctx, discount.DiscountRequest{
+		DiscountTypeID: discount.GENERAL,
+		Percentage:     20,
+		CouponOnly:     true,
+	})
+	// without its discount there is nothing for the coupon to unlock
+	if d, ok := couponDiscount.(*discount.GeneralDiscount); err == nil && ok {
+		_, _ = cps.CreateCoupon(ctx, coupon.CouponRequest{
+			Code:             "WELCOME20",
+			DiscountID:       d.ID,
+			MaxRedemptions:   100,
+			PerCustomerLimit: 1,
+			ExpiresAt:        time.Now().AddDate(1, 0, 0),
+		})
+	}
 }
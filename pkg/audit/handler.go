@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/response"
+	"net/http"
+	"strconv"
+)
+
+type Handler interface {
+	GetEntries(w http.ResponseWriter, r *http.Request)
+}
+
+type handler struct {
+	service Service
+	logger  logger.Logger
+}
+
+func NewHandler(service Service, logger logger.Logger) Handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetEntries godoc
+// @Summary Get the audit log
+// @Description Get who changed the entities of a kind, when, and their snapshots before and after, oldest first.
+// @Description Entries come a page at a time, the next one starting after the id of the last entry.
+// @Produce  json
+// @Param entity query string true "Entity kind" Enums(product, category, discount, discount_type)
+// @Param id query string false "Only the changes of this entity, e.g. a product SKU"
+// @Param limit query int false "Entries per page, 100 by default and 1000 at most"
+// @Param after query int false "ID of the last entry of the previous page"
+// @Success 200 {array} EntryResponse
+// @Failure 400 {object} apierror.ApiError "Unknown entity, wrong limit or wrong after"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/audit [get]
+func (h *handler) GetEntries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	entity, err := ParseEntity(query.Get("entity"))
+	if err != nil {
+		response.RespondWithError(w, err)
+		return
+	}
+
+	opts := ListOptions{Entity: entity, EntityID: query.Get("id"), Limit: DefaultLimit}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			response.RespondWithError(w, apierror.BadRequest("limit must be a positive number"))
+			return
+		}
+		opts.Limit = min(limit, MaxLimit)
+	}
+	if a := query.Get("after"); a != "" {
+		after, err := strconv.Atoi(a)
+		if err != nil || after < 0 {
+			response.RespondWithError(w, apierror.BadRequest("after must be the id of an entry"))
+			return
+		}
+		opts.AfterID = after
+	}
+
+	entries, err := h.service.GetEntries(ctx, opts)
+	if err != nil {
+		h.logger.WithField("entity", entity).WithError(err).Error(ctx, "Error getting audit entries")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	res := []EntryResponse{}
+	for _, e := range entries {
+		res = append(res, e.ToEntryResponse())
+	}
+	response.RespondWithData(w, http.StatusOK, res)
+}
@@ -0,0 +1,98 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/audit"
+	auditmocks "mytheresa/pkg/audit/mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewHandler(t *testing.T) {
+	h := audit.NewHandler(&auditmocks.Service{}, &loggermocks.NoopLogger{})
+
+	assert.NotNil(t, h)
+}
+
+func TestHandlerGetEntries_OK(t *testing.T) {
+	after := `{"sku":"000003"}`
+	as := auditmocks.Service{}
+	as.On("GetEntries", mock.Anything, audit.ListOptions{Entity: audit.Product, EntityID: "000003", Limit: audit.DefaultLimit}).Return([]audit.Entry{
+		{ID: 1, Entity: audit.Product, EntityID: "000003", Action: audit.Create, Actor: "ci", After: &after},
+	}, nil)
+
+	h := audit.NewHandler(&as, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/audit?entity=product&id=000003", nil)
+	w := httptest.NewRecorder()
+
+	h.GetEntries(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []audit.EntryResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Len(t, response, 1)
+	assert.Equal(t, "ci", response[0].Actor)
+	assert.JSONEq(t, after, string(response[0].After))
+	assert.Equal(t, "null", string(response[0].Before))
+}
+
+func TestHandlerGetEntries_UnknownEntity(t *testing.T) {
+	h := audit.NewHandler(&auditmocks.Service{}, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/audit?entity=coupon", nil)
+	w := httptest.NewRecorder()
+
+	h.GetEntries(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerGetEntries_Error(t *testing.T) {
+	as := auditmocks.Service{}
+	as.On("GetEntries", mock.Anything, audit.ListOptions{Entity: audit.Discount, Limit: audit.DefaultLimit}).Return([]audit.Entry{}, apierror.InternalServerError("error getting audit entries"))
+
+	h := audit.NewHandler(&as, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/audit?entity=discount", nil)
+	w := httptest.NewRecorder()
+
+	h.GetEntries(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestHandlerGetEntries_Page(t *testing.T) {
+	as := auditmocks.Service{}
+	as.On("GetEntries", mock.Anything, audit.ListOptions{Entity: audit.Product, AfterID: 12, Limit: audit.MaxLimit}).Return([]audit.Entry{}, nil)
+
+	h := audit.NewHandler(&as, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/audit?entity=product&after=12&limit=5000", nil)
+	w := httptest.NewRecorder()
+
+	h.GetEntries(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	as.AssertExpectations(t)
+}
+
+func TestHandlerGetEntries_WrongPage(t *testing.T) {
+	for _, query := range []string{"limit=0", "limit=-1", "limit=ten", "after=-1", "after=last"} {
+		h := audit.NewHandler(&auditmocks.Service{}, &loggermocks.NoopLogger{})
+
+		r := httptest.NewRequest(http.MethodGet, "/v1/audit?entity=product&"+query, nil)
+		w := httptest.NewRecorder()
+
+		h.GetEntries(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"context"
+	"mytheresa/pkg/audit"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Service struct {
+	mock.Mock
+}
+
+func (s *Service) Record(ctx context.Context, entity audit.Entity, id string, action audit.Action, before, after interface{}) error {
+	args := s.Called(ctx, entity, id, action, before, after)
+	return args.Error(0)
+}
+
+func (s *Service) GetEntries(ctx context.Context, opts audit.ListOptions) ([]audit.Entry, error) {
+	args := s.Called(ctx, opts)
+	return args.Get(0).([]audit.Entry), args.Error(1)
+}
+
+// AnyService accepts every change recorded in the audit log
+func AnyService() *Service {
+	s := Service{}
+	s.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return &s
+}
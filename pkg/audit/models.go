@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"strconv"
+	"time"
+)
+
+// SystemActor is recorded for the changes made outside of a request, e.g. the initial data
+const SystemActor = "system"
+
+// Entity is the kind of record an audit entry is about
+type Entity string
+
+const (
+	Product      Entity = "product"
+	Category     Entity = "category"
+	Discount     Entity = "discount"
+	DiscountType Entity = "discount_type"
+)
+
+func ParseEntity(value string) (Entity, error) {
+	switch e := Entity(value); e {
+	case Product, Category, Discount, DiscountType:
+		return e, nil
+	}
+	return "", apierror.BadRequest(fmt.Sprintf("Unknown entity %s, expected product, category, discount or discount_type", value))
+}
+
+const (
+	// DefaultLimit is how many entries are returned when the request sets no limit
+	DefaultLimit = 100
+	// MaxLimit is the most entries returned at once
+	MaxLimit = 1000
+)
+
+// ListOptions selects a page of the audit log
+type ListOptions struct {
+	Entity   Entity
+	EntityID string
+	// AfterID leaves out the entries up to this one, the last of the previous page
+	AfterID int
+	Limit   int
+}
+
+// Action is the kind of change recorded
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Entry records who changed an entity, when, and how it looked before and after the change.
+// Snapshots are kept as JSON, Before is empty on creation and After on deletion.
+type Entry struct {
+	ID        int       `gorm:"primaryKey"`
+	Entity    Entity    `gorm:"not null;index:idx_audit_entity"`
+	EntityID  string    `gorm:"not null;index:idx_audit_entity"`
+	Action    Action    `gorm:"not null"`
+	Actor     string    `gorm:"not null"`
+	RequestID string    `gorm:"not null;default:''"`
+	Before    *string   `gorm:"type:text"`
+	After     *string   `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+// EntryResponse represents a change of an entity
+// @Description EntryResponse tells who changed an entity and its snapshots before and after the change
+// @Accept json
+// @Produce json
+// @Success 200 {object} EntryResponse
+type EntryResponse struct {
+	ID        string          `json:"id" example:"12"`
+	Entity    Entity          `json:"entity" example:"product"`
+	EntityID  string          `json:"entity_id" example:"000003"`
+	Action    Action          `json:"action" example:"create"`
+	Actor     string          `json:"actor" example:"ci"`
+	RequestID string          `json:"request_id,omitempty" example:"5b1f6c1e-3c8e-4a47-9a8e-1c2d3e4f5a6b"`
+	Before    json.RawMessage `json:"before" swaggertype:"object"`
+	After     json.RawMessage `json:"after" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at" example:"2030-01-01T00:15:00Z"`
+}
+
+func (e *Entry) ToEntryResponse() EntryResponse {
+	return EntryResponse{
+		ID:        strconv.Itoa(e.ID),
+		Entity:    e.Entity,
+		EntityID:  e.EntityID,
+		Action:    e.Action,
+		Actor:     e.Actor,
+		RequestID: e.RequestID,
+		Before:    rawSnapshot(e.Before),
+		After:     rawSnapshot(e.After),
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+func (e *Entry) GetIdentifier() string {
+	return strconv.Itoa(e.ID)
+}
+
+// rawSnapshot keeps the stored JSON as is, and missing snapshots as null
+func rawSnapshot(snapshot *string) json.RawMessage {
+	if snapshot == nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(*snapshot)
+}
+
+type auditFilter struct {
+	field   string
+	Value   interface{}
+	Operand string
+}
+
+func (f *auditFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *auditFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *auditFilter) GetOperand() string {
+	return f.Operand
+}
+
+func NewEntityFilter(entity Entity) database.Filter {
+	return &auditFilter{
+		field:   "entity",
+		Value:   entity,
+		Operand: "=",
+	}
+}
+
+func NewEntityIDFilter(id string) database.Filter {
+	return &auditFilter{
+		field:   "entity_id",
+		Value:   id,
+		Operand: "=",
+	}
+}
+
+func NewAfterIDFilter(id int) database.Filter {
+	return &auditFilter{
+		field:   "id",
+		Value:   id,
+		Operand: ">",
+	}
+}
@@ -0,0 +1,36 @@
+package audit_test
+
+import (
+	"mytheresa/internal/apierror"
+	"mytheresa/pkg/audit"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEntity(t *testing.T) {
+	e, err := audit.ParseEntity("discount_type")
+	assert.NoError(t, err)
+	assert.Equal(t, audit.DiscountType, e)
+
+	_, err = audit.ParseEntity("coupon")
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, apierr.Code())
+}
+
+func TestEntry_ToEntryResponse(t *testing.T) {
+	after := `{"sku":"000003","price":71000}`
+	now := time.Now().UTC()
+	e := audit.Entry{ID: 4, Entity: audit.Product, EntityID: "000003", Action: audit.Create, Actor: "ci", RequestID: "r1", After: &after, CreatedAt: now}
+
+	res := e.ToEntryResponse()
+
+	assert.Equal(t, "4", res.ID)
+	assert.Equal(t, "ci", res.Actor)
+	assert.Equal(t, "null", string(res.Before))
+	assert.JSONEq(t, after, string(res.After))
+	assert.Equal(t, now, res.CreatedAt)
+}
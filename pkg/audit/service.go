@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/requestctx"
+	"mytheresa/internal/tracing"
+	"time"
+)
+
+type Service interface {
+	Record(ctx context.Context, entity Entity, id string, action Action, before, after interface{}) error
+	GetEntries(ctx context.Context, opts ListOptions) ([]Entry, error)
+}
+
+type service struct {
+	db     database.Database
+	logger logger.Logger
+}
+
+func NewService(db database.Database, logger logger.Logger) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Record saves who made the change, taken from the authenticated principal, along with the
+// request ID and the snapshots, nil when there is none. Called within the transaction of the
+// change, so a change is never kept without its entry.
+func (s *service) Record(ctx context.Context, entity Entity, id string, action Action, before, after interface{}) error {
+	ctx, span := tracing.Start(ctx, "audit.Record")
+	defer span.End()
+
+	entry := Entry{
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+		Actor:     SystemActor,
+		CreatedAt: time.Now().UTC(),
+	}
+	if caller, ok := requestctx.Caller(ctx); ok {
+		entry.Actor = caller
+	}
+	entry.RequestID = requestctx.RequestID(ctx)
+
+	var err error
+	if entry.Before, err = snapshot(before); err == nil {
+		entry.After, err = snapshot(after)
+	}
+	if err != nil {
+		s.logger.WithField("entity", entity).WithError(err).Error(ctx, "error encoding audit snapshot")
+		return apierror.InternalServerError("error recording audit entry")
+	}
+
+	if err := s.db.Save(ctx, entry.GetIdentifier(), &entry); err != nil {
+		s.logger.WithField("entity", entity).WithField("id", id).WithError(err).Error(ctx, "error saving audit entry")
+		return apierror.InternalServerError("error recording audit entry")
+	}
+	return nil
+}
+
+// GetEntries returns a page of the changes of an entity kind, oldest first, only the ones of an
+// entity when EntityID is given. The next page starts after the ID of the last entry returned.
+func (s *service) GetEntries(ctx context.Context, opts ListOptions) ([]Entry, error) {
+	ctx, span := tracing.Start(ctx, "audit.GetEntries")
+	defer span.End()
+
+	filters := []database.Filter{NewEntityFilter(opts.Entity)}
+	if opts.EntityID != "" {
+		filters = append(filters, NewEntityIDFilter(opts.EntityID))
+	}
+	if opts.AfterID > 0 {
+		filters = append(filters, NewAfterIDFilter(opts.AfterID))
+	}
+	limit := opts.Limit
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	entries := []Entry{}
+	if err := s.db.GetPage(ctx, &entries, database.Page{OrderBy: "id", Limit: limit}, filters...); err != nil {
+		s.logger.WithField("entity", opts.Entity).WithError(err).Error(ctx, "error getting audit entries")
+		return nil, apierror.InternalServerError("error getting audit entries")
+	}
+	return entries, nil
+}
+
+func snapshot(value interface{}) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	s := string(data)
+	return &s, nil
+}
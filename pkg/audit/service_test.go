@@ -0,0 +1,171 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"mytheresa/internal/auth"
+	"mytheresa/internal/database"
+	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/internal/requestctx"
+	"mytheresa/pkg/audit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewService(t *testing.T) {
+	s := audit.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{})
+
+	assert.NotNil(t, s)
+}
+
+func TestRecord_ActorAndRequestID(t *testing.T) {
+	var saved audit.Entry
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved = *args.Get(2).(*audit.Entry)
+	}).Return(nil)
+
+	s := audit.NewService(&dbmock, &loggermocks.NoopLogger{})
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "jane"})
+	ctx = requestctx.WithRequestID(ctx, "r1")
+
+	err := s.Record(ctx, audit.Discount, "3", audit.Create, nil, map[string]int{"percentage": 90})
+
+	assert.NoError(t, err)
+	assert.Equal(t, audit.Discount, saved.Entity)
+	assert.Equal(t, "3", saved.EntityID)
+	assert.Equal(t, audit.Create, saved.Action)
+	assert.Equal(t, "jane", saved.Actor)
+	assert.Equal(t, "r1", saved.RequestID)
+	assert.Nil(t, saved.Before)
+	assert.JSONEq(t, `{"percentage":90}`, *saved.After)
+	assert.WithinDuration(t, time.Now(), saved.CreatedAt, time.Minute)
+}
+
+func TestRecord_WithoutPrincipal(t *testing.T) {
+	var saved audit.Entry
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved = *args.Get(2).(*audit.Entry)
+	}).Return(nil)
+
+	s := audit.NewService(&dbmock, &loggermocks.NoopLogger{})
+	err := s.Record(context.Background(), audit.Category, "1", audit.Create, nil, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, audit.SystemActor, saved.Actor)
+	assert.Empty(t, saved.RequestID)
+	assert.Nil(t, saved.After)
+}
+
+func TestRecord_ErrorSaving(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := audit.NewService(&dbmock, &loggermocks.NoopLogger{})
+	err := s.Record(context.Background(), audit.Category, "1", audit.Create, nil, nil)
+
+	assert.EqualError(t, err, "error recording audit entry")
+}
+
+func TestRecord_UnencodableSnapshot(t *testing.T) {
+	s := audit.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{})
+	err := s.Record(context.Background(), audit.Category, "1", audit.Create, nil, make(chan int))
+
+	assert.EqualError(t, err, "error recording audit entry")
+}
+
+func TestGetEntries_ErrorGettingFromDB(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := audit.NewService(&dbmock, &loggermocks.NoopLogger{})
+	_, err := s.GetEntries(context.Background(), audit.ListOptions{Entity: audit.Product})
+
+	assert.EqualError(t, err, "error getting audit entries")
+}
+
+func TestGetEntries_SQLite(t *testing.T) {
+	sqlDB := sqlitetest.NewDB(t, &audit.Entry{})
+
+	s := audit.NewService(sqlDB, &loggermocks.NoopLogger{})
+	ctx := context.Background()
+	assert.NoError(t, s.Record(ctx, audit.Product, "000003", audit.Create, nil, map[string]int{"price": 71000}))
+	assert.NoError(t, s.Record(ctx, audit.Product, "000004", audit.Create, nil, map[string]int{"price": 79500}))
+	assert.NoError(t, s.Record(ctx, audit.Product, "000003", audit.Update, map[string]int{"price": 71000}, map[string]int{"price": 7100}))
+	assert.NoError(t, s.Record(ctx, audit.Category, "000003", audit.Create, nil, nil))
+
+	entries, err := s.GetEntries(ctx, audit.ListOptions{Entity: audit.Product, EntityID: "000003"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, audit.Create, entries[0].Action)
+	assert.Equal(t, audit.Update, entries[1].Action)
+	assert.JSONEq(t, `{"price":71000}`, *entries[1].Before)
+	assert.JSONEq(t, `{"price":7100}`, *entries[1].After)
+
+	entries, err = s.GetEntries(ctx, audit.ListOptions{Entity: audit.Product})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	entries, err = s.GetEntries(ctx, audit.ListOptions{Entity: audit.Discount})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestGetEntries_Pages(t *testing.T) {
+	sqlDB := sqlitetest.NewDB(t, &audit.Entry{})
+
+	s := audit.NewService(sqlDB, &loggermocks.NoopLogger{})
+	ctx := context.Background()
+	for _, id := range []string{"000001", "000002", "000003"} {
+		assert.NoError(t, s.Record(ctx, audit.Product, id, audit.Create, nil, nil))
+	}
+	assert.NoError(t, s.Record(ctx, audit.Category, "1", audit.Create, nil, nil))
+
+	entries, err := s.GetEntries(ctx, audit.ListOptions{Entity: audit.Product, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "000001", entries[0].EntityID)
+	assert.Equal(t, "000002", entries[1].EntityID)
+
+	entries, err = s.GetEntries(ctx, audit.ListOptions{Entity: audit.Product, Limit: 2, AfterID: entries[1].ID})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "000003", entries[0].EntityID)
+
+	entries, err = s.GetEntries(ctx, audit.ListOptions{Entity: audit.Product, Limit: 2, AfterID: entries[0].ID})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestGetEntries_LimitedInSQL(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, database.Page{OrderBy: "id", Limit: audit.MaxLimit}, mock.Anything).Return(nil)
+
+	s := audit.NewService(&dbmock, &loggermocks.NoopLogger{})
+	_, err := s.GetEntries(context.Background(), audit.ListOptions{Entity: audit.Product, Limit: 5000})
+
+	assert.NoError(t, err)
+	dbmock.AssertExpectations(t)
+}
+
+func TestRecord_RolledBackWithTheChange(t *testing.T) {
+	sqlDB := sqlitetest.NewDB(t, &audit.Entry{})
+
+	s := audit.NewService(sqlDB, &loggermocks.NoopLogger{})
+	ctx := context.Background()
+	err := sqlDB.WithTransaction(ctx, func(ctx context.Context) error {
+		assert.NoError(t, s.Record(ctx, audit.Product, "000003", audit.Create, nil, nil))
+		return errors.New("saving the product failed")
+	})
+	assert.Error(t, err)
+
+	entries, err := s.GetEntries(ctx, audit.ListOptions{Entity: audit.Product, EntityID: "000003"})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
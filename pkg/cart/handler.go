@@ -0,0 +1,57 @@
+package cart
+
+import (
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/response"
+	"net/http"
+)
+
+type Handler interface {
+	PriceCart(w http.ResponseWriter, r *http.Request)
+}
+
+type handler struct {
+	service Service
+	logger  logger.Logger
+}
+
+func NewHandler(service Service, logger logger.Logger) Handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// PriceCart godoc
+// @Summary Price a cart
+// @Description Price a list of products and quantities, applying per product discounts and basket level promotions
+// @Accept  json
+// @Produce  json
+// @Param cart body CartRequest true "Cart details"
+// @Success 200 {object} CartPriceResponse
+// @Failure 400 {object} apierror.ApiError "Wrong body"
+// @Failure 404 {object} apierror.ApiError "Product not found"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Router /v1/cart/price [post]
+func (h *handler) PriceCart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var cart CartRequest
+	err := json.NewDecoder(r.Body).Decode(&cart)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error decoding request while trying to price cart")
+		response.RespondWithError(w, apierror.BadRequest("Wrong body"))
+		return
+	}
+
+	c, err := h.service.PriceCart(ctx, cart)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error pricing cart")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, c)
+}
@@ -0,0 +1,75 @@
+package cart_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/cart"
+	cartmocks "mytheresa/pkg/cart/mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewHandler(t *testing.T) {
+	h := cart.NewHandler(&cartmocks.Service{}, &loggermocks.NoopLogger{})
+
+	assert.NotNil(t, h)
+}
+
+func TestHandlerPriceCart_OK(t *testing.T) {
+	priced := cart.CartPriceResponse{
+		Lines:      []cart.CartLineResponse{{SKU: "000001", Quantity: 2, LineTotal: 20000}},
+		Promotions: []cart.PromotionResponse{},
+		Subtotal:   20000,
+		Total:      20000,
+		Currency:   "EUR",
+	}
+	req := cart.CartRequest{Lines: []cart.CartLineRequest{{SKU: "000001", Quantity: 2}}}
+	cs := cartmocks.Service{}
+	cs.On("PriceCart", mock.Anything, req).Return(priced, nil)
+
+	h := cart.NewHandler(&cs, &loggermocks.NoopLogger{})
+
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/cart/price", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	h.PriceCart(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response cart.CartPriceResponse
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, priced, response)
+}
+
+func TestHandlerPriceCart_WrongBody(t *testing.T) {
+	h := cart.NewHandler(&cartmocks.Service{}, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/cart/price", bytes.NewReader([]byte("invalid body")))
+	w := httptest.NewRecorder()
+
+	h.PriceCart(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerPriceCart_ServiceError(t *testing.T) {
+	cs := cartmocks.Service{}
+	cs.On("PriceCart", mock.Anything, mock.Anything).Return(cart.CartPriceResponse{}, apierror.NotFound("Product 000009 not found"))
+
+	h := cart.NewHandler(&cs, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/cart/price", bytes.NewReader([]byte(`{"lines":[{"sku":"000009","quantity":1}]}`)))
+	w := httptest.NewRecorder()
+
+	h.PriceCart(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
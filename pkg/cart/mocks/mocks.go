@@ -0,0 +1,17 @@
+package mocks
+
+import (
+	"context"
+	"mytheresa/pkg/cart"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Service struct {
+	mock.Mock
+}
+
+func (s *Service) PriceCart(ctx context.Context, c cart.CartRequest) (cart.CartPriceResponse, error) {
+	args := s.Called(ctx, c)
+	return args.Get(0).(cart.CartPriceResponse), args.Error(1)
+}
@@ -0,0 +1,60 @@
+package cart
+
+import (
+	"mytheresa/pkg/product"
+)
+
+// CartRequest represents the body for pricing a cart
+// @Description CartRequest is the list of products, and their quantities, to be priced together
+// @Accept json
+// @Produce json
+// @Param cart body CartRequest true "Cart details"
+type CartRequest struct {
+	Lines  []CartLineRequest `json:"lines"`
+	Coupon string            `json:"coupon,omitempty" example:"WELCOME20"`
+}
+
+type CartLineRequest struct {
+	SKU      string `json:"sku" example:"000001"`
+	Quantity int    `json:"quantity" example:"2"`
+}
+
+// CartPriceResponse represents a priced cart
+// @Description CartPriceResponse includes the price of every line plus the basket level promotions applied to the cart
+// @Accept json
+// @Produce json
+// @Success 200 {object} CartPriceResponse
+type CartPriceResponse struct {
+	Lines      []CartLineResponse  `json:"lines"`
+	Promotions []PromotionResponse `json:"promotions"`
+	Subtotal   int                 `json:"subtotal" example:"178000"`
+	Discount   int                 `json:"discount" example:"17800"`
+	Total      int                 `json:"total" example:"160200"`
+	Currency   string              `json:"currency" example:"EUR"`
+}
+
+// CartLineResponse represents a line of a priced cart, with the per product discounts applied
+type CartLineResponse struct {
+	SKU       string                `json:"sku" example:"000001"`
+	Name      string                `json:"name" example:"BV Lean leather ankle boots"`
+	Quantity  int                   `json:"quantity" example:"2"`
+	UnitPrice product.PriceResponse `json:"unit_price"`
+	LineTotal int                   `json:"line_total" example:"124600"`
+}
+
+// PromotionResponse represents a basket level promotion applied to a cart
+type PromotionResponse struct {
+	DiscountID string `json:"discount_id" example:"5"`
+	Type       string `json:"type" example:"spend_threshold"`
+	Amount     int    `json:"amount" example:"17800"`
+}
+
+func newCartLineResponse(p product.ProductResponse, quantity int) CartLineResponse {
+	return CartLineResponse{
+		SKU:       p.SKU,
+		Name:      p.Name,
+		Quantity:  quantity,
+		UnitPrice: p.Price,
+		LineTotal: p.Price.Final * quantity,
+	}
+}
@@ -0,0 +1,130 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/requestctx"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/discount"
+	"mytheresa/pkg/product"
+)
+
+type Service interface {
+	PriceCart(ctx context.Context, cart CartRequest) (CartPriceResponse, error)
+}
+
+type service struct {
+	logger          logger.Logger
+	productService  product.Service
+	discountService discount.Service
+}
+
+func NewService(logger logger.Logger, ps product.Service, ds discount.Service) Service {
+	return &service{
+		logger:          logger,
+		productService:  ps,
+		discountService: ds,
+	}
+}
+
+// PriceCart prices every line, of a product or a variant SKU, with the same per product discounts
+// used when listing products, then evaluates the basket level promotions over the whole cart.
+func (s *service) PriceCart(ctx context.Context, req CartRequest) (CartPriceResponse, error) {
+	ctx, span := tracing.Start(ctx, "cart.PriceCart")
+	defer span.End()
+
+	skus, quantities, err := mergeLines(req.Lines)
+	if err != nil {
+		return CartPriceResponse{}, err
+	}
+
+	products, err := s.productService.ListProducts(ctx, product.ListOptions{
+		SKUs:       skus,
+		CouponCode: req.Coupon,
+	})
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "Failed to price cart products")
+		return CartPriceResponse{}, err
+	}
+
+	// variants come along with their product, priced on their own
+	bySKU := map[string]product.ProductResponse{}
+	for _, p := range products {
+		for _, sku := range skus {
+			if line, ok := p.ForSKU(sku); ok {
+				bySKU[sku] = line
+			}
+		}
+	}
+
+	response := CartPriceResponse{
+		Lines:      []CartLineResponse{},
+		Promotions: []PromotionResponse{},
+		Currency:   requestctx.Currency(ctx),
+	}
+	basket := discount.Basket{}
+	for _, sku := range skus {
+		p, ok := bySKU[sku]
+		if !ok {
+			return CartPriceResponse{}, apierror.NotFound(fmt.Sprintf("Product %s not found", sku))
+		}
+
+		line := newCartLineResponse(p, quantities[sku])
+		response.Lines = append(response.Lines, line)
+		response.Subtotal += line.LineTotal
+		response.Currency = line.UnitPrice.Currency
+
+		basket.Lines = append(basket.Lines, discount.BasketLine{
+			SKU:       sku,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice.Final,
+		})
+	}
+
+	discounts, err := s.discountService.GetApplicableDiscounts(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "Failed to get discounts from database")
+		return CartPriceResponse{}, err
+	}
+
+	for _, applied := range discount.ApplyBasketDiscounts(&basket, discounts) {
+		d := applied.Discount.ToDiscountResponse()
+		response.Promotions = append(response.Promotions, PromotionResponse{
+			DiscountID: d.ID,
+			Type:       d.DiscountType.Type,
+			Amount:     applied.Amount,
+		})
+		response.Discount += applied.Amount
+	}
+	response.Total = response.Subtotal - response.Discount
+
+	s.logger.WithField("lines", len(response.Lines)).WithField("total", response.Total).Info(ctx, "Cart priced")
+	return response, nil
+}
+
+// mergeLines validates the cart lines and adds up the quantities of repeated SKUs,
+// keeping the order in which every SKU first appeared
+func mergeLines(lines []CartLineRequest) ([]string, map[string]int, error) {
+	if len(lines) == 0 {
+		return nil, nil, apierror.BadRequest("Cart has no lines")
+	}
+
+	skus := []string{}
+	quantities := map[string]int{}
+	for _, l := range lines {
+		if l.SKU == "" {
+			return nil, nil, apierror.BadRequest("Every line needs a SKU")
+		}
+		if l.Quantity <= 0 {
+			return nil, nil, apierror.BadRequest(fmt.Sprintf("Invalid quantity for product %s", l.SKU))
+		}
+		if _, ok := quantities[l.SKU]; !ok {
+			skus = append(skus, l.SKU)
+		}
+		quantities[l.SKU] += l.Quantity
+	}
+
+	return skus, quantities, nil
+}
@@ -0,0 +1,191 @@
+package cart_test
+
+import (
+	"context"
+	"mytheresa/internal/apierror"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/cart"
+	"mytheresa/pkg/discount"
+	discountmocks "mytheresa/pkg/discount/mocks"
+	"mytheresa/pkg/product"
+	productmocks "mytheresa/pkg/product/mocks"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func productResponse(sku string, original, final int) product.ProductResponse {
+	return product.ProductResponse{
+		SKU:  sku,
+		Name: "Product " + sku,
+		Price: product.PriceResponse{
+			Original: original,
+			Final:    final,
+			Currency: "EUR",
+		},
+	}
+}
+
+func TestNewService(t *testing.T) {
+	s := cart.NewService(&loggermocks.NoopLogger{}, &productmocks.Service{}, &discountmocks.Service{})
+
+	assert.NotNil(t, s)
+}
+
+func TestPriceCart_OK(t *testing.T) {
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, withCoupon("WELCOME20")).Return([]product.ProductResponse{
+		productResponse("000001", 10000, 7000),
+		productResponse("000005", 5000, 5000),
+	}, nil)
+
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
+		&discount.BuyXGetYDiscount{GeneralDiscount: discount.GeneralDiscount{
+			ID: 4, DiscountTypeID: discount.BUY_X_GET_Y, DiscountType: discount.DiscountType{Type: "buy_x_get_y"},
+			Target: "000005", BuyQuantity: 2, FreeQuantity: 1,
+		}},
+		&discount.SpendThresholdDiscount{GeneralDiscount: discount.GeneralDiscount{
+			ID: 5, DiscountTypeID: discount.SPEND_THRESHOLD, DiscountType: discount.DiscountType{Type: "spend_threshold"},
+			Percentage: 10, MinSpend: 20000,
+		}},
+	}, nil)
+
+	s := cart.NewService(&loggermocks.NoopLogger{}, &ps, &ds)
+
+	result, err := s.PriceCart(context.Background(), cart.CartRequest{
+		Coupon: "WELCOME20",
+		Lines: []cart.CartLineRequest{
+			{SKU: "000005", Quantity: 2},
+			{SKU: "000001", Quantity: 2},
+			{SKU: "000005", Quantity: 1},
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, result.Lines, 2)
+	assert.Equal(t, "000005", result.Lines[0].SKU)
+	assert.Equal(t, 3, result.Lines[0].Quantity)
+	assert.Equal(t, 15000, result.Lines[0].LineTotal)
+	assert.Equal(t, 14000, result.Lines[1].LineTotal)
+	assert.Equal(t, 29000, result.Subtotal)
+	assert.Equal(t, []cart.PromotionResponse{
+		{DiscountID: "4", Type: "buy_x_get_y", Amount: 5000},
+		{DiscountID: "5", Type: "spend_threshold", Amount: 2400},
+	}, result.Promotions)
+	assert.Equal(t, 7400, result.Discount)
+	assert.Equal(t, 21600, result.Total)
+	assert.Equal(t, "EUR", result.Currency)
+}
+
+func TestPriceCart_VariantLine(t *testing.T) {
+	boots := productResponse("000005", 10000, 9000)
+	boots.Variants = []product.VariantResponse{
+		{SKU: "000005-42-WHT", Size: "42", Colour: "white", Price: product.PriceResponse{Original: 12000, Final: 10800, Currency: "EUR"}},
+	}
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, mock.MatchedBy(func(opts product.ListOptions) bool {
+		return len(opts.SKUs) == 2 && opts.SKUs[0] == "000005-42-WHT" && opts.SKUs[1] == "000005"
+	})).Return([]product.ProductResponse{boots}, nil)
+
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
+		&discount.BuyXGetYDiscount{GeneralDiscount: discount.GeneralDiscount{
+			ID: 4, DiscountTypeID: discount.BUY_X_GET_Y, DiscountType: discount.DiscountType{Type: "buy_x_get_y"},
+			Target: "000005-42-WHT", BuyQuantity: 1, FreeQuantity: 1,
+		}},
+	}, nil)
+
+	s := cart.NewService(&loggermocks.NoopLogger{}, &ps, &ds)
+
+	result, err := s.PriceCart(context.Background(), cart.CartRequest{Lines: []cart.CartLineRequest{
+		{SKU: "000005-42-WHT", Quantity: 2},
+		{SKU: "000005", Quantity: 1},
+	}})
+
+	assert.Nil(t, err)
+	assert.Len(t, result.Lines, 2)
+	assert.Equal(t, "000005-42-WHT", result.Lines[0].SKU)
+	assert.Equal(t, "Product 000005", result.Lines[0].Name)
+	assert.Equal(t, 10800, result.Lines[0].UnitPrice.Final)
+	assert.Equal(t, 21600, result.Lines[0].LineTotal)
+	assert.Equal(t, 9000, result.Lines[1].LineTotal)
+	assert.Equal(t, []cart.PromotionResponse{{DiscountID: "4", Type: "buy_x_get_y", Amount: 10800}}, result.Promotions)
+	assert.Equal(t, 19800, result.Total)
+}
+
+func TestPriceCart_InvalidLines(t *testing.T) {
+	tests := map[string][]cart.CartLineRequest{
+		"Cart has no lines":                 {},
+		"Every line needs a SKU":            {{Quantity: 1}},
+		"Invalid quantity for product 0001": {{SKU: "0001", Quantity: 0}},
+	}
+
+	for message, lines := range tests {
+		t.Run(message, func(t *testing.T) {
+			s := cart.NewService(&loggermocks.NoopLogger{}, &productmocks.Service{}, &discountmocks.Service{})
+
+			_, err := s.PriceCart(context.Background(), cart.CartRequest{Lines: lines})
+
+			apierr, ok := err.(*apierror.ApiError)
+			assert.True(t, ok)
+			assert.Equal(t, http.StatusBadRequest, apierr.Code())
+			assert.Equal(t, message, apierr.Error())
+		})
+	}
+}
+
+func TestPriceCart_ProductNotFound(t *testing.T) {
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, withCoupon("")).Return([]product.ProductResponse{
+		productResponse("000001", 10000, 7000),
+	}, nil)
+
+	s := cart.NewService(&loggermocks.NoopLogger{}, &ps, &discountmocks.Service{})
+
+	_, err := s.PriceCart(context.Background(), cart.CartRequest{Lines: []cart.CartLineRequest{
+		{SKU: "000001", Quantity: 1},
+		{SKU: "999999", Quantity: 1},
+	}})
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, apierr.Code())
+	assert.Equal(t, "Product 999999 not found", apierr.Error())
+}
+
+func TestPriceCart_ErrorListingProducts(t *testing.T) {
+	productErr := apierror.InternalServerError("Failed to get products from database")
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, withCoupon("")).Return([]product.ProductResponse{}, productErr)
+
+	s := cart.NewService(&loggermocks.NoopLogger{}, &ps, &discountmocks.Service{})
+
+	_, err := s.PriceCart(context.Background(), cart.CartRequest{Lines: []cart.CartLineRequest{{SKU: "000001", Quantity: 1}}})
+
+	assert.Equal(t, productErr, err)
+}
+
+func TestPriceCart_ErrorGettingDiscounts(t *testing.T) {
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, withCoupon("")).Return([]product.ProductResponse{
+		productResponse("000001", 10000, 7000),
+	}, nil)
+	discountErr := apierror.InternalServerError("error getting discounts")
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{}, discountErr)
+
+	s := cart.NewService(&loggermocks.NoopLogger{}, &ps, &ds)
+
+	_, err := s.PriceCart(context.Background(), cart.CartRequest{Lines: []cart.CartLineRequest{{SKU: "000001", Quantity: 1}}})
+
+	assert.Equal(t, discountErr, err)
+}
+
+func withCoupon(code string) interface{} {
+	return mock.MatchedBy(func(opts product.ListOptions) bool {
+		return opts.CouponCode == code && len(opts.SKUs) > 0
+	})
+}
@@ -0,0 +1,164 @@
+package catalog
+
+import (
+	"fmt"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/response"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler interface {
+	Import(w http.ResponseWriter, r *http.Request)
+	Export(w http.ResponseWriter, r *http.Request)
+}
+
+type handler struct {
+	service Service
+	logger  logger.Logger
+}
+
+func NewHandler(service Service, logger logger.Logger) Handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Import godoc
+// @Summary Bulk import products, categories or discounts
+// @Description Create every row of a CSV (with header) or JSON Lines file. Failing rows are reported by line.
+// @Description With atomic nothing is imported unless every row is valid, with dry_run rows are only validated.
+// @Accept  text/csv
+// @Accept  application/x-ndjson
+// @Produce  json
+// @Param kind path string true "What the file holds" Enums(products, categories, discounts)
+// @Param format query string false "File format, taken from the Content-Type when missing" Enums(csv, jsonl)
+// @Param dry_run query bool false "Only validate the rows"
+// @Param atomic query bool false "Import every row or none"
+// @Success 200 {object} ImportResult
+// @Failure 400 {object} apierror.ApiError "Wrong kind, format or CSV header"
+// @Failure 413 {object} apierror.ApiError "Body too large"
+// @Failure 429 {object} apierror.ApiError "Too many requests"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/import/{kind} [post]
+func (h *handler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	kind, err := ParseKind(mux.Vars(r)["kind"])
+	if err != nil {
+		response.RespondWithError(w, err)
+		return
+	}
+	format, err := ParseFormat(formatFromRequest(r))
+	if err != nil {
+		response.RespondWithError(w, err)
+		return
+	}
+
+	// a large file takes longer to upload and import than the timeouts of the server, its size is
+	// bounded by the bulk body limit
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	query := r.URL.Query()
+	opts := ImportOptions{}
+	opts.DryRun, _ = strconv.ParseBool(query.Get("dry_run"))
+	opts.Atomic, _ = strconv.ParseBool(query.Get("atomic"))
+
+	result, err := h.service.Import(ctx, kind, format, r.Body, opts)
+	if err != nil {
+		h.logger.WithField("kind", kind).WithError(err).Error(ctx, "Error importing catalog")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, result)
+}
+
+// Export godoc
+// @Summary Export the catalog
+// @Description Stream every product with its computed price and stock, as CSV (a row per product and variant) or JSON Lines
+// @Produce  text/csv
+// @Produce  application/x-ndjson
+// @Param format query string false "File format" Enums(csv, jsonl) default(jsonl)
+// @Success 200 {string} string "Catalog file"
+// @Failure 400 {object} apierror.ApiError "Wrong format"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/export/products [get]
+func (h *handler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, err := ParseFormat(formatFromRequest(r))
+	if err != nil {
+		response.RespondWithError(w, err)
+		return
+	}
+
+	// the whole catalog takes longer to stream than the write timeout of the server
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	ew := &exportWriter{ResponseWriter: w, format: format}
+	if err := h.service.Export(ctx, format, ew); err != nil {
+		h.logger.WithError(err).Error(ctx, "Error exporting catalog")
+		if !ew.started {
+			response.RespondWithError(w, err)
+		}
+	}
+}
+
+// formatFromRequest returns the format query param, or the one matching the Content-Type
+func formatFromRequest(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		return string(CSV)
+	}
+	return string(JSONL)
+}
+
+// exportWriter only sends the file headers once the export starts writing, so errors
+// happening before can still be returned as JSON
+type exportWriter struct {
+	http.ResponseWriter
+	format  Format
+	started bool
+}
+
+func (w *exportWriter) Write(data []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		contentType := "application/x-ndjson"
+		if w.format == CSV {
+			contentType = "text/csv; charset=UTF-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=products.%s", w.format))
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush sends what was written so far, so the file reaches the client as it's exported
+func (w *exportWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap gives the http.ResponseController access to the wrapped writer
+func (w *exportWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
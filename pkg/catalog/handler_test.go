@@ -0,0 +1,132 @@
+package catalog_test
+
+import (
+	"encoding/json"
+	"io"
+	"mytheresa/internal/apierror"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/catalog"
+	catalogmocks "mytheresa/pkg/catalog/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewHandler(t *testing.T) {
+	h := catalog.NewHandler(&catalogmocks.Service{}, &loggermocks.NoopLogger{})
+
+	assert.NotNil(t, h)
+}
+
+func TestHandlerImport_OK(t *testing.T) {
+	cs := catalogmocks.Service{}
+	cs.On("Import", mock.Anything, catalog.Products, catalog.CSV, mock.Anything, catalog.ImportOptions{DryRun: true}).
+		Return(catalog.ImportResult{Kind: catalog.Products, Total: 1, Valid: 1, DryRun: true}, nil)
+
+	h := catalog.NewHandler(&cs, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/import/products?dry_run=true", strings.NewReader("sku\n000001\n"))
+	r.Header.Set("Content-Type", "text/csv")
+	r = mux.SetURLVars(r, map[string]string{"kind": "products"})
+	w := httptest.NewRecorder()
+
+	h.Import(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result catalog.ImportResult
+	err := json.NewDecoder(w.Body).Decode(&result)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Valid)
+	assert.True(t, result.DryRun)
+}
+
+func TestHandlerImport_OutlastsServerTimeouts(t *testing.T) {
+	cs := catalogmocks.Service{}
+	var imported string
+	cs.On("Import", mock.Anything, catalog.Products, catalog.CSV, mock.Anything, catalog.ImportOptions{}).Run(func(args mock.Arguments) {
+		body, _ := io.ReadAll(args.Get(3).(io.Reader))
+		imported = string(body)
+		// applying the rows outlasts the write timeout too
+		time.Sleep(100 * time.Millisecond)
+	}).Return(catalog.ImportResult{Kind: catalog.Products, Total: 2, Valid: 2, Imported: 2}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/import/{kind}", catalog.NewHandler(&cs, &loggermocks.NoopLogger{}).Import)
+	srv := httptest.NewUnstartedServer(router)
+	srv.Config.ReadTimeout = 50 * time.Millisecond
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	// a slow upload, longer than the read timeout
+	body, upload := io.Pipe()
+	go func() {
+		for _, row := range []string{"sku\n", "000001\n", "000002\n"} {
+			time.Sleep(40 * time.Millisecond)
+			_, _ = io.WriteString(upload, row)
+		}
+		upload.Close()
+	}()
+	res, err := http.Post(srv.URL+"/import/products", "text/csv", body)
+
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var result catalog.ImportResult
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, "sku\n000001\n000002\n", imported)
+}
+
+func TestHandlerImport_UnknownKind(t *testing.T) {
+	h := catalog.NewHandler(&catalogmocks.Service{}, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/import/coupons", strings.NewReader(""))
+	r = mux.SetURLVars(r, map[string]string{"kind": "coupons"})
+	w := httptest.NewRecorder()
+
+	h.Import(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerExport_CSV(t *testing.T) {
+	cs := catalogmocks.Service{}
+	cs.On("Export", mock.Anything, catalog.CSV, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = io.WriteString(args.Get(2).(io.Writer), "sku\n000001\n")
+	}).Return(nil)
+
+	h := catalog.NewHandler(&cs, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodGet, "/export/products?format=csv", nil)
+	w := httptest.NewRecorder()
+
+	h.Export(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=products.csv", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "sku\n000001\n", w.Body.String())
+}
+
+func TestHandlerExport_ErrorBeforeWriting(t *testing.T) {
+	cs := catalogmocks.Service{}
+	cs.On("Export", mock.Anything, catalog.JSONL, mock.Anything).Return(apierror.InternalServerError("Failed to get products from database"))
+
+	h := catalog.NewHandler(&cs, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodGet, "/export/products", nil)
+	w := httptest.NewRecorder()
+
+	h.Export(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"context"
+	"io"
+	"mytheresa/pkg/catalog"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Service struct {
+	mock.Mock
+}
+
+func (s *Service) Import(ctx context.Context, kind catalog.Kind, format catalog.Format, r io.Reader, opts catalog.ImportOptions) (catalog.ImportResult, error) {
+	args := s.Called(ctx, kind, format, r, opts)
+	return args.Get(0).(catalog.ImportResult), args.Error(1)
+}
+
+func (s *Service) Export(ctx context.Context, format catalog.Format, w io.Writer) error {
+	args := s.Called(ctx, format, w)
+	return args.Error(0)
+}
@@ -0,0 +1,136 @@
+package catalog
+
+import (
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/pkg/product"
+	"strconv"
+)
+
+// Kind is the type of entity found on every row of an import file
+type Kind string
+
+const (
+	Products   Kind = "products"
+	Categories Kind = "categories"
+	Discounts  Kind = "discounts"
+)
+
+// Format is the encoding of an import or export file
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+)
+
+func ParseKind(value string) (Kind, error) {
+	switch k := Kind(value); k {
+	case Products, Categories, Discounts:
+		return k, nil
+	}
+	return "", apierror.BadRequest(fmt.Sprintf("Unknown kind %s, use products, categories or discounts", value))
+}
+
+func ParseFormat(value string) (Format, error) {
+	switch f := Format(value); f {
+	case CSV, JSONL:
+		return f, nil
+	}
+	return "", apierror.BadRequest(fmt.Sprintf("Unknown format %s, use csv or jsonl", value))
+}
+
+// ImportOptions changes how the rows of an import file are written
+type ImportOptions struct {
+	// DryRun only parses and validates the rows, nothing is written
+	DryRun bool
+	// Atomic imports every row or none of them
+	Atomic bool
+}
+
+// RowError is the reason a row of an import file was not imported
+// @Description RowError tells the line of the file that failed and why
+type RowError struct {
+	Line    int    `json:"line" example:"3"`
+	Message string `json:"message" example:"SKU is required"`
+}
+
+// ImportResult represents the outcome of an import
+// @Description ImportResult counts the rows of the file and lists the ones that failed
+// @Produce json
+// @Success 200 {object} ImportResult
+type ImportResult struct {
+	Kind     Kind       `json:"kind" example:"products"`
+	Total    int        `json:"total" example:"10"`
+	Valid    int        `json:"valid" example:"9"`
+	Imported int        `json:"imported" example:"9"`
+	Failed   int        `json:"failed" example:"1"`
+	DryRun   bool       `json:"dry_run" example:"false"`
+	Atomic   bool       `json:"atomic" example:"false"`
+	Errors   []RowError `json:"errors"`
+}
+
+func (r *ImportResult) addError(line int, err error) {
+	r.Failed++
+	r.Errors = append(r.Errors, RowError{Line: line, Message: err.Error()})
+}
+
+// ExportRow is a product or variant of the exported catalog, with its computed price
+type ExportRow struct {
+	SKU                string
+	ParentSKU          string
+	Name               string
+	Category           string
+	Size               string
+	Colour             string
+	OriginalPrice      int
+	FinalPrice         int
+	DiscountPercentage string
+	Currency           string
+	LowestPrice30d     string
+	Stock              int
+}
+
+var exportHeader = []string{
+	"sku", "parent_sku", "name", "category", "size", "colour",
+	"original_price", "final_price", "discount_percentage", "currency", "lowest_price_30d", "stock",
+}
+
+// NewExportRows flattens a product into one row for itself followed by one row per variant
+func NewExportRows(p product.ProductResponse) []ExportRow {
+	rows := []ExportRow{newExportRow(p.SKU, "", p.Name, p.Category, p.Price, p.Stock)}
+	for _, v := range p.Variants {
+		row := newExportRow(v.SKU, p.SKU, p.Name, p.Category, v.Price, v.Stock)
+		row.Size = v.Size
+		row.Colour = v.Colour
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func newExportRow(sku, parentSKU, name, category string, price product.PriceResponse, stock int) ExportRow {
+	row := ExportRow{
+		SKU:           sku,
+		ParentSKU:     parentSKU,
+		Name:          name,
+		Category:      category,
+		OriginalPrice: price.Original,
+		FinalPrice:    price.Final,
+		Currency:      price.Currency,
+		Stock:         stock,
+	}
+	if price.DiscountPercentage != nil {
+		row.DiscountPercentage = *price.DiscountPercentage
+	}
+	if price.LowestPrice30d != nil {
+		row.LowestPrice30d = strconv.Itoa(*price.LowestPrice30d)
+	}
+	return row
+}
+
+func (r *ExportRow) toCSV() []string {
+	return []string{
+		r.SKU, r.ParentSKU, r.Name, r.Category, r.Size, r.Colour,
+		fmt.Sprint(r.OriginalPrice), fmt.Sprint(r.FinalPrice), r.DiscountPercentage, r.Currency, r.LowestPrice30d, fmt.Sprint(r.Stock),
+	}
+}
@@ -0,0 +1,56 @@
+package catalog_test
+
+import (
+	"mytheresa/pkg/catalog"
+	"mytheresa/pkg/product"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKind(t *testing.T) {
+	kind, err := catalog.ParseKind("discounts")
+	assert.NoError(t, err)
+	assert.Equal(t, catalog.Discounts, kind)
+
+	_, err = catalog.ParseKind("coupons")
+	assert.EqualError(t, err, "Unknown kind coupons, use products, categories or discounts")
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := catalog.ParseFormat("csv")
+	assert.NoError(t, err)
+	assert.Equal(t, catalog.CSV, format)
+
+	_, err = catalog.ParseFormat("xml")
+	assert.EqualError(t, err, "Unknown format xml, use csv or jsonl")
+}
+
+func TestNewExportRows(t *testing.T) {
+	percentage := "30"
+	lowest := 800
+	p := product.ProductResponse{
+		SKU:      "000005",
+		Name:     "Sneakers",
+		Category: "sneakers",
+		Price:    product.PriceResponse{Original: 1000, Final: 700, DiscountPercentage: &percentage, Currency: "EUR", LowestPrice30d: &lowest},
+		Stock:    3,
+		Variants: []product.VariantResponse{
+			{SKU: "000005-42", Size: "42", Colour: "white", Price: product.PriceResponse{Original: 1200, Final: 1200, Currency: "EUR"}, Stock: 3},
+		},
+	}
+
+	rows := catalog.NewExportRows(p)
+
+	assert.Len(t, rows, 2)
+	assert.Equal(t, catalog.ExportRow{
+		SKU: "000005", Name: "Sneakers", Category: "sneakers",
+		OriginalPrice: 1000, FinalPrice: 700, DiscountPercentage: "30", Currency: "EUR", LowestPrice30d: "800", Stock: 3,
+	}, rows[0])
+	assert.Equal(t, "000005", rows[1].ParentSKU)
+	assert.Equal(t, "42", rows[1].Size)
+	assert.Equal(t, "white", rows[1].Colour)
+	assert.Equal(t, 1200, rows[1].FinalPrice)
+	assert.Equal(t, "", rows[1].DiscountPercentage)
+	assert.Equal(t, "", rows[1].LowestPrice30d)
+}
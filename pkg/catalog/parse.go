@@ -0,0 +1,212 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mytheresa/internal/apierror"
+	"mytheresa/pkg/category"
+	"mytheresa/pkg/discount"
+	"mytheresa/pkg/product"
+	"strconv"
+	"strings"
+)
+
+// maxLineSize is the longest JSON Lines row accepted, products with many variants fit easily
+const maxLineSize = 1024 * 1024
+
+// row is a request read from an import file, err is set when the row can't be imported
+type row struct {
+	line    int
+	request validator
+	err     error
+}
+
+type validator interface {
+	Validate() error
+}
+
+// csvColumns are the columns accepted by the CSV files of every kind
+var csvColumns = map[Kind][]string{
+	Products:   {"sku", "name", "price", "category_id", "stock"},
+	Categories: {"name"},
+	Discounts: {
+		"discount_type_id", "target", "percentage", "coupon_only",
+		"buy_quantity", "free_quantity", "min_spend", "bundle_price",
+	},
+}
+
+// parse reads every row of the file. Errors affecting the whole file, like an unknown CSV
+// column, are returned, while the ones of a single row are kept in that row.
+func parse(kind Kind, format Format, r io.Reader) ([]row, error) {
+	if format == CSV {
+		return parseCSV(kind, r)
+	}
+	return parseJSONL(kind, r)
+}
+
+func parseJSONL(kind Kind, r io.Reader) ([]row, error) {
+	var rows []row
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		req := newRequest(kind)
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(req); err != nil {
+			rows = append(rows, row{line: line, err: fmt.Errorf("invalid JSON: %w", err)})
+			continue
+		}
+		rows = append(rows, row{line: line, request: req})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, apierror.BadRequest(fmt.Sprintf("Error reading line %d: %s", line+1, err))
+	}
+
+	return rows, nil
+}
+
+func parseCSV(kind Kind, r io.Reader) ([]row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apierror.BadRequest(fmt.Sprintf("Error reading CSV header: %s", err))
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !contains(csvColumns[kind], name) {
+			return nil, apierror.BadRequest(fmt.Sprintf("Unknown column %s, %s accept %s",
+				name, kind, strings.Join(csvColumns[kind], ", ")))
+		}
+		columns[name] = i
+	}
+
+	var rows []row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, apierror.BadRequest(fmt.Sprintf("Error reading CSV: %s", err))
+			}
+			rows = append(rows, row{line: parseErr.StartLine, err: parseErr.Err})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		fields := csvRecord{columns: columns, values: record}
+		req := fields.toRequest(kind)
+		rows = append(rows, row{line: line, request: req, err: fields.err})
+	}
+
+	return rows, nil
+}
+
+func newRequest(kind Kind) validator {
+	switch kind {
+	case Categories:
+		return &category.CategoryRequest{}
+	case Discounts:
+		return &discount.DiscountRequest{}
+	default:
+		return &product.ProductRequest{}
+	}
+}
+
+// csvRecord reads the values of a CSV row by column name, keeping the first conversion error
+type csvRecord struct {
+	columns map[string]int
+	values  []string
+	err     error
+}
+
+func (r *csvRecord) toRequest(kind Kind) validator {
+	switch kind {
+	case Categories:
+		return &category.CategoryRequest{
+			Name: r.string("name"),
+		}
+	case Discounts:
+		return &discount.DiscountRequest{
+			DiscountTypeID: r.int("discount_type_id"),
+			Target:         r.string("target"),
+			Percentage:     r.int("percentage"),
+			CouponOnly:     r.bool("coupon_only"),
+			BuyQuantity:    r.int("buy_quantity"),
+			FreeQuantity:   r.int("free_quantity"),
+			MinSpend:       r.int("min_spend"),
+			BundlePrice:    r.int("bundle_price"),
+		}
+	default:
+		return &product.ProductRequest{
+			SKU:        r.string("sku"),
+			Name:       r.string("name"),
+			Price:      r.int("price"),
+			CategoryID: r.int("category_id"),
+			Stock:      r.int("stock"),
+		}
+	}
+}
+
+func (r *csvRecord) string(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+func (r *csvRecord) int(column string) int {
+	value := r.string(column)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("%s must be a number, got %q", column, value)
+	}
+	return n
+}
+
+func (r *csvRecord) bool(column string) bool {
+	value := r.string(column)
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("%s must be true or false, got %q", column, value)
+	}
+	return b
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
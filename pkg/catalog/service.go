@@ -0,0 +1,215 @@
+package catalog
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/category"
+	"mytheresa/pkg/discount"
+	"mytheresa/pkg/product"
+	"net/http"
+)
+
+type Service interface {
+	Import(ctx context.Context, kind Kind, format Format, r io.Reader, opts ImportOptions) (ImportResult, error)
+	Export(ctx context.Context, format Format, w io.Writer) error
+}
+
+type service struct {
+	db              database.Database
+	logger          logger.Logger
+	categoryService category.Service
+	productService  product.Service
+	discountService discount.Service
+}
+
+func NewService(db database.Database, logger logger.Logger, cs category.Service, ps product.Service, ds discount.Service) Service {
+	return &service{
+		db:              db,
+		logger:          logger,
+		categoryService: cs,
+		productService:  ps,
+		discountService: ds,
+	}
+}
+
+// Import creates every row of the file through the service of its kind, so rows are validated
+// exactly as the ones created one by one. Rows failing are reported with their line and the
+// rest are still imported, unless the import is atomic: then every row is written in a single
+// transaction, rolled back as soon as one of them fails.
+func (s *service) Import(ctx context.Context, kind Kind, format Format, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	ctx, span := tracing.Start(ctx, "catalog.Import")
+	defer span.End()
+
+	rows, err := parse(kind, format, r)
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "error parsing import file")
+		return ImportResult{}, err
+	}
+
+	result := ImportResult{Kind: kind, Total: len(rows), DryRun: opts.DryRun, Atomic: opts.Atomic, Errors: []RowError{}}
+	var valid []row
+	for _, rw := range rows {
+		if rw.err == nil {
+			rw.err = rw.request.Validate()
+		}
+		if rw.err != nil {
+			result.addError(rw.line, rw.err)
+			continue
+		}
+		valid = append(valid, rw)
+	}
+	result.Valid = len(valid)
+
+	if opts.DryRun || (opts.Atomic && result.Failed > 0) {
+		return result, nil
+	}
+
+	if opts.Atomic {
+		err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+			for _, rw := range valid {
+				if err := s.create(ctx, rw.request); err != nil {
+					result.addError(rw.line, err)
+					return err
+				}
+				result.Imported++
+			}
+			return nil
+		})
+		if err != nil && result.Failed == 0 {
+			s.logger.WithError(err).Error(ctx, "error committing import")
+			return ImportResult{}, apierror.InternalServerError("error importing catalog")
+		}
+		if err != nil {
+			result.Imported = 0
+		}
+	} else {
+		for _, rw := range valid {
+			if err := s.create(ctx, rw.request); err != nil {
+				result.addError(rw.line, err)
+				continue
+			}
+			result.Imported++
+		}
+	}
+
+	s.logger.
+		WithField("kind", kind).
+		WithField("imported", result.Imported).
+		WithField("failed", result.Failed).
+		Info(ctx, "catalog imported")
+	return result, nil
+}
+
+func (s *service) create(ctx context.Context, req validator) error {
+	var err error
+	switch r := req.(type) {
+	case *category.CategoryRequest:
+		_, err = s.categoryService.CreateCategory(ctx, *r)
+	case *discount.DiscountRequest:
+		_, err = s.discountService.CreateDiscount(ctx, *r)
+	case *product.ProductRequest:
+		_, err = s.productService.CreateProduct(ctx, *r)
+	}
+	return err
+}
+
+// exportPageSize is how many products the export reads at a time
+const exportPageSize = 500
+
+// Export writes every product of the catalog with its computed price and stock, reading and
+// writing them a page at a time so the whole catalog is never held in memory. JSON Lines files
+// have a product per line with its variants nested, CSV files a row per product and variant.
+func (s *service) Export(ctx context.Context, format Format, w io.Writer) error {
+	ctx, span := tracing.Start(ctx, "catalog.Export")
+	defer span.End()
+
+	var e exporter = newJSONLExporter(w)
+	if format == CSV {
+		e = newCSVExporter(w)
+	}
+
+	opts := product.ListOptions{Limit: exportPageSize}
+	for started := false; ; started = true {
+		products, err := s.productService.ListProducts(ctx, opts)
+		if err != nil {
+			s.logger.WithError(err).Error(ctx, "error getting products to export")
+			return err
+		}
+		if !started {
+			if err := e.start(); err != nil {
+				return err
+			}
+		}
+		if err := e.write(products); err != nil {
+			return err
+		}
+		// sent as it goes rather than once the whole catalog is read
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if len(products) < exportPageSize {
+			return nil
+		}
+		opts.AfterSKU = products[len(products)-1].SKU
+	}
+}
+
+// exporter writes the products in a file format, flushing what it buffers after every page
+type exporter interface {
+	start() error
+	write(products []product.ProductResponse) error
+}
+
+type csvExporter struct {
+	writer *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) *csvExporter {
+	return &csvExporter{writer: csv.NewWriter(w)}
+}
+
+func (e *csvExporter) start() error {
+	return e.writer.Write(exportHeader)
+}
+
+func (e *csvExporter) write(products []product.ProductResponse) error {
+	for _, p := range products {
+		for _, r := range NewExportRows(p) {
+			if err := e.writer.Write(r.toCSV()); err != nil {
+				return err
+			}
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonlExporter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func newJSONLExporter(w io.Writer) *jsonlExporter {
+	buffered := bufio.NewWriter(w)
+	return &jsonlExporter{buffered: buffered, encoder: json.NewEncoder(buffered)}
+}
+
+func (e *jsonlExporter) start() error {
+	return nil
+}
+
+func (e *jsonlExporter) write(products []product.ProductResponse) error {
+	for _, p := range products {
+		if err := e.encoder.Encode(p); err != nil {
+			return err
+		}
+	}
+	return e.buffered.Flush()
+}
@@ -0,0 +1,224 @@
+package catalog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mytheresa/internal/apierror"
+	dbmocks "mytheresa/internal/database/mocks"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/catalog"
+	"mytheresa/pkg/category"
+	categorymocks "mytheresa/pkg/category/mocks"
+	"mytheresa/pkg/discount"
+	discountmocks "mytheresa/pkg/discount/mocks"
+	"mytheresa/pkg/product"
+	productmocks "mytheresa/pkg/product/mocks"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewService(t *testing.T) {
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &productmocks.Service{}, &discountmocks.Service{})
+
+	assert.NotNil(t, s)
+}
+
+const productsCSV = `sku,name,price,category_id,stock
+000010,Boots,10000,1,5
+,Missing SKU,10000,1,0
+000011,Wrong price,ten,1,0
+000012,Sandals,8000,2,
+`
+
+func TestImport_CSVReportsRowErrors(t *testing.T) {
+	ps := productmocks.Service{}
+	ps.On("CreateProduct", mock.Anything, mock.Anything).Return(product.Product{}, nil)
+
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &ps, &discountmocks.Service{})
+
+	result, err := s.Import(context.Background(), catalog.Products, catalog.CSV, strings.NewReader(productsCSV), catalog.ImportOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Total)
+	assert.Equal(t, 2, result.Valid)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, []catalog.RowError{
+		{Line: 3, Message: "SKU is required"},
+		{Line: 4, Message: `price must be a number, got "ten"`},
+	}, result.Errors)
+	ps.AssertCalled(t, "CreateProduct", mock.Anything, product.ProductRequest{SKU: "000010", Name: "Boots", Price: 10000, CategoryID: 1, Stock: 5})
+	ps.AssertCalled(t, "CreateProduct", mock.Anything, product.ProductRequest{SKU: "000012", Name: "Sandals", Price: 8000, CategoryID: 2})
+}
+
+func TestImport_DryRunWritesNothing(t *testing.T) {
+	ps := productmocks.Service{}
+
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &ps, &discountmocks.Service{})
+
+	result, err := s.Import(context.Background(), catalog.Products, catalog.CSV, strings.NewReader(productsCSV), catalog.ImportOptions{DryRun: true})
+
+	assert.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 2, result.Valid)
+	assert.Equal(t, 0, result.Imported)
+	assert.Equal(t, 2, result.Failed)
+	ps.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+}
+
+func TestImport_AtomicWritesNothingWithInvalidRows(t *testing.T) {
+	ps := productmocks.Service{}
+
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &ps, &discountmocks.Service{})
+
+	result, err := s.Import(context.Background(), catalog.Products, catalog.CSV, strings.NewReader(productsCSV), catalog.ImportOptions{Atomic: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Imported)
+	assert.Equal(t, 2, result.Failed)
+	ps.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+}
+
+func TestImport_AtomicRollsBackOnWriteError(t *testing.T) {
+	ds := discountmocks.Service{}
+	ds.On("CreateDiscount", mock.Anything, discount.DiscountRequest{DiscountTypeID: 1, Target: "1", Percentage: 10}).
+		Return(&discount.GeneralDiscount{}, nil)
+	ds.On("CreateDiscount", mock.Anything, discount.DiscountRequest{DiscountTypeID: 2, Target: "000001", Percentage: 20}).
+		Return(&discount.GeneralDiscount{}, apierror.InternalServerError("error creating discount"))
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+
+	s := catalog.NewService(&dbmock, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &productmocks.Service{}, &ds)
+
+	file := `{"discount_type_id":1,"target":"1","percentage":10}
+
+{"discount_type_id":2,"target":"000001","percentage":20}
+{"discount_type_id":3,"percentage":5}
+`
+	result, err := s.Import(context.Background(), catalog.Discounts, catalog.JSONL, strings.NewReader(file), catalog.ImportOptions{Atomic: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Total)
+	assert.Equal(t, 0, result.Imported)
+	assert.Equal(t, []catalog.RowError{{Line: 3, Message: "error creating discount"}}, result.Errors)
+	ds.AssertNumberOfCalls(t, "CreateDiscount", 2)
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+}
+
+func TestImport_JSONLCategories(t *testing.T) {
+	cs := categorymocks.Service{}
+	cs.On("CreateCategory", mock.Anything, category.CategoryRequest{Name: "bags"}).Return(category.Category{ID: 4, Name: "bags"}, nil)
+
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &cs, &productmocks.Service{}, &discountmocks.Service{})
+
+	file := `{"name":"bags"}
+{"name":""}
+{"name":"hats","colour":"red"}
+not json
+`
+	result, err := s.Import(context.Background(), catalog.Categories, catalog.JSONL, strings.NewReader(file), catalog.ImportOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Total)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, 3, result.Failed)
+	assert.Equal(t, "name is required", result.Errors[0].Message)
+	assert.Equal(t, 3, result.Errors[1].Line)
+	assert.Contains(t, result.Errors[1].Message, "unknown field")
+	assert.Equal(t, 4, result.Errors[2].Line)
+}
+
+func TestImport_UnknownCSVColumn(t *testing.T) {
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &productmocks.Service{}, &discountmocks.Service{})
+
+	_, err := s.Import(context.Background(), catalog.Categories, catalog.CSV, strings.NewReader("name,colour\nbags,red\n"), catalog.ImportOptions{})
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "Unknown column colour, categories accept name", apierr.Error())
+}
+
+func exportedProducts() []product.ProductResponse {
+	return []product.ProductResponse{
+		{
+			SKU: "000001", Name: "Boots", Category: "boots", Stock: 2,
+			Price: product.PriceResponse{Original: 1000, Final: 1000, Currency: "EUR"},
+			Variants: []product.VariantResponse{
+				{SKU: "000001-42", Size: "42", Colour: "black", Stock: 2, Price: product.PriceResponse{Original: 1000, Final: 1000, Currency: "EUR"}},
+			},
+		},
+	}
+}
+
+func TestExport_CSV(t *testing.T) {
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, product.ListOptions{Limit: 500}).Return(exportedProducts(), nil)
+
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &ps, &discountmocks.Service{})
+
+	var out bytes.Buffer
+	err := s.Export(context.Background(), catalog.CSV, &out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `sku,parent_sku,name,category,size,colour,original_price,final_price,discount_percentage,currency,lowest_price_30d,stock
+000001,,Boots,boots,,,1000,1000,,EUR,,2
+000001-42,000001,Boots,boots,42,black,1000,1000,,EUR,,2
+`, out.String())
+}
+
+func TestExport_JSONL(t *testing.T) {
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, product.ListOptions{Limit: 500}).Return(exportedProducts(), nil)
+
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &ps, &discountmocks.Service{})
+
+	var out bytes.Buffer
+	err := s.Export(context.Background(), catalog.JSONL, &out)
+
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 1)
+
+	var p product.ProductResponse
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &p))
+	assert.Equal(t, exportedProducts()[0], p)
+}
+
+func TestExport_ReadsPages(t *testing.T) {
+	var page []product.ProductResponse
+	for i := 0; i < 500; i++ {
+		page = append(page, product.ProductResponse{SKU: fmt.Sprintf("%06d", i)})
+	}
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, product.ListOptions{Limit: 500}).Return(page, nil).Once()
+	ps.On("ListProducts", mock.Anything, product.ListOptions{Limit: 500, AfterSKU: "000499"}).Return(exportedProducts(), nil).Once()
+
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &ps, &discountmocks.Service{})
+
+	var out bytes.Buffer
+	err := s.Export(context.Background(), catalog.JSONL, &out)
+
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 501)
+	ps.AssertExpectations(t)
+}
+
+func TestExport_ErrorListingProducts(t *testing.T) {
+	listErr := apierror.InternalServerError("Failed to get products from database")
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, mock.Anything).Return([]product.ProductResponse{}, listErr)
+
+	s := catalog.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, &categorymocks.Service{}, &ps, &discountmocks.Service{})
+
+	var out bytes.Buffer
+	err := s.Export(context.Background(), catalog.CSV, &out)
+
+	assert.Equal(t, listErr, err)
+	assert.Empty(t, out.String())
+}
@@ -15,3 +15,8 @@ func (s *Service) CreateCategory(ctx context.Context, c category.CategoryRequest
 	args := s.Called(ctx, c)
 	return args.Get(0).(category.Category), args.Error(1)
 }
+
+func (s *Service) GetCategories(ctx context.Context, ids []int) ([]category.Category, error) {
+	args := s.Called(ctx, ids)
+	return args.Get(0).([]category.Category), args.Error(1)
+}
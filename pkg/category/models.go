@@ -2,12 +2,15 @@ package category
 
 import (
 	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
 	"strconv"
 )
 
 type Category struct {
-	ID   int    `gorm:"primaryKey" json:"id"`
-	Name string `gorm:"unique;not null" json:"name"`
+	ID   int    `gorm:"primaryKey" json:"id" xml:"id"`
+	Name string `gorm:"unique;not null" json:"name" xml:"name"`
+	database.Versioned
 }
 
 type CategoryRequest struct {
@@ -19,6 +22,14 @@ type CategoryResponse struct {
 	Name string `json:"name"`
 }
 
+// Validate checks the category can be created
+func (c *CategoryRequest) Validate() error {
+	if c.Name == "" {
+		return apierror.BadRequest("name is required")
+	}
+	return nil
+}
+
 func (c *CategoryRequest) ToCategory() Category {
 	return Category{
 		Name: c.Name,
@@ -35,3 +46,30 @@ func (c *Category) ToCategoryResponse() CategoryResponse {
 func (c *Category) GetIdentifier() string {
 	return fmt.Sprint(c.ID)
 }
+
+type idFilter struct {
+	field   string
+	Value   []int
+	Operand string
+}
+
+func (f *idFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *idFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *idFilter) GetOperand() string {
+	return f.Operand
+}
+
+// NewIDFilter matches the categories whose ID is any of the given ones
+func NewIDFilter(ids []int) database.Filter {
+	return &idFilter{
+		field:   "id",
+		Value:   ids,
+		Operand: "IN",
+	}
+}
@@ -29,3 +29,8 @@ func TestCategory_GetIdentifier(t *testing.T) {
 
 	assert.Equal(t, fmt.Sprint(c.ID), identifier)
 }
+
+func TestCategoryRequest_Validate(t *testing.T) {
+	assert.NoError(t, (&category.CategoryRequest{Name: "boots"}).Validate())
+	assert.EqualError(t, (&category.CategoryRequest{}).Validate(), "name is required")
+}
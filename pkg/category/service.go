@@ -5,28 +5,64 @@ import (
 	"mytheresa/internal/apierror"
 	"mytheresa/internal/database"
 	"mytheresa/internal/logger"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/audit"
 )
 
 type Service interface {
 	CreateCategory(ctx context.Context, category CategoryRequest) (Category, error)
+	GetCategories(ctx context.Context, ids []int) ([]Category, error)
 }
 
 type service struct {
-	db     database.Database
-	logger logger.Logger
+	db           database.Database
+	logger       logger.Logger
+	auditService audit.Service
 }
 
-func NewService(db database.Database, logger logger.Logger) Service {
-	return &service{db: db, logger: logger}
+func NewService(db database.Database, logger logger.Logger, as audit.Service) Service {
+	return &service{db: db, logger: logger, auditService: as}
 }
 
 func (s *service) CreateCategory(ctx context.Context, req CategoryRequest) (Category, error) {
+	ctx, span := tracing.Start(ctx, "category.CreateCategory")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		return Category{}, err
+	}
+
 	category := req.ToCategory()
-	err := s.db.Save(ctx, category.GetIdentifier(), &category)
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		err := s.db.Save(ctx, category.GetIdentifier(), &category)
+		if err != nil {
+			s.logger.WithError(err).Error(ctx, "failed to save category")
+			return apierror.InternalServerError("there was an error saving the category")
+		}
+		return s.auditService.Record(ctx, audit.Category, category.GetIdentifier(), audit.Create, nil, category)
+	})
 	if err != nil {
-		s.logger.WithError(err).Error(ctx, "failed to save category")
-		return Category{}, apierror.InternalServerError("there was an error saving the category")
+		return Category{}, err
 	}
 
 	return category, nil
 }
+
+// GetCategories returns the categories with the given IDs in a single query, every category when
+// no ID is given. IDs not found are left out.
+func (s *service) GetCategories(ctx context.Context, ids []int) ([]Category, error) {
+	ctx, span := tracing.Start(ctx, "category.GetCategories")
+	defer span.End()
+
+	var filters []database.Filter
+	if len(ids) > 0 {
+		filters = append(filters, NewIDFilter(ids))
+	}
+
+	categories := []Category{}
+	if err := s.db.GetWithFilters(ctx, &categories, filters...); err != nil {
+		s.logger.WithError(err).Error(ctx, "failed to get categories")
+		return nil, apierror.InternalServerError("there was an error getting the categories")
+	}
+	return categories, nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
 	databasemocks "mytheresa/internal/database/mocks"
 	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/audit"
+	auditmocks "mytheresa/pkg/audit/mocks"
 	"mytheresa/pkg/category"
 	"net/http"
 	"testing"
@@ -18,7 +21,7 @@ func TestNewService(t *testing.T) {
 	dbmock := databasemocks.Database{}
 	logmock := loggermocks.NoopLogger{}
 
-	s := category.NewService(&dbmock, &logmock)
+	s := category.NewService(&dbmock, &logmock, auditmocks.AnyService())
 
 	assert.NotNil(t, s)
 }
@@ -33,7 +36,7 @@ func TestService_CreateCategory_Success(t *testing.T) {
 
 	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-	s := category.NewService(&dbmock, &logmock)
+	s := category.NewService(&dbmock, &logmock, auditmocks.AnyService())
 
 	result, err := s.CreateCategory(context.Background(), catReq)
 
@@ -51,7 +54,7 @@ func TestService_CreateCategory_Error(t *testing.T) {
 
 	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
 
-	s := category.NewService(&dbmock, &logmock)
+	s := category.NewService(&dbmock, &logmock, auditmocks.AnyService())
 	_, err := s.CreateCategory(context.Background(), catReq)
 
 	assert.NotNil(t, err)
@@ -62,3 +65,63 @@ func TestService_CreateCategory_Error(t *testing.T) {
 	assert.Equal(t, "there was an error saving the category", apierr.Error())
 	assert.Equal(t, http.StatusInternalServerError, apierr.Code())
 }
+
+func TestService_CreateCategory_RecordsAudit(t *testing.T) {
+	dbmock := databasemocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(2).(*category.Category).ID = 7
+	}).Return(nil)
+	as := auditmocks.Service{}
+	as.On("Record", mock.Anything, audit.Category, "7", audit.Create, nil, category.Category{ID: 7, Name: "boots"}).Return(nil)
+
+	s := category.NewService(&dbmock, &loggermocks.NoopLogger{}, &as)
+	_, err := s.CreateCategory(context.Background(), category.CategoryRequest{Name: "boots"})
+
+	assert.NoError(t, err)
+	as.AssertExpectations(t)
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+}
+
+func TestService_CreateCategory_ErrorRecordingAudit(t *testing.T) {
+	dbmock := databasemocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	as := auditmocks.Service{}
+	as.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(apierror.InternalServerError("error recording audit entry"))
+
+	s := category.NewService(&dbmock, &loggermocks.NoopLogger{}, &as)
+	_, err := s.CreateCategory(context.Background(), category.CategoryRequest{Name: "boots"})
+
+	assert.EqualError(t, err, "error recording audit entry")
+}
+
+func TestService_GetCategories(t *testing.T) {
+	dbmock := databasemocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, []database.Filter{category.NewIDFilter([]int{1, 3})}).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]category.Category) = []category.Category{{ID: 1, Name: "boots"}, {ID: 3, Name: "sneakers"}}
+	}).Return(nil)
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, []database.Filter(nil)).Return(nil)
+
+	s := category.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService())
+
+	categories, err := s.GetCategories(context.Background(), []int{1, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []category.Category{{ID: 1, Name: "boots"}, {ID: 3, Name: "sneakers"}}, categories)
+
+	categories, err = s.GetCategories(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, categories)
+}
+
+func TestService_GetCategories_Error(t *testing.T) {
+	dbmock := databasemocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := category.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService())
+	_, err := s.GetCategories(context.Background(), []int{1})
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusInternalServerError, apierr.Code())
+}
@@ -0,0 +1,102 @@
+package coupon
+
+import (
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/response"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler interface {
+	CreateCoupon(w http.ResponseWriter, r *http.Request)
+	RedeemCoupon(w http.ResponseWriter, r *http.Request)
+}
+
+type handler struct {
+	service Service
+	logger  logger.Logger
+}
+
+func NewHandler(service Service, logger logger.Logger) Handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// CreateCoupon godoc
+// @Summary Create a new coupon
+// @Description Create a coupon code unlocking a coupon only discount
+// @Accept  json
+// @Produce  json
+// @Param coupon body CouponRequest true "Coupon details"
+// @Success 201 {object} CouponResponse
+// @Failure 400 {object} apierror.ApiError "Wrong body"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/coupon [post]
+func (h *handler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var coupon CouponRequest
+	err := json.NewDecoder(r.Body).Decode(&coupon)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error decoding request while trying to create coupon")
+		response.RespondWithError(w, apierror.BadRequest("Wrong body"))
+		return
+	}
+
+	c, err := h.service.CreateCoupon(ctx, coupon)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error creating coupon")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusCreated, c.ToCouponResponse())
+}
+
+// RedeemCoupon godoc
+// @Summary Redeem a coupon
+// @Description Use one of the redemptions left on a coupon for a customer
+// @Accept  json
+// @Produce  json
+// @Param code path string true "Coupon code"
+// @Param redemption body RedemptionRequest true "Redemption details"
+// @Success 200 {object} CouponResponse
+// @Failure 400 {object} apierror.ApiError "Wrong body"
+// @Failure 404 {object} apierror.ApiError "Coupon not found"
+// @Failure 409 {object} apierror.ApiError "Coupon expired or exhausted"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/coupon/{code}/redeem [post]
+func (h *handler) RedeemCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := mux.Vars(r)["code"]
+
+	var redemption RedemptionRequest
+	err := json.NewDecoder(r.Body).Decode(&redemption)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error decoding request while trying to redeem coupon")
+		response.RespondWithError(w, apierror.BadRequest("Wrong body"))
+		return
+	}
+
+	c, err := h.service.RedeemCoupon(ctx, code, redemption)
+	if err != nil {
+		h.logger.WithField("code", code).WithError(err).Error(ctx, "Error redeeming coupon")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, c.ToCouponResponse())
+}
@@ -0,0 +1,105 @@
+package coupon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/coupon"
+	couponmocks "mytheresa/pkg/coupon/mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewHandler(t *testing.T) {
+	h := coupon.NewHandler(&couponmocks.Service{}, &loggermocks.NoopLogger{})
+
+	assert.NotNil(t, h)
+}
+
+func TestHandlerCreateCoupon_OK(t *testing.T) {
+	req := coupon.CouponRequest{
+		Code:           "WINTER10",
+		DiscountID:     4,
+		MaxRedemptions: 10,
+		ExpiresAt:      time.Now().Add(time.Hour).UTC(),
+	}
+	cs := couponmocks.Service{}
+	cs.On("CreateCoupon", mock.Anything, mock.Anything).Return(req.ToCoupon(), nil)
+
+	h := coupon.NewHandler(&cs, &loggermocks.NoopLogger{})
+
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/coupon", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	h.CreateCoupon(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response coupon.CouponResponse
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, "WINTER10", response.Code)
+	assert.Equal(t, "4", response.DiscountID)
+	assert.Equal(t, 10, response.RemainingRedemptions)
+}
+
+func TestHandlerCreateCoupon_WrongBody(t *testing.T) {
+	h := coupon.NewHandler(&couponmocks.Service{}, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/coupon", bytes.NewReader([]byte("invalid body")))
+	w := httptest.NewRecorder()
+
+	h.CreateCoupon(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerRedeemCoupon_OK(t *testing.T) {
+	cs := couponmocks.Service{}
+	cs.On("RedeemCoupon", mock.Anything, "WINTER10", coupon.RedemptionRequest{CustomerID: "c1"}).
+		Return(coupon.Coupon{Code: "WINTER10", MaxRedemptions: 10, RemainingRedemptions: 9}, nil)
+
+	h := coupon.NewHandler(&cs, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/coupon/WINTER10/redeem", bytes.NewReader([]byte(`{"customer_id":"c1"}`)))
+	r = mux.SetURLVars(r, map[string]string{"code": "WINTER10"})
+	w := httptest.NewRecorder()
+
+	h.RedeemCoupon(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response coupon.CouponResponse
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, response.RemainingRedemptions)
+}
+
+func TestHandlerRedeemCoupon_Exhausted(t *testing.T) {
+	cs := couponmocks.Service{}
+	cs.On("RedeemCoupon", mock.Anything, "WINTER10", mock.Anything).
+		Return(coupon.Coupon{}, apierror.Conflict("Coupon has no redemptions left"))
+
+	h := coupon.NewHandler(&cs, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/coupon/WINTER10/redeem", bytes.NewReader([]byte(`{"customer_id":"c1"}`)))
+	r = mux.SetURLVars(r, map[string]string{"code": "WINTER10"})
+	w := httptest.NewRecorder()
+
+	h.RedeemCoupon(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var apierr apierror.ApiError
+	err := json.NewDecoder(w.Body).Decode(&apierr)
+	assert.NoError(t, err)
+	assert.Equal(t, "Coupon has no redemptions left", apierr.Error())
+}
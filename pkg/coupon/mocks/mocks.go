@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"context"
+	"mytheresa/pkg/coupon"
+	"mytheresa/pkg/discount"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Service struct {
+	mock.Mock
+}
+
+func (s *Service) CreateCoupon(ctx context.Context, c coupon.CouponRequest) (coupon.Coupon, error) {
+	args := s.Called(ctx, c)
+	return args.Get(0).(coupon.Coupon), args.Error(1)
+}
+
+func (s *Service) GetCouponDiscount(ctx context.Context, code string) (discount.Discount, error) {
+	args := s.Called(ctx, code)
+	d, _ := args.Get(0).(discount.Discount)
+	return d, args.Error(1)
+}
+
+func (s *Service) RedeemCoupon(ctx context.Context, code string, r coupon.RedemptionRequest) (coupon.Coupon, error) {
+	args := s.Called(ctx, code, r)
+	return args.Get(0).(coupon.Coupon), args.Error(1)
+}
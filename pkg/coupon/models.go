@@ -0,0 +1,155 @@
+package coupon
+
+import (
+	"mytheresa/internal/database"
+	"mytheresa/pkg/discount"
+	"strconv"
+	"time"
+)
+
+// Coupon represents a code that unlocks a coupon only discount
+type Coupon struct {
+	Code                 string                   `gorm:"primaryKey" json:"code"`
+	DiscountID           int                      `gorm:"not null" json:"discount_id"`
+	Discount             discount.GeneralDiscount `gorm:"foreignKey:DiscountID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"discount"`
+	MaxRedemptions       int                      `gorm:"not null" json:"max_redemptions"`
+	RemainingRedemptions int                      `gorm:"not null" json:"remaining_redemptions"`
+	PerCustomerLimit     int                      `gorm:"not null;default:0" json:"per_customer_limit"`
+	ExpiresAt            time.Time                `gorm:"not null" json:"expires_at"`
+}
+
+// CustomerRedemption keeps track of the redemptions left for a customer on a given coupon
+type CustomerRedemption struct {
+	CouponCode           string `gorm:"primaryKey"`
+	CustomerID           string `gorm:"primaryKey"`
+	RemainingRedemptions int    `gorm:"not null"`
+}
+
+// CouponRequest represents the body for creating a coupon
+// @Description CouponRequest is the input for creating a new coupon. A per customer limit of 0 means no limit
+// @Accept json
+// @Produce json
+// @Param coupon body CouponRequest true "Coupon details"
+type CouponRequest struct {
+	Code             string    `json:"code" example:"WINTER10"`
+	DiscountID       int       `json:"discount_id" example:"4"`
+	MaxRedemptions   int       `json:"max_redemptions" example:"100"`
+	PerCustomerLimit int       `json:"per_customer_limit" example:"1"`
+	ExpiresAt        time.Time `json:"expires_at" example:"2030-01-01T00:00:00Z"`
+}
+
+// RedemptionRequest represents the body for redeeming a coupon
+// @Description RedemptionRequest identifies the customer redeeming a coupon
+// @Accept json
+// @Produce json
+// @Param redemption body RedemptionRequest true "Redemption details"
+type RedemptionRequest struct {
+	CustomerID string `json:"customer_id" example:"customer-1"`
+}
+
+// CouponResponse represents the output when retrieving coupon details
+// @Description CouponResponse is the response structure for coupons
+// @Accept json
+// @Produce json
+// @Success 200 {object} CouponResponse
+type CouponResponse struct {
+	Code                 string    `json:"code" example:"WINTER10"`
+	DiscountID           string    `json:"discount_id" example:"4"`
+	MaxRedemptions       int       `json:"max_redemptions" example:"100"`
+	RemainingRedemptions int       `json:"remaining_redemptions" example:"99"`
+	PerCustomerLimit     int       `json:"per_customer_limit" example:"1"`
+	ExpiresAt            time.Time `json:"expires_at" example:"2030-01-01T00:00:00Z"`
+}
+
+func (c *CouponRequest) ToCoupon() Coupon {
+	return Coupon{
+		Code:                 c.Code,
+		DiscountID:           c.DiscountID,
+		MaxRedemptions:       c.MaxRedemptions,
+		RemainingRedemptions: c.MaxRedemptions,
+		PerCustomerLimit:     c.PerCustomerLimit,
+		ExpiresAt:            c.ExpiresAt.UTC(),
+	}
+}
+
+func (c *Coupon) ToCouponResponse() CouponResponse {
+	return CouponResponse{
+		Code:                 c.Code,
+		DiscountID:           strconv.Itoa(c.DiscountID),
+		MaxRedemptions:       c.MaxRedemptions,
+		RemainingRedemptions: c.RemainingRedemptions,
+		PerCustomerLimit:     c.PerCustomerLimit,
+		ExpiresAt:            c.ExpiresAt,
+	}
+}
+
+func (c *Coupon) GetIdentifier() string {
+	return c.Code
+}
+
+// IsExpired tells if the coupon can't be used anymore at the given time
+func (c *Coupon) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+func (c *CustomerRedemption) GetIdentifier() string {
+	return c.CouponCode + ":" + c.CustomerID
+}
+
+type couponFilter struct {
+	field   string
+	Value   interface{}
+	Operand string
+}
+
+func (f *couponFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *couponFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *couponFilter) GetOperand() string {
+	return f.Operand
+}
+
+func NewCodeFilter(code string) database.Filter {
+	return &couponFilter{
+		field:   "code",
+		Value:   code,
+		Operand: "=",
+	}
+}
+
+func NewCouponCodeFilter(code string) database.Filter {
+	return &couponFilter{
+		field:   "coupon_code",
+		Value:   code,
+		Operand: "=",
+	}
+}
+
+func NewCustomerFilter(customerID string) database.Filter {
+	return &couponFilter{
+		field:   "customer_id",
+		Value:   customerID,
+		Operand: "=",
+	}
+}
+
+func NewRemainingRedemptionsFilter(value int, operand string) database.Filter {
+	return &couponFilter{
+		field:   "remaining_redemptions",
+		Value:   value,
+		Operand: operand,
+	}
+}
+
+func NewExpiresAtFilter(value time.Time, operand string) database.Filter {
+	return &couponFilter{
+		field:   "expires_at",
+		Value:   value.UTC(),
+		Operand: operand,
+	}
+}
@@ -0,0 +1,49 @@
+package coupon_test
+
+import (
+	"mytheresa/pkg/coupon"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCouponRequest_ToCoupon(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600))
+	request := coupon.CouponRequest{
+		Code:             "WINTER10",
+		DiscountID:       4,
+		MaxRedemptions:   100,
+		PerCustomerLimit: 1,
+		ExpiresAt:        expiresAt,
+	}
+
+	c := request.ToCoupon()
+
+	assert.Equal(t, "WINTER10", c.Code)
+	assert.Equal(t, 4, c.DiscountID)
+	assert.Equal(t, 100, c.MaxRedemptions)
+	assert.Equal(t, 100, c.RemainingRedemptions)
+	assert.Equal(t, 1, c.PerCustomerLimit)
+	assert.True(t, expiresAt.Equal(c.ExpiresAt))
+	assert.Equal(t, time.UTC, c.ExpiresAt.Location())
+}
+
+func TestCoupon_ToCouponResponse(t *testing.T) {
+	c := coupon.Coupon{Code: "WINTER10", DiscountID: 4, MaxRedemptions: 100, RemainingRedemptions: 42}
+
+	response := c.ToCouponResponse()
+
+	assert.Equal(t, "WINTER10", response.Code)
+	assert.Equal(t, "4", response.DiscountID)
+	assert.Equal(t, 42, response.RemainingRedemptions)
+}
+
+func TestCoupon_IsExpired(t *testing.T) {
+	now := time.Now()
+	c := coupon.Coupon{ExpiresAt: now}
+
+	assert.True(t, c.IsExpired(now))
+	assert.True(t, c.IsExpired(now.Add(time.Second)))
+	assert.False(t, c.IsExpired(now.Add(-time.Second)))
+}
@@ -0,0 +1,214 @@
+package coupon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/discount"
+	"strconv"
+	"time"
+)
+
+type Service interface {
+	CreateCoupon(ctx context.Context, coupon CouponRequest) (Coupon, error)
+	GetCouponDiscount(ctx context.Context, code string) (discount.Discount, error)
+	RedeemCoupon(ctx context.Context, code string, redemption RedemptionRequest) (Coupon, error)
+}
+
+type service struct {
+	db     database.Database
+	logger logger.Logger
+}
+
+func NewService(db database.Database, logger logger.Logger) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *service) CreateCoupon(ctx context.Context, req CouponRequest) (Coupon, error) {
+	ctx, span := tracing.Start(ctx, "coupon.CreateCoupon")
+	defer span.End()
+
+	if err := s.validateCouponRequest(ctx, req); err != nil {
+		return Coupon{}, err
+	}
+
+	coupon := req.ToCoupon()
+	err := s.db.Save(ctx, coupon.GetIdentifier(), &coupon)
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "error creating coupon")
+		return Coupon{}, apierror.InternalServerError("error creating coupon")
+	}
+
+	return coupon, nil
+}
+
+func (s *service) GetCouponDiscount(ctx context.Context, code string) (discount.Discount, error) {
+	ctx, span := tracing.Start(ctx, "coupon.GetCouponDiscount")
+	defer span.End()
+
+	coupon, err := s.getCoupon(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if coupon.IsExpired(time.Now()) {
+		return nil, apierror.Conflict("Coupon has expired")
+	}
+	if coupon.RemainingRedemptions <= 0 {
+		return nil, apierror.Conflict("Coupon has no redemptions left")
+	}
+
+	return discount.NewDiscount(coupon.Discount), nil
+}
+
+// RedeemCoupon uses one of the coupon redemptions on behalf of a customer. Every counter is
+// decremented with a conditional update, so concurrent redemptions can never go over the limits,
+// and both counters are decremented in a single transaction, so a use is never taken from the
+// customer without being taken from the coupon.
+func (s *service) RedeemCoupon(ctx context.Context, code string, req RedemptionRequest) (Coupon, error) {
+	ctx, span := tracing.Start(ctx, "coupon.RedeemCoupon")
+	defer span.End()
+
+	if req.CustomerID == "" {
+		return Coupon{}, apierror.BadRequest("customer_id is required")
+	}
+
+	coupon, err := s.getCoupon(ctx, code)
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	now := time.Now()
+	if coupon.IsExpired(now) {
+		return Coupon{}, apierror.Conflict("Coupon has expired")
+	}
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if coupon.PerCustomerLimit > 0 {
+			if err := s.takeCustomerRedemption(ctx, coupon, req.CustomerID); err != nil {
+				return err
+			}
+		}
+
+		affected, err := s.db.Increment(ctx, &Coupon{}, "remaining_redemptions", -1,
+			NewCodeFilter(code),
+			NewRemainingRedemptionsFilter(0, ">"),
+			NewExpiresAtFilter(now, ">"),
+		)
+		if err != nil {
+			s.logger.WithField("code", code).WithError(err).Error(ctx, "error redeeming coupon")
+			return apierror.InternalServerError("error redeeming coupon")
+		}
+		if affected == 0 {
+			return apierror.Conflict("Coupon has no redemptions left")
+		}
+		return nil
+	})
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	s.logger.WithField("code", code).WithField("customer_id", req.CustomerID).Info(ctx, "coupon redeemed")
+
+	return s.getCoupon(ctx, code)
+}
+
+func (s *service) getCoupon(ctx context.Context, code string) (Coupon, error) {
+	var coupons []Coupon
+	err := s.db.GetWithFilters(ctx, &coupons, NewCodeFilter(code))
+	if err != nil {
+		s.logger.WithField("code", code).WithError(err).Error(ctx, "error getting coupon")
+		return Coupon{}, apierror.InternalServerError(fmt.Sprintf("Error getting coupon %s", code))
+	}
+	if len(coupons) == 0 {
+		return Coupon{}, apierror.NotFound("Coupon not found")
+	}
+
+	return coupons[0], nil
+}
+
+func (s *service) takeCustomerRedemption(ctx context.Context, coupon Coupon, customerID string) error {
+	exists, err := s.customerRedemptionExists(ctx, coupon.Code, customerID)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		redemption := CustomerRedemption{
+			CouponCode:           coupon.Code,
+			CustomerID:           customerID,
+			RemainingRedemptions: coupon.PerCustomerLimit,
+		}
+		// A concurrent redemption from the same customer may have created the row first,
+		// in which case saving fails but the row we need is already there.
+		if err := s.db.Save(ctx, redemption.GetIdentifier(), &redemption); err != nil {
+			if exists, _ = s.customerRedemptionExists(ctx, coupon.Code, customerID); !exists {
+				s.logger.WithField("code", coupon.Code).WithError(err).Error(ctx, "error saving customer redemption")
+				return apierror.InternalServerError("error redeeming coupon")
+			}
+		}
+	}
+
+	affected, err := s.db.Increment(ctx, &CustomerRedemption{}, "remaining_redemptions", -1,
+		NewCouponCodeFilter(coupon.Code),
+		NewCustomerFilter(customerID),
+		NewRemainingRedemptionsFilter(0, ">"),
+	)
+	if err != nil {
+		s.logger.WithField("code", coupon.Code).WithError(err).Error(ctx, "error updating customer redemption")
+		return apierror.InternalServerError("error redeeming coupon")
+	}
+	if affected == 0 {
+		return apierror.Conflict("Customer has reached the redemption limit for this coupon")
+	}
+
+	return nil
+}
+
+func (s *service) customerRedemptionExists(ctx context.Context, code string, customerID string) (bool, error) {
+	var redemptions []CustomerRedemption
+	err := s.db.GetWithFilters(ctx, &redemptions, NewCouponCodeFilter(code), NewCustomerFilter(customerID))
+	if err != nil {
+		s.logger.WithField("code", code).WithError(err).Error(ctx, "error getting customer redemption")
+		return false, apierror.InternalServerError("error redeeming coupon")
+	}
+
+	return len(redemptions) > 0, nil
+}
+
+func (s *service) validateCouponRequest(ctx context.Context, req CouponRequest) error {
+	if req.Code == "" {
+		return apierror.BadRequest("code is required")
+	}
+	if req.MaxRedemptions <= 0 {
+		return apierror.BadRequest("max_redemptions must be greater than 0")
+	}
+	if req.PerCustomerLimit < 0 {
+		return apierror.BadRequest("per_customer_limit can't be negative")
+	}
+	if !req.ExpiresAt.After(time.Now()) {
+		return apierror.BadRequest("expires_at must be in the future")
+	}
+
+	var d discount.GeneralDiscount
+	err := s.db.Get(ctx, strconv.Itoa(req.DiscountID), &d)
+	if err != nil {
+		if errors.Is(err, s.db.ErrRecordNotFound()) {
+			return apierror.BadRequest("Discount not found")
+		}
+		s.logger.WithField("discount_id", req.DiscountID).WithError(err).Error(ctx, "error getting discount")
+		return apierror.InternalServerError("error creating coupon")
+	}
+	if !d.CouponOnly {
+		return apierror.BadRequest("Discount must be coupon only")
+	}
+
+	return nil
+}
@@ -0,0 +1,359 @@
+package coupon_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mytheresa/internal/apierror"
+	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/audit"
+	"mytheresa/pkg/coupon"
+	"mytheresa/pkg/discount"
+	eventsmocks "mytheresa/pkg/events/mocks"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func validCouponRequest() coupon.CouponRequest {
+	return coupon.CouponRequest{
+		Code:             "WINTER10",
+		DiscountID:       4,
+		MaxRedemptions:   10,
+		PerCustomerLimit: 1,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	}
+}
+
+func TestNewService(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	logMock := loggermocks.NoopLogger{}
+
+	s := coupon.NewService(&dbmock, &logMock)
+
+	assert.NotNil(t, s)
+}
+
+func TestCreateCoupon_OK(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Get", mock.Anything, "4", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(2).(*discount.GeneralDiscount) = discount.GeneralDiscount{ID: 4, CouponOnly: true}
+	}).Return(nil)
+	dbmock.On("Save", mock.Anything, "WINTER10", mock.Anything).Return(nil)
+	logMock := loggermocks.NoopLogger{}
+
+	s := coupon.NewService(&dbmock, &logMock)
+	req := validCouponRequest()
+
+	result, err := s.CreateCoupon(context.Background(), req)
+
+	assert.Nil(t, err)
+	assert.Equal(t, req.Code, result.Code)
+	assert.Equal(t, req.MaxRedemptions, result.RemainingRedemptions)
+}
+
+func TestCreateCoupon_InvalidRequest(t *testing.T) {
+	tests := map[string]func(r *coupon.CouponRequest){
+		"code is required":                       func(r *coupon.CouponRequest) { r.Code = "" },
+		"max_redemptions must be greater than 0": func(r *coupon.CouponRequest) { r.MaxRedemptions = 0 },
+		"per_customer_limit can't be negative":   func(r *coupon.CouponRequest) { r.PerCustomerLimit = -1 },
+		"expires_at must be in the future":       func(r *coupon.CouponRequest) { r.ExpiresAt = time.Now().Add(-time.Hour) },
+	}
+
+	for message, modify := range tests {
+		t.Run(message, func(t *testing.T) {
+			s := coupon.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{})
+			req := validCouponRequest()
+			modify(&req)
+
+			_, err := s.CreateCoupon(context.Background(), req)
+
+			apierr, ok := err.(*apierror.ApiError)
+			assert.True(t, ok)
+			assert.Equal(t, http.StatusBadRequest, apierr.Code())
+			assert.Equal(t, message, apierr.Error())
+		})
+	}
+}
+
+func TestCreateCoupon_DiscountNotFound(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Get", mock.Anything, "4", mock.Anything).Return(gorm.ErrRecordNotFound)
+	dbmock.On("ErrRecordNotFound").Return(gorm.ErrRecordNotFound)
+
+	s := coupon.NewService(&dbmock, &loggermocks.NoopLogger{})
+
+	_, err := s.CreateCoupon(context.Background(), validCouponRequest())
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, apierr.Code())
+	assert.Equal(t, "Discount not found", apierr.Error())
+}
+
+func TestCreateCoupon_DiscountNotCouponOnly(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Get", mock.Anything, "4", mock.Anything).Return(nil)
+
+	s := coupon.NewService(&dbmock, &loggermocks.NoopLogger{})
+
+	_, err := s.CreateCoupon(context.Background(), validCouponRequest())
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, apierr.Code())
+	assert.Equal(t, "Discount must be coupon only", apierr.Error())
+}
+
+func TestCreateCoupon_ErrorSaving(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Get", mock.Anything, "4", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(2).(*discount.GeneralDiscount) = discount.GeneralDiscount{ID: 4, CouponOnly: true}
+	}).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := coupon.NewService(&dbmock, &loggermocks.NoopLogger{})
+
+	_, err := s.CreateCoupon(context.Background(), validCouponRequest())
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusInternalServerError, apierr.Code())
+	assert.Equal(t, "error creating coupon", apierr.Error())
+}
+
+func TestGetCouponDiscount_OK(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]coupon.Coupon) = []coupon.Coupon{{
+			Code:                 "WINTER10",
+			RemainingRedemptions: 1,
+			ExpiresAt:            time.Now().Add(time.Hour),
+			Discount:             discount.GeneralDiscount{ID: 4, Percentage: 10, DiscountTypeID: discount.SKU, Target: "000001"},
+		}}
+	}).Return(nil)
+
+	s := coupon.NewService(&dbmock, &loggermocks.NoopLogger{})
+
+	d, err := s.GetCouponDiscount(context.Background(), "WINTER10")
+
+	assert.Nil(t, err)
+	_, ok := d.(*discount.SkuDiscount)
+	assert.True(t, ok)
+	assert.Equal(t, 10, d.GetPercentage())
+}
+
+func TestGetCouponDiscount_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		coupons []coupon.Coupon
+		code    int
+		message string
+	}{
+		{
+			name:    "not found",
+			coupons: []coupon.Coupon{},
+			code:    http.StatusNotFound,
+			message: "Coupon not found",
+		},
+		{
+			name:    "expired",
+			coupons: []coupon.Coupon{{Code: "WINTER10", RemainingRedemptions: 1, ExpiresAt: time.Now().Add(-time.Hour)}},
+			code:    http.StatusConflict,
+			message: "Coupon has expired",
+		},
+		{
+			name:    "exhausted",
+			coupons: []coupon.Coupon{{Code: "WINTER10", RemainingRedemptions: 0, ExpiresAt: time.Now().Add(time.Hour)}},
+			code:    http.StatusConflict,
+			message: "Coupon has no redemptions left",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbmock := dbmocks.Database{}
+			dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				*args.Get(1).(*[]coupon.Coupon) = tt.coupons
+			}).Return(nil)
+
+			s := coupon.NewService(&dbmock, &loggermocks.NoopLogger{})
+
+			_, err := s.GetCouponDiscount(context.Background(), "WINTER10")
+
+			apierr, ok := err.(*apierror.ApiError)
+			assert.True(t, ok)
+			assert.Equal(t, tt.code, apierr.Code())
+			assert.Equal(t, tt.message, apierr.Error())
+		})
+	}
+}
+
+func TestRedeemCoupon_MissingCustomer(t *testing.T) {
+	s := coupon.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{})
+
+	_, err := s.RedeemCoupon(context.Background(), "WINTER10", coupon.RedemptionRequest{})
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, apierr.Code())
+}
+
+func TestRedeemCoupon_ExhaustedRollsBackCustomerRedemption(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		switch h := args.Get(1).(type) {
+		case *[]coupon.Coupon:
+			*h = []coupon.Coupon{{Code: "WINTER10", PerCustomerLimit: 1, ExpiresAt: time.Now().Add(time.Hour)}}
+		case *[]coupon.CustomerRedemption:
+			*h = []coupon.CustomerRedemption{{CouponCode: "WINTER10", CustomerID: "c1", RemainingRedemptions: 1}}
+		}
+	}).Return(nil)
+	dbmock.On("Increment", mock.Anything, mock.AnythingOfType("*coupon.CustomerRedemption"), "remaining_redemptions", -1, mock.Anything).Return(int64(1), nil)
+	dbmock.On("Increment", mock.Anything, mock.AnythingOfType("*coupon.Coupon"), "remaining_redemptions", -1, mock.Anything).Return(int64(0), nil)
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+
+	s := coupon.NewService(&dbmock, &loggermocks.NoopLogger{})
+
+	_, err := s.RedeemCoupon(context.Background(), "WINTER10", coupon.RedemptionRequest{CustomerID: "c1"})
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusConflict, apierr.Code())
+	assert.Equal(t, "Coupon has no redemptions left", apierr.Error())
+	// both decrements in the transaction rolled back, nothing to give back by hand
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+	dbmock.AssertNumberOfCalls(t, "Increment", 2)
+}
+
+func newSQLiteService(t *testing.T) (coupon.Service, func(req coupon.CouponRequest)) {
+	sqlDB := sqlitetest.NewDB(t,
+		&discount.DiscountType{},
+		&discount.GeneralDiscount{},
+		&coupon.Coupon{},
+		&coupon.CustomerRedemption{},
+		&audit.Entry{},
+	)
+
+	ctx := context.Background()
+	ds := discount.NewService(sqlDB, &loggermocks.NoopLogger{}, audit.NewService(sqlDB, &loggermocks.NoopLogger{}), eventsmocks.AnyPublisher())
+	dt, _ := ds.CreateDiscountType(ctx, discount.DiscountTypeRequest{Type: "general"})
+	d, err := ds.CreateDiscount(ctx, discount.DiscountRequest{DiscountTypeID: dt.ID, Percentage: 20, CouponOnly: true})
+	assert.NoError(t, err)
+
+	s := coupon.NewService(sqlDB, &loggermocks.NoopLogger{})
+	create := func(req coupon.CouponRequest) {
+		req.DiscountID = d.(*discount.GeneralDiscount).ID
+		_, err := s.CreateCoupon(ctx, req)
+		assert.NoError(t, err)
+	}
+
+	return s, create
+}
+
+func TestRedeemCoupon_ConcurrentRedemptionsNeverExceedMax(t *testing.T) {
+	s, create := newSQLiteService(t)
+	create(coupon.CouponRequest{
+		Code:           "RUSH",
+		MaxRedemptions: 10,
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.RedeemCoupon(context.Background(), "RUSH", coupon.RedemptionRequest{CustomerID: fmt.Sprint(i)})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				redeemed++
+				return
+			}
+			// never an internal error, e.g. the database being locked
+			assert.Equal(t, apierror.Conflict("Coupon has no redemptions left"), err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, redeemed)
+
+	_, err := s.GetCouponDiscount(context.Background(), "RUSH")
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "Coupon has no redemptions left", apierr.Error())
+}
+
+func TestRedeemCoupon_ConcurrentRedemptionsOfDifferentCustomers(t *testing.T) {
+	s, create := newSQLiteService(t)
+	create(coupon.CouponRequest{
+		Code:             "WELCOME",
+		MaxRedemptions:   100,
+		PerCustomerLimit: 1,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 50)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.RedeemCoupon(context.Background(), "WELCOME", coupon.RedemptionRequest{CustomerID: fmt.Sprint(i)})
+		}(i)
+	}
+	wg.Wait()
+
+	// every customer redeems it once, none failing on the lock taken by another
+	for i, err := range errs {
+		assert.NoError(t, err, "customer %d", i)
+	}
+	c, err := s.RedeemCoupon(context.Background(), "WELCOME", coupon.RedemptionRequest{CustomerID: "another-customer"})
+	assert.NoError(t, err)
+	assert.Equal(t, 49, c.RemainingRedemptions)
+}
+
+func TestRedeemCoupon_ConcurrentRedemptionsRespectPerCustomerLimit(t *testing.T) {
+	s, create := newSQLiteService(t)
+	create(coupon.CouponRequest{
+		Code:             "ONCE",
+		MaxRedemptions:   100,
+		PerCustomerLimit: 2,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.RedeemCoupon(context.Background(), "ONCE", coupon.RedemptionRequest{CustomerID: "same-customer"})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				redeemed++
+				return
+			}
+			assert.Equal(t, apierror.Conflict("Customer has reached the redemption limit for this coupon"), err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, redeemed)
+
+	c, err := s.RedeemCoupon(context.Background(), "ONCE", coupon.RedemptionRequest{CustomerID: "another-customer"})
+	assert.NoError(t, err)
+	assert.Equal(t, 97, c.RemainingRedemptions)
+}
@@ -0,0 +1,192 @@
+package discount
+
+import (
+	"mytheresa/internal/metrics"
+	"sort"
+	"strings"
+)
+
+// Basket is a set of products priced together, every line at its per product final price
+type Basket struct {
+	Lines []BasketLine
+}
+
+type BasketLine struct {
+	SKU       string
+	Quantity  int
+	UnitPrice int
+	// units already taken by a previous promotion, they can't take part in another one
+	promoted int
+}
+
+func (l *BasketLine) available() int {
+	return l.Quantity - l.promoted
+}
+
+func (b *Basket) Subtotal() int {
+	subtotal := 0
+	for _, l := range b.Lines {
+		subtotal += l.UnitPrice * l.Quantity
+	}
+	return subtotal
+}
+
+func (b *Basket) line(sku string) *BasketLine {
+	for i := range b.Lines {
+		if b.Lines[i].SKU == sku {
+			return &b.Lines[i]
+		}
+	}
+	return nil
+}
+
+// BasketDiscount is a discount evaluated over a whole basket instead of a single product
+type BasketDiscount interface {
+	Discount
+	// ApplyToBasket returns the amount saved on the basket, given the basket total so far
+	ApplyToBasket(basket *Basket, total int) int
+}
+
+// AppliedBasketDiscount is a basket discount together with the amount it saved
+type AppliedBasketDiscount struct {
+	Discount BasketDiscount
+	Amount   int
+}
+
+// ApplyBasketDiscounts evaluates every basket discount found in discounts over the basket.
+// Unit based promotions (buy x get y, then bundles) go first and every unit takes part in one
+// of them at most. Spend thresholds are checked last, against the total after those savings.
+func ApplyBasketDiscounts(basket *Basket, discounts []Discount) []AppliedBasketDiscount {
+	var basketDiscounts []BasketDiscount
+	for _, d := range discounts {
+		if bd, ok := d.(BasketDiscount); ok {
+			basketDiscounts = append(basketDiscounts, bd)
+		}
+	}
+
+	sort.SliceStable(basketDiscounts, func(i, j int) bool {
+		return basketPriority(basketDiscounts[i]) < basketPriority(basketDiscounts[j])
+	})
+
+	applied := []AppliedBasketDiscount{}
+	total := basket.Subtotal()
+	for _, d := range basketDiscounts {
+		amount := d.ApplyToBasket(basket, total)
+		metrics.CountDiscountEvaluation(Kind(d), amount > 0)
+		if amount <= 0 {
+			continue
+		}
+		total -= amount
+		applied = append(applied, AppliedBasketDiscount{Discount: d, Amount: amount})
+	}
+
+	return applied
+}
+
+func basketPriority(d BasketDiscount) int {
+	switch d.(type) {
+	case *BuyXGetYDiscount:
+		return 0
+	case *BundleDiscount:
+		return 1
+	default:
+		return 2
+	}
+}
+
+type BuyXGetYDiscount struct {
+	GeneralDiscount
+}
+
+func (d *BuyXGetYDiscount) IsApplicableFor(item DiscountConditions) bool {
+	return false
+}
+
+func (d *BuyXGetYDiscount) ApplyToBasket(basket *Basket, total int) int {
+	line := basket.line(d.Target)
+	if line == nil || d.BuyQuantity <= 0 || d.FreeQuantity <= 0 {
+		return 0
+	}
+
+	group := d.BuyQuantity + d.FreeQuantity
+	groups := line.available() / group
+	line.promoted += groups * group
+
+	return groups * d.FreeQuantity * line.UnitPrice
+}
+
+type SpendThresholdDiscount struct {
+	GeneralDiscount
+}
+
+func (d *SpendThresholdDiscount) IsApplicableFor(item DiscountConditions) bool {
+	return false
+}
+
+func (d *SpendThresholdDiscount) ApplyToBasket(basket *Basket, total int) int {
+	if total < d.MinSpend {
+		return 0
+	}
+	return total * d.Percentage / 100
+}
+
+type BundleDiscount struct {
+	GeneralDiscount
+}
+
+func (d *BundleDiscount) IsApplicableFor(item DiscountConditions) bool {
+	return false
+}
+
+// SKUs returns the products that make up the bundle
+func (d *BundleDiscount) SKUs() []string {
+	return BundleSKUs(d.Target)
+}
+
+func (d *BundleDiscount) ApplyToBasket(basket *Basket, total int) int {
+	skus := d.SKUs()
+	if len(skus) == 0 {
+		return 0
+	}
+
+	bundles := -1
+	regular := 0
+	lines := []*BasketLine{}
+	for _, sku := range skus {
+		line := basket.line(sku)
+		if line == nil {
+			return 0
+		}
+		if bundles == -1 || line.available() < bundles {
+			bundles = line.available()
+		}
+		regular += line.UnitPrice
+		lines = append(lines, line)
+	}
+
+	saving := regular - d.BundlePrice
+	if bundles <= 0 || saving <= 0 {
+		return 0
+	}
+
+	for _, line := range lines {
+		line.promoted += bundles
+	}
+
+	return bundles * saving
+}
+
+// BundleSKUs splits a bundle target into its SKUs, ignoring duplicates
+func BundleSKUs(target string) []string {
+	skus := []string{}
+	seen := map[string]bool{}
+	for _, sku := range strings.Split(target, ",") {
+		sku = strings.TrimSpace(sku)
+		if sku == "" || seen[sku] {
+			continue
+		}
+		seen[sku] = true
+		skus = append(skus, sku)
+	}
+	return skus
+}
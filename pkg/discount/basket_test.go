@@ -0,0 +1,130 @@
+package discount_test
+
+import (
+	"mytheresa/pkg/discount"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyBasketDiscounts(t *testing.T) {
+	buy2get1 := &discount.BuyXGetYDiscount{GeneralDiscount: discount.GeneralDiscount{
+		ID: 1, DiscountTypeID: discount.BUY_X_GET_Y, Target: "A", BuyQuantity: 2, FreeQuantity: 1,
+	}}
+	bundle := &discount.BundleDiscount{GeneralDiscount: discount.GeneralDiscount{
+		ID: 2, DiscountTypeID: discount.BUNDLE, Target: "A, B", BundlePrice: 1500,
+	}}
+	spend := &discount.SpendThresholdDiscount{GeneralDiscount: discount.GeneralDiscount{
+		ID: 3, DiscountTypeID: discount.SPEND_THRESHOLD, Percentage: 10, MinSpend: 5000,
+	}}
+	sku := &discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{
+		ID: 4, DiscountTypeID: discount.SKU, Target: "A", Percentage: 50,
+	}}
+
+	tests := []struct {
+		name      string
+		lines     []discount.BasketLine
+		discounts []discount.Discount
+		amounts   map[string]int
+	}{
+		{
+			name:      "buy 2 get 1 gives one free unit every three",
+			lines:     []discount.BasketLine{{SKU: "A", Quantity: 7, UnitPrice: 1000}},
+			discounts: []discount.Discount{buy2get1},
+			amounts:   map[string]int{"1": 2000},
+		},
+		{
+			name:      "buy 2 get 1 needs the whole group",
+			lines:     []discount.BasketLine{{SKU: "A", Quantity: 2, UnitPrice: 1000}},
+			discounts: []discount.Discount{buy2get1},
+			amounts:   map[string]int{},
+		},
+		{
+			name:      "bundle applies as many times as complete sets",
+			lines:     []discount.BasketLine{{SKU: "A", Quantity: 2, UnitPrice: 1000}, {SKU: "B", Quantity: 3, UnitPrice: 1000}},
+			discounts: []discount.Discount{bundle},
+			amounts:   map[string]int{"2": 1000},
+		},
+		{
+			name:      "bundle needs every product",
+			lines:     []discount.BasketLine{{SKU: "A", Quantity: 2, UnitPrice: 1000}},
+			discounts: []discount.Discount{bundle},
+			amounts:   map[string]int{},
+		},
+		{
+			name:      "units in a buy x get y group can't be bundled",
+			lines:     []discount.BasketLine{{SKU: "A", Quantity: 4, UnitPrice: 1000}, {SKU: "B", Quantity: 2, UnitPrice: 1000}},
+			discounts: []discount.Discount{bundle, buy2get1},
+			amounts:   map[string]int{"1": 1000, "2": 500},
+		},
+		{
+			name:      "spend threshold is checked after the other promotions",
+			lines:     []discount.BasketLine{{SKU: "A", Quantity: 6, UnitPrice: 1000}},
+			discounts: []discount.Discount{spend, buy2get1},
+			amounts:   map[string]int{"1": 2000},
+		},
+		{
+			name:      "spend threshold reached",
+			lines:     []discount.BasketLine{{SKU: "B", Quantity: 6, UnitPrice: 1000}},
+			discounts: []discount.Discount{spend, buy2get1},
+			amounts:   map[string]int{"3": 600},
+		},
+		{
+			name:      "per product discounts are ignored",
+			lines:     []discount.BasketLine{{SKU: "A", Quantity: 6, UnitPrice: 1000}},
+			discounts: []discount.Discount{sku},
+			amounts:   map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basket := discount.Basket{Lines: tt.lines}
+
+			applied := discount.ApplyBasketDiscounts(&basket, tt.discounts)
+
+			amounts := map[string]int{}
+			for _, a := range applied {
+				amounts[a.Discount.ToDiscountResponse().ID] = a.Amount
+			}
+			assert.Equal(t, tt.amounts, amounts)
+		})
+	}
+}
+
+func TestBasketDiscounts_NotApplicableForProducts(t *testing.T) {
+	item := discount.DiscountConditions{CategoryID: "1", SKU: "A"}
+
+	assert.False(t, (&discount.BuyXGetYDiscount{GeneralDiscount: discount.GeneralDiscount{Target: "A"}}).IsApplicableFor(item))
+	assert.False(t, (&discount.SpendThresholdDiscount{}).IsApplicableFor(item))
+	assert.False(t, (&discount.BundleDiscount{GeneralDiscount: discount.GeneralDiscount{Target: "A,B"}}).IsApplicableFor(item))
+}
+
+func TestBundleSKUs(t *testing.T) {
+	assert.Equal(t, []string{"000001", "000004"}, discount.BundleSKUs(" 000001,000004,,000001 "))
+	assert.Equal(t, []string{}, discount.BundleSKUs(""))
+}
+
+func TestBasket_Subtotal(t *testing.T) {
+	basket := discount.Basket{Lines: []discount.BasketLine{
+		{SKU: "A", Quantity: 2, UnitPrice: 1000},
+		{SKU: "B", Quantity: 1, UnitPrice: 500},
+	}}
+
+	assert.Equal(t, 2500, basket.Subtotal())
+}
+
+func TestKind(t *testing.T) {
+	tests := map[string]discount.Discount{
+		"category":        &discount.CategoryDiscount{},
+		"sku":             &discount.SkuDiscount{},
+		"general":         &discount.GeneralDiscount{},
+		"buy_x_get_y":     &discount.BuyXGetYDiscount{},
+		"spend_threshold": &discount.SpendThresholdDiscount{},
+		"bundle":          &discount.BundleDiscount{},
+	}
+
+	for kind, d := range tests {
+		assert.Equal(t, kind, discount.Kind(d))
+	}
+}
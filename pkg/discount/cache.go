@@ -0,0 +1,99 @@
+package discount
+
+import (
+	"context"
+	"mytheresa/internal/database"
+	"mytheresa/internal/metrics"
+	"sync"
+	"time"
+)
+
+// cacheName labels the lookups of the discount cache in the metrics
+const cacheName = "discounts"
+
+// cachedService keeps the discounts in memory for a while, so listing products does not query
+// them every time. Writes invalidate it once their transaction is over, and until then every
+// read goes to the database, as it could see changes not committed yet.
+type cachedService struct {
+	Service
+	db    database.Database
+	ttl   time.Duration
+	clock func() time.Time
+
+	mu         sync.RWMutex
+	discounts  []Discount
+	expires    time.Time
+	writes     int
+	generation uint64
+}
+
+// NewCachedService caches the discounts of s for ttl, a ttl of 0 disables the cache. Discounts
+// must be written through the returned service for readers to see them before ttl.
+func NewCachedService(s Service, db database.Database, ttl time.Duration, clock func() time.Time) Service {
+	if ttl <= 0 {
+		return s
+	}
+	return &cachedService{Service: s, db: db, ttl: ttl, clock: clock}
+}
+
+func (s *cachedService) GetDiscounts(ctx context.Context) ([]Discount, error) {
+	s.mu.RLock()
+	cached, fresh := s.discounts, s.discounts != nil && s.writes == 0 && s.clock().Before(s.expires)
+	generation := s.generation
+	s.mu.RUnlock()
+
+	metrics.CountCacheLookup(cacheName, fresh)
+	if fresh {
+		// callers get their own slice, the discounts themselves are never modified
+		return append([]Discount(nil), cached...), nil
+	}
+
+	discounts, err := s.Service.GetDiscounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	// a write that started meanwhile might have been missed
+	if s.writes == 0 && s.generation == generation {
+		s.discounts = append([]Discount(nil), discounts...)
+		s.expires = s.clock().Add(s.ttl)
+	}
+	s.mu.Unlock()
+	return discounts, nil
+}
+
+// GetApplicableDiscounts leaves the coupon only discounts out of the cached ones
+func (s *cachedService) GetApplicableDiscounts(ctx context.Context) ([]Discount, error) {
+	discounts, err := s.GetDiscounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applicable := []Discount{}
+	for _, d := range discounts {
+		if !d.ToDiscountResponse().CouponOnly {
+			applicable = append(applicable, d)
+		}
+	}
+	return applicable, nil
+}
+
+func (s *cachedService) CreateDiscount(ctx context.Context, req DiscountRequest) (Discount, error) {
+	s.mu.Lock()
+	s.writes++
+	s.generation++
+	s.mu.Unlock()
+	defer s.db.AfterTransaction(ctx, s.endWrite)
+
+	return s.Service.CreateDiscount(ctx, req)
+}
+
+// endWrite drops the cached discounts once the transaction of a write is over
+func (s *cachedService) endWrite() {
+	s.mu.Lock()
+	s.writes--
+	s.generation++
+	s.discounts = nil
+	s.mu.Unlock()
+}
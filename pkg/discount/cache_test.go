@@ -0,0 +1,175 @@
+package discount_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
+	loggermocks "mytheresa/internal/logger/mocks"
+	auditmocks "mytheresa/pkg/audit/mocks"
+	"mytheresa/pkg/discount"
+	discountmocks "mytheresa/pkg/discount/mocks"
+	eventsmocks "mytheresa/pkg/events/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeClock is a clock only moving when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func storedDiscounts() []discount.Discount {
+	return []discount.Discount{&discount.GeneralDiscount{ID: 1, Percentage: 30}}
+}
+
+func TestCachedService_Hit(t *testing.T) {
+	ds := discountmocks.Service{}
+	ds.On("GetDiscounts", mock.Anything).Return(storedDiscounts(), nil).Once()
+
+	s := discount.NewCachedService(&ds, &dbmocks.Database{}, time.Minute, time.Now)
+
+	for i := 0; i < 3; i++ {
+		discounts, err := s.GetDiscounts(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, storedDiscounts(), discounts)
+	}
+	ds.AssertExpectations(t)
+}
+
+func TestCachedService_ApplicableDiscounts(t *testing.T) {
+	coupon := &discount.GeneralDiscount{ID: 2, Percentage: 20, CouponOnly: true}
+	ds := discountmocks.Service{}
+	ds.On("GetDiscounts", mock.Anything).Return(append(storedDiscounts(), coupon), nil).Once()
+
+	s := discount.NewCachedService(&ds, &dbmocks.Database{}, time.Minute, time.Now)
+
+	discounts, err := s.GetApplicableDiscounts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, storedDiscounts(), discounts)
+
+	discounts, err = s.GetDiscounts(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, discounts, 2)
+	ds.AssertExpectations(t)
+}
+
+func TestCachedService_Expires(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}
+	ds := discountmocks.Service{}
+	ds.On("GetDiscounts", mock.Anything).Return(storedDiscounts(), nil).Twice()
+
+	s := discount.NewCachedService(&ds, &dbmocks.Database{}, time.Minute, clock.Now)
+
+	_, _ = s.GetDiscounts(context.Background())
+	clock.now = clock.now.Add(59 * time.Second)
+	_, _ = s.GetDiscounts(context.Background())
+	clock.now = clock.now.Add(time.Second)
+	_, _ = s.GetDiscounts(context.Background())
+
+	ds.AssertExpectations(t)
+}
+
+func TestCachedService_ErrorsAreNotCached(t *testing.T) {
+	ds := discountmocks.Service{}
+	ds.On("GetDiscounts", mock.Anything).Return([]discount.Discount(nil), errors.New("some DB error")).Once()
+	ds.On("GetDiscounts", mock.Anything).Return(storedDiscounts(), nil).Once()
+
+	s := discount.NewCachedService(&ds, &dbmocks.Database{}, time.Minute, time.Now)
+
+	_, err := s.GetDiscounts(context.Background())
+	assert.Error(t, err)
+	discounts, err := s.GetDiscounts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, storedDiscounts(), discounts)
+}
+
+func TestCachedService_CreateDiscountInvalidates(t *testing.T) {
+	created := &discount.GeneralDiscount{ID: 2, Percentage: 10}
+	ds := discountmocks.Service{}
+	ds.On("GetDiscounts", mock.Anything).Return(storedDiscounts(), nil).Once()
+	ds.On("GetDiscounts", mock.Anything).Return(append(storedDiscounts(), created), nil).Once()
+	ds.On("CreateDiscount", mock.Anything, mock.Anything).Return(created, nil)
+
+	s := discount.NewCachedService(&ds, &dbmocks.Database{}, time.Minute, time.Now)
+
+	discounts, _ := s.GetDiscounts(context.Background())
+	assert.Len(t, discounts, 1)
+
+	_, err := s.CreateDiscount(context.Background(), discount.DiscountRequest{Percentage: 10})
+	assert.NoError(t, err)
+
+	discounts, _ = s.GetDiscounts(context.Background())
+	assert.Len(t, discounts, 2)
+	ds.AssertExpectations(t)
+}
+
+func TestCachedService_NotCachedUntilTransactionEnds(t *testing.T) {
+	sqlDB := sqlitetest.NewDB(t)
+
+	ds := discountmocks.Service{}
+	ds.On("GetDiscounts", mock.Anything).Return(storedDiscounts(), nil)
+	ds.On("CreateDiscount", mock.Anything, mock.Anything).Return(&discount.GeneralDiscount{ID: 2}, nil)
+
+	s := discount.NewCachedService(&ds, sqlDB, time.Minute, time.Now)
+
+	failure := errors.New("import failed")
+	err := sqlDB.WithTransaction(context.Background(), func(ctx context.Context) error {
+		_, _ = s.CreateDiscount(ctx, discount.DiscountRequest{Percentage: 10})
+		// what is read now might be rolled back
+		_, _ = s.GetDiscounts(ctx)
+		_, _ = s.GetDiscounts(ctx)
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	ds.AssertNumberOfCalls(t, "GetDiscounts", 2)
+
+	_, _ = s.GetDiscounts(context.Background())
+	_, _ = s.GetDiscounts(context.Background())
+	ds.AssertNumberOfCalls(t, "GetDiscounts", 3)
+}
+
+func TestCachedService_Disabled(t *testing.T) {
+	ds := discountmocks.Service{}
+
+	assert.Same(t, &ds, discount.NewCachedService(&ds, &dbmocks.Database{}, 0, time.Now))
+}
+
+// BenchmarkGetDiscounts compares getting the discounts from SQLite with getting them cached
+func BenchmarkGetDiscounts(b *testing.B) {
+	sqlDB := sqlitetest.NewDB(b, &discount.DiscountType{}, &discount.GeneralDiscount{})
+
+	ds := discount.NewService(sqlDB, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+	ctx := context.Background()
+	dt, _ := ds.CreateDiscountType(ctx, discount.DiscountTypeRequest{Type: "sku"})
+	for i := 0; i < 50; i++ {
+		_, err := ds.CreateDiscount(ctx, discount.DiscountRequest{DiscountTypeID: dt.ID, Target: fmt.Sprintf("%06d", i), Percentage: 10})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for name, s := range map[string]discount.Service{
+		"uncached": ds,
+		"cached":   discount.NewCachedService(ds, sqlDB, time.Minute, time.Now),
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := s.GetDiscounts(ctx); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -2,10 +2,13 @@ package discount
 
 import (
 	"encoding/json"
+	"fmt"
 	"mytheresa/internal/apierror"
+	"mytheresa/internal/httpcache"
 	"mytheresa/internal/logger"
 	"mytheresa/internal/response"
 	"net/http"
+	"time"
 )
 
 type Handler interface {
@@ -33,7 +36,13 @@ func NewHandler(service Service, logger logger.Logger) Handler {
 // @Param discount body DiscountRequest true "Discount details"
 // @Success 201 {object} DiscountResponse
 // @Failure 400 {object} apierror.ApiError "Wrong body"
+// @Failure 413 {object} apierror.ApiError "Body too large"
+// @Failure 429 {object} apierror.ApiError "Too many requests"
 // @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /v1/discounts [post]
 func (h handler) CreateDiscount(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -58,9 +67,16 @@ func (h handler) CreateDiscount(w http.ResponseWriter, r *http.Request) {
 
 // GetDiscounts godoc
 // @Summary Get all discounts
-// @Description Retrieve a list of all available discounts
-// @Produce  json
+// @Description Retrieve a list of all available discounts, coupon only ones included
+// @Produce  json,xml,text/csv
+// @Param If-None-Match header string false "ETag of the representation already held"
+// @Param If-Modified-Since header string false "Last-Modified of the representation already held"
 // @Success 200 {array} GeneralDiscount
+// @Header 200,304 {string} ETag "Hash of the response body"
+// @Header 200,304 {string} Last-Modified "Last change of what the response shows"
+// @Header 200,304 {string} Cache-Control "Caching policy, configurable"
+// @Success 304 "Not modified"
+// @Failure 406 {object} apierror.ApiError "Not acceptable"
 // @Failure 500 {object} apierror.ApiError "Internal server error"
 // @Router /v1/discounts [get]
 func (h handler) GetDiscounts(w http.ResponseWriter, r *http.Request) {
@@ -72,5 +88,11 @@ func (h handler) GetDiscounts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response.RespondWithData(w, http.StatusOK, discounts)
+	var lastModified time.Time
+	versions := []string{}
+	for _, d := range discounts {
+		lastModified = httpcache.Latest(lastModified, d.LastModified())
+		versions = append(versions, fmt.Sprintf("%s@%d", d.ToDiscountResponse().ID, d.LastModified().UnixNano()))
+	}
+	httpcache.Respond(w, r, httpcache.ETag(w, versions...), lastModified, discounts)
 }
@@ -0,0 +1,54 @@
+package discount
+
+import "sort"
+
+// Index finds the discounts that might apply to an item without going through all of them: SKU
+// and category discounts are looked up by their target, general ones apply to every item and
+// basket level ones to none.
+type Index struct {
+	discounts  []Discount
+	bySKU      map[string][]int
+	byCategory map[string][]int
+	general    []int
+}
+
+// NewIndex indexes the discounts, meant to be built once and used for every item priced
+func NewIndex(discounts []Discount) *Index {
+	idx := &Index{
+		discounts:  discounts,
+		bySKU:      map[string][]int{},
+		byCategory: map[string][]int{},
+	}
+	for i, d := range discounts {
+		switch d := d.(type) {
+		case *SkuDiscount:
+			idx.bySKU[d.Target] = append(idx.bySKU[d.Target], i)
+		case *CategoryDiscount:
+			idx.byCategory[d.Target] = append(idx.byCategory[d.Target], i)
+		case *BuyXGetYDiscount, *SpendThresholdDiscount, *BundleDiscount:
+			// only applied to whole baskets
+		default:
+			// general discounts, and any other kind, are checked for every item
+			idx.general = append(idx.general, i)
+		}
+	}
+	return idx
+}
+
+// Candidates returns the discounts that might apply to the item, still to be checked with
+// IsApplicableFor, in the order they were indexed so ties are broken as before
+func (idx *Index) Candidates(item DiscountConditions) []Discount {
+	positions := append([]int(nil), idx.general...)
+	positions = append(positions, idx.bySKU[item.SKU]...)
+	if item.ParentSKU != "" && item.ParentSKU != item.SKU {
+		positions = append(positions, idx.bySKU[item.ParentSKU]...)
+	}
+	positions = append(positions, idx.byCategory[item.CategoryID]...)
+	sort.Ints(positions)
+
+	candidates := make([]Discount, len(positions))
+	for i, p := range positions {
+		candidates[i] = idx.discounts[p]
+	}
+	return candidates
+}
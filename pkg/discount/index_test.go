@@ -0,0 +1,67 @@
+package discount_test
+
+import (
+	"mytheresa/pkg/discount"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex_Candidates(t *testing.T) {
+	general := &discount.GeneralDiscount{ID: 1}
+	boots := &discount.CategoryDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 2, Target: "1"}}
+	sku := &discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 3, Target: "000001"}}
+	variant := &discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 4, Target: "000001-42"}}
+	other := &discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 5, Target: "000002"}}
+	bundle := &discount.BundleDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 6, Target: "000001,000002"}}
+	coupon := &discount.GeneralDiscount{ID: 7, CouponOnly: true}
+
+	idx := discount.NewIndex([]discount.Discount{general, boots, sku, variant, other, bundle, coupon})
+
+	tests := []struct {
+		name string
+		item discount.DiscountConditions
+		want []discount.Discount
+	}{
+		{"product", discount.DiscountConditions{CategoryID: "1", SKU: "000001"}, []discount.Discount{general, boots, sku, coupon}},
+		{"variant", discount.DiscountConditions{CategoryID: "1", SKU: "000001-42", ParentSKU: "000001"}, []discount.Discount{general, boots, sku, variant, coupon}},
+		{"other category", discount.DiscountConditions{CategoryID: "2", SKU: "000002"}, []discount.Discount{general, other, coupon}},
+		{"no discount of its own", discount.DiscountConditions{CategoryID: "3", SKU: "000003"}, []discount.Discount{general, coupon}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, idx.Candidates(tt.item))
+		})
+	}
+}
+
+// TestIndex_SameAsScanning checks the index leaves out no discount a full scan would apply
+func TestIndex_SameAsScanning(t *testing.T) {
+	var discounts []discount.Discount
+	for i, target := range []string{"1", "2", "000001", "000002", "000001-42", ""} {
+		for _, typeID := range []int{discount.CATEGORY, discount.SKU, discount.GENERAL, discount.BUY_X_GET_Y, discount.SPEND_THRESHOLD, discount.BUNDLE} {
+			discounts = append(discounts, discount.NewDiscount(discount.GeneralDiscount{ID: i, DiscountTypeID: typeID, Target: target}))
+		}
+	}
+	idx := discount.NewIndex(discounts)
+
+	for _, item := range []discount.DiscountConditions{
+		{CategoryID: "1", SKU: "000001"},
+		{CategoryID: "1", SKU: "000001-42", ParentSKU: "000001"},
+		{CategoryID: "2", SKU: "000002"},
+		{CategoryID: "3", SKU: "000003"},
+	} {
+		var scanned, indexed []discount.Discount
+		for _, d := range discounts {
+			if d.IsApplicableFor(item) {
+				scanned = append(scanned, d)
+			}
+		}
+		for _, d := range idx.Candidates(item) {
+			if d.IsApplicableFor(item) {
+				indexed = append(indexed, d)
+			}
+		}
+		assert.Equal(t, scanned, indexed, "%+v", item)
+	}
+}
@@ -16,6 +16,11 @@ func (s *Service) CreateDiscountType(ctx context.Context, discountType discount.
 	return args.Get(0).(discount.DiscountType), args.Error(1)
 }
 
+func (s *Service) EnsureDiscountTypes(ctx context.Context) error {
+	args := s.Called(ctx)
+	return args.Error(0)
+}
+
 func (s *Service) CreateDiscount(ctx context.Context, d discount.DiscountRequest) (discount.Discount, error) {
 	args := s.Called(ctx, d)
 	return args.Get(0).(discount.Discount), args.Error(1)
@@ -25,3 +30,12 @@ func (s *Service) GetDiscounts(ctx context.Context) ([]discount.Discount, error)
 	args := s.Called(ctx)
 	return args.Get(0).([]discount.Discount), args.Error(1)
 }
+
+func (s *Service) GetApplicableDiscounts(ctx context.Context) ([]discount.Discount, error) {
+	args := s.Called(ctx)
+	return args.Get(0).([]discount.Discount), args.Error(1)
+}
+
+func (s *Service) AddChangeListener(listener discount.ChangeListener) {
+	s.Called(listener)
+}
@@ -1,31 +1,51 @@
 package discount
 
 import (
+	"encoding/xml"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
 	"strconv"
+	"time"
 )
 
 const (
-	CATEGORY = 1 //applies to a whole category
-	SKU      = 2 //applies to a single product SKU
-	GENERAL  = 3 //applies to all products
+	CATEGORY        = 1 //applies to a whole category
+	SKU             = 2 //applies to a single product SKU
+	GENERAL         = 3 //applies to all products
+	BUY_X_GET_Y     = 4 //basket level, every BuyQuantity units of the target SKU FreeQuantity more are free
+	SPEND_THRESHOLD = 5 //basket level, percentage off the whole basket when it reaches MinSpend
+	BUNDLE          = 6 //basket level, the target SKUs (comma separated) bought together cost BundlePrice
 )
 
+// TypeNames are the discount types stored with the IDs above, which discounts are told apart by
+var TypeNames = map[int]string{
+	CATEGORY:        "category",
+	SKU:             "sku",
+	GENERAL:         "general",
+	BUY_X_GET_Y:     "buy_x_get_y",
+	SPEND_THRESHOLD: "spend_threshold",
+	BUNDLE:          "bundle",
+}
+
 type Discount interface {
 	IsApplicableFor(item DiscountConditions) bool
 	Apply(original int) int
 	GetPercentage() int
 	ToDiscountResponse() DiscountResponse
+	LastModified() time.Time
 }
 
 type DiscountConditions struct {
 	CategoryID string
 	SKU        string
+	// ParentSKU is set when pricing a product variant, so discounts on the parent also apply
+	ParentSKU string
 }
 
 // DiscountType represents the type of discount
 type DiscountType struct {
-	ID   int    `gorm:"primaryKey" json:"id" example:"1"`
-	Type string `gorm:"unique;not null" json:"type" example:"category"`
+	ID   int    `gorm:"primaryKey" json:"id" xml:"id" example:"1"`
+	Type string `gorm:"unique;not null" json:"type" xml:"type" example:"category"`
 }
 
 // DiscountTypeRequest represents the body for creating a discount type
@@ -70,11 +90,18 @@ func (d *DiscountType) GetIdentifier() string {
 // @Produce json
 // @Success 200 {object} GeneralDiscount
 type GeneralDiscount struct {
-	ID             int          `gorm:"primaryKey" json:"id" example:"1"`
-	Percentage     int          `gorm:"not null" json:"percentage" example:"10"`
-	DiscountTypeID int          `gorm:"not null" json:"discount_type_id" example:"1"`
-	DiscountType   DiscountType `gorm:"foreignKey:DiscountTypeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"discount_type"`
-	Target         string       `gorm:"not null" json:"target" example:"boots"`
+	XMLName        xml.Name     `gorm:"-" json:"-" xml:"discount"`
+	ID             int          `gorm:"primaryKey" json:"id" xml:"id" example:"1"`
+	Percentage     int          `gorm:"not null" json:"percentage" xml:"percentage" example:"10"`
+	DiscountTypeID int          `gorm:"not null" json:"discount_type_id" xml:"discount_type_id" example:"1"`
+	DiscountType   DiscountType `gorm:"foreignKey:DiscountTypeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"discount_type" xml:"discount_type"`
+	Target         string       `gorm:"not null" json:"target" xml:"target" example:"boots"`
+	CouponOnly     bool         `gorm:"not null;default:false" json:"coupon_only" xml:"coupon_only" example:"false"`
+	BuyQuantity    int          `gorm:"not null;default:0" json:"buy_quantity,omitempty" xml:"buy_quantity,omitempty" example:"2"`
+	FreeQuantity   int          `gorm:"not null;default:0" json:"free_quantity,omitempty" xml:"free_quantity,omitempty" example:"1"`
+	MinSpend       int          `gorm:"not null;default:0" json:"min_spend,omitempty" xml:"min_spend,omitempty" example:"50000"`
+	BundlePrice    int          `gorm:"not null;default:0" json:"bundle_price,omitempty" xml:"bundle_price,omitempty" example:"120000"`
+	database.Versioned
 }
 
 // DiscountRequest represents the body for creating a discount
@@ -86,6 +113,11 @@ type DiscountRequest struct {
 	Percentage     int    `json:"percentage" example:"10"`
 	DiscountTypeID int    `json:"discount_type_id" example:"1"`
 	Target         string `json:"target" example:"boots"`
+	CouponOnly     bool   `json:"coupon_only" example:"false"`
+	BuyQuantity    int    `json:"buy_quantity,omitempty" example:"2"`
+	FreeQuantity   int    `json:"free_quantity,omitempty" example:"1"`
+	MinSpend       int    `json:"min_spend,omitempty" example:"50000"`
+	BundlePrice    int    `json:"bundle_price,omitempty" example:"120000"`
 }
 
 // DiscountResponse represents the output when retrieving discount details
@@ -98,6 +130,30 @@ type DiscountResponse struct {
 	Target       string       `json:"target" example:"boots"`
 	DiscountType DiscountType `json:"discount_type"`
 	Percentage   int          `json:"percentage" example:"10"`
+	CouponOnly   bool         `json:"coupon_only" example:"false"`
+	BuyQuantity  int          `json:"buy_quantity,omitempty" example:"2"`
+	FreeQuantity int          `json:"free_quantity,omitempty" example:"1"`
+	MinSpend     int          `json:"min_spend,omitempty" example:"50000"`
+	BundlePrice  int          `json:"bundle_price,omitempty" example:"120000"`
+}
+
+// Validate checks the fields every basket level promotion needs to be evaluated
+func (d *DiscountRequest) Validate() error {
+	switch d.DiscountTypeID {
+	case BUY_X_GET_Y:
+		if d.Target == "" || d.BuyQuantity <= 0 || d.FreeQuantity <= 0 {
+			return apierror.BadRequest("buy x get y discounts need a target SKU, buy_quantity and free_quantity")
+		}
+	case SPEND_THRESHOLD:
+		if d.MinSpend <= 0 || d.Percentage <= 0 || d.Percentage > 100 {
+			return apierror.BadRequest("spend threshold discounts need a min_spend and a percentage between 1 and 100")
+		}
+	case BUNDLE:
+		if len(BundleSKUs(d.Target)) < 2 || d.BundlePrice <= 0 {
+			return apierror.BadRequest("bundle discounts need at least two comma separated target SKUs and a bundle_price")
+		}
+	}
+	return nil
 }
 
 func (d *DiscountRequest) ToDiscount() GeneralDiscount {
@@ -105,6 +161,11 @@ func (d *DiscountRequest) ToDiscount() GeneralDiscount {
 		Percentage:     d.Percentage,
 		DiscountTypeID: d.DiscountTypeID,
 		Target:         d.Target,
+		CouponOnly:     d.CouponOnly,
+		BuyQuantity:    d.BuyQuantity,
+		FreeQuantity:   d.FreeQuantity,
+		MinSpend:       d.MinSpend,
+		BundlePrice:    d.BundlePrice,
 	}
 }
 
@@ -114,6 +175,33 @@ func (d *GeneralDiscount) ToDiscountResponse() DiscountResponse {
 		Percentage:   d.Percentage,
 		DiscountType: d.DiscountType,
 		Target:       d.Target,
+		CouponOnly:   d.CouponOnly,
+		BuyQuantity:  d.BuyQuantity,
+		FreeQuantity: d.FreeQuantity,
+		MinSpend:     d.MinSpend,
+		BundlePrice:  d.BundlePrice,
+	}
+}
+
+// CSVHeader names the columns of the discounts given as CSV
+func (d GeneralDiscount) CSVHeader() []string {
+	return []string{"id", "discount_type_id", "target", "percentage", "coupon_only", "buy_quantity", "free_quantity", "min_spend", "bundle_price", "version", "updated_at"}
+}
+
+// CSVRecord returns the row of the discount
+func (d GeneralDiscount) CSVRecord() []string {
+	return []string{
+		strconv.Itoa(d.ID),
+		strconv.Itoa(d.DiscountTypeID),
+		d.Target,
+		strconv.Itoa(d.Percentage),
+		strconv.FormatBool(d.CouponOnly),
+		strconv.Itoa(d.BuyQuantity),
+		strconv.Itoa(d.FreeQuantity),
+		strconv.Itoa(d.MinSpend),
+		strconv.Itoa(d.BundlePrice),
+		strconv.Itoa(d.Version),
+		d.UpdatedAt.Format(time.RFC3339),
 	}
 }
 
@@ -133,6 +221,41 @@ func (d *GeneralDiscount) IsApplicableFor(item DiscountConditions) bool {
 	return true
 }
 
+// Kind names the type of a discount, e.g. for metrics
+func Kind(d Discount) string {
+	switch d.(type) {
+	case *CategoryDiscount:
+		return "category"
+	case *SkuDiscount:
+		return "sku"
+	case *BuyXGetYDiscount:
+		return "buy_x_get_y"
+	case *SpendThresholdDiscount:
+		return "spend_threshold"
+	case *BundleDiscount:
+		return "bundle"
+	}
+	return "general"
+}
+
+// NewDiscount wraps a stored discount in the implementation matching its type
+func NewDiscount(d GeneralDiscount) Discount {
+	switch d.DiscountTypeID {
+	case CATEGORY:
+		return &CategoryDiscount{d}
+	case SKU:
+		return &SkuDiscount{d}
+	case BUY_X_GET_Y:
+		return &BuyXGetYDiscount{d}
+	case SPEND_THRESHOLD:
+		return &SpendThresholdDiscount{d}
+	case BUNDLE:
+		return &BundleDiscount{d}
+	default:
+		return &d
+	}
+}
+
 type CategoryDiscount struct {
 	GeneralDiscount
 }
@@ -146,5 +269,31 @@ type SkuDiscount struct {
 }
 
 func (d *SkuDiscount) IsApplicableFor(item DiscountConditions) bool {
-	return item.SKU == d.Target
+	return item.SKU == d.Target || (item.ParentSKU != "" && item.ParentSKU == d.Target)
+}
+
+type couponOnlyFilter struct {
+	field   string
+	Value   bool
+	Operand string
+}
+
+func (f *couponOnlyFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *couponOnlyFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *couponOnlyFilter) GetOperand() string {
+	return f.Operand
+}
+
+func NewCouponOnlyFilter(value bool) database.Filter {
+	return &couponOnlyFilter{
+		field:   "coupon_only",
+		Value:   value,
+		Operand: "=",
+	}
 }
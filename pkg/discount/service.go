@@ -2,74 +2,188 @@ package discount
 
 import (
 	"context"
+	"fmt"
 	"mytheresa/internal/apierror"
 	"mytheresa/internal/database"
 	"mytheresa/internal/logger"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/audit"
+	"mytheresa/pkg/events"
+	"sort"
 )
 
 type Service interface {
 	CreateDiscountType(ctx context.Context, discountType DiscountTypeRequest) (DiscountType, error)
+	EnsureDiscountTypes(ctx context.Context) error
 	CreateDiscount(ctx context.Context, discount DiscountRequest) (Discount, error)
 	GetDiscounts(ctx context.Context) ([]Discount, error)
+	GetApplicableDiscounts(ctx context.Context) ([]Discount, error)
+	AddChangeListener(listener ChangeListener)
 }
 
+// ChangeListener is called with the discount created, within its transaction, e.g. to refresh
+// what depends on the discounts it targets. Returning an error rolls the discount back.
+type ChangeListener func(ctx context.Context, created GeneralDiscount) error
+
 type service struct {
-	db     database.Database
-	logger logger.Logger
+	db           database.Database
+	logger       logger.Logger
+	auditService audit.Service
+	events       events.Publisher
+	listeners    []ChangeListener
 }
 
-func NewService(db database.Database, logger logger.Logger) Service {
+func NewService(db database.Database, logger logger.Logger, as audit.Service, ep events.Publisher) Service {
 	return &service{
-		db,
-		logger,
+		db:           db,
+		logger:       logger,
+		auditService: as,
+		events:       ep,
 	}
 }
 
+// AddChangeListener registers a listener for the discounts created from now on. Not safe to call
+// concurrently with CreateDiscount, listeners are meant to be added on start up.
+func (s *service) AddChangeListener(listener ChangeListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
 func (s *service) CreateDiscountType(ctx context.Context, req DiscountTypeRequest) (DiscountType, error) {
+	ctx, span := tracing.Start(ctx, "discount.CreateDiscountType")
+	defer span.End()
+
 	discountType := req.ToDiscountType()
-	err := s.db.Save(ctx, discountType.GetIdentifier(), &discountType)
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		err := s.db.Save(ctx, discountType.GetIdentifier(), &discountType)
+		if err != nil {
+			s.logger.WithError(err).Error(ctx, "error creating discount type")
+			return apierror.InternalServerError("error creating discount type")
+		}
+		return s.auditService.Record(ctx, audit.DiscountType, discountType.GetIdentifier(), audit.Create, nil, discountType)
+	})
 	if err != nil {
-		s.logger.WithError(err).Error(ctx, "error creating discount type")
-		return DiscountType{}, apierror.InternalServerError("error creating discount type")
+		return DiscountType{}, err
 	}
 	return discountType, nil
 }
 
+// EnsureDiscountTypes creates the discount types of TypeNames missing, with their own IDs, and
+// fails when one of those IDs or names is taken by another type, as the discounts of that type
+// would then be priced as another kind. Meant to be called on start up.
+func (s *service) EnsureDiscountTypes(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "discount.EnsureDiscountTypes")
+	defer span.End()
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		var stored []DiscountType
+		if err := s.db.GetWithFilters(ctx, &stored); err != nil {
+			s.logger.WithError(err).Error(ctx, "error getting discount types")
+			return apierror.InternalServerError("error getting discount types")
+		}
+		byID := map[int]DiscountType{}
+		byName := map[string]DiscountType{}
+		for _, t := range stored {
+			byID[t.ID] = t
+			byName[t.Type] = t
+		}
+
+		ids := make([]int, 0, len(TypeNames))
+		for id := range TypeNames {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			name := TypeNames[id]
+			if t, ok := byID[id]; ok {
+				if t.Type != name {
+					return fmt.Errorf("discount type %d is %s instead of %s", id, t.Type, name)
+				}
+				continue
+			}
+			if t, ok := byName[name]; ok {
+				return fmt.Errorf("discount type %s has ID %d instead of %d", name, t.ID, id)
+			}
+
+			discountType := DiscountType{ID: id, Type: name}
+			if err := s.db.Save(ctx, discountType.GetIdentifier(), &discountType); err != nil {
+				s.logger.WithField("type", name).WithError(err).Error(ctx, "error creating discount type")
+				return apierror.InternalServerError("error creating discount type")
+			}
+			if err := s.auditService.Record(ctx, audit.DiscountType, discountType.GetIdentifier(), audit.Create, nil, discountType); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (s *service) CreateDiscount(ctx context.Context, req DiscountRequest) (Discount, error) {
+	ctx, span := tracing.Start(ctx, "discount.CreateDiscount")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		return &GeneralDiscount{}, err
+	}
+
 	discount := req.ToDiscount()
-	err := s.db.Save(ctx, discount.GetIdentifier(), &discount)
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		err := s.db.Save(ctx, discount.GetIdentifier(), &discount)
+		if err != nil {
+			s.logger.
+				WithError(err).
+				Error(ctx, "error creating discount")
+			return apierror.InternalServerError("error creating discount")
+		}
+		if err := s.auditService.Record(ctx, audit.Discount, discount.GetIdentifier(), audit.Create, nil, discount); err != nil {
+			return err
+		}
+		if err := s.events.Publish(ctx, events.DiscountCreated, discount); err != nil {
+			return err
+		}
+		for _, listener := range s.listeners {
+			if err := listener(ctx, discount); err != nil {
+				s.logger.WithError(err).Error(ctx, "error notifying discount change")
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		s.logger.
-			WithError(err).
-			Error(ctx, "error creating discount")
-		return &GeneralDiscount{}, apierror.InternalServerError("error creating discount")
+		return &GeneralDiscount{}, err
 	}
 
 	return &discount, nil
 }
 
+// GetDiscounts returns every discount, coupon only ones included
 func (s *service) GetDiscounts(ctx context.Context) ([]Discount, error) {
+	ctx, span := tracing.Start(ctx, "discount.GetDiscounts")
+	defer span.End()
+
+	return s.getDiscounts(ctx)
+}
+
+// GetApplicableDiscounts returns the discounts applied to everyone. Coupon only discounts are
+// left out, as they are unlocked through their coupon.
+func (s *service) GetApplicableDiscounts(ctx context.Context) ([]Discount, error) {
+	ctx, span := tracing.Start(ctx, "discount.GetApplicableDiscounts")
+	defer span.End()
+
+	return s.getDiscounts(ctx, NewCouponOnlyFilter(false))
+}
+
+func (s *service) getDiscounts(ctx context.Context, filters ...database.Filter) ([]Discount, error) {
 	var discounts []GeneralDiscount
 	results := []Discount{}
 
-	err := s.db.GetWithFilters(ctx, &discounts)
+	err := s.db.GetWithFilters(ctx, &discounts, filters...)
 	if err != nil {
 		s.logger.WithError(err).Error(ctx, "error getting discounts")
 		return nil, apierror.InternalServerError("error getting discounts")
 	}
 
 	for _, d := range discounts {
-		switch d.DiscountTypeID {
-		case CATEGORY:
-			results = append(results, &CategoryDiscount{d})
-			break
-		case SKU:
-			results = append(results, &SkuDiscount{d})
-			break
-		default:
-			results = append(results, &d)
-
-		}
+		results = append(results, NewDiscount(d))
 	}
 
 	return results, nil
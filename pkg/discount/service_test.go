@@ -4,9 +4,15 @@ import (
 	"context"
 	"errors"
 	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
 	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
 	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/audit"
+	auditmocks "mytheresa/pkg/audit/mocks"
 	"mytheresa/pkg/discount"
+	"mytheresa/pkg/events"
+	eventsmocks "mytheresa/pkg/events/mocks"
 	"net/http"
 	"testing"
 
@@ -18,7 +24,7 @@ func TestNewService(t *testing.T) {
 	dbmock := dbmocks.Database{}
 	logMock := loggermocks.NoopLogger{}
 
-	s := discount.NewService(&dbmock, &logMock)
+	s := discount.NewService(&dbmock, &logMock, auditmocks.AnyService(), eventsmocks.AnyPublisher())
 
 	assert.NotNil(t, s)
 }
@@ -29,7 +35,7 @@ func TestCreateDiscountType_OK(t *testing.T) {
 
 	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-	s := discount.NewService(&dbmock, &logMock)
+	s := discount.NewService(&dbmock, &logMock, auditmocks.AnyService(), eventsmocks.AnyPublisher())
 	req := discount.DiscountTypeRequest{
 		Type: "Test",
 	}
@@ -47,7 +53,7 @@ func TestCreateDiscountType_Error(t *testing.T) {
 
 	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
 
-	s := discount.NewService(&dbmock, &logMock)
+	s := discount.NewService(&dbmock, &logMock, auditmocks.AnyService(), eventsmocks.AnyPublisher())
 	req := discount.DiscountTypeRequest{
 		Type: "Test",
 	}
@@ -62,12 +68,54 @@ func TestCreateDiscountType_Error(t *testing.T) {
 	assert.Equal(t, "error creating discount type", apierr.Error())
 }
 
+func TestEnsureDiscountTypes(t *testing.T) {
+	sqlDB := sqlitetest.NewDB(t, &discount.DiscountType{})
+	s := discount.NewService(sqlDB, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+	ctx := context.Background()
+
+	// types of an earlier run are kept, the missing ones added with their IDs
+	_, err := s.CreateDiscountType(ctx, discount.DiscountTypeRequest{Type: "category"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.EnsureDiscountTypes(ctx))
+	assert.NoError(t, s.EnsureDiscountTypes(ctx))
+
+	var types []discount.DiscountType
+	assert.NoError(t, sqlDB.GetWithFilters(ctx, &types))
+	assert.Len(t, types, len(discount.TypeNames))
+	for _, dt := range types {
+		assert.Equal(t, discount.TypeNames[dt.ID], dt.Type)
+	}
+}
+
+func TestEnsureDiscountTypes_TakenByAnotherType(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := sqlitetest.NewDB(t, &discount.DiscountType{})
+	s := discount.NewService(sqlDB, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+	for _, name := range []string{"category", "sku", "general", "seasonal"} {
+		_, err := s.CreateDiscountType(ctx, discount.DiscountTypeRequest{Type: name})
+		assert.NoError(t, err)
+	}
+	assert.EqualError(t, s.EnsureDiscountTypes(ctx), "discount type 4 is seasonal instead of buy_x_get_y")
+
+	sqlDB = sqlitetest.NewDB(t, &discount.DiscountType{})
+	s = discount.NewService(sqlDB, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+	assert.NoError(t, sqlDB.Save(ctx, "9", &discount.DiscountType{ID: 9, Type: "bundle"}))
+	assert.EqualError(t, s.EnsureDiscountTypes(ctx), "discount type bundle has ID 9 instead of 6")
+
+	// nothing is created when failing
+	var types []discount.DiscountType
+	assert.NoError(t, sqlDB.GetWithFilters(ctx, &types))
+	assert.Len(t, types, 1)
+}
+
 func TestCreateDiscount_OK(t *testing.T) {
 	dbmock := dbmocks.Database{}
 	logMock := loggermocks.NoopLogger{}
 
 	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-	s := discount.NewService(&dbmock, &logMock)
+	s := discount.NewService(&dbmock, &logMock, auditmocks.AnyService(), eventsmocks.AnyPublisher())
 
 	req := discount.DiscountRequest{
 		Percentage:     10,
@@ -87,7 +135,7 @@ func TestCreateDiscount_Error(t *testing.T) {
 	logMock := loggermocks.NoopLogger{}
 
 	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
-	s := discount.NewService(&dbmock, &logMock)
+	s := discount.NewService(&dbmock, &logMock, auditmocks.AnyService(), eventsmocks.AnyPublisher())
 	req := discount.DiscountRequest{
 		Percentage:     10,
 		DiscountTypeID: 1,
@@ -139,7 +187,7 @@ func TestGetDiscounts_OK_OneOfEachDiscountOnDB(t *testing.T) {
 		}
 	}).Return(nil)
 
-	s := discount.NewService(&dbmock, &logMock)
+	s := discount.NewService(&dbmock, &logMock, auditmocks.AnyService(), eventsmocks.AnyPublisher())
 
 	results, err := s.GetDiscounts(context.Background())
 
@@ -160,7 +208,7 @@ func TestGetDiscounts_OK_NoDiscountsOnDB(t *testing.T) {
 
 	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-	s := discount.NewService(&dbmock, &logMock)
+	s := discount.NewService(&dbmock, &logMock, auditmocks.AnyService(), eventsmocks.AnyPublisher())
 	results, err := s.GetDiscounts(context.Background())
 
 	assert.Nil(t, err)
@@ -174,7 +222,7 @@ func TestGetDiscounts_DatabaseError(t *testing.T) {
 
 	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
 
-	s := discount.NewService(&dbmock, &logMock)
+	s := discount.NewService(&dbmock, &logMock, auditmocks.AnyService(), eventsmocks.AnyPublisher())
 	_, err := s.GetDiscounts(context.Background())
 
 	assert.NotNil(t, err)
@@ -183,3 +231,191 @@ func TestGetDiscounts_DatabaseError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, apierr.Code())
 	assert.Equal(t, "error getting discounts", apierr.Error())
 }
+
+func TestGetDiscounts_CouponOnlyIncluded(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, []database.Filter(nil)).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]discount.GeneralDiscount) = []discount.GeneralDiscount{
+			{ID: 1, DiscountTypeID: discount.GENERAL, Percentage: 10},
+			{ID: 2, DiscountTypeID: discount.GENERAL, Percentage: 20, CouponOnly: true},
+		}
+	}).Return(nil)
+
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+
+	results, err := s.GetDiscounts(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	dbmock.AssertExpectations(t)
+}
+
+func TestGetApplicableDiscounts_LeavesCouponOnlyOut(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, []database.Filter{discount.NewCouponOnlyFilter(false)}).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]discount.GeneralDiscount) = []discount.GeneralDiscount{
+			{ID: 1, DiscountTypeID: discount.GENERAL, Percentage: 10},
+		}
+	}).Return(nil)
+
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+
+	results, err := s.GetApplicableDiscounts(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	dbmock.AssertExpectations(t)
+}
+
+func TestCreateDiscount_InvalidBasketPromotions(t *testing.T) {
+	tests := map[string]discount.DiscountRequest{
+		"buy x get y without free quantity": {DiscountTypeID: discount.BUY_X_GET_Y, Target: "000005", BuyQuantity: 2},
+		"spend threshold without min spend": {DiscountTypeID: discount.SPEND_THRESHOLD, Percentage: 10},
+		"bundle with a single product":      {DiscountTypeID: discount.BUNDLE, Target: "000001", BundlePrice: 1000},
+	}
+
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			dbmock := dbmocks.Database{}
+			s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+
+			_, err := s.CreateDiscount(context.Background(), req)
+
+			apierr, ok := err.(*apierror.ApiError)
+			assert.True(t, ok)
+			assert.Equal(t, http.StatusBadRequest, apierr.Code())
+			dbmock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetDiscounts_OK_BasketPromotions(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]discount.GeneralDiscount) = []discount.GeneralDiscount{
+			{ID: 1, DiscountTypeID: discount.BUY_X_GET_Y, Target: "000005", BuyQuantity: 2, FreeQuantity: 1},
+			{ID: 2, DiscountTypeID: discount.SPEND_THRESHOLD, Percentage: 10, MinSpend: 50000},
+			{ID: 3, DiscountTypeID: discount.BUNDLE, Target: "000001,000004", BundlePrice: 120000},
+		}
+	}).Return(nil)
+
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+
+	results, err := s.GetDiscounts(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	_, ok := results[0].(*discount.BuyXGetYDiscount)
+	assert.True(t, ok)
+	_, ok = results[1].(*discount.SpendThresholdDiscount)
+	assert.True(t, ok)
+	_, ok = results[2].(*discount.BundleDiscount)
+	assert.True(t, ok)
+}
+
+func TestCreateDiscount_RecordsAudit(t *testing.T) {
+	req := discount.DiscountRequest{DiscountTypeID: discount.SKU, Target: "000003", Percentage: 90}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(2).(*discount.GeneralDiscount).ID = 3
+	}).Return(nil)
+	stored := req.ToDiscount()
+	stored.ID = 3
+	as := auditmocks.Service{}
+	as.On("Record", mock.Anything, audit.Discount, "3", audit.Create, nil, stored).Return(nil)
+
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, &as, eventsmocks.AnyPublisher())
+	_, err := s.CreateDiscount(context.Background(), req)
+
+	assert.NoError(t, err)
+	as.AssertExpectations(t)
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+}
+
+func TestCreateDiscount_PublishesDiscount(t *testing.T) {
+	req := discount.DiscountRequest{DiscountTypeID: discount.SKU, Target: "000003", Percentage: 90}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(2).(*discount.GeneralDiscount).ID = 3
+	}).Return(nil)
+	stored := req.ToDiscount()
+	stored.ID = 3
+	ep := eventsmocks.Publisher{}
+	ep.On("Publish", mock.Anything, events.DiscountCreated, stored).Return(nil)
+
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService(), &ep)
+	_, err := s.CreateDiscount(context.Background(), req)
+
+	assert.NoError(t, err)
+	ep.AssertExpectations(t)
+}
+
+func TestCreateDiscountType_RecordsAudit(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(2).(*discount.DiscountType).ID = 1
+	}).Return(nil)
+	as := auditmocks.Service{}
+	as.On("Record", mock.Anything, audit.DiscountType, "1", audit.Create, nil, discount.DiscountType{ID: 1, Type: "category"}).Return(nil)
+
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, &as, eventsmocks.AnyPublisher())
+	_, err := s.CreateDiscountType(context.Background(), discount.DiscountTypeRequest{Type: "category"})
+
+	assert.NoError(t, err)
+	as.AssertExpectations(t)
+}
+
+func TestCreateDiscount_ErrorRecordingAudit(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	as := auditmocks.Service{}
+	as.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(apierror.InternalServerError("error recording audit entry"))
+
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, &as, eventsmocks.AnyPublisher())
+	_, err := s.CreateDiscount(context.Background(), discount.DiscountRequest{DiscountTypeID: discount.GENERAL, Percentage: 10})
+
+	assert.EqualError(t, err, "error recording audit entry")
+}
+
+func TestCreateDiscount_NotifiesListeners(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+	notified := 0
+	var created discount.GeneralDiscount
+	s.AddChangeListener(func(ctx context.Context, d discount.GeneralDiscount) error {
+		notified++
+		created = d
+		return nil
+	})
+
+	_, err := s.CreateDiscount(context.Background(), discount.DiscountRequest{DiscountTypeID: discount.CATEGORY, Target: "2", Percentage: 10})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notified)
+	assert.Equal(t, discount.CATEGORY, created.DiscountTypeID)
+	assert.Equal(t, "2", created.Target)
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+}
+
+func TestCreateDiscount_ListenerErrorRollsBack(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	listenerErr := apierror.InternalServerError("error recording price history")
+	s := discount.NewService(&dbmock, &loggermocks.NoopLogger{}, auditmocks.AnyService(), eventsmocks.AnyPublisher())
+	s.AddChangeListener(func(ctx context.Context, _ discount.GeneralDiscount) error {
+		return listenerErr
+	})
+
+	_, err := s.CreateDiscount(context.Background(), discount.DiscountRequest{DiscountTypeID: discount.GENERAL, Percentage: 10})
+
+	assert.Equal(t, listenerErr, err)
+}
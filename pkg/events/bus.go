@@ -0,0 +1,207 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/metrics"
+	"mytheresa/internal/requestctx"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// history is how many of the latest events are kept for the subscribers resuming after one
+	history = 256
+	// buffer is how many events a subscriber can fall behind before being dropped
+	buffer = 64
+)
+
+// ErrClosed is returned when subscribing to a bus already closed
+var ErrClosed = errors.New("event bus closed")
+
+// Publisher sends the changes of the catalog to whoever listens to them
+type Publisher interface {
+	// Publish sends an event of the type with data once the transaction of ctx commits, never
+	// if it rolls back. Failing to encode or store it is an error, meant to roll the change back.
+	Publish(ctx context.Context, eventType Type, data interface{}) error
+}
+
+// Subscriber hands out the events published
+type Subscriber interface {
+	// Subscribe returns the events of the types from now on, every type when none is given,
+	// preceded by the ones still kept that came after the event with ID after, if given
+	Subscribe(types []Type, after string) (*Subscription, error)
+	Unsubscribe(s *Subscription)
+}
+
+// Subscription gets the events a subscriber wants, until it falls too far behind or the bus
+// is closed, when its channel is closed
+type Subscription struct {
+	events chan Event
+	types  map[Type]bool
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) wants(t Type) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+// Bus writes the events published to the outbox, along with the change, and hands them to the
+// subscribers in memory once relayed, the latest ones being kept so streams can be resumed
+type Bus struct {
+	db     database.Database
+	logger logger.Logger
+	clock  func() time.Time
+	// pending wakes the relay up when events were committed to the outbox
+	pending   chan struct{}
+	listeners []RelayListener
+	// relaying keeps two relays from handing the same events to the listeners
+	relaying sync.Mutex
+
+	mu sync.Mutex
+	// last is the ID of the latest event sent, the ones up to it were sent already
+	last        uint64
+	recent      []Event
+	subscribers map[*Subscription]bool
+	closed      bool
+}
+
+func NewBus(db database.Database, logger logger.Logger, clock func() time.Time) *Bus {
+	return &Bus{
+		db:          db,
+		logger:      logger,
+		clock:       clock,
+		pending:     make(chan struct{}, 1),
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+func (b *Bus) Publish(ctx context.Context, eventType Type, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		b.logger.WithField("type", eventType).WithError(err).Error(ctx, "error encoding event")
+		return apierror.InternalServerError("error publishing event")
+	}
+
+	entry := OutboxEvent{
+		IdempotencyKey: uuid.New().String(),
+		Type:           eventType,
+		OccurredAt:     b.clock().UTC(),
+		RequestID:      requestctx.RequestID(ctx),
+		Data:           payload,
+	}
+	if err := b.db.Save(ctx, entry.GetIdentifier(), &entry); err != nil {
+		b.logger.WithField("type", eventType).WithError(err).Error(ctx, "error writing event to the outbox")
+		return apierror.InternalServerError("error publishing event")
+	}
+
+	b.db.AfterCommit(ctx, func() {
+		select {
+		case b.pending <- struct{}{}:
+		default:
+		}
+	})
+	return nil
+}
+
+// send hands the event to the subscribers wanting it, unless the bus is closed. The ones too
+// far behind are dropped rather than holding up the rest, they can resume after the last event
+// they got.
+func (b *Bus) send(event Event) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	// sent already, in case the same event is ever relayed twice
+	id, _ := strconv.ParseUint(event.ID, 10, 64)
+	if id <= b.last {
+		return true
+	}
+	b.last = id
+
+	b.recent = append(b.recent, event)
+	if len(b.recent) > history {
+		b.recent = b.recent[len(b.recent)-history:]
+	}
+	metrics.CountEvent(string(event.Type))
+
+	for s := range b.subscribers {
+		if !s.wants(event.Type) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			b.logger.WithField("event", event.ID).Warn(context.Background(), "Dropping event subscriber falling behind")
+			delete(b.subscribers, s)
+			close(s.events)
+		}
+	}
+	return true
+}
+
+func (b *Bus) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+func (b *Bus) Subscribe(types []Type, after string) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	s := &Subscription{types: map[Type]bool{}}
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	var missed []Event
+	if last, err := strconv.ParseUint(after, 10, 64); err == nil {
+		for _, e := range b.recent {
+			if id, _ := strconv.ParseUint(e.ID, 10, 64); id > last && s.wants(e.Type) {
+				missed = append(missed, e)
+			}
+		}
+	}
+	s.events = make(chan Event, buffer+len(missed))
+	for _, e := range missed {
+		s.events <- e
+	}
+
+	b.subscribers[s] = true
+	return s, nil
+}
+
+func (b *Bus) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[s] {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Close ends every subscription, meant for the shutdown as streams would otherwise keep it waiting
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
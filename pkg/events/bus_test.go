@@ -0,0 +1,168 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"mytheresa/internal/database/sqlite/sqlitetest"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/events"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func newBus(t *testing.T) *events.Bus {
+	return events.NewBus(sqlitetest.NewDB(t, &events.OutboxEvent{}), &loggermocks.NoopLogger{}, func() time.Time { return now })
+}
+
+// publish writes the event to the outbox and relays it
+func publish(t *testing.T, b *events.Bus, eventType events.Type, data interface{}) {
+	assert.NoError(t, b.Publish(context.Background(), eventType, data))
+	_, err := b.Relay(context.Background())
+	assert.NoError(t, err)
+}
+
+// received returns the events already sent to the subscription
+func received(s *events.Subscription) []events.Event {
+	var got []events.Event
+	for {
+		select {
+		case e, ok := <-s.Events():
+			if !ok {
+				return got
+			}
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	types, err := events.ParseTypes("product.created, price.changed")
+	assert.NoError(t, err)
+	assert.Equal(t, []events.Type{events.ProductCreated, events.PriceChanged}, types)
+
+	types, err = events.ParseTypes("")
+	assert.NoError(t, err)
+	assert.Empty(t, types)
+
+	_, err = events.ParseTypes("product.deleted")
+	assert.EqualError(t, err, "unknown event type product.deleted")
+}
+
+func TestPublish_SentToSubscribersWantingIt(t *testing.T) {
+	b := newBus(t)
+	all, err := b.Subscribe(nil, "")
+	assert.NoError(t, err)
+	prices, err := b.Subscribe([]events.Type{events.PriceChanged}, "")
+	assert.NoError(t, err)
+
+	publish(t, b, events.ProductCreated, map[string]string{"sku": "000001"})
+	publish(t, b, events.PriceChanged, map[string]int{"price": 100})
+
+	got := received(all)
+	assert.Len(t, got, 2)
+	assert.Equal(t, "1", got[0].ID)
+	assert.Equal(t, events.ProductCreated, got[0].Type)
+	assert.Equal(t, now, got[0].OccurredAt)
+	assert.JSONEq(t, `{"sku":"000001"}`, string(got[0].Data))
+	assert.Equal(t, "2", got[1].ID)
+	assert.NotEqual(t, got[0].IdempotencyKey, got[1].IdempotencyKey)
+
+	got = received(prices)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "2", got[0].ID)
+}
+
+func TestPublish_WrongData(t *testing.T) {
+	b := newBus(t)
+	s, _ := b.Subscribe(nil, "")
+
+	err := b.Publish(context.Background(), events.ProductCreated, func() {})
+
+	assert.EqualError(t, err, "error publishing event")
+	relayed, err := b.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, relayed)
+	assert.Empty(t, received(s))
+}
+
+func TestPublish_OnlyOnceCommitted(t *testing.T) {
+	db := sqlitetest.NewDB(t, &events.OutboxEvent{})
+	b := events.NewBus(db, &loggermocks.NoopLogger{}, time.Now)
+	s, _ := b.Subscribe(nil, "")
+
+	err := db.WithTransaction(context.Background(), func(ctx context.Context) error {
+		assert.NoError(t, b.Publish(ctx, events.ProductCreated, "committed"))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	err = db.WithTransaction(context.Background(), func(ctx context.Context) error {
+		assert.NoError(t, b.Publish(ctx, events.ProductCreated, "rolled back"))
+		return errors.New("some error")
+	})
+	assert.Error(t, err)
+
+	relayed, err := b.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+	got := received(s)
+	assert.Len(t, got, 1)
+	assert.JSONEq(t, `"committed"`, string(got[0].Data))
+}
+
+func TestSubscribe_ResumesAfterLastEvent(t *testing.T) {
+	b := newBus(t)
+	for i := 0; i < 3; i++ {
+		publish(t, b, events.ProductUpdated, i)
+	}
+	publish(t, b, events.PriceChanged, 3)
+
+	s, err := b.Subscribe([]events.Type{events.ProductUpdated}, "1")
+	assert.NoError(t, err)
+
+	got := received(s)
+	assert.Len(t, got, 2)
+	assert.Equal(t, "2", got[0].ID)
+	assert.Equal(t, "3", got[1].ID)
+}
+
+func TestSubscribe_SlowSubscriberDropped(t *testing.T) {
+	b := newBus(t)
+	slow, _ := b.Subscribe(nil, "")
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, b.Publish(context.Background(), events.ProductUpdated, i))
+	}
+	relayed, err := b.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 100, relayed)
+
+	got := received(slow)
+	assert.Less(t, len(got), 100)
+	_, open := <-slow.Events()
+	assert.False(t, open)
+
+	// the events missed are still there to resume
+	again, _ := b.Subscribe(nil, got[len(got)-1].ID)
+	assert.Len(t, received(again), 100-len(got))
+}
+
+func TestClose(t *testing.T) {
+	b := newBus(t)
+	s, _ := b.Subscribe(nil, "")
+
+	b.Close()
+
+	_, open := <-s.Events()
+	assert.False(t, open)
+	_, err := b.Subscribe(nil, "")
+	assert.ErrorIs(t, err, events.ErrClosed)
+	// unsubscribing once closed does nothing
+	b.Unsubscribe(s)
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/response"
+	"net/http"
+	"time"
+)
+
+type Handler interface {
+	Stream(w http.ResponseWriter, r *http.Request)
+}
+
+type handler struct {
+	bus    Subscriber
+	logger logger.Logger
+	// keepAlive is how often a comment is sent on idle streams, so proxies don't close them
+	keepAlive time.Duration
+}
+
+func NewHandler(bus Subscriber, logger logger.Logger, keepAlive time.Duration) Handler {
+	return &handler{
+		bus:       bus,
+		logger:    logger,
+		keepAlive: keepAlive,
+	}
+}
+
+// Stream godoc
+// @Summary Stream the catalog changes
+// @Description Server-Sent Events with every change committed from now on: product.created, product.updated,
+// @Description discount.created and price.changed. Each event has its ID, type and the Event as JSON data.
+// @Description Reconnecting with Last-Event-ID resumes after that event, as long as it's among the latest ones kept.
+// @Produce text/event-stream
+// @Param types query string false "Comma separated event types, every type when empty"
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Success 200 {object} Event
+// @Failure 400 {object} apierror.ApiError "Unknown event type"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Failure 429 {object} apierror.ApiError "Too many requests"
+// @Failure 503 {object} apierror.ApiError "Shutting down"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/events [get]
+func (h *handler) Stream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	types, err := ParseTypes(r.URL.Query().Get("types"))
+	if err != nil {
+		response.RespondWithError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	sub, err := h.bus.Subscribe(types, r.Header.Get("Last-Event-ID"))
+	if errors.Is(err, ErrClosed) {
+		response.RespondWithError(w, apierror.ServiceUnavailable("Shutting down"))
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error subscribing to events")
+		response.RespondWithError(w, err)
+		return
+	}
+	defer h.bus.Unsubscribe(sub)
+
+	// streams outlive the write timeout of the server
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// proxies like nginx would otherwise hold the events back
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.logger.WithError(err).Error(ctx, "Events can't be streamed")
+		return
+	}
+
+	h.logger.WithField("types", types).Info(ctx, "Event stream started")
+	keepAlive := time.NewTicker(h.keepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-sub.Events():
+			if !ok {
+				h.logger.Info(ctx, "Event stream ended")
+				return
+			}
+			err = writeEvent(w, event)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			h.logger.WithError(err).Info(ctx, "Event stream closed")
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,123 @@
+package events_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/events"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHandler(t *testing.T) {
+	h := events.NewHandler(newBus(t), &loggermocks.NoopLogger{}, time.Second)
+
+	assert.NotNil(t, h)
+}
+
+func TestHandlerStream_OK(t *testing.T) {
+	b := newBus(t)
+	publish(t, b, events.ProductCreated, map[string]string{"sku": "000001"})
+	publish(t, b, events.PriceChanged, map[string]int{"price": 100})
+	h := events.NewHandler(b, &loggermocks.NoopLogger{}, time.Minute)
+	srv := httptest.NewServer(http.HandlerFunc(h.Stream))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?types=price.changed", nil)
+	// resumes from the start
+	r.Header.Set("Last-Event-ID", "0")
+	res, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(res.Body)
+	var got []string
+	for len(got) < 3 && lines.Scan() {
+		got = append(got, lines.Text())
+	}
+	assert.Equal(t, "id: 2", got[0])
+	assert.Equal(t, "event: price.changed", got[1])
+
+	var event events.Event
+	assert.NoError(t, json.Unmarshal([]byte(got[2][len("data: "):]), &event))
+	assert.Equal(t, "2", event.ID)
+	assert.JSONEq(t, `{"price":100}`, string(event.Data))
+}
+
+func TestHandlerStream_KeepAlive(t *testing.T) {
+	h := events.NewHandler(newBus(t), &loggermocks.NoopLogger{}, time.Millisecond)
+	srv := httptest.NewServer(http.HandlerFunc(h.Stream))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	lines := bufio.NewScanner(res.Body)
+	assert.True(t, lines.Scan())
+	assert.Equal(t, ": keep-alive", lines.Text())
+}
+
+func TestHandlerStream_UnknownType(t *testing.T) {
+	h := events.NewHandler(newBus(t), &loggermocks.NoopLogger{}, time.Second)
+
+	r := httptest.NewRequest(http.MethodGet, "/events?types=product.deleted", nil)
+	w := httptest.NewRecorder()
+
+	h.Stream(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var res apierror.ApiError
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.Equal(t, "unknown event type product.deleted", res.Message)
+}
+
+func TestHandlerStream_BusClosed(t *testing.T) {
+	b := newBus(t)
+	b.Close()
+	h := events.NewHandler(b, &loggermocks.NoopLogger{}, time.Second)
+
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	w := httptest.NewRecorder()
+
+	h.Stream(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandlerStream_EndsWhenBusCloses(t *testing.T) {
+	b := newBus(t)
+	h := events.NewHandler(b, &loggermocks.NoopLogger{}, time.Minute)
+	srv := httptest.NewServer(http.HandlerFunc(h.Stream))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	b.Close()
+
+	done := make(chan struct{})
+	go func() {
+		lines := bufio.NewScanner(res.Body)
+		for lines.Scan() {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream still open after closing the bus")
+	}
+}
@@ -0,0 +1,24 @@
+package mocks
+
+import (
+	"context"
+	"mytheresa/pkg/events"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Publisher struct {
+	mock.Mock
+}
+
+func (p *Publisher) Publish(ctx context.Context, eventType events.Type, data interface{}) error {
+	args := p.Called(ctx, eventType, data)
+	return args.Error(0)
+}
+
+// AnyPublisher accepts every event published
+func AnyPublisher() *Publisher {
+	p := Publisher{}
+	p.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return &p
+}
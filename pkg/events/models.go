@@ -0,0 +1,112 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"mytheresa/internal/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type tells what changed in the catalog
+type Type string
+
+const (
+	// ProductCreated carries the product created, variants included
+	ProductCreated Type = "product.created"
+	// ProductUpdated carries the units available of a product or variant SKU whose stock changed
+	ProductUpdated Type = "product.updated"
+	// DiscountCreated carries the discount created
+	DiscountCreated Type = "discount.created"
+	// PriceChanged carries the final price of a SKU, after discounts, along with the previous one
+	PriceChanged Type = "price.changed"
+)
+
+// Types are every type of event published
+var Types = []Type{ProductCreated, ProductUpdated, DiscountCreated, PriceChanged}
+
+// ParseTypes reads comma separated event types, every type being wanted when value is empty
+func ParseTypes(value string) ([]Type, error) {
+	var types []Type
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, t := range Types {
+			known = known || Type(name) == t
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown event type %s", name)
+		}
+		types = append(types, Type(name))
+	}
+	return types, nil
+}
+
+// Event is a change of the catalog, committed. IDs grow with every event published, so a
+// stream can be resumed after the last one seen. An event may be sent more than once, always
+// with the same IdempotencyKey, unique to it even across databases.
+type Event struct {
+	ID             string    `json:"id" example:"42"`
+	IdempotencyKey string    `json:"idempotency_key" example:"9b2f6c1e-3a4d-4f5e-8a7b-6c5d4e3f2a1b"`
+	Type           Type      `json:"type" example:"price.changed"`
+	OccurredAt     time.Time `json:"occurred_at" example:"2025-01-02T15:04:05Z"`
+	// RequestID is the request making the change, empty for the ones made in the background
+	RequestID string          `json:"request_id,omitempty" example:"0f8fad5b-d9cb-469f-a165-70867728950e"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// OutboxEvent is an event waiting to be published, written in the same transaction as the change
+// so it's only there once the change is committed, and removed once published
+type OutboxEvent struct {
+	ID             int       `gorm:"primaryKey"`
+	IdempotencyKey string    `gorm:"not null;uniqueIndex"`
+	Type           Type      `gorm:"not null"`
+	OccurredAt     time.Time `gorm:"not null"`
+	RequestID      string
+	Data           []byte `gorm:"not null"`
+}
+
+func (o *OutboxEvent) GetIdentifier() string {
+	return strconv.Itoa(o.ID)
+}
+
+func (o OutboxEvent) ToEvent() Event {
+	return Event{
+		ID:             strconv.Itoa(o.ID),
+		IdempotencyKey: o.IdempotencyKey,
+		Type:           o.Type,
+		OccurredAt:     o.OccurredAt,
+		RequestID:      o.RequestID,
+		Data:           o.Data,
+	}
+}
+
+type outboxFilter struct {
+	field   string
+	Value   interface{}
+	Operand string
+}
+
+func (f *outboxFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *outboxFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *outboxFilter) GetOperand() string {
+	return f.Operand
+}
+
+func NewIDsFilter(ids []int) database.Filter {
+	return &outboxFilter{
+		field:   "id",
+		Value:   ids,
+		Operand: "IN",
+	}
+}
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/internal/logger"
+	"time"
+)
+
+// RelayOutbox publishes the events committed to the outbox as soon as their transaction is over,
+// and every interval in case some were left, e.g. by a previous run stopped in between, until ctx
+// is cancelled. A relay under way is finished first, so waiting for it to return never leaves
+// events removed from the outbox without being handed to the subscribers.
+func RelayOutbox(ctx context.Context, b *Bus, l logger.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// not cancelled halfway, the events removed would not reach the subscribers
+	relayCtx := context.WithoutCancel(ctx)
+	relay := func() {
+		if _, err := b.Relay(relayCtx); err != nil {
+			l.WithError(err).Error(relayCtx, "error relaying outbox events")
+		}
+	}
+
+	relay()
+	for {
+		select {
+		case <-ctx.Done():
+			l.Info(context.Background(), "Outbox relay stopped")
+			return
+		case <-b.pending:
+			relay()
+		case <-ticker.C:
+			relay()
+		}
+	}
+}
+
+// RelayListener is called within the transaction removing the events relayed from the outbox,
+// oldest first, e.g. to store what's still to be done with them. Returning an error leaves them
+// in the outbox, to be relayed again.
+type RelayListener func(ctx context.Context, relayed []Event) error
+
+// AddRelayListener registers a listener for the events relayed from now on. Not safe to call
+// concurrently with Relay, listeners are meant to be added on start up.
+func (b *Bus) AddRelayListener(listener RelayListener) {
+	b.listeners = append(b.listeners, listener)
+}
+
+// Relay removes the events pending in the outbox, along with what the listeners store for them,
+// then hands them to the subscribers oldest first, a page at a time. It returns how many were
+// relayed, those of the pages before one failing included. An event is handed to the listeners
+// again with the same ID and idempotency key when removing it fails, while subscribers in memory
+// only get the ones removed, so they can miss those of a process stopped in between. Once the
+// bus is closed nothing is relayed and the events are left for the next run.
+func (b *Bus) Relay(ctx context.Context) (int, error) {
+	b.relaying.Lock()
+	defer b.relaying.Unlock()
+	if b.isClosed() {
+		return 0, nil
+	}
+
+	total := 0
+	for {
+		relayed, err := b.relayPage(ctx)
+		total += relayed
+		if err != nil || relayed < database.MaxInValues || b.isClosed() {
+			return total, err
+		}
+	}
+}
+
+// relayPage relays the oldest events of the outbox, at most database.MaxInValues of them so
+// they're removed with a single IN filter however many were left, e.g. by a long downtime
+func (b *Bus) relayPage(ctx context.Context) (int, error) {
+	var pending []OutboxEvent
+	page := database.Page{OrderBy: "id", Limit: database.MaxInValues}
+	if err := b.db.GetPage(ctx, &pending, page); err != nil {
+		b.logger.WithError(err).Error(ctx, "error getting outbox events")
+		return 0, apierror.InternalServerError("error getting outbox events")
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	relayed := make([]Event, 0, len(pending))
+	ids := make([]int, 0, len(pending))
+	for _, entry := range pending {
+		relayed = append(relayed, entry.ToEvent())
+		ids = append(ids, entry.ID)
+	}
+
+	err := b.db.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, listener := range b.listeners {
+			if err := listener(ctx, relayed); err != nil {
+				b.logger.WithField("events", ids).WithError(err).Error(ctx, "error handing relayed events to a listener")
+				return err
+			}
+		}
+		removed, err := b.db.Delete(ctx, &OutboxEvent{}, NewIDsFilter(ids))
+		if err != nil {
+			b.logger.WithField("events", ids).WithError(err).Error(ctx, "error removing relayed events from the outbox")
+			return apierror.InternalServerError("error removing relayed events from the outbox")
+		}
+		if removed != int64(len(ids)) {
+			// relayed meanwhile by another process on the same database
+			b.logger.WithField("events", ids).Warn(ctx, "Outbox events relayed by another process")
+			return apierror.Conflict("outbox events relayed by another process")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range relayed {
+		if !b.send(event) {
+			break
+		}
+	}
+	return len(relayed), nil
+}
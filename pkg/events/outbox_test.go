@@ -0,0 +1,191 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"mytheresa/internal/database"
+	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/events"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRelay_LeavesEventsOnceClosed(t *testing.T) {
+	db := sqlitetest.NewDB(t, &events.OutboxEvent{})
+	b := events.NewBus(db, &loggermocks.NoopLogger{}, time.Now)
+	assert.NoError(t, b.Publish(context.Background(), events.ProductCreated, "before restart"))
+
+	b.Close()
+	relayed, err := b.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, relayed)
+
+	// the next run publishes them, with the same ID and idempotency key
+	next := events.NewBus(db, &loggermocks.NoopLogger{}, time.Now)
+	s, _ := next.Subscribe(nil, "")
+	relayed, err = next.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+	got := received(s)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "1", got[0].ID)
+
+	// and no more
+	relayed, err = next.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, relayed)
+}
+
+func TestRelay_ErrorRemovingLeavesEvents(t *testing.T) {
+	pending := []events.OutboxEvent{{ID: 1, IdempotencyKey: "a", Type: events.ProductCreated}, {ID: 2, IdempotencyKey: "b", Type: events.PriceChanged}}
+	dbmock := dbmocks.Database{}
+	// oldest first
+	page := database.Page{OrderBy: "id", Limit: database.MaxInValues}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, page, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]events.OutboxEvent) = pending
+	}).Return(nil)
+	dbmock.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(int64(0), errors.New("some DB error")).Once()
+	dbmock.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(int64(2), nil)
+	b := events.NewBus(&dbmock, &loggermocks.NoopLogger{}, time.Now)
+	s, _ := b.Subscribe(nil, "")
+
+	relayed, err := b.Relay(context.Background())
+	assert.EqualError(t, err, "error removing relayed events from the outbox")
+	assert.Zero(t, relayed)
+	assert.Empty(t, received(s))
+
+	relayed, err = b.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+	got := received(s)
+	assert.Len(t, got, 2)
+	assert.Equal(t, "a", got[0].IdempotencyKey)
+}
+
+func TestRelay_RelayedByAnotherProcess(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]events.OutboxEvent) = []events.OutboxEvent{{ID: 1}, {ID: 2}}
+	}).Return(nil)
+	// the other one removed the first event already
+	dbmock.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil)
+	b := events.NewBus(&dbmock, &loggermocks.NoopLogger{}, time.Now)
+	s, _ := b.Subscribe(nil, "")
+
+	relayed, err := b.Relay(context.Background())
+
+	assert.EqualError(t, err, "outbox events relayed by another process")
+	assert.Zero(t, relayed)
+	assert.Empty(t, received(s))
+}
+
+func TestRelay_Listeners(t *testing.T) {
+	db := sqlitetest.NewDB(t, &events.OutboxEvent{})
+	b := events.NewBus(db, &loggermocks.NoopLogger{}, time.Now)
+	var handed [][]events.Event
+	fail := true
+	b.AddRelayListener(func(ctx context.Context, relayed []events.Event) error {
+		handed = append(handed, relayed)
+		if fail {
+			fail = false
+			return errors.New("some listener error")
+		}
+		return nil
+	})
+	s, _ := b.Subscribe(nil, "")
+	ctx := context.Background()
+	assert.NoError(t, b.Publish(ctx, events.ProductCreated, "first"))
+	assert.NoError(t, b.Publish(ctx, events.PriceChanged, "second"))
+
+	// left in the outbox when the listener fails
+	relayed, err := b.Relay(ctx)
+	assert.EqualError(t, err, "some listener error")
+	assert.Zero(t, relayed)
+	assert.Empty(t, received(s))
+
+	relayed, err = b.Relay(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+	assert.Len(t, received(s), 2)
+	// the same events both times, oldest first
+	assert.Len(t, handed, 2)
+	assert.Equal(t, handed[0], handed[1])
+	assert.Equal(t, []string{"1", "2"}, []string{handed[1][0].ID, handed[1][1].ID})
+
+	relayed, err = b.Relay(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, relayed)
+	assert.Len(t, handed, 2)
+}
+
+func TestRelay_InPages(t *testing.T) {
+	db := sqlitetest.NewDB(t, &events.OutboxEvent{})
+	b := events.NewBus(db, &loggermocks.NoopLogger{}, time.Now)
+	var pages [][]events.Event
+	b.AddRelayListener(func(ctx context.Context, relayed []events.Event) error {
+		pages = append(pages, relayed)
+		return nil
+	})
+	// left by a long downtime, more than a single IN filter takes
+	pending := 2*database.MaxInValues + 500
+	ctx := context.Background()
+	assert.NoError(t, db.WithTransaction(ctx, func(ctx context.Context) error {
+		for i := 0; i < pending; i++ {
+			if err := b.Publish(ctx, events.ProductUpdated, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	relayed, err := b.Relay(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, pending, relayed)
+	assert.Len(t, pages, 3)
+	assert.Len(t, pages[2], 500)
+	id := 0
+	for _, page := range pages {
+		assert.LessOrEqual(t, len(page), database.MaxInValues)
+		for _, e := range page {
+			id++
+			assert.Equal(t, strconv.Itoa(id), e.ID)
+		}
+	}
+
+	left, err := db.Count(ctx, &events.OutboxEvent{})
+	assert.NoError(t, err)
+	assert.Zero(t, left)
+}
+
+func TestRelayOutbox(t *testing.T) {
+	b := newBus(t)
+	s, _ := b.Subscribe(nil, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		events.RelayOutbox(ctx, b, &loggermocks.NoopLogger{}, time.Hour)
+		close(done)
+	}()
+
+	// relayed as soon as committed, long before the interval
+	assert.NoError(t, b.Publish(context.Background(), events.ProductCreated, "first"))
+	select {
+	case e := <-s.Events():
+		assert.Equal(t, "1", e.ID)
+	case <-time.After(time.Second):
+		t.Fatal("event not relayed once committed")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay didn't stop after cancelling the context")
+	}
+}
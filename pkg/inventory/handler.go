@@ -0,0 +1,182 @@
+package inventory
+
+import (
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/response"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler interface {
+	GetStock(w http.ResponseWriter, r *http.Request)
+	AdjustStock(w http.ResponseWriter, r *http.Request)
+	Reserve(w http.ResponseWriter, r *http.Request)
+	ConfirmReservation(w http.ResponseWriter, r *http.Request)
+	ReleaseReservation(w http.ResponseWriter, r *http.Request)
+}
+
+type handler struct {
+	service Service
+	logger  logger.Logger
+}
+
+func NewHandler(service Service, logger logger.Logger) Handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetStock godoc
+// @Summary Get the stock of a SKU
+// @Description Get the units available and reserved for a product or variant SKU
+// @Produce  json
+// @Param sku path string true "Product or variant SKU"
+// @Success 200 {object} StockResponse
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Router /v1/inventory/{sku} [get]
+func (h *handler) GetStock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sku := mux.Vars(r)["sku"]
+
+	stock, err := h.service.GetStock(ctx, sku)
+	if err != nil {
+		h.logger.WithField("sku", sku).WithError(err).Error(ctx, "Error getting stock")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, stock.ToStockResponse())
+}
+
+// AdjustStock godoc
+// @Summary Adjust the stock of a SKU
+// @Description Add or remove units from the stock of a product or variant SKU
+// @Accept  json
+// @Produce  json
+// @Param sku path string true "Product or variant SKU"
+// @Param adjustment body AdjustStockRequest true "Stock adjustment"
+// @Success 200 {object} StockResponse
+// @Failure 400 {object} apierror.ApiError "Wrong body"
+// @Failure 409 {object} apierror.ApiError "Not enough stock"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/inventory/{sku}/adjust [post]
+func (h *handler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sku := mux.Vars(r)["sku"]
+
+	var adjustment AdjustStockRequest
+	err := json.NewDecoder(r.Body).Decode(&adjustment)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error decoding request while trying to adjust stock")
+		response.RespondWithError(w, apierror.BadRequest("Wrong body"))
+		return
+	}
+
+	stock, err := h.service.AdjustStock(ctx, sku, adjustment.Delta)
+	if err != nil {
+		h.logger.WithField("sku", sku).WithError(err).Error(ctx, "Error adjusting stock")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, stock.ToStockResponse())
+}
+
+// Reserve godoc
+// @Summary Reserve stock
+// @Description Hold units of a SKU until the reservation is confirmed, released or expires
+// @Accept  json
+// @Produce  json
+// @Param reservation body ReservationRequest true "Reservation details"
+// @Success 201 {object} ReservationResponse
+// @Failure 400 {object} apierror.ApiError "Wrong body"
+// @Failure 409 {object} apierror.ApiError "Not enough stock"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/reservation [post]
+func (h *handler) Reserve(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var reservation ReservationRequest
+	err := json.NewDecoder(r.Body).Decode(&reservation)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error decoding request while trying to reserve stock")
+		response.RespondWithError(w, apierror.BadRequest("Wrong body"))
+		return
+	}
+
+	res, err := h.service.Reserve(ctx, reservation)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error reserving stock")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusCreated, res.ToReservationResponse())
+}
+
+// ConfirmReservation godoc
+// @Summary Confirm a reservation
+// @Description Turn the reserved units into a sale
+// @Produce  json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} ReservationResponse
+// @Failure 404 {object} apierror.ApiError "Reservation not found"
+// @Failure 409 {object} apierror.ApiError "Reservation expired"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/reservation/{id}/confirm [post]
+func (h *handler) ConfirmReservation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+
+	res, err := h.service.ConfirmReservation(ctx, id)
+	if err != nil {
+		h.logger.WithField("id", id).WithError(err).Error(ctx, "Error confirming reservation")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, res.ToReservationResponse())
+}
+
+// ReleaseReservation godoc
+// @Summary Release a reservation
+// @Description Cancel a reservation, returning its units to the stock
+// @Produce  json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} ReservationResponse
+// @Failure 404 {object} apierror.ApiError "Reservation not found"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/reservation/{id} [delete]
+func (h *handler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+
+	res, err := h.service.ReleaseReservation(ctx, id)
+	if err != nil {
+		h.logger.WithField("id", id).WithError(err).Error(ctx, "Error releasing reservation")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, res.ToReservationResponse())
+}
@@ -0,0 +1,126 @@
+package inventory_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/inventory"
+	inventorymocks "mytheresa/pkg/inventory/mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewHandler(t *testing.T) {
+	h := inventory.NewHandler(&inventorymocks.Service{}, &loggermocks.NoopLogger{})
+
+	assert.NotNil(t, h)
+}
+
+func TestHandlerGetStock_OK(t *testing.T) {
+	is := inventorymocks.Service{}
+	is.On("GetStock", mock.Anything, "000001").Return(inventory.StockLevel{SKU: "000001", Available: 4, Reserved: 1}, nil)
+
+	h := inventory.NewHandler(&is, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodGet, "/inventory/000001", nil)
+	r = mux.SetURLVars(r, map[string]string{"sku": "000001"})
+	w := httptest.NewRecorder()
+
+	h.GetStock(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response inventory.StockResponse
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, response.Available)
+	assert.Equal(t, 1, response.Reserved)
+	assert.True(t, response.InStock)
+}
+
+func TestHandlerAdjustStock_NotEnoughStock(t *testing.T) {
+	is := inventorymocks.Service{}
+	is.On("AdjustStock", mock.Anything, "000001", -5).Return(inventory.StockLevel{}, apierror.Conflict("Not enough stock for 000001"))
+
+	h := inventory.NewHandler(&is, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/inventory/000001/adjust", bytes.NewReader([]byte(`{"delta":-5}`)))
+	r = mux.SetURLVars(r, map[string]string{"sku": "000001"})
+	w := httptest.NewRecorder()
+
+	h.AdjustStock(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestHandlerAdjustStock_WrongBody(t *testing.T) {
+	h := inventory.NewHandler(&inventorymocks.Service{}, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/inventory/000001/adjust", bytes.NewReader([]byte("invalid body")))
+	w := httptest.NewRecorder()
+
+	h.AdjustStock(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerReserve_OK(t *testing.T) {
+	req := inventory.ReservationRequest{SKU: "000001", Quantity: 2}
+	is := inventorymocks.Service{}
+	is.On("Reserve", mock.Anything, req).Return(inventory.Reservation{
+		ID: "r1", SKU: "000001", Quantity: 2, ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+
+	h := inventory.NewHandler(&is, &loggermocks.NoopLogger{})
+
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/reservation", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	h.Reserve(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response inventory.ReservationResponse
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", response.ID)
+	assert.Equal(t, 2, response.Quantity)
+}
+
+func TestHandlerConfirmReservation_Expired(t *testing.T) {
+	is := inventorymocks.Service{}
+	is.On("ConfirmReservation", mock.Anything, "r1").Return(inventory.Reservation{}, apierror.Conflict("Reservation has expired"))
+
+	h := inventory.NewHandler(&is, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/reservation/r1/confirm", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "r1"})
+	w := httptest.NewRecorder()
+
+	h.ConfirmReservation(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestHandlerReleaseReservation_NotFound(t *testing.T) {
+	is := inventorymocks.Service{}
+	is.On("ReleaseReservation", mock.Anything, "r1").Return(inventory.Reservation{}, apierror.NotFound("Reservation not found"))
+
+	h := inventory.NewHandler(&is, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodDelete, "/reservation/r1", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "r1"})
+	w := httptest.NewRecorder()
+
+	h.ReleaseReservation(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
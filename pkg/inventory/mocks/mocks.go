@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"context"
+	"mytheresa/pkg/inventory"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Service struct {
+	mock.Mock
+}
+
+func (s *Service) GetStock(ctx context.Context, sku string) (inventory.StockLevel, error) {
+	args := s.Called(ctx, sku)
+	return args.Get(0).(inventory.StockLevel), args.Error(1)
+}
+
+func (s *Service) GetStocks(ctx context.Context, skus []string) (map[string]inventory.StockLevel, error) {
+	args := s.Called(ctx, skus)
+	return args.Get(0).(map[string]inventory.StockLevel), args.Error(1)
+}
+
+func (s *Service) AdjustStock(ctx context.Context, sku string, delta int) (inventory.StockLevel, error) {
+	args := s.Called(ctx, sku, delta)
+	return args.Get(0).(inventory.StockLevel), args.Error(1)
+}
+
+func (s *Service) Reserve(ctx context.Context, r inventory.ReservationRequest) (inventory.Reservation, error) {
+	args := s.Called(ctx, r)
+	return args.Get(0).(inventory.Reservation), args.Error(1)
+}
+
+func (s *Service) ConfirmReservation(ctx context.Context, id string) (inventory.Reservation, error) {
+	args := s.Called(ctx, id)
+	return args.Get(0).(inventory.Reservation), args.Error(1)
+}
+
+func (s *Service) ReleaseReservation(ctx context.Context, id string) (inventory.Reservation, error) {
+	args := s.Called(ctx, id)
+	return args.Get(0).(inventory.Reservation), args.Error(1)
+}
+
+func (s *Service) ReleaseExpired(ctx context.Context, now time.Time) (int, error) {
+	args := s.Called(ctx, now)
+	return args.Int(0), args.Error(1)
+}
@@ -0,0 +1,176 @@
+package inventory
+
+import (
+	"mytheresa/internal/database"
+	"time"
+)
+
+const (
+	DefaultReservationTTL = 15 * time.Minute
+	MaxReservationTTL     = 24 * time.Hour
+)
+
+// StockLevel holds the units of a SKU (product or variant) available for sale.
+// Reserved units are not part of Available until their reservation is released.
+type StockLevel struct {
+	SKU       string `gorm:"primaryKey" json:"sku"`
+	Available int    `gorm:"not null;default:0" json:"available"`
+	Reserved  int    `gorm:"-" json:"reserved"`
+	// UpdatedAt is the last change of the units available, zero for SKUs never stocked
+	UpdatedAt time.Time `json:"-"`
+}
+
+// StockChange is the data of the product.updated events, published when the units available of
+// a SKU change
+type StockChange struct {
+	SKU       string `json:"sku"`
+	Available int    `json:"available"`
+}
+
+// Reservation holds units of a SKU for a while, e.g. during checkout
+type Reservation struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	SKU       string    `gorm:"not null;index" json:"sku"`
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// AdjustStockRequest represents the body for adjusting the stock of a SKU
+// @Description AdjustStockRequest adds (positive delta) or removes (negative delta) units from the stock
+// @Accept json
+// @Produce json
+// @Param adjustment body AdjustStockRequest true "Stock adjustment"
+type AdjustStockRequest struct {
+	Delta int `json:"delta" example:"5"`
+}
+
+// ReservationRequest represents the body for reserving stock
+// @Description ReservationRequest is the input for reserving units of a SKU. TTL defaults to 15 minutes
+// @Accept json
+// @Produce json
+// @Param reservation body ReservationRequest true "Reservation details"
+type ReservationRequest struct {
+	SKU        string `json:"sku" example:"000005-42-WHT"`
+	Quantity   int    `json:"quantity" example:"1"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty" example:"900"`
+}
+
+// StockResponse represents the stock of a SKU
+// @Description StockResponse includes the units available for sale and the ones held by reservations
+// @Accept json
+// @Produce json
+// @Success 200 {object} StockResponse
+type StockResponse struct {
+	SKU       string `json:"sku" example:"000005-42-WHT"`
+	Available int    `json:"available" example:"4"`
+	Reserved  int    `json:"reserved" example:"1"`
+	InStock   bool   `json:"in_stock" example:"true"`
+}
+
+// ReservationResponse represents a stock reservation
+// @Description ReservationResponse is the output when reserving stock
+// @Accept json
+// @Produce json
+// @Success 200 {object} ReservationResponse
+type ReservationResponse struct {
+	ID        string    `json:"id" example:"5b1f6c1e-3c8e-4a47-9a8e-1c2d3e4f5a6b"`
+	SKU       string    `json:"sku" example:"000005-42-WHT"`
+	Quantity  int       `json:"quantity" example:"1"`
+	ExpiresAt time.Time `json:"expires_at" example:"2030-01-01T00:15:00Z"`
+}
+
+func (s *StockLevel) ToStockResponse() StockResponse {
+	return StockResponse{
+		SKU:       s.SKU,
+		Available: s.Available,
+		Reserved:  s.Reserved,
+		InStock:   s.Available > 0,
+	}
+}
+
+func (s *StockLevel) GetIdentifier() string {
+	return s.SKU
+}
+
+func (r *ReservationRequest) TTL() time.Duration {
+	if r.TTLSeconds <= 0 {
+		return DefaultReservationTTL
+	}
+	return time.Duration(r.TTLSeconds) * time.Second
+}
+
+func (r *Reservation) ToReservationResponse() ReservationResponse {
+	return ReservationResponse{
+		ID:        r.ID,
+		SKU:       r.SKU,
+		Quantity:  r.Quantity,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
+func (r *Reservation) GetIdentifier() string {
+	return r.ID
+}
+
+// IsExpired tells if the reservation doesn't hold its units anymore at the given time
+func (r *Reservation) IsExpired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
+
+type inventoryFilter struct {
+	field   string
+	Value   interface{}
+	Operand string
+}
+
+func (f *inventoryFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *inventoryFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *inventoryFilter) GetOperand() string {
+	return f.Operand
+}
+
+func NewSKUFilter(sku string) database.Filter {
+	return &inventoryFilter{
+		field:   "sku",
+		Value:   sku,
+		Operand: "=",
+	}
+}
+
+func NewSKUsFilter(skus []string) database.Filter {
+	return &inventoryFilter{
+		field:   "sku",
+		Value:   skus,
+		Operand: "IN",
+	}
+}
+
+func NewAvailableFilter(value int, operand string) database.Filter {
+	return &inventoryFilter{
+		field:   "available",
+		Value:   value,
+		Operand: operand,
+	}
+}
+
+func NewReservationIDFilter(id string) database.Filter {
+	return &inventoryFilter{
+		field:   "id",
+		Value:   id,
+		Operand: "=",
+	}
+}
+
+func NewExpiresAtFilter(value time.Time, operand string) database.Filter {
+	return &inventoryFilter{
+		field:   "expires_at",
+		Value:   value.UTC(),
+		Operand: operand,
+	}
+}
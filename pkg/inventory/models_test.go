@@ -0,0 +1,55 @@
+package inventory_test
+
+import (
+	"mytheresa/pkg/inventory"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStockLevel_ToStockResponse(t *testing.T) {
+	stock := inventory.StockLevel{SKU: "000005-42-WHT", Available: 4, Reserved: 1}
+
+	response := stock.ToStockResponse()
+
+	assert.Equal(t, "000005-42-WHT", response.SKU)
+	assert.Equal(t, 4, response.Available)
+	assert.Equal(t, 1, response.Reserved)
+	assert.True(t, response.InStock)
+
+	empty := inventory.StockLevel{SKU: "000005-42-WHT", Reserved: 2}
+	assert.False(t, empty.ToStockResponse().InStock)
+}
+
+func TestReservationRequest_TTL(t *testing.T) {
+	assert.Equal(t, inventory.DefaultReservationTTL, (&inventory.ReservationRequest{}).TTL())
+	assert.Equal(t, inventory.DefaultReservationTTL, (&inventory.ReservationRequest{TTLSeconds: -5}).TTL())
+	assert.Equal(t, time.Minute, (&inventory.ReservationRequest{TTLSeconds: 60}).TTL())
+}
+
+func TestReservation_IsExpired(t *testing.T) {
+	now := time.Now()
+	r := inventory.Reservation{ExpiresAt: now}
+
+	assert.True(t, r.IsExpired(now))
+	assert.True(t, r.IsExpired(now.Add(time.Second)))
+	assert.False(t, r.IsExpired(now.Add(-time.Second)))
+}
+
+func TestNewSKUsFilter(t *testing.T) {
+	filter := inventory.NewSKUsFilter([]string{"000001", "000002"})
+
+	assert.Equal(t, "sku", filter.GetColumnName())
+	assert.Equal(t, []string{"000001", "000002"}, filter.GetValue())
+	assert.Equal(t, "IN", filter.GetOperand())
+}
+
+func TestNewExpiresAtFilter(t *testing.T) {
+	now := time.Date(2030, 1, 1, 1, 0, 0, 0, time.FixedZone("CET", 3600))
+	filter := inventory.NewExpiresAtFilter(now, "<=")
+
+	assert.Equal(t, "expires_at", filter.GetColumnName())
+	assert.Equal(t, now.UTC(), filter.GetValue())
+	assert.Equal(t, "<=", filter.GetOperand())
+}
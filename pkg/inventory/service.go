@@ -0,0 +1,309 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/events"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	GetStock(ctx context.Context, sku string) (StockLevel, error)
+	GetStocks(ctx context.Context, skus []string) (map[string]StockLevel, error)
+	AdjustStock(ctx context.Context, sku string, delta int) (StockLevel, error)
+	Reserve(ctx context.Context, reservation ReservationRequest) (Reservation, error)
+	ConfirmReservation(ctx context.Context, id string) (Reservation, error)
+	ReleaseReservation(ctx context.Context, id string) (Reservation, error)
+	ReleaseExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+type service struct {
+	db     database.Database
+	logger logger.Logger
+	events events.Publisher
+}
+
+func NewService(db database.Database, logger logger.Logger, ep events.Publisher) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+		events: ep,
+	}
+}
+
+// GetStock returns the stock of a SKU, including the units held by active reservations.
+// SKUs that were never stocked have no units available.
+func (s *service) GetStock(ctx context.Context, sku string) (StockLevel, error) {
+	ctx, span := tracing.Start(ctx, "inventory.GetStock")
+	defer span.End()
+
+	stocks, err := s.GetStocks(ctx, []string{sku})
+	if err != nil {
+		return StockLevel{}, err
+	}
+	stock := stocks[sku]
+
+	var reservations []Reservation
+	err = s.db.GetWithFilters(ctx, &reservations, NewSKUFilter(sku))
+	if err != nil {
+		s.logger.WithField("sku", sku).WithError(err).Error(ctx, "error getting reservations")
+		return StockLevel{}, apierror.InternalServerError("error getting stock")
+	}
+	for _, r := range reservations {
+		stock.Reserved += r.Quantity
+	}
+
+	return stock, nil
+}
+
+// GetStocks returns the available units of every given SKU, without looking at reservations.
+// The SKUs are looked up in chunks, so there can be any number of them.
+func (s *service) GetStocks(ctx context.Context, skus []string) (map[string]StockLevel, error) {
+	ctx, span := tracing.Start(ctx, "inventory.GetStocks")
+	defer span.End()
+
+	var levels []StockLevel
+	for _, chunk := range database.Chunk(skus, database.MaxInValues) {
+		var found []StockLevel
+		err := s.db.GetWithFilters(ctx, &found, NewSKUsFilter(chunk))
+		if err != nil {
+			s.logger.WithError(err).Error(ctx, "error getting stock")
+			return nil, apierror.InternalServerError("error getting stock")
+		}
+		levels = append(levels, found...)
+	}
+
+	stocks := map[string]StockLevel{}
+	for _, sku := range skus {
+		stocks[sku] = StockLevel{SKU: sku}
+	}
+	for _, l := range levels {
+		stocks[l.SKU] = l
+	}
+
+	return stocks, nil
+}
+
+// AdjustStock adds delta units to the stock of a SKU. Removing more units than available fails,
+// so the stock never goes below zero even with concurrent adjustments.
+func (s *service) AdjustStock(ctx context.Context, sku string, delta int) (StockLevel, error) {
+	ctx, span := tracing.Start(ctx, "inventory.AdjustStock")
+	defer span.End()
+
+	if delta == 0 {
+		return StockLevel{}, apierror.BadRequest("delta can't be 0")
+	}
+
+	if delta > 0 {
+		if err := s.addStock(ctx, sku, delta); err != nil {
+			return StockLevel{}, err
+		}
+	} else if err := s.takeStock(ctx, sku, -delta); err != nil {
+		return StockLevel{}, err
+	}
+
+	s.logger.WithField("sku", sku).WithField("delta", delta).Info(ctx, "stock adjusted")
+	return s.GetStock(ctx, sku)
+}
+
+// Reserve takes units out of the available stock until the reservation is confirmed, released or expires
+func (s *service) Reserve(ctx context.Context, req ReservationRequest) (Reservation, error) {
+	ctx, span := tracing.Start(ctx, "inventory.Reserve")
+	defer span.End()
+
+	if req.SKU == "" {
+		return Reservation{}, apierror.BadRequest("sku is required")
+	}
+	if req.Quantity <= 0 {
+		return Reservation{}, apierror.BadRequest("quantity must be greater than 0")
+	}
+	if req.TTL() > MaxReservationTTL {
+		return Reservation{}, apierror.BadRequest(fmt.Sprintf("ttl_seconds can't be greater than %d", int(MaxReservationTTL.Seconds())))
+	}
+
+	reservation := Reservation{
+		ID:        uuid.New().String(),
+		SKU:       req.SKU,
+		Quantity:  req.Quantity,
+		ExpiresAt: time.Now().Add(req.TTL()).UTC(),
+	}
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.takeStock(ctx, req.SKU, req.Quantity); err != nil {
+			return err
+		}
+
+		err := s.db.Save(ctx, reservation.GetIdentifier(), &reservation)
+		if err != nil {
+			s.logger.WithField("sku", req.SKU).WithError(err).Error(ctx, "error saving reservation")
+			return apierror.InternalServerError("error reserving stock")
+		}
+		return nil
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// ConfirmReservation turns the reserved units into a sale, they won't go back to the stock
+func (s *service) ConfirmReservation(ctx context.Context, id string) (Reservation, error) {
+	ctx, span := tracing.Start(ctx, "inventory.ConfirmReservation")
+	defer span.End()
+
+	reservation, err := s.getReservation(ctx, id)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	if reservation.IsExpired(time.Now()) {
+		if _, err := s.release(ctx, reservation); err != nil {
+			return Reservation{}, err
+		}
+		return Reservation{}, apierror.Conflict("Reservation has expired")
+	}
+
+	affected, err := s.db.Delete(ctx, &Reservation{}, NewReservationIDFilter(id))
+	if err != nil {
+		s.logger.WithField("id", id).WithError(err).Error(ctx, "error confirming reservation")
+		return Reservation{}, apierror.InternalServerError("error confirming reservation")
+	}
+	if affected == 0 {
+		return Reservation{}, apierror.NotFound("Reservation not found")
+	}
+
+	return reservation, nil
+}
+
+// ReleaseReservation cancels a reservation, returning its units to the stock
+func (s *service) ReleaseReservation(ctx context.Context, id string) (Reservation, error) {
+	ctx, span := tracing.Start(ctx, "inventory.ReleaseReservation")
+	defer span.End()
+
+	reservation, err := s.getReservation(ctx, id)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	released, err := s.release(ctx, reservation)
+	if err != nil {
+		return Reservation{}, err
+	}
+	if !released {
+		return Reservation{}, apierror.NotFound("Reservation not found")
+	}
+
+	return reservation, nil
+}
+
+// ReleaseExpired releases every reservation expired at the given time and returns how many were released
+func (s *service) ReleaseExpired(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "inventory.ReleaseExpired")
+	defer span.End()
+
+	var reservations []Reservation
+	err := s.db.GetWithFilters(ctx, &reservations, NewExpiresAtFilter(now, "<="))
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "error getting expired reservations")
+		return 0, apierror.InternalServerError("error releasing expired reservations")
+	}
+
+	count := 0
+	for _, r := range reservations {
+		released, err := s.release(ctx, r)
+		if err != nil {
+			return count, err
+		}
+		if released {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// release deletes the reservation and returns its units to the stock. Only the caller that
+// actually deletes the reservation returns the units, so they are never returned twice.
+func (s *service) release(ctx context.Context, r Reservation) (bool, error) {
+	released := false
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		affected, err := s.db.Delete(ctx, &Reservation{}, NewReservationIDFilter(r.ID))
+		if err != nil {
+			s.logger.WithField("id", r.ID).WithError(err).Error(ctx, "error releasing reservation")
+			return apierror.InternalServerError("error releasing reservation")
+		}
+		if affected == 0 {
+			return nil
+		}
+
+		released = true
+		return s.addStock(ctx, r.SKU, r.Quantity)
+	})
+	if err != nil {
+		return false, err
+	}
+	return released, nil
+}
+
+func (s *service) getReservation(ctx context.Context, id string) (Reservation, error) {
+	var reservations []Reservation
+	err := s.db.GetWithFilters(ctx, &reservations, NewReservationIDFilter(id))
+	if err != nil {
+		s.logger.WithField("id", id).WithError(err).Error(ctx, "error getting reservation")
+		return Reservation{}, apierror.InternalServerError(fmt.Sprintf("Error getting reservation %s", id))
+	}
+	if len(reservations) == 0 {
+		return Reservation{}, apierror.NotFound("Reservation not found")
+	}
+
+	return reservations[0], nil
+}
+
+func (s *service) addStock(ctx context.Context, sku string, quantity int) error {
+	affected, err := s.db.Increment(ctx, &StockLevel{}, "available", quantity, NewSKUFilter(sku))
+	if err == nil && affected == 0 {
+		// first units of this SKU, a concurrent adjustment may create the row before us
+		level := StockLevel{SKU: sku, Available: quantity}
+		if s.db.Save(ctx, level.GetIdentifier(), &level) == nil {
+			return s.stockChanged(ctx, sku)
+		}
+		affected, err = s.db.Increment(ctx, &StockLevel{}, "available", quantity, NewSKUFilter(sku))
+	}
+	if err != nil || affected == 0 {
+		s.logger.WithField("sku", sku).WithError(err).Error(ctx, "error adding stock")
+		return apierror.InternalServerError("error updating stock")
+	}
+
+	return s.stockChanged(ctx, sku)
+}
+
+func (s *service) takeStock(ctx context.Context, sku string, quantity int) error {
+	affected, err := s.db.Increment(ctx, &StockLevel{}, "available", -quantity,
+		NewSKUFilter(sku),
+		NewAvailableFilter(quantity, ">="),
+	)
+	if err != nil {
+		s.logger.WithField("sku", sku).WithError(err).Error(ctx, "error taking stock")
+		return apierror.InternalServerError("error updating stock")
+	}
+	if affected == 0 {
+		return apierror.Conflict(fmt.Sprintf("Not enough stock for %s", sku))
+	}
+
+	return s.stockChanged(ctx, sku)
+}
+
+// stockChanged publishes the units now available of the SKU
+func (s *service) stockChanged(ctx context.Context, sku string) error {
+	stocks, err := s.GetStocks(ctx, []string{sku})
+	if err != nil {
+		return err
+	}
+	return s.events.Publish(ctx, events.ProductUpdated, StockChange{SKU: sku, Available: stocks[sku].Available})
+}
@@ -0,0 +1,322 @@
+package inventory_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mytheresa/internal/apierror"
+	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/events"
+	eventsmocks "mytheresa/pkg/events/mocks"
+	"mytheresa/pkg/inventory"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewService(t *testing.T) {
+	s := inventory.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher())
+
+	assert.NotNil(t, s)
+}
+
+func TestGetStocks_DefaultsToNoUnits(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		if h, ok := args.Get(1).(*[]inventory.StockLevel); ok {
+			*h = []inventory.StockLevel{{SKU: "000001", Available: 3}}
+		}
+	}).Return(nil)
+
+	s := inventory.NewService(&dbmock, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher())
+
+	stocks, err := s.GetStocks(context.Background(), []string{"000001", "000002"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stocks["000001"].Available)
+	assert.Equal(t, inventory.StockLevel{SKU: "000002"}, stocks["000002"])
+}
+
+func TestGetStocks_ErrorGettingFromDB(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := inventory.NewService(&dbmock, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher())
+
+	_, err := s.GetStocks(context.Background(), []string{"000001"})
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "error getting stock", apierr.Error())
+}
+
+func TestAdjustStock_ZeroDelta(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	s := inventory.NewService(&dbmock, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher())
+
+	_, err := s.AdjustStock(context.Background(), "000001", 0)
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "delta can't be 0", apierr.Error())
+	dbmock.AssertNotCalled(t, "Increment", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReserve_InvalidRequest(t *testing.T) {
+	tests := map[string]inventory.ReservationRequest{
+		"sku is required":                         {Quantity: 1},
+		"quantity must be greater than 0":         {SKU: "000001"},
+		"ttl_seconds can't be greater than 86400": {SKU: "000001", Quantity: 1, TTLSeconds: 86401},
+	}
+
+	for message, req := range tests {
+		t.Run(message, func(t *testing.T) {
+			dbmock := dbmocks.Database{}
+			s := inventory.NewService(&dbmock, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher())
+
+			_, err := s.Reserve(context.Background(), req)
+
+			apierr, ok := err.(*apierror.ApiError)
+			assert.True(t, ok)
+			assert.Equal(t, message, apierr.Error())
+			dbmock.AssertNotCalled(t, "Increment", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestReserve_ErrorSavingRollsBackStock(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Increment", mock.Anything, mock.Anything, "available", -2, mock.Anything).Return(int64(1), nil)
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := inventory.NewService(&dbmock, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher())
+
+	_, err := s.Reserve(context.Background(), inventory.ReservationRequest{SKU: "000001", Quantity: 2})
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "error reserving stock", apierr.Error())
+	// the units taken go back with the rollback, nothing has to add them again
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+	dbmock.AssertNumberOfCalls(t, "Increment", 1)
+}
+
+func newSQLiteService(t *testing.T) inventory.Service {
+	return newSQLiteServiceWithEvents(t, eventsmocks.AnyPublisher())
+}
+
+func newSQLiteServiceWithEvents(t *testing.T, ep *eventsmocks.Publisher) inventory.Service {
+	sqlDB := sqlitetest.NewDB(t, &inventory.StockLevel{}, &inventory.Reservation{})
+	return inventory.NewService(sqlDB, &loggermocks.NoopLogger{}, ep)
+}
+
+func TestGetStocks_MoreSKUsThanBindVariables(t *testing.T) {
+	s := newSQLiteService(t)
+	ctx := context.Background()
+	_, err := s.AdjustStock(ctx, "000001", 3)
+	assert.NoError(t, err)
+
+	// SQLite takes 32766 bind variables at most per statement
+	skus := []string{"000001"}
+	for i := 0; i < 40000; i++ {
+		skus = append(skus, fmt.Sprintf("%06d-X", i))
+	}
+	stocks, err := s.GetStocks(ctx, skus)
+
+	assert.NoError(t, err)
+	assert.Len(t, stocks, len(skus))
+	assert.Equal(t, 3, stocks["000001"].Available)
+}
+
+func TestAdjustStock_UpdatedAt(t *testing.T) {
+	s := newSQLiteService(t)
+	ctx := context.Background()
+
+	added, err := s.AdjustStock(ctx, "000001", 3)
+	assert.NoError(t, err)
+	assert.False(t, added.UpdatedAt.IsZero())
+
+	time.Sleep(10 * time.Millisecond)
+	taken, err := s.AdjustStock(ctx, "000001", -1)
+	assert.NoError(t, err)
+	assert.True(t, taken.UpdatedAt.After(added.UpdatedAt))
+}
+
+func TestAdjustStock_PublishesUnitsAvailable(t *testing.T) {
+	ep := eventsmocks.Publisher{}
+	ep.On("Publish", mock.Anything, events.ProductUpdated, inventory.StockChange{SKU: "000001", Available: 5}).Return(nil).Once()
+	ep.On("Publish", mock.Anything, events.ProductUpdated, inventory.StockChange{SKU: "000001", Available: 3}).Return(nil).Once()
+	s := newSQLiteServiceWithEvents(t, &ep)
+
+	_, err := s.AdjustStock(context.Background(), "000001", 5)
+	assert.NoError(t, err)
+	_, err = s.AdjustStock(context.Background(), "000001", -2)
+	assert.NoError(t, err)
+	// refused, nothing changed
+	_, err = s.AdjustStock(context.Background(), "000001", -4)
+	assert.Error(t, err)
+
+	ep.AssertExpectations(t)
+	ep.AssertNumberOfCalls(t, "Publish", 2)
+}
+
+func TestAdjustStock_NeverBelowZero(t *testing.T) {
+	s := newSQLiteService(t)
+	ctx := context.Background()
+
+	stock, err := s.AdjustStock(ctx, "000001", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stock.Available)
+
+	_, err = s.AdjustStock(ctx, "000001", -4)
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "Not enough stock for 000001", apierr.Error())
+
+	stock, err = s.AdjustStock(ctx, "000001", -3)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stock.Available)
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	s := newSQLiteService(t)
+	ctx := context.Background()
+	_, err := s.AdjustStock(ctx, "000001", 5)
+	assert.NoError(t, err)
+
+	confirmed, err := s.Reserve(ctx, inventory.ReservationRequest{SKU: "000001", Quantity: 2})
+	assert.NoError(t, err)
+	released, err := s.Reserve(ctx, inventory.ReservationRequest{SKU: "000001", Quantity: 1})
+	assert.NoError(t, err)
+
+	stock, err := s.GetStock(ctx, "000001")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stock.Available)
+	assert.Equal(t, 3, stock.Reserved)
+
+	_, err = s.ConfirmReservation(ctx, confirmed.ID)
+	assert.NoError(t, err)
+	_, err = s.ReleaseReservation(ctx, released.ID)
+	assert.NoError(t, err)
+
+	stock, err = s.GetStock(ctx, "000001")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stock.Available)
+	assert.Equal(t, 0, stock.Reserved)
+
+	_, err = s.ReleaseReservation(ctx, released.ID)
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "Reservation not found", apierr.Error())
+}
+
+func TestReleaseExpired(t *testing.T) {
+	s := newSQLiteService(t)
+	ctx := context.Background()
+	_, err := s.AdjustStock(ctx, "000001", 5)
+	assert.NoError(t, err)
+
+	short, err := s.Reserve(ctx, inventory.ReservationRequest{SKU: "000001", Quantity: 2, TTLSeconds: 60})
+	assert.NoError(t, err)
+	_, err = s.Reserve(ctx, inventory.ReservationRequest{SKU: "000001", Quantity: 1, TTLSeconds: 3600})
+	assert.NoError(t, err)
+
+	released, err := s.ReleaseExpired(ctx, time.Now().Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, released)
+
+	stock, err := s.GetStock(ctx, "000001")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, stock.Available)
+	assert.Equal(t, 1, stock.Reserved)
+
+	_, err = s.ConfirmReservation(ctx, short.ID)
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "Reservation not found", apierr.Error())
+}
+
+func TestConfirmReservation_Expired(t *testing.T) {
+	s := newSQLiteService(t)
+	ctx := context.Background()
+	_, err := s.AdjustStock(ctx, "000001", 1)
+	assert.NoError(t, err)
+
+	r, err := s.Reserve(ctx, inventory.ReservationRequest{SKU: "000001", Quantity: 1, TTLSeconds: 1})
+	assert.NoError(t, err)
+	time.Sleep(1100 * time.Millisecond)
+
+	_, err = s.ConfirmReservation(ctx, r.ID)
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "Reservation has expired", apierr.Error())
+
+	stock, err := s.GetStock(ctx, "000001")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stock.Available)
+}
+
+func TestReserve_ConcurrentReservationsNeverOversell(t *testing.T) {
+	s := newSQLiteService(t)
+	ctx := context.Background()
+	_, err := s.AdjustStock(ctx, "000001", 10)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = s.Reserve(ctx, inventory.ReservationRequest{SKU: "000001", Quantity: 1})
+			} else {
+				_, err = s.AdjustStock(ctx, "000001", -1)
+			}
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, reserved)
+
+	stock, err := s.GetStock(ctx, "000001")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stock.Available)
+}
+
+func TestReleaseReservation_ConcurrentReleasesReturnStockOnce(t *testing.T) {
+	s := newSQLiteService(t)
+	ctx := context.Background()
+	_, err := s.AdjustStock(ctx, "000001", 3)
+	assert.NoError(t, err)
+	r, err := s.Reserve(ctx, inventory.ReservationRequest{SKU: "000001", Quantity: 3})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = s.ReleaseReservation(ctx, r.ID)
+		}()
+	}
+	wg.Wait()
+
+	stock, err := s.GetStock(ctx, "000001")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stock.Available)
+}
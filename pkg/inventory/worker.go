@@ -0,0 +1,31 @@
+package inventory
+
+import (
+	"context"
+	"mytheresa/internal/logger"
+	"time"
+)
+
+// ReleaseExpiredReservations returns the units held by expired reservations to the stock
+// every interval. It blocks until the context is cancelled.
+func ReleaseExpiredReservations(ctx context.Context, s Service, l logger.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.Info(context.Background(), "Reservation release worker stopped")
+			return
+		case now := <-ticker.C:
+			released, err := s.ReleaseExpired(ctx, now)
+			if err != nil {
+				l.WithError(err).Error(ctx, "error releasing expired reservations")
+				continue
+			}
+			if released > 0 {
+				l.WithField("released", released).Info(ctx, "expired reservations released")
+			}
+		}
+	}
+}
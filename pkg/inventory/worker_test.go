@@ -0,0 +1,44 @@
+package inventory_test
+
+import (
+	"context"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/inventory"
+	inventorymocks "mytheresa/pkg/inventory/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReleaseExpiredReservations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	called := make(chan struct{}, 1)
+
+	is := inventorymocks.Service{}
+	is.On("ReleaseExpired", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+	}).Return(1, nil)
+
+	done := make(chan struct{})
+	go func() {
+		inventory.ReleaseExpiredReservations(ctx, &is, &loggermocks.NoopLogger{}, time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("expired reservations were never released")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker didn't stop after cancelling the context")
+	}
+}
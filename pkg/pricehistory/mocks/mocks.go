@@ -0,0 +1,22 @@
+package mocks
+
+import (
+	"context"
+	"mytheresa/pkg/pricehistory"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Service struct {
+	mock.Mock
+}
+
+func (s *Service) RecordPrices(ctx context.Context, prices map[string]int) error {
+	args := s.Called(ctx, prices)
+	return args.Error(0)
+}
+
+func (s *Service) GetLowestPrices(ctx context.Context, skus []string) (map[string]pricehistory.LowestPrice, error) {
+	args := s.Called(ctx, skus)
+	return args.Get(0).(map[string]pricehistory.LowestPrice), args.Error(1)
+}
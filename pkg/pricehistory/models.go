@@ -0,0 +1,94 @@
+package pricehistory
+
+import (
+	"mytheresa/internal/database"
+	"time"
+)
+
+// Window is how far back the lowest price of a SKU is looked for
+const Window = 30 * 24 * time.Hour
+
+// Clock tells the current time, injected so the history can be tested at any moment
+type Clock func() time.Time
+
+// CurrentPrice is the final price a SKU has been sold at since a moment
+type CurrentPrice struct {
+	SKU   string    `gorm:"primaryKey"`
+	Price int       `gorm:"not null"`
+	Since time.Time `gorm:"not null"`
+}
+
+// PriceChange is the data of the price.changed events
+type PriceChange struct {
+	SKU string `json:"sku"`
+	// Previous is the price the SKU had before, nil for the first one
+	Previous *int `json:"previous_price"`
+	Price    int  `json:"price"`
+}
+
+// LowestPrice is the lowest final price of a SKU in the window, along with when it last changed:
+// when the current price was set or when the last price left the window, whichever came later
+type LowestPrice struct {
+	Price     int
+	ChangedAt time.Time
+}
+
+// PricePeriod is a final price a SKU was sold at from a moment until it changed
+type PricePeriod struct {
+	ID        int       `gorm:"primaryKey"`
+	SKU       string    `gorm:"not null;index:idx_price_period_sku_valid_to"`
+	Price     int       `gorm:"not null"`
+	ValidFrom time.Time `gorm:"not null"`
+	ValidTo   time.Time `gorm:"not null;index:idx_price_period_sku_valid_to"`
+}
+
+func (c *CurrentPrice) GetIdentifier() string {
+	return c.SKU
+}
+
+func (p *PricePeriod) GetIdentifier() string {
+	return p.SKU
+}
+
+type priceHistoryFilter struct {
+	field   string
+	Value   interface{}
+	Operand string
+}
+
+func (f *priceHistoryFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *priceHistoryFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *priceHistoryFilter) GetOperand() string {
+	return f.Operand
+}
+
+func NewSKUFilter(sku string) database.Filter {
+	return &priceHistoryFilter{
+		field:   "sku",
+		Value:   sku,
+		Operand: "=",
+	}
+}
+
+func NewSKUsFilter(skus []string) database.Filter {
+	return &priceHistoryFilter{
+		field:   "sku",
+		Value:   skus,
+		Operand: "IN",
+	}
+}
+
+// NewEndedAfterFilter keeps the periods still ongoing at the given time
+func NewEndedAfterFilter(value time.Time) database.Filter {
+	return &priceHistoryFilter{
+		field:   "valid_to",
+		Value:   value.UTC(),
+		Operand: ">",
+	}
+}
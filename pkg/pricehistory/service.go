@@ -0,0 +1,166 @@
+package pricehistory
+
+import (
+	"context"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/events"
+	"time"
+)
+
+type Service interface {
+	RecordPrices(ctx context.Context, prices map[string]int) error
+	GetLowestPrices(ctx context.Context, skus []string) (map[string]LowestPrice, error)
+}
+
+type service struct {
+	db     database.Database
+	logger logger.Logger
+	events events.Publisher
+	clock  Clock
+}
+
+func NewService(db database.Database, logger logger.Logger, ep events.Publisher, clock Clock) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+		events: ep,
+		clock:  clock,
+	}
+}
+
+// RecordPrices takes the final price of every given SKU. Only the ones that changed are
+// recorded: their previous price becomes a closed period and the new one the current price,
+// and the change is published.
+func (s *service) RecordPrices(ctx context.Context, prices map[string]int) error {
+	ctx, span := tracing.Start(ctx, "pricehistory.RecordPrices")
+	defer span.End()
+
+	if len(prices) == 0 {
+		return nil
+	}
+
+	skus := make([]string, 0, len(prices))
+	for sku := range prices {
+		skus = append(skus, sku)
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		currents, err := s.getCurrentPrices(ctx, skus)
+		if err != nil {
+			return err
+		}
+
+		now := s.clock().UTC()
+		for sku, price := range prices {
+			current, ok := currents[sku]
+			if ok && current.Price == price {
+				continue
+			}
+
+			change := PriceChange{SKU: sku, Price: price}
+			if ok {
+				previous := current.Price
+				change.Previous = &previous
+			}
+			if err := s.events.Publish(ctx, events.PriceChanged, change); err != nil {
+				return err
+			}
+
+			if ok {
+				period := PricePeriod{SKU: sku, Price: current.Price, ValidFrom: current.Since, ValidTo: now}
+				if err := s.db.Save(ctx, period.GetIdentifier(), &period); err != nil {
+					s.logger.WithField("sku", sku).WithError(err).Error(ctx, "error saving price period")
+					return apierror.InternalServerError("error recording price history")
+				}
+				if _, err := s.db.Delete(ctx, &CurrentPrice{}, NewSKUFilter(sku)); err != nil {
+					s.logger.WithField("sku", sku).WithError(err).Error(ctx, "error replacing current price")
+					return apierror.InternalServerError("error recording price history")
+				}
+			}
+
+			current = CurrentPrice{SKU: sku, Price: price, Since: now}
+			if err := s.db.Save(ctx, current.GetIdentifier(), &current); err != nil {
+				s.logger.WithField("sku", sku).WithError(err).Error(ctx, "error saving current price")
+				return apierror.InternalServerError("error recording price history")
+			}
+		}
+		return nil
+	})
+}
+
+// GetLowestPrices returns the lowest final price of every SKU before its current one, out of
+// the prices that ended in the last 30 days, so a discount starting today is shown against the
+// prices it reduced. SKUs without such prices get their current one, and the ones never priced
+// are left out. The SKUs are looked up a chunk at a time, whatever their number.
+func (s *service) GetLowestPrices(ctx context.Context, skus []string) (map[string]LowestPrice, error) {
+	ctx, span := tracing.Start(ctx, "pricehistory.GetLowestPrices")
+	defer span.End()
+
+	lowest := map[string]LowestPrice{}
+	if len(skus) == 0 {
+		return lowest, nil
+	}
+
+	now := s.clock()
+	since := now.Add(-Window)
+	// the periods of a SKU follow each other, so the one before the first still in the
+	// window left it a window after this one started
+	firstStarted := map[string]time.Time{}
+	for _, chunk := range database.Chunk(skus, database.MaxInValues) {
+		var periods []PricePeriod
+		err := s.db.GetWithFilters(ctx, &periods, NewSKUsFilter(chunk), NewEndedAfterFilter(since))
+		if err != nil {
+			s.logger.WithError(err).Error(ctx, "error getting price periods")
+			return nil, apierror.InternalServerError("error getting price history")
+		}
+		for _, p := range periods {
+			if l, ok := lowest[p.SKU]; !ok || p.Price < l.Price {
+				lowest[p.SKU] = LowestPrice{Price: p.Price}
+			}
+			if started, ok := firstStarted[p.SKU]; !ok || p.ValidFrom.Before(started) {
+				firstStarted[p.SKU] = p.ValidFrom
+			}
+		}
+	}
+
+	currents, err := s.getCurrentPrices(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+	for sku, c := range currents {
+		l, ok := lowest[sku]
+		if !ok {
+			l.Price = c.Price
+		}
+		started, ok := firstStarted[sku]
+		if !ok {
+			started = c.Since
+		}
+		l.ChangedAt = c.Since
+		if left := started.Add(Window); !left.After(now) && left.After(l.ChangedAt) {
+			l.ChangedAt = left
+		}
+		lowest[sku] = l
+	}
+
+	return lowest, nil
+}
+
+func (s *service) getCurrentPrices(ctx context.Context, skus []string) (map[string]CurrentPrice, error) {
+	currents := map[string]CurrentPrice{}
+	for _, chunk := range database.Chunk(skus, database.MaxInValues) {
+		var prices []CurrentPrice
+		err := s.db.GetWithFilters(ctx, &prices, NewSKUsFilter(chunk))
+		if err != nil {
+			s.logger.WithError(err).Error(ctx, "error getting current prices")
+			return nil, apierror.InternalServerError("error getting price history")
+		}
+		for _, p := range prices {
+			currents[p.SKU] = p
+		}
+	}
+	return currents, nil
+}
@@ -0,0 +1,265 @@
+package pricehistory_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/events"
+	eventsmocks "mytheresa/pkg/events/mocks"
+	"mytheresa/pkg/pricehistory"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeClock is a clock only moving forward when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+const day = 24 * time.Hour
+
+func newSQLiteService(t *testing.T) (pricehistory.Service, *fakeClock) {
+	sqlDB := sqlitetest.NewDB(t, &pricehistory.CurrentPrice{}, &pricehistory.PricePeriod{})
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)}
+	return pricehistory.NewService(sqlDB, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher(), clock.Now), clock
+}
+
+// pricesOf leaves out when the lowest prices changed
+func pricesOf(lowest map[string]pricehistory.LowestPrice) map[string]int {
+	prices := map[string]int{}
+	for sku, l := range lowest {
+		prices[sku] = l.Price
+	}
+	return prices
+}
+
+func TestNewService(t *testing.T) {
+	s := pricehistory.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher(), time.Now)
+
+	assert.NotNil(t, s)
+}
+
+func TestGetLowestPrices_NeverPriced(t *testing.T) {
+	s, _ := newSQLiteService(t)
+
+	lowest, err := s.GetLowestPrices(context.Background(), []string{"000001"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, lowest)
+}
+
+func TestGetLowestPrices_CurrentPriceOnly(t *testing.T) {
+	s, clock := newSQLiteService(t)
+	ctx := context.Background()
+
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 89000, "000002": 99000}))
+	clock.Advance(90 * day)
+
+	lowest, err := s.GetLowestPrices(ctx, []string{"000001", "000002", "000003"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"000001": 89000, "000002": 99000}, pricesOf(lowest))
+}
+
+func TestGetLowestPrices_Window(t *testing.T) {
+	s, clock := newSQLiteService(t)
+	ctx := context.Background()
+
+	// 100 -> 60 (old sale) -> 100 -> 80 -> 90 -> 70 (today's discount)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 100}))
+	clock.Advance(10 * day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 60}))
+	clock.Advance(5 * day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 100}))
+	clock.Advance(20 * day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 80}))
+	clock.Advance(5 * day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 90}))
+
+	// the 60 sale ended 30 days ago, a second ago it was still within the window
+	clock.Advance(30*day - 25*day - time.Second)
+	lowest, err := s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 60, lowest["000001"].Price)
+
+	clock.Advance(time.Second)
+	lowest, err = s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 80, lowest["000001"].Price)
+
+	// today's discount is not compared with itself
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 70}))
+	lowest, err = s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 80, lowest["000001"].Price)
+
+	// the price in effect when the window starts counts even if it was set before
+	clock.Advance(28 * day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 95}))
+	clock.Advance(10 * day)
+	lowest, err = s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 70, lowest["000001"].Price)
+}
+
+func TestGetLowestPrices_DiscountStarting(t *testing.T) {
+	s, clock := newSQLiteService(t)
+	ctx := context.Background()
+
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 89000, "000002": 99000}))
+	clock.Advance(40 * day)
+
+	// a 30% discount starts on 000001
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 62300, "000002": 99000}))
+	lowest, err := s.GetLowestPrices(ctx, []string{"000001", "000002"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"000001": 89000, "000002": 99000}, pricesOf(lowest))
+
+	// still shown against the price it reduced until that one is 30 days old
+	clock.Advance(30*day - time.Second)
+	lowest, err = s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 89000, lowest["000001"].Price)
+
+	clock.Advance(time.Second)
+	lowest, err = s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 62300, lowest["000001"].Price)
+}
+
+func TestGetLowestPrices_ChangedAt(t *testing.T) {
+	s, clock := newSQLiteService(t)
+	ctx := context.Background()
+
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 100}))
+	clock.Advance(10 * day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 80}))
+	second := clock.Now()
+	clock.Advance(5 * day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 90}))
+	third := clock.Now()
+
+	lowest, err := s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, third, lowest["000001"].ChangedAt)
+
+	// the 100 period leaves the window 30 days after the 80 one started
+	clock.Advance(30*day - 5*day)
+	lowest, err = s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, second.Add(pricehistory.Window), lowest["000001"].ChangedAt)
+
+	// and so does the 80 one once the 90 one is 30 days old
+	clock.Advance(5 * day)
+	lowest, err = s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 90, lowest["000001"].Price)
+	assert.Equal(t, third.Add(pricehistory.Window), lowest["000001"].ChangedAt)
+}
+
+func TestGetLowestPrices_MoreSKUsThanBindVariables(t *testing.T) {
+	s, clock := newSQLiteService(t)
+	ctx := context.Background()
+
+	// SQLite takes 32766 bind variables at most per statement
+	prices := map[string]int{}
+	for i := 0; i < 40000; i++ {
+		prices[fmt.Sprintf("%06d", i)] = 100
+	}
+	assert.NoError(t, s.RecordPrices(ctx, prices))
+	clock.Advance(day)
+	for sku := range prices {
+		prices[sku] = 80
+	}
+	assert.NoError(t, s.RecordPrices(ctx, prices))
+
+	skus := make([]string, 0, len(prices))
+	for sku := range prices {
+		skus = append(skus, sku)
+	}
+	lowest, err := s.GetLowestPrices(ctx, skus)
+
+	assert.NoError(t, err)
+	assert.Len(t, lowest, len(skus))
+	assert.Equal(t, 100, lowest["039999"].Price)
+}
+
+func TestRecordPrices_UnchangedPricesAreNotRecorded(t *testing.T) {
+	s, clock := newSQLiteService(t)
+	ctx := context.Background()
+
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 100}))
+	clock.Advance(day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 50}))
+	clock.Advance(day)
+	// recording the same price again must not close the 50 period
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 50}))
+	clock.Advance(day)
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 100}))
+
+	// the 50 period lasted two days, so it leaves the window 30 days after it ended
+	clock.Advance(30*day - time.Second)
+	lowest, err := s.GetLowestPrices(ctx, []string{"000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 50, lowest["000001"].Price)
+}
+
+func TestRecordPrices_PublishesChanges(t *testing.T) {
+	sqlDB := sqlitetest.NewDB(t, &pricehistory.CurrentPrice{}, &pricehistory.PricePeriod{})
+
+	previous := 100
+	ep := eventsmocks.Publisher{}
+	ep.On("Publish", mock.Anything, events.PriceChanged, pricehistory.PriceChange{SKU: "000001", Price: 100}).Return(nil).Once()
+	ep.On("Publish", mock.Anything, events.PriceChanged, pricehistory.PriceChange{SKU: "000001", Previous: &previous, Price: 50}).Return(nil).Once()
+	s := pricehistory.NewService(sqlDB, &loggermocks.NoopLogger{}, &ep, time.Now)
+	ctx := context.Background()
+
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 100}))
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 50}))
+	// unchanged, nothing to publish
+	assert.NoError(t, s.RecordPrices(ctx, map[string]int{"000001": 50}))
+
+	ep.AssertExpectations(t)
+	ep.AssertNumberOfCalls(t, "Publish", 2)
+}
+
+func TestRecordPrices_Nothing(t *testing.T) {
+	s := pricehistory.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher(), time.Now)
+
+	assert.NoError(t, s.RecordPrices(context.Background(), map[string]int{}))
+}
+
+func TestRecordPrices_ErrorSaving(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := pricehistory.NewService(&dbmock, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher(), time.Now)
+	err := s.RecordPrices(context.Background(), map[string]int{"000001": 100})
+
+	assert.EqualError(t, err, "error recording price history")
+}
+
+func TestGetLowestPrices_ErrorGettingFromDB(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := pricehistory.NewService(&dbmock, &loggermocks.NoopLogger{}, eventsmocks.AnyPublisher(), time.Now)
+	_, err := s.GetLowestPrices(context.Background(), []string{"000001"})
+
+	assert.EqualError(t, err, "error getting price history")
+}
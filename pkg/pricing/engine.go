@@ -0,0 +1,88 @@
+package pricing
+
+import (
+	"mytheresa/internal/metrics"
+	"mytheresa/pkg/discount"
+)
+
+// PricingEngine prices single items, a product or one of its variants, with the greatest of the
+// discounts applicable to them. Basket level promotions are left to whoever prices the basket.
+type PricingEngine interface {
+	Price(item Item, pc Context) Result
+}
+
+// Item holds the facts about what is priced
+type Item struct {
+	SKU        string
+	CategoryID string
+	// ParentSKU is set for variants, so discounts on their product also apply
+	ParentSKU string
+	// Price is the original price, in cents
+	Price int
+}
+
+// Context holds what the price depends on besides the item. Discounts apply to everyone from
+// their creation on, so the currency is all there is for now.
+type Context struct {
+	Currency string
+}
+
+// Result is the price of an item along with how it was reached
+type Result struct {
+	Original int
+	Final    int
+	Currency string
+	// Applied is the discount giving the final price, nil when none applies
+	Applied *AppliedDiscount
+}
+
+// AppliedDiscount describes the discount taken off a price
+type AppliedDiscount struct {
+	ID         string
+	Kind       string
+	Percentage int
+	// Amount is what the discount takes off the original price, in cents
+	Amount int
+}
+
+type engine struct {
+	index *discount.Index
+}
+
+// NewEngine returns an engine choosing among the discounts, meant to be built once for every
+// item priced with the same discounts
+func NewEngine(discounts []discount.Discount) PricingEngine {
+	return &engine{index: discount.NewIndex(discounts)}
+}
+
+func (e *engine) Price(item Item, pc Context) Result {
+	result := Result{
+		Original: item.Price,
+		Final:    item.Price,
+		Currency: pc.Currency,
+	}
+
+	conditions := discount.DiscountConditions{
+		CategoryID: item.CategoryID,
+		SKU:        item.SKU,
+		ParentSKU:  item.ParentSKU,
+	}
+	// on a tie the discount found first is kept
+	for _, d := range e.index.Candidates(conditions) {
+		applicable := d.IsApplicableFor(conditions)
+		metrics.CountDiscountEvaluation(discount.Kind(d), applicable)
+		if !applicable {
+			continue
+		}
+		if candidate := d.Apply(item.Price); candidate < result.Final {
+			result.Final = candidate
+			result.Applied = &AppliedDiscount{
+				ID:         d.ToDiscountResponse().ID,
+				Kind:       discount.Kind(d),
+				Percentage: d.GetPercentage(),
+				Amount:     item.Price - candidate,
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+package pricing_test
+
+import (
+	"mytheresa/pkg/discount"
+	"mytheresa/pkg/pricing"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDiscount(id, typeID int, target string, percentage int) discount.Discount {
+	return discount.NewDiscount(discount.GeneralDiscount{ID: id, DiscountTypeID: typeID, Target: target, Percentage: percentage})
+}
+
+func TestEnginePrice(t *testing.T) {
+	boots := pricing.Item{SKU: "000001", CategoryID: "1", Price: 10000}
+	variant := pricing.Item{SKU: "000001-42", CategoryID: "1", ParentSKU: "000001", Price: 12000}
+	pc := pricing.Context{Currency: "EUR"}
+
+	tests := []struct {
+		name      string
+		discounts []discount.Discount
+		item      pricing.Item
+		final     int
+		applied   *pricing.AppliedDiscount
+	}{
+		{
+			name:  "no discounts",
+			item:  boots,
+			final: 10000,
+		},
+		{
+			name:      "general",
+			discounts: []discount.Discount{newDiscount(1, discount.GENERAL, "", 10)},
+			item:      boots,
+			final:     9000,
+			applied:   &pricing.AppliedDiscount{ID: "1", Kind: "general", Percentage: 10, Amount: 1000},
+		},
+		{
+			name:      "category",
+			discounts: []discount.Discount{newDiscount(1, discount.CATEGORY, "1", 30)},
+			item:      boots,
+			final:     7000,
+			applied:   &pricing.AppliedDiscount{ID: "1", Kind: "category", Percentage: 30, Amount: 3000},
+		},
+		{
+			name:      "category of another product",
+			discounts: []discount.Discount{newDiscount(1, discount.CATEGORY, "2", 30)},
+			item:      boots,
+			final:     10000,
+		},
+		{
+			name:      "sku",
+			discounts: []discount.Discount{newDiscount(1, discount.SKU, "000001", 15)},
+			item:      boots,
+			final:     8500,
+			applied:   &pricing.AppliedDiscount{ID: "1", Kind: "sku", Percentage: 15, Amount: 1500},
+		},
+		{
+			name:      "sku of another product",
+			discounts: []discount.Discount{newDiscount(1, discount.SKU, "000002", 15)},
+			item:      boots,
+			final:     10000,
+		},
+		{
+			name:      "sku of the parent applies to its variants",
+			discounts: []discount.Discount{newDiscount(1, discount.SKU, "000001", 15)},
+			item:      variant,
+			final:     10200,
+			applied:   &pricing.AppliedDiscount{ID: "1", Kind: "sku", Percentage: 15, Amount: 1800},
+		},
+		{
+			name:      "sku of the variant",
+			discounts: []discount.Discount{newDiscount(1, discount.SKU, "000001-42", 50)},
+			item:      variant,
+			final:     6000,
+			applied:   &pricing.AppliedDiscount{ID: "1", Kind: "sku", Percentage: 50, Amount: 6000},
+		},
+		{
+			name: "basket promotions never apply to a single item",
+			discounts: []discount.Discount{
+				discount.NewDiscount(discount.GeneralDiscount{ID: 1, DiscountTypeID: discount.BUY_X_GET_Y, Target: "000001", BuyQuantity: 2, FreeQuantity: 1}),
+				discount.NewDiscount(discount.GeneralDiscount{ID: 2, DiscountTypeID: discount.SPEND_THRESHOLD, Percentage: 10, MinSpend: 1}),
+				discount.NewDiscount(discount.GeneralDiscount{ID: 3, DiscountTypeID: discount.BUNDLE, Target: "000001,000002", BundlePrice: 1}),
+			},
+			item:  boots,
+			final: 10000,
+		},
+		{
+			name: "the greatest discount wins",
+			discounts: []discount.Discount{
+				newDiscount(1, discount.GENERAL, "", 10),
+				newDiscount(2, discount.SKU, "000001", 40),
+				newDiscount(3, discount.CATEGORY, "1", 30),
+			},
+			item:    boots,
+			final:   6000,
+			applied: &pricing.AppliedDiscount{ID: "2", Kind: "sku", Percentage: 40, Amount: 4000},
+		},
+		{
+			name: "on a tie the first one is kept",
+			discounts: []discount.Discount{
+				newDiscount(1, discount.CATEGORY, "1", 20),
+				newDiscount(2, discount.SKU, "000001", 20),
+			},
+			item:    boots,
+			final:   8000,
+			applied: &pricing.AppliedDiscount{ID: "1", Kind: "category", Percentage: 20, Amount: 2000},
+		},
+		{
+			name:      "a coupon discount is one more general discount",
+			discounts: []discount.Discount{newDiscount(1, discount.CATEGORY, "1", 10), &discount.GeneralDiscount{ID: 7, Percentage: 20, CouponOnly: true}},
+			item:      boots,
+			final:     8000,
+			applied:   &pricing.AppliedDiscount{ID: "7", Kind: "general", Percentage: 20, Amount: 2000},
+		},
+		{
+			name:      "a discount of 0% changes nothing",
+			discounts: []discount.Discount{newDiscount(1, discount.GENERAL, "", 0)},
+			item:      boots,
+			final:     10000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pricing.NewEngine(tt.discounts).Price(tt.item, pc)
+
+			assert.Equal(t, pricing.Result{
+				Original: tt.item.Price,
+				Final:    tt.final,
+				Currency: "EUR",
+				Applied:  tt.applied,
+			}, result)
+		})
+	}
+}
+
+func TestEnginePrice_Currency(t *testing.T) {
+	result := pricing.NewEngine(nil).Price(pricing.Item{SKU: "000001", Price: 10000}, pricing.Context{Currency: "CHF"})
+
+	assert.Equal(t, "CHF", result.Currency)
+}
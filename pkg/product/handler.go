@@ -2,13 +2,16 @@ package product
 
 import (
 	"encoding/json"
+	"fmt"
 	"mytheresa/internal/apierror"
 	"mytheresa/internal/database"
+	"mytheresa/internal/httpcache"
 	"mytheresa/internal/logger"
 	"mytheresa/internal/response"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -22,12 +25,15 @@ type Handler interface {
 type handler struct {
 	service Service
 	logger  logger.Logger
+	// defaultLimit is how many products are listed when the request sets no limit
+	defaultLimit int
 }
 
-func NewHandler(service Service, logger logger.Logger) Handler {
+func NewHandler(service Service, logger logger.Logger, defaultLimit int) Handler {
 	return &handler{
-		service: service,
-		logger:  logger,
+		service:      service,
+		logger:       logger,
+		defaultLimit: defaultLimit,
 	}
 }
 
@@ -39,7 +45,13 @@ func NewHandler(service Service, logger logger.Logger) Handler {
 // @Param product body ProductRequest true "Product details"
 // @Success 201 {object} ProductResponse
 // @Failure 400 {object} apierror.ApiError "Wrong body"
+// @Failure 413 {object} apierror.ApiError "Body too large"
+// @Failure 429 {object} apierror.ApiError "Too many requests"
 // @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /v1/products [post]
 func (h *handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -64,16 +76,33 @@ func (h *handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 
 // GetProduct godoc
 // @Summary Get a product by SKU
-// @Description Get the details of a product by its SKU
-// @Produce  json
+// @Description Get the details of a product by its SKU, or of the product of a variant SKU. Clients opting in to v2,
+// @Description with the application/vnd.mytheresa.v2+json media type or X-API-Version: 2, get the ProductResponse
+// @Description priced as in the product list instead, wrapped in an envelope, a variant SKU giving the variant alone
+// @Produce  json,xml
 // @Param id path string true "Product SKU"
-// @Success 200 {object} ProductResponse
-// @Failure 404 {object} apierror.ApiError "Product not found"
+// @Param coupon query string false "Coupon code unlocking an additional discount, v2 only"
+// @Param If-None-Match header string false "ETag of the representation already held"
+// @Param If-Modified-Since header string false "Last-Modified of the representation already held"
+// @Success 200 {object} Product "The product, or the ProductResponse in v2"
+// @Header 200,304 {string} ETag "Hash of the versions of what the response shows"
+// @Header 200,304 {string} Last-Modified "Last change of what the response shows"
+// @Header 200,304 {string} Cache-Control "Caching policy, configurable"
+// @Success 304 "Not modified"
+// @Failure 404 {object} apierror.ApiError "Product or coupon not found"
+// @Failure 409 {object} apierror.ApiError "Coupon expired or exhausted"
+// @Failure 406 {object} apierror.ApiError "Not acceptable"
 // @Failure 500 {object} apierror.ApiError "Internal server error"
 // @Router /v1/products/{id} [get]
 func (h *handler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	// the priced product is the v2 shape, clients not opting in keep getting the stored one
+	if response.Enveloped(w) {
+		h.getPricedProduct(w, r)
+		return
+	}
+
 	product, err := h.service.GetProduct(ctx, mux.Vars(r)["id"])
 	if err != nil {
 		h.logger.
@@ -84,31 +113,70 @@ func (h *handler) GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response.RespondWithData(w, http.StatusOK, product)
+	lastModified := httpcache.Latest(product.UpdatedAt, product.Category.UpdatedAt)
+	etag := httpcache.ETag(w, fmt.Sprintf("%s@%d:%d", product.SKU, product.Version, lastModified.UnixNano()))
+	httpcache.Respond(w, r, etag, lastModified, product)
+}
+
+func (h *handler) getPricedProduct(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	product, err := h.service.GetPricedProduct(ctx, mux.Vars(r)["id"], r.URL.Query().Get("coupon"))
+	if err != nil {
+		h.logger.
+			WithField("product_id", mux.Vars(r)["id"]).
+			WithError(err).
+			Error(ctx, "Error getting product")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	httpcache.Respond(w, r, httpcache.ETag(w, product.Version()), product.UpdatedAt, product)
 }
 
 // ListProducts godoc
 // @Summary List all products
 // @Description Retrieve a list of products, with optional filtering by category and price range
-// @Produce  json
-// @Param limit query int false "Limit the number of products" default(5)
+// @Produce  json,xml,text/csv
+// @Param limit query int false "Limit the number of products, 5 unless configured otherwise"
 // @Param category query string false "Filter products by category ID"
 // @Param priceLessThan query int false "Filter products with price less than"
 // @Param priceGreaterThan query int false "Filter products with price greater than"
+// @Param coupon query string false "Coupon code unlocking an additional discount"
+// @Param in_stock query bool false "Only list products with units available"
+// @Param If-None-Match header string false "ETag of the representation already held"
 // @Success 200 {array} ProductResponse
+// @Header 200,304 {string} ETag "Hash of the versions of what the response shows"
+// @Header 200,304 {string} Cache-Control "Caching policy, configurable"
+// @Success 304 "Not modified"
+// @Failure 400 {object} apierror.ApiError "Wrong limit"
+// @Failure 404 {object} apierror.ApiError "Coupon not found"
+// @Failure 409 {object} apierror.ApiError "Coupon expired or exhausted"
+// @Failure 406 {object} apierror.ApiError "Not acceptable"
 // @Failure 500 {object} apierror.ApiError "Internal server error"
 // @Router /v1/products [get]
 func (h *handler) ListProducts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	limit := 5
+	limit := h.defaultLimit
 
 	queryParams := r.URL.Query()
-	if l, err := strconv.Atoi(queryParams.Get("limit")); queryParams.Get("limit") != "" && err == nil {
-		limit = l
+	if l := queryParams.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			response.RespondWithError(w, apierror.BadRequest("limit must be a positive number"))
+			return
+		}
+	}
+	opts := ListOptions{
+		Filters:    createFilters(queryParams),
+		CouponCode: queryParams.Get("coupon"),
+		Limit:      limit,
+	}
+	if inStock, err := strconv.ParseBool(queryParams.Get("in_stock")); err == nil {
+		opts.InStock = inStock
 	}
-	filters := createFilters(queryParams)
 
-	products, err := h.service.ListProducts(ctx, filters...)
+	products, err := h.service.ListProducts(ctx, opts)
 	if err != nil {
 		h.logger.
 			WithError(err).
@@ -116,10 +184,24 @@ func (h *handler) ListProducts(w http.ResponseWriter, r *http.Request) {
 		response.RespondWithError(w, err)
 		return
 	}
-	if len(products) < limit {
-		limit = len(products)
+	// a page not filled holds every product matching, only a full one needs counting them
+	total := len(products)
+	if total == limit {
+		if total, err = h.service.CountProducts(ctx, opts); err != nil {
+			h.logger.
+				WithError(err).
+				Error(ctx, "Error counting products")
+			response.RespondWithError(w, err)
+			return
+		}
+	}
+
+	// products leaving the list have no change time to show, so only the ETag is sent
+	versions := []string{strconv.Itoa(limit), strconv.Itoa(total)}
+	for _, p := range products {
+		versions = append(versions, p.Version())
 	}
-	response.RespondWithData(w, http.StatusOK, products[:limit])
+	httpcache.Respond(w, r, httpcache.ETag(w, versions...), time.Time{}, response.NewPage(products, limit, total))
 }
 
 func createFilters(params url.Values) []database.Filter {
@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
 	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/internal/response"
 	"mytheresa/pkg/category"
 	"mytheresa/pkg/product"
 	productmocks "mytheresa/pkg/product/mocks"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
@@ -21,7 +24,7 @@ func TestNewHandler(t *testing.T) {
 	ps := productmocks.Service{}
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	assert.NotNil(t, h)
 }
@@ -41,7 +44,7 @@ func TestHandlerCreateProduct_OK(t *testing.T) {
 	body, _ := json.Marshal(p)
 	r := httptest.NewRequest("POST", "/products", bytes.NewReader(body))
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 	h.CreateProduct(w, r)
 
 	assert.Equal(t, http.StatusCreated, w.Code)
@@ -59,7 +62,7 @@ func TestHandlerCreateProduct_WrongBody(t *testing.T) {
 	ps := productmocks.Service{}
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	r := httptest.NewRequest(http.MethodPost, "/products", bytes.NewReader([]byte("invalid body")))
 	w := httptest.NewRecorder()
@@ -86,7 +89,7 @@ func TestHandlerCreateProduct_ServiceError(t *testing.T) {
 	ps.On("CreateProduct", mock.Anything, productRequest).Return(product.Product{}, apierror.InternalServerError("service error"))
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	body, _ := json.Marshal(productRequest)
 	req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewReader(body))
@@ -106,16 +109,17 @@ func TestHandlerGetProduct_OK(t *testing.T) {
 	expectedProduct := product.Product{
 		SKU:        productID,
 		Name:       "Test Product",
-		Price:      95000,
+		Category:   category.Category{ID: 1, Name: "Boots"},
 		CategoryID: 1,
-		Category:   category.Category{Name: "Boots"},
+		Price:      95000,
+		Versioned:  database.Versioned{Version: 1, UpdatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
 	}
 
 	ps := productmocks.Service{}
 	ps.On("GetProduct", mock.Anything, productID).Return(expectedProduct, nil)
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	r := httptest.NewRequest("GET", "/products/"+productID, nil)
 	r = mux.SetURLVars(r, map[string]string{"id": productID})
@@ -124,12 +128,74 @@ func TestHandlerGetProduct_OK(t *testing.T) {
 	h.GetProduct(w, r)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Fri, 01 Mar 2024 10:00:00 GMT", w.Header().Get("Last-Modified"))
 
 	var response product.Product
 	err := json.NewDecoder(w.Body).Decode(&response)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedProduct, response)
+	ps.AssertNotCalled(t, "GetPricedProduct", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandlerGetProduct_V2Priced(t *testing.T) {
+	productID := "000001"
+	percentage := "30"
+	expectedProduct := product.ProductResponse{
+		SKU:      productID,
+		Name:     "Test Product",
+		Category: "Boots",
+		Price:    product.PriceResponse{Original: 95000, Final: 66500, DiscountPercentage: &percentage, Currency: "EUR"},
+		Stock:    3,
+	}
+	updatedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	ps := productmocks.Service{}
+	priced := expectedProduct
+	priced.UpdatedAt = updatedAt
+	ps.On("GetPricedProduct", mock.Anything, productID, "").Return(priced, nil)
+
+	h := product.NewHandler(&ps, &loggermocks.NoopLogger{}, 5)
+
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/products/"+productID, nil), map[string]string{"id": productID})
+	r.Header.Set(response.VersionHeader, "2")
+	w := httptest.NewRecorder()
+	response.Middleware(http.HandlerFunc(h.GetProduct)).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var envelope struct {
+		Data product.ProductResponse `json:"data"`
+	}
+	err := json.NewDecoder(w.Body).Decode(&envelope)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedProduct, envelope.Data)
+	assert.Equal(t, "Fri, 01 Mar 2024 10:00:00 GMT", w.Header().Get("Last-Modified"))
+
+	r = mux.SetURLVars(httptest.NewRequest("GET", "/products/"+productID, nil), map[string]string{"id": productID})
+	r.Header.Set(response.VersionHeader, "2")
+	r.Header.Set("If-Modified-Since", "Fri, 01 Mar 2024 10:00:00 GMT")
+	w = httptest.NewRecorder()
+	response.Middleware(http.HandlerFunc(h.GetProduct)).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestHandlerGetProduct_WithCoupon(t *testing.T) {
+	productID := "000001"
+	ps := productmocks.Service{}
+	ps.On("GetPricedProduct", mock.Anything, productID, "WELCOME20").Return(product.ProductResponse{SKU: productID}, nil)
+
+	h := product.NewHandler(&ps, &loggermocks.NoopLogger{}, 5)
+
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/products/"+productID+"?coupon=WELCOME20", nil), map[string]string{"id": productID})
+	r.Header.Set("Accept", response.EnvelopeMediaType)
+	w := httptest.NewRecorder()
+	response.Middleware(http.HandlerFunc(h.GetProduct)).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	ps.AssertExpectations(t)
 }
 
 func TestHandlerGetProduct_NotFound(t *testing.T) {
@@ -139,7 +205,7 @@ func TestHandlerGetProduct_NotFound(t *testing.T) {
 	ps.On("GetProduct", mock.Anything, productID).Return(product.Product{}, apierror.NotFound("product not found"))
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	r := httptest.NewRequest("GET", "/products/"+productID, nil)
 	r = mux.SetURLVars(r, map[string]string{"id": productID})
@@ -162,7 +228,7 @@ func TestHandlerGetProduct_ServiceError(t *testing.T) {
 	ps.On("GetProduct", mock.Anything, productID).Return(product.Product{}, apierror.InternalServerError("service error"))
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	r := httptest.NewRequest("GET", "/products/"+productID, nil)
 	r = mux.SetURLVars(r, map[string]string{"id": productID})
@@ -200,24 +266,64 @@ func TestHandlerListProducts_OK(t *testing.T) {
 		},
 	}
 	ps := productmocks.Service{}
-	ps.On("ListProducts", mock.Anything, mock.Anything).Return(products, nil)
+	ps.On("ListProducts", mock.Anything, withLimit(2)).Return(products[:2], nil)
+	ps.On("CountProducts", mock.Anything, withLimit(2)).Return(len(products), nil)
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	r := httptest.NewRequest("GET", "/products?limit=2", nil)
+	r.Header.Set(response.VersionHeader, "2")
 	w := httptest.NewRecorder()
 
-	h.ListProducts(w, r)
+	response.Middleware(http.HandlerFunc(h.ListProducts)).ServeHTTP(w, r)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response []product.ProductResponse
-	err := json.NewDecoder(w.Body).Decode(&response)
+	var envelope struct {
+		Data []product.ProductResponse `json:"data"`
+		Meta struct {
+			Pagination struct {
+				Limit int `json:"limit"`
+				Total int `json:"total"`
+			} `json:"pagination"`
+		} `json:"meta"`
+	}
+	err := json.NewDecoder(w.Body).Decode(&envelope)
 
 	assert.NoError(t, err)
-	assert.Len(t, response, 2)
-	assert.Equal(t, products[:2], response)
+	assert.Equal(t, products[:2], envelope.Data)
+	assert.Equal(t, 2, envelope.Meta.Pagination.Limit)
+	assert.Equal(t, 3, envelope.Meta.Pagination.Total)
+	ps.AssertExpectations(t)
+}
+
+func TestHandlerListProducts_PageNotFilledIsNotCounted(t *testing.T) {
+	products := []product.ProductResponse{{SKU: "000001", Name: "Product 1"}}
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, withLimit(2)).Return(products, nil)
+
+	h := product.NewHandler(&ps, &loggermocks.NoopLogger{}, 5)
+
+	w := httptest.NewRecorder()
+	h.ListProducts(w, httptest.NewRequest("GET", "/products?limit=2", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	ps.AssertNotCalled(t, "CountProducts", mock.Anything, mock.Anything)
+}
+
+func TestHandlerListProducts_ErrorCounting(t *testing.T) {
+	products := []product.ProductResponse{{SKU: "000001"}, {SKU: "000002"}}
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, withLimit(2)).Return(products, nil)
+	ps.On("CountProducts", mock.Anything, withLimit(2)).Return(0, apierror.InternalServerError("Failed to count products in database"))
+
+	h := product.NewHandler(&ps, &loggermocks.NoopLogger{}, 5)
+
+	w := httptest.NewRecorder()
+	h.ListProducts(w, httptest.NewRequest("GET", "/products?limit=2", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
 func TestHandlerListProducts_DefaultLimit(t *testing.T) {
@@ -260,10 +366,12 @@ func TestHandlerListProducts_DefaultLimit(t *testing.T) {
 		},
 	}
 	ps := productmocks.Service{}
-	ps.On("ListProducts", mock.Anything, mock.Anything).Return(products, nil)
+	ps.On("ListProducts", mock.Anything, withLimit(5)).Return(products[:5], nil)
+	ps.On("ListProducts", mock.Anything, withLimit(3)).Return(products[:3], nil)
+	ps.On("CountProducts", mock.Anything, mock.Anything).Return(len(products), nil)
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	r := httptest.NewRequest("GET", "/products", nil)
 	w := httptest.NewRecorder()
@@ -278,6 +386,16 @@ func TestHandlerListProducts_DefaultLimit(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, response, 5)
 	assert.Equal(t, products[:5], response)
+
+	// the default comes from the configuration
+	h = product.NewHandler(&ps, &logMock, 3)
+	w = httptest.NewRecorder()
+
+	h.ListProducts(w, httptest.NewRequest("GET", "/products", nil))
+
+	response = nil
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, products[:3], response)
 }
 
 func TestHandlerListProducts_WithFilters(t *testing.T) {
@@ -293,7 +411,7 @@ func TestHandlerListProducts_WithFilters(t *testing.T) {
 	ps.On("ListProducts", mock.Anything, mock.Anything).Return(products, nil)
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	r := httptest.NewRequest("GET", "/products?category=Boots&priceLessThan=90000", nil)
 	w := httptest.NewRecorder()
@@ -310,12 +428,26 @@ func TestHandlerListProducts_WithFilters(t *testing.T) {
 	assert.Equal(t, products, response)
 }
 
+func TestHandlerListProducts_WrongLimit(t *testing.T) {
+	for _, limit := range []string{"0", "-1", "five"} {
+		h := product.NewHandler(&productmocks.Service{}, &loggermocks.NoopLogger{}, 5)
+
+		w := httptest.NewRecorder()
+		h.ListProducts(w, httptest.NewRequest("GET", "/products?limit="+limit, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+		var apierr apierror.ApiError
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&apierr))
+		assert.Equal(t, "limit must be a positive number", apierr.Error())
+	}
+}
+
 func TestHandlerListProducts_ServiceError(t *testing.T) {
 	ps := productmocks.Service{}
 	ps.On("ListProducts", mock.Anything, mock.Anything).Return([]product.ProductResponse{}, apierror.InternalServerError("service error"))
 	logMock := loggermocks.NoopLogger{}
 
-	h := product.NewHandler(&ps, &logMock)
+	h := product.NewHandler(&ps, &logMock, 5)
 
 	r := httptest.NewRequest("GET", "/products", nil)
 	w := httptest.NewRecorder()
@@ -329,3 +461,116 @@ func TestHandlerListProducts_ServiceError(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "service error", apierr.Error())
 }
+
+func TestHandlerListProducts_InStockAndCoupon(t *testing.T) {
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, mock.MatchedBy(func(opts product.ListOptions) bool {
+		return opts.InStock && opts.CouponCode == "WELCOME20" && len(opts.Filters) == 1
+	})).Return([]product.ProductResponse{}, nil)
+
+	h := product.NewHandler(&ps, &loggermocks.NoopLogger{}, 5)
+
+	r := httptest.NewRequest("GET", "/products?in_stock=true&coupon=WELCOME20&category=1", nil)
+	w := httptest.NewRecorder()
+
+	h.ListProducts(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	ps.AssertExpectations(t)
+}
+
+func TestHandlerGetProduct_NotModified(t *testing.T) {
+	productID := "000001"
+	p := product.Product{SKU: productID, Name: "Test Product", Versioned: database.Versioned{Version: 1, UpdatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}}
+
+	ps := productmocks.Service{}
+	ps.On("GetProduct", mock.Anything, productID).Return(p, nil)
+
+	h := product.NewHandler(&ps, &loggermocks.NoopLogger{}, 5)
+
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/products/"+productID, nil), map[string]string{"id": productID})
+	w := httptest.NewRecorder()
+	h.GetProduct(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	r = mux.SetURLVars(httptest.NewRequest("GET", "/products/"+productID, nil), map[string]string{"id": productID})
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.GetProduct(w, r)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	r = mux.SetURLVars(httptest.NewRequest("GET", "/products/"+productID, nil), map[string]string{"id": productID})
+	r.Header.Set("If-Modified-Since", "Fri, 01 Mar 2024 10:00:00 GMT")
+	w = httptest.NewRecorder()
+	h.GetProduct(w, r)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestHandlerListProducts_NotModified(t *testing.T) {
+	products := []product.ProductResponse{
+		{SKU: "000001", Name: "Product 1", UpdatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{SKU: "000002", Name: "Product 2", UpdatedAt: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)},
+		{SKU: "000003", Name: "Product 3", UpdatedAt: time.Date(2024, 3, 3, 10, 0, 0, 0, time.UTC)},
+	}
+	ps := productmocks.Service{}
+	ps.On("ListProducts", mock.Anything, withLimit(2)).Return(products[:2], nil)
+	ps.On("CountProducts", mock.Anything, withLimit(2)).Return(len(products), nil)
+
+	h := product.NewHandler(&ps, &loggermocks.NoopLogger{}, 5)
+
+	w := httptest.NewRecorder()
+	h.ListProducts(w, httptest.NewRequest("GET", "/products?limit=2", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+
+	r := httptest.NewRequest("GET", "/products?limit=2", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ListProducts(w, r)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestHandlerListProducts_ETagFollowsStockAndLowestPrice(t *testing.T) {
+	lowest := 8000
+	base := product.ProductResponse{
+		SKU:       "000001",
+		Price:     product.PriceResponse{Original: 10000, Final: 8000, Currency: "EUR"},
+		Stock:     3,
+		UpdatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+	soldOut := base
+	soldOut.Stock = 0
+	lowered := base
+	lowered.Price.LowestPrice30d = &lowest
+	variant := base
+	variant.Variants = []product.VariantResponse{{SKU: "000001-42", Stock: 1}}
+
+	etags := map[string]bool{}
+	for _, p := range []product.ProductResponse{base, soldOut, lowered, variant} {
+		ps := productmocks.Service{}
+		ps.On("ListProducts", mock.Anything, mock.Anything).Return([]product.ProductResponse{p}, nil)
+		h := product.NewHandler(&ps, &loggermocks.NoopLogger{}, 5)
+
+		w := httptest.NewRecorder()
+		h.ListProducts(w, httptest.NewRequest("GET", "/products", nil))
+
+		etags[w.Header().Get("ETag")] = true
+	}
+
+	assert.Len(t, etags, 4)
+}
+
+func withLimit(limit int) interface{} {
+	return mock.MatchedBy(func(opts product.ListOptions) bool {
+		return opts.Limit == limit
+	})
+}
@@ -2,7 +2,7 @@ package mocks
 
 import (
 	"context"
-	"mytheresa/internal/database"
+	"mytheresa/pkg/discount"
 	"mytheresa/pkg/product"
 
 	"github.com/stretchr/testify/mock"
@@ -22,7 +22,22 @@ func (s *Service) GetProduct(ctx context.Context, id string) (product.Product, e
 	return args.Get(0).(product.Product), args.Error(1)
 }
 
-func (s *Service) ListProducts(ctx context.Context, filters ...database.Filter) ([]product.ProductResponse, error) {
-	args := s.Called(ctx, filters)
+func (s *Service) GetPricedProduct(ctx context.Context, id string, couponCode string) (product.ProductResponse, error) {
+	args := s.Called(ctx, id, couponCode)
+	return args.Get(0).(product.ProductResponse), args.Error(1)
+}
+
+func (s *Service) ListProducts(ctx context.Context, opts product.ListOptions) ([]product.ProductResponse, error) {
+	args := s.Called(ctx, opts)
 	return args.Get(0).([]product.ProductResponse), args.Error(1)
 }
+
+func (s *Service) CountProducts(ctx context.Context, opts product.ListOptions) (int, error) {
+	args := s.Called(ctx, opts)
+	return args.Int(0), args.Error(1)
+}
+
+func (s *Service) RefreshPriceHistory(ctx context.Context, changed discount.GeneralDiscount) error {
+	args := s.Called(ctx, changed)
+	return args.Error(0)
+}
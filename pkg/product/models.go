@@ -1,16 +1,37 @@
 package product
 
 import (
+	"encoding/xml"
+	"fmt"
+	"mytheresa/internal/apierror"
 	"mytheresa/internal/database"
 	"mytheresa/pkg/category"
+	"mytheresa/pkg/inventory"
+	"mytheresa/pkg/pricing"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Product struct {
-	SKU        string            `gorm:"primaryKey" json:"sku"`
-	Name       string            `gorm:"not null" json:"name"`
-	Category   category.Category `gorm:"foreignKey:CategoryID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"category"`
-	CategoryID int               `gorm:"not null" json:"category_id"`
-	Price      int               `gorm:"not null" json:"price"`
+	XMLName    xml.Name          `gorm:"-" json:"-" xml:"product"`
+	SKU        string            `gorm:"primaryKey" json:"sku" xml:"sku"`
+	Name       string            `gorm:"not null" json:"name" xml:"name"`
+	Category   category.Category `gorm:"foreignKey:CategoryID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"category" xml:"category"`
+	CategoryID int               `gorm:"not null" json:"category_id" xml:"category_id"`
+	Price      int               `gorm:"not null" json:"price" xml:"price"`
+	Variants   []Variant         `gorm:"foreignKey:ParentSKU;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"variants,omitempty" xml:"variant,omitempty"`
+	database.Versioned
+}
+
+// Variant is a version of a parent product (e.g. a size and colour) sold under its own SKU.
+// When Price is nil the variant is sold at the parent price. Its stock is kept by the inventory.
+type Variant struct {
+	SKU       string `gorm:"primaryKey" json:"sku" xml:"sku"`
+	ParentSKU string `gorm:"not null;index" json:"parent_sku" xml:"parent_sku"`
+	Size      string `json:"size" xml:"size"`
+	Colour    string `json:"colour" xml:"colour"`
+	Price     *int   `json:"price,omitempty" xml:"price,omitempty"`
 }
 
 // ProductRequest represents the body for creating a product
@@ -19,61 +40,290 @@ type Product struct {
 // @Produce json
 // @Param product body ProductRequest true "Product details"
 type ProductRequest struct {
-	SKU        string `json:"sku" example:"000005"`
-	Name       string `json:"name" example:"Legendary Boots"`
-	Price      int    `json:"price" example:"10000"`
-	CategoryID int    `json:"category_id" example:"1"`
+	SKU        string           `json:"sku" example:"000005"`
+	Name       string           `json:"name" example:"Legendary Boots"`
+	Price      int              `json:"price" example:"10000"`
+	CategoryID int              `json:"category_id" example:"1"`
+	Stock      int              `json:"stock,omitempty" example:"10"`
+	Variants   []VariantRequest `json:"variants,omitempty"`
+}
+
+// VariantRequest represents a variant to be created along with its parent product
+// @Description VariantRequest is the input for a product variant. Price is optional and overrides the parent price
+type VariantRequest struct {
+	SKU    string `json:"sku" example:"000005-42-BLK"`
+	Size   string `json:"size" example:"42"`
+	Colour string `json:"colour" example:"black"`
+	Price  *int   `json:"price,omitempty" example:"12000"`
+	Stock  int    `json:"stock" example:"3"`
+}
+
+// Validate checks the product can be created together with its variants and initial stock
+func (p *ProductRequest) Validate() error {
+	if p.SKU == "" {
+		return apierror.BadRequest("SKU is required")
+	}
+	if p.Stock < 0 {
+		return apierror.BadRequest("Invalid stock")
+	}
+
+	skus := map[string]bool{p.SKU: true}
+	for _, v := range p.Variants {
+		if v.SKU == "" {
+			return apierror.BadRequest("Every variant needs a SKU")
+		}
+		if skus[v.SKU] {
+			return apierror.BadRequest(fmt.Sprintf("Duplicated SKU %s", v.SKU))
+		}
+		if v.Price != nil && *v.Price <= 0 {
+			return apierror.BadRequest(fmt.Sprintf("Invalid price for variant %s", v.SKU))
+		}
+		if v.Stock < 0 {
+			return apierror.BadRequest(fmt.Sprintf("Invalid stock for variant %s", v.SKU))
+		}
+		skus[v.SKU] = true
+	}
+	return nil
 }
 
 func (p *ProductRequest) ToProduct() Product {
+	var variants []Variant
+	for _, v := range p.Variants {
+		variants = append(variants, Variant{
+			SKU:       v.SKU,
+			ParentSKU: p.SKU,
+			Size:      v.Size,
+			Colour:    v.Colour,
+			Price:     v.Price,
+		})
+	}
+
 	return Product{
 		SKU:        p.SKU,
 		Name:       p.Name,
 		Price:      p.Price,
 		CategoryID: p.CategoryID,
+		Variants:   variants,
 	}
 }
 
-func (p *Product) ToProductResponse() ProductResponse {
+// ToProductResponse returns the product with its prices in currency, before any discount
+func (p *Product) ToProductResponse(currency string) ProductResponse {
+	var variants []VariantResponse
+	for _, v := range p.Variants {
+		variants = append(variants, VariantResponse{
+			SKU:    v.SKU,
+			Size:   v.Size,
+			Colour: v.Colour,
+			Price:  newPriceResponse(v.GetPrice(p.Price), currency),
+		})
+	}
+
 	return ProductResponse{
-		SKU:      p.SKU,
-		Name:     p.Name,
-		Category: p.Category.Name,
-		Price: PriceResponse{
-			Original:           p.Price,
-			Final:              p.Price,
-			DiscountPercentage: nil,
-			Currency:           "EUR",
-		},
+		SKU:        p.SKU,
+		Name:       p.Name,
+		Category:   p.Category.Name,
+		CategoryID: p.CategoryID,
+		Price:      newPriceResponse(p.Price, currency),
+		Variants:   variants,
+	}
+}
+
+func newPriceResponse(price int, currency string) PriceResponse {
+	return PriceResponse{
+		Original:           price,
+		Final:              price,
+		DiscountPercentage: nil,
+		Currency:           currency,
 	}
 }
 
+// toPriceResponse returns the price worked out by the pricing engine
+func toPriceResponse(result pricing.Result) PriceResponse {
+	price := newPriceResponse(result.Original, result.Currency)
+	price.Final = result.Final
+	if result.Applied != nil {
+		percentage := fmt.Sprint(result.Applied.Percentage)
+		price.DiscountPercentage = &percentage
+		price.DiscountID = result.Applied.ID
+	}
+	return price
+}
+
+// GetPrice returns the variant price, which is the parent one unless overridden
+func (v *Variant) GetPrice(parentPrice int) int {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return parentPrice
+}
+
 func (p *Product) GetIdentifier() string {
 	return p.SKU
 }
 
+// SKUs returns the SKU of the product followed by the ones of its variants
+func (p *Product) SKUs() []string {
+	skus := []string{p.SKU}
+	for _, v := range p.Variants {
+		skus = append(skus, v.SKU)
+	}
+	return skus
+}
+
+// InitialStock returns the units to stock for every SKU created by the request
+func (p *ProductRequest) InitialStock() map[string]int {
+	stock := map[string]int{}
+	if p.Stock > 0 {
+		stock[p.SKU] = p.Stock
+	}
+	for _, v := range p.Variants {
+		if v.Stock > 0 {
+			stock[v.SKU] = v.Stock
+		}
+	}
+	return stock
+}
+
+// ListOptions narrows down and prices the products returned by ListProducts
+type ListOptions struct {
+	Filters []database.Filter
+	// SKUs, when given, keeps the products having any of them, as their own SKU or as the SKU of
+	// one of their variants
+	SKUs []string
+	// CouponCode unlocks the coupon only discount linked to the coupon
+	CouponCode string
+	// InStock keeps only the products with units available, of their own or of any variant
+	InStock bool
+	// Limit, when greater than 0, lists that many products at most in SKU order, the ones after
+	// AfterSKU, so the catalog can be read a page at a time
+	Limit    int
+	AfterSKU string
+}
+
 // ProductResponse represents a product with its details
 // @Description ProductResponse is the output when retrieving product details
 // @Accept json
 // @Produce json
 // @Success 200 {object} ProductResponse
 type ProductResponse struct {
-	SKU      string        `json:"sku" example:"000005"`
-	Name     string        `json:"name" example:"Legendary boots"`
-	Category string        `json:"category" example:"Boots"`
-	Price    PriceResponse `json:"price"`
+	XMLName  xml.Name `json:"-" xml:"product"`
+	SKU      string   `json:"sku" xml:"sku" example:"000005"`
+	Name     string   `json:"name" xml:"name" example:"Legendary boots"`
+	Category string   `json:"category" xml:"category" example:"Boots"`
+	// ParentSKU, Size and Colour are only set for a variant given as a product, see ForSKU
+	ParentSKU string            `json:"parent_sku,omitempty" xml:"parent_sku,omitempty"`
+	Size      string            `json:"size,omitempty" xml:"size,omitempty"`
+	Colour    string            `json:"colour,omitempty" xml:"colour,omitempty"`
+	Price     PriceResponse     `json:"price" xml:"price"`
+	Stock     int               `json:"stock" xml:"stock" example:"3"`
+	Variants  []VariantResponse `json:"variants,omitempty" xml:"variant,omitempty"`
+	// UpdatedAt is the last change of the product, its category, the discounts or the stock
+	// and lowest prices of its SKUs
+	UpdatedAt  time.Time `json:"-" xml:"-"`
+	CategoryID int       `json:"-" xml:"-"`
+}
+
+// Version tells apart every state of the product as shown: UpdatedAt follows every change of
+// what it shows, and the stock and prices of its SKUs are added as changes can share a time
+func (p ProductResponse) Version() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s@%d:%s:%d", p.SKU, p.UpdatedAt.UnixNano(), p.Price.version(), p.Stock)
+	for _, v := range p.Variants {
+		fmt.Fprintf(&b, ";%s:%s:%d", v.SKU, v.Price.version(), v.Stock)
+	}
+	return b.String()
+}
+
+// ForSKU returns what the SKU, the product's own or the one of a variant, is sold as. A variant
+// is given as a product of its own, with its price and stock and the name and category of its
+// parent.
+func (p ProductResponse) ForSKU(sku string) (ProductResponse, bool) {
+	if sku == p.SKU {
+		return p, true
+	}
+	for _, v := range p.Variants {
+		if v.SKU == sku {
+			return ProductResponse{
+				SKU:        v.SKU,
+				Name:       p.Name,
+				Category:   p.Category,
+				ParentSKU:  p.SKU,
+				Size:       v.Size,
+				Colour:     v.Colour,
+				Price:      v.Price,
+				Stock:      v.Stock,
+				UpdatedAt:  p.UpdatedAt,
+				CategoryID: p.CategoryID,
+			}, true
+		}
+	}
+	return ProductResponse{}, false
+}
+
+// CSVHeader names the columns of the products given as CSV
+func (p ProductResponse) CSVHeader() []string {
+	return []string{"sku", "name", "category", "original_price", "final_price", "discount_percentage", "currency", "lowest_price_30d", "stock"}
+}
+
+// CSVRecord returns the row of the product, its variants left out
+func (p ProductResponse) CSVRecord() []string {
+	var discount, lowest string
+	if p.Price.DiscountPercentage != nil {
+		discount = *p.Price.DiscountPercentage
+	}
+	if p.Price.LowestPrice30d != nil {
+		lowest = strconv.Itoa(*p.Price.LowestPrice30d)
+	}
+	return []string{
+		p.SKU,
+		p.Name,
+		p.Category,
+		strconv.Itoa(p.Price.Original),
+		strconv.Itoa(p.Price.Final),
+		discount,
+		p.Price.Currency,
+		lowest,
+		strconv.Itoa(p.Stock),
+	}
+}
+
+// VariantResponse represents a product variant with its own price details
+// @Description VariantResponse is the output for every variant nested in a product
+type VariantResponse struct {
+	SKU    string        `json:"sku" xml:"sku" example:"000005-42-BLK"`
+	Size   string        `json:"size" xml:"size" example:"42"`
+	Colour string        `json:"colour" xml:"colour" example:"black"`
+	Price  PriceResponse `json:"price" xml:"price"`
+	Stock  int           `json:"stock" xml:"stock" example:"3"`
 }
 
 // PriceResponse represents the price details of a product
-// @Description PriceResponse includes the original and final price of a product, along with any discounts
+// @Description PriceResponse includes the original and final price of a product, along with any discounts.
+// @Description LowestPrice30d is the lowest final price of the SKU in the 30 days before its current one, as required when showing a discount.
 // @Accept json
 // @Produce json
 // @Success 200 {object} PriceResponse
 type PriceResponse struct {
-	Original           int     `json:"original" example:"10000"`
-	Final              int     `json:"final" example:"8000"`
-	DiscountPercentage *string `json:"discount_percentage,omitempty" example:"20"`
-	Currency           string  `json:"currency" example:"EUR"`
+	Original           int     `json:"original" xml:"original" example:"10000"`
+	Final              int     `json:"final" xml:"final" example:"8000"`
+	DiscountPercentage *string `json:"discount_percentage,omitempty" xml:"discount_percentage,omitempty" example:"20"`
+	Currency           string  `json:"currency" xml:"currency" example:"EUR"`
+	LowestPrice30d     *int    `json:"lowest_price_30d,omitempty" xml:"lowest_price_30d,omitempty" example:"8000"`
+	// DiscountID is the discount giving the final price, if any
+	DiscountID string `json:"-" xml:"-"`
+}
+
+func (p PriceResponse) version() string {
+	var discount string
+	if p.DiscountPercentage != nil {
+		discount = *p.DiscountPercentage
+	}
+	lowest := -1
+	if p.LowestPrice30d != nil {
+		lowest = *p.LowestPrice30d
+	}
+	return fmt.Sprintf("%d/%d/%s/%s/%d", p.Original, p.Final, discount, p.Currency, lowest)
 }
 
 type categoryFilter struct {
@@ -127,3 +377,60 @@ func NewPriceFilter(value string, operand string) database.Filter {
 		Operand: operand,
 	}
 }
+
+type skuFilter struct {
+	field   string
+	Value   interface{}
+	Operand string
+}
+
+func (f *skuFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *skuFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *skuFilter) GetOperand() string {
+	return f.Operand
+}
+
+// NewSKUFilter matches the products, or variants, whose SKU is any of the given ones
+func NewSKUFilter(skus []string) database.Filter {
+	return &skuFilter{
+		field:   "sku",
+		Value:   skus,
+		Operand: "IN",
+	}
+}
+
+// NewInStockFilter matches the products with units available, of their own or of any variant
+func NewInStockFilter() database.Filter {
+	inStock := &skuFilter{
+		field: "sku",
+		Value: database.Subquery{
+			Model:   &inventory.StockLevel{},
+			Column:  "sku",
+			Filters: []database.Filter{inventory.NewAvailableFilter(0, ">")},
+		},
+		Operand: "IN",
+	}
+	return database.AnyOf{
+		inStock,
+		&skuFilter{
+			field:   "sku",
+			Value:   database.Subquery{Model: &Variant{}, Column: "parent_sku", Filters: []database.Filter{inStock}},
+			Operand: "IN",
+		},
+	}
+}
+
+// NewSKUAfterFilter matches the products whose SKU sorts after the given one
+func NewSKUAfterFilter(sku string) database.Filter {
+	return &skuFilter{
+		field:   "sku",
+		Value:   sku,
+		Operand: ">",
+	}
+}
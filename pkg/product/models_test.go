@@ -34,7 +34,7 @@ func TestProduct_ToProductResponse(t *testing.T) {
 		Price: 500,
 	}
 
-	response := p.ToProductResponse()
+	response := p.ToProductResponse("EUR")
 
 	assert.Equal(t, "000005", response.SKU)
 	assert.Equal(t, "Epic Sandals", response.Name)
@@ -69,3 +69,77 @@ func TestNewPriceFilter(t *testing.T) {
 	assert.Equal(t, "100", filter.GetValue())
 	assert.Equal(t, ">", filter.GetOperand())
 }
+
+func TestProductRequest_ToProduct_WithVariants(t *testing.T) {
+	price := 12000
+	request := product.ProductRequest{
+		SKU:   "000005",
+		Price: 11000,
+		Variants: []product.VariantRequest{
+			{SKU: "000005-42", Size: "42", Colour: "black"},
+			{SKU: "000005-43", Size: "43", Colour: "black", Price: &price},
+		},
+	}
+
+	p := request.ToProduct()
+
+	assert.Len(t, p.Variants, 2)
+	assert.Equal(t, "000005", p.Variants[0].ParentSKU)
+	assert.Equal(t, "42", p.Variants[0].Size)
+	assert.Nil(t, p.Variants[0].Price)
+	assert.Equal(t, &price, p.Variants[1].Price)
+}
+
+func TestProduct_ToProductResponse_WithVariants(t *testing.T) {
+	price := 700
+	p := product.Product{
+		SKU:   "000005",
+		Price: 500,
+		Variants: []product.Variant{
+			{SKU: "000005-42", Size: "42"},
+			{SKU: "000005-43", Size: "43", Price: &price},
+		},
+	}
+
+	response := p.ToProductResponse("EUR")
+
+	assert.Len(t, response.Variants, 2)
+	assert.Equal(t, "000005-42", response.Variants[0].SKU)
+	assert.Equal(t, 500, response.Variants[0].Price.Original)
+	assert.Equal(t, 700, response.Variants[1].Price.Original)
+	assert.Equal(t, 700, response.Variants[1].Price.Final)
+	assert.Equal(t, "EUR", response.Variants[1].Price.Currency)
+}
+
+func TestVariant_GetPrice(t *testing.T) {
+	price := 700
+	assert.Equal(t, 500, (&product.Variant{}).GetPrice(500))
+	assert.Equal(t, 700, (&product.Variant{Price: &price}).GetPrice(500))
+}
+
+func TestProduct_SKUs(t *testing.T) {
+	p := product.Product{
+		SKU:      "000005",
+		Variants: []product.Variant{{SKU: "000005-42"}, {SKU: "000005-43"}},
+	}
+
+	assert.Equal(t, []string{"000005", "000005-42", "000005-43"}, p.SKUs())
+}
+
+func TestProductRequest_InitialStock(t *testing.T) {
+	request := product.ProductRequest{
+		SKU:   "000005",
+		Stock: 4,
+		Variants: []product.VariantRequest{
+			{SKU: "000005-42", Stock: 3},
+			{SKU: "000005-43"},
+		},
+	}
+
+	assert.Equal(t, map[string]int{"000005": 4, "000005-42": 3}, request.InitialStock())
+}
+
+func TestProductRequest_Validate(t *testing.T) {
+	assert.NoError(t, (&product.ProductRequest{SKU: "000001"}).Validate())
+	assert.EqualError(t, (&product.ProductRequest{Name: "Boots"}).Validate(), "SKU is required")
+}
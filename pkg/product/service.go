@@ -6,8 +6,18 @@ import (
 	"fmt"
 	"mytheresa/internal/apierror"
 	"mytheresa/internal/database"
+	"mytheresa/internal/httpcache"
 	"mytheresa/internal/logger"
+	"mytheresa/internal/requestctx"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/audit"
+	"mytheresa/pkg/coupon"
 	"mytheresa/pkg/discount"
+	"mytheresa/pkg/events"
+	"mytheresa/pkg/inventory"
+	"mytheresa/pkg/pricehistory"
+	"mytheresa/pkg/pricing"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -15,37 +25,90 @@ import (
 type Service interface {
 	CreateProduct(ctx context.Context, product ProductRequest) (Product, error)
 	GetProduct(ctx context.Context, id string) (Product, error)
-	ListProducts(ctx context.Context, filters ...database.Filter) ([]ProductResponse, error)
+	GetPricedProduct(ctx context.Context, id string, couponCode string) (ProductResponse, error)
+	ListProducts(ctx context.Context, opts ListOptions) ([]ProductResponse, error)
+	CountProducts(ctx context.Context, opts ListOptions) (int, error)
+	RefreshPriceHistory(ctx context.Context, changed discount.GeneralDiscount) error
 }
 
 type service struct {
-	db              database.Database
-	logger          logger.Logger
-	discountService discount.Service
+	db               database.Database
+	logger           logger.Logger
+	discountService  discount.Service
+	couponService    coupon.Service
+	inventoryService inventory.Service
+	auditService     audit.Service
+	priceHistory     pricehistory.Service
+	events           events.Publisher
 }
 
-func NewService(db database.Database, logger logger.Logger, ds discount.Service) Service {
+func NewService(db database.Database, logger logger.Logger, ds discount.Service, cs coupon.Service, is inventory.Service, as audit.Service, ph pricehistory.Service, ep events.Publisher) Service {
 	return &service{
-		db:              db,
-		logger:          logger,
-		discountService: ds,
+		db:               db,
+		logger:           logger,
+		discountService:  ds,
+		couponService:    cs,
+		inventoryService: is,
+		auditService:     as,
+		priceHistory:     ph,
+		events:           ep,
 	}
 }
 
+// CreateProduct saves and publishes the product with its variants, stocks their initial units
+// and records the change in the audit log and its prices in the price history, all of it or
+// nothing.
 func (s *service) CreateProduct(ctx context.Context, req ProductRequest) (Product, error) {
+	ctx, span := tracing.Start(ctx, "product.CreateProduct")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		return Product{}, err
+	}
+
 	product := req.ToProduct()
-	err := s.db.Save(ctx, product.GetIdentifier(), &product)
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		err := s.db.Save(ctx, product.GetIdentifier(), &product)
+		if err != nil {
+			msg := fmt.Sprintf("Error creating product: %s", product.Name)
+			s.logger.Error(ctx, msg)
+			return apierror.InternalServerError(msg)
+		}
+		// ahead of the stock of its SKUs, published as product.updated
+		if err := s.events.Publish(ctx, events.ProductCreated, product); err != nil {
+			return err
+		}
+
+		for sku, units := range req.InitialStock() {
+			if _, err := s.inventoryService.AdjustStock(ctx, sku, units); err != nil {
+				s.logger.WithField("sku", sku).WithError(err).Error(ctx, "Error stocking product")
+				return err
+			}
+		}
+		if err := s.auditService.Record(ctx, audit.Product, product.SKU, audit.Create, nil, product); err != nil {
+			return err
+		}
+		return s.recordPrices(ctx, []Product{product})
+	})
 	if err != nil {
-		msg := fmt.Sprintf("Error creating product: %s", product.Name)
-		s.logger.Error(ctx, msg)
-		return Product{}, apierror.InternalServerError(msg)
+		return Product{}, err
 	}
 	return product, nil
 }
 
+// GetProduct returns the product stored with the SKU, or the product of the variant with the SKU
 func (s *service) GetProduct(ctx context.Context, id string) (Product, error) {
+	ctx, span := tracing.Start(ctx, "product.GetProduct")
+	defer span.End()
+
 	var product Product
 	err := s.db.Get(ctx, id, &product)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var variant Variant
+		if err = s.db.Get(ctx, id, &variant); err == nil {
+			err = s.db.Get(ctx, variant.ParentSKU, &product)
+		}
+	}
 	if err != nil {
 		s.logger.
 			WithField("id", id).
@@ -61,58 +124,306 @@ func (s *service) GetProduct(ctx context.Context, id string) (Product, error) {
 	return product, nil
 }
 
-func (s *service) ListProducts(ctx context.Context, filters ...database.Filter) ([]ProductResponse, error) {
+// GetPricedProduct returns the product as ListProducts does, priced with the discount unlocked
+// by the coupon code when given. The SKU of a variant gives the variant as a product.
+func (s *service) GetPricedProduct(ctx context.Context, id string, couponCode string) (ProductResponse, error) {
+	ctx, span := tracing.Start(ctx, "product.GetPricedProduct")
+	defer span.End()
+
+	products, err := s.ListProducts(ctx, ListOptions{
+		SKUs:       []string{id},
+		CouponCode: couponCode,
+	})
+	if err != nil {
+		return ProductResponse{}, err
+	}
+	for _, p := range products {
+		if product, ok := p.ForSKU(id); ok {
+			return product, nil
+		}
+	}
+	s.logger.WithField("id", id).Error(ctx, "product not found")
+	return ProductResponse{}, apierror.NotFound("Product not found")
+}
+
+// ListProducts returns the products matching the filters with the greater discount applied.
+// When a coupon code is given, the discount it unlocks is also taken into account.
+// Stock comes from the inventory, and products without units can be left out with InStock, in
+// the query itself so pages are filled with the ones in stock.
+// Every price carries the lowest final price of its SKU in the 30 days before its current one.
+func (s *service) ListProducts(ctx context.Context, opts ListOptions) ([]ProductResponse, error) {
+	ctx, span := tracing.Start(ctx, "product.ListProducts")
+	defer span.End()
+
 	var products []Product
 
-	s.logger.WithField("filters", filters).Info(ctx, "Listing products")
+	s.logger.WithField("filters", opts.Filters).Info(ctx, "Listing products")
+
+	filters, err := s.listFilters(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	err := s.db.GetWithFilters(ctx, &products, filters...)
+	if opts.Limit > 0 {
+		products, err = s.getPage(ctx, filters, opts.AfterSKU, opts.Limit)
+	} else {
+		products, err = s.getProducts(ctx, filters)
+	}
 	if err != nil {
 		s.logger.WithError(err).Error(ctx, "Failed to get products from database")
 		return nil, apierror.InternalServerError(fmt.Sprintf("Failed to get products from database"))
 	}
 
-	response, err := s.getProductResponseWithDiscounts(ctx, products)
+	var skus []string
+	for _, p := range products {
+		skus = append(skus, p.SKUs()...)
+	}
+	stocks, err := s.inventoryService.GetStocks(ctx, skus)
 	if err != nil {
+		s.logger.WithError(err).Error(ctx, "Failed to get stock of products")
 		return nil, err
 	}
 
-	s.logger.WithField("quantity", len(products)).Info(ctx, "Successfully retrieved products")
+	lowest, err := s.priceHistory.GetLowestPrices(ctx, skus)
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "Failed to get lowest prices of products")
+		return nil, err
+	}
+
+	response, err := s.getProductResponseWithDiscounts(ctx, products, opts.CouponCode)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, pr := range response {
+		pr.Stock = stocks[pr.SKU].Available
+		pr.Price.LowestPrice30d = lowestPrice(lowest, pr.SKU)
+		pr.UpdatedAt = httpcache.Latest(pr.UpdatedAt, stocks[pr.SKU].UpdatedAt, lowest[pr.SKU].ChangedAt)
+		for i, v := range pr.Variants {
+			pr.Variants[i].Stock = stocks[v.SKU].Available
+			pr.Variants[i].Price.LowestPrice30d = lowestPrice(lowest, v.SKU)
+			pr.Stock += pr.Variants[i].Stock
+			pr.UpdatedAt = httpcache.Latest(pr.UpdatedAt, stocks[v.SKU].UpdatedAt, lowest[v.SKU].ChangedAt)
+		}
+		response[i] = pr
+	}
+
+	s.logger.WithField("quantity", len(response)).Info(ctx, "Successfully retrieved products")
 	return response, nil
 }
 
-func (s *service) getProductResponseWithDiscounts(ctx context.Context, products []Product) ([]ProductResponse, error) {
-	discounts, err := s.discountService.GetDiscounts(ctx)
+// CountProducts returns how many products ListProducts would list without a limit, counted
+// in the query itself
+func (s *service) CountProducts(ctx context.Context, opts ListOptions) (int, error) {
+	ctx, span := tracing.Start(ctx, "product.CountProducts")
+	defer span.End()
+
+	filters, err := s.listFilters(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := s.db.Count(ctx, &Product{}, filters...)
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "Failed to count products in database")
+		return 0, apierror.InternalServerError("Failed to count products in database")
+	}
+	return int(count), nil
+}
+
+// listFilters returns the filters of the options along with the ones for their SKUs and stock
+func (s *service) listFilters(ctx context.Context, opts ListOptions) ([]database.Filter, error) {
+	filters := opts.Filters
+	if len(opts.SKUs) > 0 {
+		skus, err := s.productSKUs(ctx, opts.SKUs)
+		if err != nil {
+			s.logger.WithError(err).Error(ctx, "Failed to get variants from database")
+			return nil, apierror.InternalServerError("Failed to get products from database")
+		}
+		filters = append(filters[:len(filters):len(filters)], NewSKUFilter(skus))
+	}
+	if opts.InStock {
+		filters = append(filters[:len(filters):len(filters)], NewInStockFilter())
+	}
+	return filters, nil
+}
+
+// RefreshPriceHistory records the final price of the products and variants the discount changed,
+// to be called when one is created. Only a general discount reprices the whole catalog, coupon
+// only and basket level ones no product at all, and only the prices that changed end up in the
+// history.
+func (s *service) RefreshPriceHistory(ctx context.Context, changed discount.GeneralDiscount) error {
+	ctx, span := tracing.Start(ctx, "product.RefreshPriceHistory")
+	defer span.End()
+
+	if changed.CouponOnly {
+		return nil
+	}
+	var filters []database.Filter
+	switch changed.DiscountTypeID {
+	case discount.GENERAL:
+	case discount.CATEGORY:
+		filters = append(filters, NewCategoryFilter(changed.Target, "="))
+	case discount.SKU:
+		// the parent of a variant is priced along with it
+		skus, err := s.productSKUs(ctx, []string{changed.Target})
+		if err != nil {
+			s.logger.WithError(err).Error(ctx, "Failed to get variants from database")
+			return apierror.InternalServerError("Failed to get products from database")
+		}
+		filters = append(filters, NewSKUFilter(skus))
+	default:
+		return nil
+	}
+
+	products, err := s.getProducts(ctx, filters)
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "Failed to get products from database")
+		return apierror.InternalServerError("Failed to get products from database")
+	}
+	return s.recordPrices(ctx, products)
+}
+
+// productSKUs returns the SKUs of the products having any of the given SKUs, the parents of the
+// variants among them added
+func (s *service) productSKUs(ctx context.Context, skus []string) ([]string, error) {
+	found := map[string]bool{}
+	var products []string
+	add := func(sku string) {
+		if !found[sku] {
+			found[sku] = true
+			products = append(products, sku)
+		}
+	}
+
+	for _, chunk := range database.Chunk(skus, database.MaxInValues) {
+		var variants []Variant
+		if err := s.db.GetWithFilters(ctx, &variants, NewSKUFilter(chunk)); err != nil {
+			return nil, err
+		}
+		for _, sku := range chunk {
+			add(sku)
+		}
+		for _, v := range variants {
+			add(v.ParentSKU)
+		}
+	}
+	return products, nil
+}
+
+// getProducts reads every product matching the filters a page at a time, as the variants of a
+// page are preloaded with a bind variable per product
+func (s *service) getProducts(ctx context.Context, filters []database.Filter) ([]Product, error) {
+	var products []Product
+	var after string
+	for {
+		page, err := s.getPage(ctx, filters, after, database.MaxInValues)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, page...)
+		if len(page) < database.MaxInValues {
+			return products, nil
+		}
+		after = page[len(page)-1].SKU
+	}
+}
+
+// getPage reads up to limit products matching the filters by SKU, the ones after afterSKU when given
+func (s *service) getPage(ctx context.Context, filters []database.Filter, afterSKU string, limit int) ([]Product, error) {
+	filters = filters[:len(filters):len(filters)]
+	if afterSKU != "" {
+		filters = append(filters, NewSKUAfterFilter(afterSKU))
+	}
+	var products []Product
+	err := s.db.GetPage(ctx, &products, database.Page{OrderBy: "sku", Limit: limit}, filters...)
+	return products, err
+}
+
+// recordPrices records the final prices everyone gets, coupons aside, of the products and
+// their variants
+func (s *service) recordPrices(ctx context.Context, products []Product) error {
+	response, err := s.getProductResponseWithDiscounts(ctx, products, "")
+	if err != nil {
+		return err
+	}
+
+	prices := map[string]int{}
+	for _, pr := range response {
+		prices[pr.SKU] = pr.Price.Final
+		for _, v := range pr.Variants {
+			prices[v.SKU] = v.Price.Final
+		}
+	}
+
+	if err := s.priceHistory.RecordPrices(ctx, prices); err != nil {
+		s.logger.WithError(err).Error(ctx, "Failed to record prices of products")
+		return err
+	}
+	return nil
+}
+
+func (s *service) getProductResponseWithDiscounts(ctx context.Context, products []Product, couponCode string) ([]ProductResponse, error) {
+	discounts, err := s.discountService.GetApplicableDiscounts(ctx)
 	if err != nil {
 		s.logger.WithError(err).Error(ctx, "Failed to get discounts from database")
 		return nil, err
 	}
 
+	if couponCode != "" {
+		d, err := s.couponService.GetCouponDiscount(ctx, couponCode)
+		if err != nil {
+			s.logger.WithField("coupon", couponCode).WithError(err).Error(ctx, "Failed to get coupon discount")
+			return nil, err
+		}
+		discounts = append(discounts, d)
+	}
+
+	// any discount can change the price of any product
+	var discountsChanged time.Time
+	for _, d := range discounts {
+		discountsChanged = httpcache.Latest(discountsChanged, d.LastModified())
+	}
+
+	engine := pricing.NewEngine(discounts)
+	pc := pricingContext(ctx)
+
 	response := []ProductResponse{}
 	for _, p := range products {
-		pr := p.ToProductResponse()
+		pr := p.ToProductResponse(pc.Currency)
+		pr.UpdatedAt = httpcache.Latest(p.UpdatedAt, p.Category.UpdatedAt, discountsChanged)
 
-		item := discount.DiscountConditions{
-			CategoryID: fmt.Sprint(p.CategoryID),
+		item := pricing.Item{
 			SKU:        p.SKU,
+			CategoryID: fmt.Sprint(p.CategoryID),
+			Price:      p.Price,
 		}
+		pr.Price = toPriceResponse(engine.Price(item, pc))
 
-		//apply greater discount
-		for _, d := range discounts {
-			if d.IsApplicableFor(item) {
-				candidate := d.Apply(pr.Price.Original)
-				if candidate < pr.Price.Final {
-					percentaje := fmt.Sprint(d.GetPercentage())
-					pr.Price = PriceResponse{
-						Original:           pr.Price.Original,
-						Final:              candidate,
-						DiscountPercentage: &percentaje,
-						Currency:           "EUR",
-					}
-				}
+		// variants are priced on their own, but discounts on the parent SKU apply to them too
+		for i, v := range p.Variants {
+			item := pricing.Item{
+				SKU:        v.SKU,
+				CategoryID: fmt.Sprint(p.CategoryID),
+				ParentSKU:  p.SKU,
+				Price:      v.GetPrice(p.Price),
 			}
+			pr.Variants[i].Price = toPriceResponse(engine.Price(item, pc))
 		}
+
 		response = append(response, pr)
 	}
 	return response, nil
 }
+
+func lowestPrice(lowest map[string]pricehistory.LowestPrice, sku string) *int {
+	if l, ok := lowest[sku]; ok {
+		return &l.Price
+	}
+	return nil
+}
+
+// pricingContext returns what the prices of the request depend on
+func pricingContext(ctx context.Context) pricing.Context {
+	return pricing.Context{Currency: requestctx.Currency(ctx)}
+}
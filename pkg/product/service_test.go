@@ -5,24 +5,59 @@ import (
 	"errors"
 	"fmt"
 	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
 	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
 	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/audit"
+	auditmocks "mytheresa/pkg/audit/mocks"
 	"mytheresa/pkg/category"
+	couponmocks "mytheresa/pkg/coupon/mocks"
 	"mytheresa/pkg/discount"
 	discountmocks "mytheresa/pkg/discount/mocks"
+	"mytheresa/pkg/events"
+	eventsmocks "mytheresa/pkg/events/mocks"
+	"mytheresa/pkg/inventory"
+	inventorymocks "mytheresa/pkg/inventory/mocks"
+	"mytheresa/pkg/pricehistory"
+	pricehistorymocks "mytheresa/pkg/pricehistory/mocks"
 	"mytheresa/pkg/product"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
+// emptyInventory returns an inventory where nothing was ever stocked
+func emptyInventory() *inventorymocks.Service {
+	is := inventorymocks.Service{}
+	is.On("GetStocks", mock.Anything, mock.Anything).Return(map[string]inventory.StockLevel{}, nil)
+	return &is
+}
+
+// noDiscounts returns a discount service without any discount
+func noDiscounts() *discountmocks.Service {
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{}, nil)
+	return &ds
+}
+
+// emptyPriceHistory returns a price history where nothing was ever priced
+func emptyPriceHistory() *pricehistorymocks.Service {
+	phs := pricehistorymocks.Service{}
+	phs.On("RecordPrices", mock.Anything, mock.Anything).Return(nil)
+	phs.On("GetLowestPrices", mock.Anything, mock.Anything).Return(map[string]pricehistory.LowestPrice{}, nil)
+	return &phs
+}
+
 func TestNewService(t *testing.T) {
 	ds := discountmocks.Service{}
 	dbmock := dbmocks.Database{}
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
 	assert.NotNil(t, s)
 }
@@ -35,7 +70,7 @@ func TestCreateProduct_OK(t *testing.T) {
 		CategoryID: 1,
 	}
 
-	ds := discountmocks.Service{}
+	ds := noDiscounts()
 	dbmock := dbmocks.Database{}
 	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 		if h, ok := args.Get(2).(*product.Product); ok {
@@ -45,7 +80,7 @@ func TestCreateProduct_OK(t *testing.T) {
 	).Return(nil)
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
 	result, err := s.CreateProduct(context.Background(), pr)
 
@@ -70,7 +105,7 @@ func TestCreateProduct_ErrorSavingOnDB(t *testing.T) {
 	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
 	_, err := s.CreateProduct(context.Background(), pr)
 
@@ -95,7 +130,7 @@ func TestGetProduct_OK(t *testing.T) {
 	}).Return(nil)
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
 	result, err := s.GetProduct(context.Background(), "1234")
 
@@ -104,13 +139,42 @@ func TestGetProduct_OK(t *testing.T) {
 	assert.Equal(t, p.SKU, result.SKU)
 }
 
+func TestGetProduct_Variant(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Get", mock.Anything, "1234-42", mock.AnythingOfType("*product.Product")).Return(gorm.ErrRecordNotFound)
+	dbmock.On("Get", mock.Anything, "1234-42", mock.AnythingOfType("*product.Variant")).Run(func(args mock.Arguments) {
+		*args.Get(2).(*product.Variant) = product.Variant{SKU: "1234-42", ParentSKU: "1234"}
+	}).Return(nil)
+	dbmock.On("Get", mock.Anything, "1234", mock.AnythingOfType("*product.Product")).Run(func(args mock.Arguments) {
+		*args.Get(2).(*product.Product) = product.Product{SKU: "1234", Variants: []product.Variant{{SKU: "1234-42", ParentSKU: "1234"}}}
+	}).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	result, err := s.GetProduct(context.Background(), "1234-42")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1234", result.SKU)
+}
+
+func TestGetProduct_NotFound(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(gorm.ErrRecordNotFound)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	_, err := s.GetProduct(context.Background(), "9999")
+
+	assert.Equal(t, apierror.NotFound("Product not found"), err)
+}
+
 func TestGetProduct_ErrorGettingFromDB(t *testing.T) {
 	ds := discountmocks.Service{}
 	dbmock := dbmocks.Database{}
 	dbmock.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
 	_, err := s.GetProduct(context.Background(), "1234")
 	assert.NotNil(t, err)
@@ -130,7 +194,7 @@ func TestListProducts_OK(t *testing.T) {
 		},
 	}
 	ds := discountmocks.Service{}
-	ds.On("GetDiscounts", mock.Anything).Return([]discount.Discount{
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
 		&discount.GeneralDiscount{
 			ID:             1,
 			Percentage:     10,
@@ -141,7 +205,7 @@ func TestListProducts_OK(t *testing.T) {
 	}, nil)
 
 	dbmock := dbmocks.Database{}
-	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 		if h, ok := args.Get(1).(*[]product.Product); ok {
 			*h = dbdata
 		}
@@ -149,9 +213,9 @@ func TestListProducts_OK(t *testing.T) {
 
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
-	result, err := s.ListProducts(context.Background())
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
 
 	assert.Nil(t, err)
 	assert.NotNil(t, result)
@@ -188,13 +252,13 @@ func TestListProducts_SeveralDiscountsApplyToSameProduct(t *testing.T) {
 			Target:         "1234",
 		},
 	}
-	ds.On("GetDiscounts", mock.Anything).Return([]discount.Discount{
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
 		minorDiscount,
 		greaterDiscount,
 	}, nil)
 
 	dbmock := dbmocks.Database{}
-	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 		if h, ok := args.Get(1).(*[]product.Product); ok {
 			*h = dbdata
 		}
@@ -202,9 +266,9 @@ func TestListProducts_SeveralDiscountsApplyToSameProduct(t *testing.T) {
 
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
-	result, err := s.ListProducts(context.Background())
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
 
 	assert.Nil(t, err)
 	assert.NotNil(t, result)
@@ -215,17 +279,36 @@ func TestListProducts_SeveralDiscountsApplyToSameProduct(t *testing.T) {
 	assert.Equal(t, resultPrice, p.Price.Final)
 }
 
+func TestListProducts_Page(t *testing.T) {
+	filters := []database.Filter{product.NewCategoryFilter("1", "=")}
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, database.Page{OrderBy: "sku", Limit: 2},
+		[]database.Filter{filters[0], product.NewSKUAfterFilter("000002")}).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]product.Product) = []product.Product{{SKU: "000003", CategoryID: 1, Price: 100}}
+	}).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	result, err := s.ListProducts(context.Background(), product.ListOptions{Filters: filters, Limit: 2, AfterSKU: "000002"})
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "000003", result[0].SKU)
+	// the filters given are left as they were
+	assert.Len(t, filters, 1)
+}
+
 func TestListProducts_ErrorSearchingOnDBProducts(t *testing.T) {
 	ds := discountmocks.Service{}
 
 	dbmock := dbmocks.Database{}
-	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
 
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
-	result, err := s.ListProducts(context.Background())
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
 
 	assert.Nil(t, result)
 	assert.NotNil(t, err)
@@ -246,10 +329,10 @@ func TestListProducts_ErrorGettingDiscounts(t *testing.T) {
 	}
 	ds := discountmocks.Service{}
 	discountErr := apierror.InternalServerError("error getting discounts")
-	ds.On("GetDiscounts", mock.Anything).Return([]discount.Discount{}, discountErr)
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{}, discountErr)
 
 	dbmock := dbmocks.Database{}
-	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 		if h, ok := args.Get(1).(*[]product.Product); ok {
 			*h = dbdata
 		}
@@ -257,9 +340,9 @@ func TestListProducts_ErrorGettingDiscounts(t *testing.T) {
 
 	logMock := loggermocks.NoopLogger{}
 
-	s := product.NewService(&dbmock, &logMock, &ds)
+	s := product.NewService(&dbmock, &logMock, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
 
-	result, err := s.ListProducts(context.Background())
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
 
 	assert.Nil(t, result)
 	assert.NotNil(t, err)
@@ -267,3 +350,767 @@ func TestListProducts_ErrorGettingDiscounts(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, discountErr.Error(), apierr.Error())
 }
+
+func TestListProducts_CouponUnlocksGreaterDiscount(t *testing.T) {
+	dbdata := []product.Product{
+		{
+			SKU:        "1234",
+			Name:       "Test product",
+			Category:   category.Category{},
+			CategoryID: 1,
+			Price:      10000,
+		},
+	}
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
+		&discount.CategoryDiscount{
+			GeneralDiscount: discount.GeneralDiscount{ID: 1, Percentage: 10, DiscountTypeID: discount.CATEGORY, Target: "1"},
+		},
+	}, nil)
+
+	cs := couponmocks.Service{}
+	cs.On("GetCouponDiscount", mock.Anything, "WINTER25").Return(&discount.GeneralDiscount{
+		ID: 2, Percentage: 25, DiscountTypeID: discount.GENERAL, CouponOnly: true,
+	}, nil)
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		if h, ok := args.Get(1).(*[]product.Product); ok {
+			*h = dbdata
+		}
+	}).Return(nil)
+
+	logMock := loggermocks.NoopLogger{}
+
+	s := product.NewService(&dbmock, &logMock, &ds, &cs, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	result, err := s.ListProducts(context.Background(), product.ListOptions{CouponCode: "WINTER25"})
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, 7500, result[0].Price.Final)
+	assert.Equal(t, "25", *result[0].Price.DiscountPercentage)
+}
+
+func TestListProducts_ErrorGettingCouponDiscount(t *testing.T) {
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{}, nil)
+
+	couponErr := apierror.NotFound("Coupon not found")
+	cs := couponmocks.Service{}
+	cs.On("GetCouponDiscount", mock.Anything, "UNKNOWN").Return(nil, couponErr)
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	logMock := loggermocks.NoopLogger{}
+
+	s := product.NewService(&dbmock, &logMock, &ds, &cs, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	result, err := s.ListProducts(context.Background(), product.ListOptions{CouponCode: "UNKNOWN"})
+
+	assert.Nil(t, result)
+	assert.Equal(t, couponErr, err)
+}
+
+func TestGetPricedProduct_CouponUnlocksGreaterDiscount(t *testing.T) {
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{}, nil)
+
+	cs := couponmocks.Service{}
+	cs.On("GetCouponDiscount", mock.Anything, "WINTER25").Return(&discount.GeneralDiscount{
+		ID: 2, Percentage: 25, DiscountTypeID: discount.GENERAL, CouponOnly: true,
+	}, nil)
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, []database.Filter{product.NewSKUFilter([]string{"1234"})}).Return(nil)
+	dbmock.On("GetPage", mock.Anything, mock.Anything, database.Page{OrderBy: "sku", Limit: database.MaxInValues}, []database.Filter{product.NewSKUFilter([]string{"1234"})}).Run(func(args mock.Arguments) {
+		if h, ok := args.Get(1).(*[]product.Product); ok {
+			*h = []product.Product{{SKU: "1234", Name: "Test product", CategoryID: 1, Price: 10000}}
+		}
+	}).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &ds, &cs, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	result, err := s.GetPricedProduct(context.Background(), "1234", "WINTER25")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", result.SKU)
+	assert.Equal(t, 7500, result.Price.Final)
+	assert.Equal(t, "25", *result.Price.DiscountPercentage)
+}
+
+func TestGetPricedProduct_NotFound(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	_, err := s.GetPricedProduct(context.Background(), "9999", "")
+
+	assert.Equal(t, apierror.NotFound("Product not found"), err)
+}
+
+func TestGetPricedProduct_Variant(t *testing.T) {
+	db := sqlitetest.NewDB(t, &product.Product{}, &product.Variant{}, &category.Category{},
+		&inventory.StockLevel{}, &inventory.Reservation{}, &pricehistory.CurrentPrice{}, &pricehistory.PricePeriod{})
+	l := &loggermocks.NoopLogger{}
+	ep := eventsmocks.AnyPublisher()
+	ph := pricehistory.NewService(db, l, ep, time.Now)
+
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
+		&discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 1, Percentage: 10, DiscountTypeID: discount.SKU, Target: "000005"}},
+	}, nil)
+	s := product.NewService(db, l, &ds, &couponmocks.Service{}, inventory.NewService(db, l, ep), auditmocks.AnyService(), ph, ep)
+
+	price := 12000
+	_, err := s.CreateProduct(context.Background(), product.ProductRequest{
+		SKU: "000005", Name: "Legendary boots", Price: 10000, CategoryID: 1,
+		Variants: []product.VariantRequest{{SKU: "000005-42-WHT", Size: "42", Colour: "white", Price: &price, Stock: 2}},
+	})
+	assert.NoError(t, err)
+
+	result, err := s.GetPricedProduct(context.Background(), "000005-42-WHT", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "000005-42-WHT", result.SKU)
+	assert.Equal(t, "000005", result.ParentSKU)
+	assert.Equal(t, "Legendary boots", result.Name)
+	assert.Equal(t, "white", result.Colour)
+	// the discount on the parent applies to the variant price
+	assert.Equal(t, 12000, result.Price.Original)
+	assert.Equal(t, 10800, result.Price.Final)
+	assert.Equal(t, 2, result.Stock)
+	assert.Empty(t, result.Variants)
+}
+
+func TestCreateProduct_InvalidVariants(t *testing.T) {
+	price := 0
+	tests := map[string][]product.VariantRequest{
+		"Every variant needs a SKU":         {{Size: "42"}},
+		"Duplicated SKU 1234":               {{SKU: "1234"}},
+		"Duplicated SKU 1234-42":            {{SKU: "1234-42"}, {SKU: "1234-42"}},
+		"Invalid price for variant 1234-42": {{SKU: "1234-42", Price: &price}},
+		"Invalid stock for variant 1234-42": {{SKU: "1234-42", Stock: -1}},
+	}
+
+	for message, variants := range tests {
+		t.Run(message, func(t *testing.T) {
+			dbmock := dbmocks.Database{}
+			s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &discountmocks.Service{}, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+			_, err := s.CreateProduct(context.Background(), product.ProductRequest{SKU: "1234", Variants: variants})
+
+			apierr, ok := err.(*apierror.ApiError)
+			assert.True(t, ok)
+			assert.Equal(t, message, apierr.Error())
+			dbmock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestListProducts_VariantsPricing(t *testing.T) {
+	override := 20000
+	dbdata := []product.Product{
+		{
+			SKU:        "1234",
+			Name:       "Test product",
+			CategoryID: 1,
+			Price:      10000,
+			Variants: []product.Variant{
+				{SKU: "1234-42", ParentSKU: "1234", Size: "42"},
+				{SKU: "1234-43", ParentSKU: "1234", Size: "43", Price: &override},
+				{SKU: "1234-44", ParentSKU: "1234", Size: "44"},
+			},
+		},
+	}
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
+		&discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 1, Percentage: 10, DiscountTypeID: discount.SKU, Target: "1234"}},
+		&discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 2, Percentage: 50, DiscountTypeID: discount.SKU, Target: "1234-44"}},
+	}, nil)
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		if h, ok := args.Get(1).(*[]product.Product); ok {
+			*h = dbdata
+		}
+	}).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, 9000, result[0].Price.Final)
+
+	variants := result[0].Variants
+	assert.Len(t, variants, 3)
+	// parent discount applies to the variant
+	assert.Equal(t, 10000, variants[0].Price.Original)
+	assert.Equal(t, 9000, variants[0].Price.Final)
+	// parent discount applies over the overridden price
+	assert.Equal(t, 20000, variants[1].Price.Original)
+	assert.Equal(t, 18000, variants[1].Price.Final)
+	// variant discount is greater than the parent one
+	assert.Equal(t, 5000, variants[2].Price.Final)
+	assert.Equal(t, "50", *variants[2].Price.DiscountPercentage)
+}
+
+func TestCreateProduct_InitialStock(t *testing.T) {
+	pr := product.ProductRequest{
+		SKU:      "1234",
+		Name:     "Test product",
+		Price:    11000,
+		Stock:    4,
+		Variants: []product.VariantRequest{{SKU: "1234-42", Stock: 2}, {SKU: "1234-43"}},
+	}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	is := inventorymocks.Service{}
+	is.On("AdjustStock", mock.Anything, "1234", 4).Return(inventory.StockLevel{SKU: "1234", Available: 4}, nil)
+	is.On("AdjustStock", mock.Anything, "1234-42", 2).Return(inventory.StockLevel{SKU: "1234-42", Available: 2}, nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, &is, auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	_, err := s.CreateProduct(context.Background(), pr)
+
+	assert.Nil(t, err)
+	is.AssertExpectations(t)
+	is.AssertNumberOfCalls(t, "AdjustStock", 2)
+}
+
+func TestCreateProduct_InvalidStock(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &discountmocks.Service{}, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	_, err := s.CreateProduct(context.Background(), product.ProductRequest{SKU: "1234", Stock: -1})
+
+	apierr, ok := err.(*apierror.ApiError)
+	assert.True(t, ok)
+	assert.Equal(t, "Invalid stock", apierr.Error())
+	dbmock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListProducts_Stock(t *testing.T) {
+	dbdata := []product.Product{
+		{SKU: "1234", CategoryID: 1, Price: 10000},
+		{SKU: "5678", CategoryID: 1, Price: 10000, Variants: []product.Variant{
+			{SKU: "5678-42", ParentSKU: "5678"},
+			{SKU: "5678-43", ParentSKU: "5678"},
+		}},
+		{SKU: "9999", CategoryID: 1, Price: 10000},
+	}
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{}, nil)
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		if h, ok := args.Get(1).(*[]product.Product); ok {
+			*h = dbdata
+		}
+	}).Return(nil)
+
+	is := inventorymocks.Service{}
+	is.On("GetStocks", mock.Anything, []string{"1234", "5678", "5678-42", "5678-43", "9999"}).Return(map[string]inventory.StockLevel{
+		"1234":    {SKU: "1234", Available: 3},
+		"5678-43": {SKU: "5678-43", Available: 2},
+	}, nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &ds, &couponmocks.Service{}, &is, auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	t.Run("all products", func(t *testing.T) {
+		result, err := s.ListProducts(context.Background(), product.ListOptions{})
+
+		assert.Nil(t, err)
+		assert.Len(t, result, 3)
+		assert.Equal(t, 3, result[0].Stock)
+		assert.Equal(t, 2, result[1].Stock)
+		assert.Equal(t, 0, result[1].Variants[0].Stock)
+		assert.Equal(t, 2, result[1].Variants[1].Stock)
+		assert.Equal(t, 0, result[2].Stock)
+	})
+}
+
+func TestListProducts_InStockPages(t *testing.T) {
+	db := sqlitetest.NewDB(t, &product.Product{}, &product.Variant{}, &category.Category{},
+		&inventory.StockLevel{}, &inventory.Reservation{}, &pricehistory.CurrentPrice{}, &pricehistory.PricePeriod{})
+	l := &loggermocks.NoopLogger{}
+	ep := eventsmocks.AnyPublisher()
+	s := product.NewService(db, l, noDiscounts(), &couponmocks.Service{}, inventory.NewService(db, l, ep), auditmocks.AnyService(), pricehistory.NewService(db, l, ep, time.Now), ep)
+
+	for _, req := range []product.ProductRequest{
+		{SKU: "0001", Price: 10000, CategoryID: 1},
+		{SKU: "0002", Price: 10000, CategoryID: 1, Stock: 3},
+		{SKU: "0003", Price: 10000, CategoryID: 1, Variants: []product.VariantRequest{{SKU: "0003-42", Stock: 2}, {SKU: "0003-43"}}},
+		{SKU: "0004", Price: 10000, CategoryID: 1, Variants: []product.VariantRequest{{SKU: "0004-42"}}},
+		{SKU: "0005", Price: 10000, CategoryID: 1, Stock: 1},
+	} {
+		_, err := s.CreateProduct(context.Background(), req)
+		assert.NoError(t, err)
+	}
+
+	// the products out of stock don't leave the pages short
+	result, err := s.ListProducts(context.Background(), product.ListOptions{InStock: true, Limit: 2})
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, "0002", result[0].SKU)
+	assert.Equal(t, "0003", result[1].SKU)
+	assert.Equal(t, 2, result[1].Stock)
+
+	result, err = s.ListProducts(context.Background(), product.ListOptions{InStock: true, Limit: 2, AfterSKU: "0003"})
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "0005", result[0].SKU)
+
+	// counted without the limit, out of stock products left out as when listing
+	count, err := s.CountProducts(context.Background(), product.ListOptions{InStock: true, Limit: 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+
+	count, err = s.CountProducts(context.Background(), product.ListOptions{Limit: 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+}
+
+func TestCountProducts_ErrorCounting(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Count", mock.Anything, mock.Anything, mock.Anything).Return(int64(0), errors.New("some DB error"))
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	_, err := s.CountProducts(context.Background(), product.ListOptions{})
+
+	assert.EqualError(t, err, "Failed to count products in database")
+}
+
+func TestListProducts_ErrorGettingStock(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	stockErr := apierror.InternalServerError("error getting stock")
+	is := inventorymocks.Service{}
+	is.On("GetStocks", mock.Anything, mock.Anything).Return(map[string]inventory.StockLevel{}, stockErr)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &discountmocks.Service{}, &couponmocks.Service{}, &is, auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
+
+	assert.Nil(t, result)
+	assert.Equal(t, stockErr, err)
+}
+
+func TestCreateProduct_ErrorStockingRollsBack(t *testing.T) {
+	pr := product.ProductRequest{SKU: "1234", Name: "Test product", Price: 11000, Stock: 4}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	stockErr := apierror.InternalServerError("error updating stock")
+	is := inventorymocks.Service{}
+	is.On("AdjustStock", mock.Anything, "1234", 4).Return(inventory.StockLevel{}, stockErr)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &discountmocks.Service{}, &couponmocks.Service{}, &is, auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	_, err := s.CreateProduct(context.Background(), pr)
+
+	assert.Equal(t, stockErr, err)
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+}
+
+func TestCreateProduct_RecordsAudit(t *testing.T) {
+	pr := product.ProductRequest{SKU: "1234", Name: "Test product", Price: 11000, CategoryID: 1}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	as := auditmocks.Service{}
+	as.On("Record", mock.Anything, audit.Product, "1234", audit.Create, nil, pr.ToProduct()).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), &as, emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	_, err := s.CreateProduct(context.Background(), pr)
+
+	assert.NoError(t, err)
+	as.AssertExpectations(t)
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+}
+
+func TestCreateProduct_ErrorRecordingAuditRollsBack(t *testing.T) {
+	pr := product.ProductRequest{SKU: "1234", Name: "Test product", Price: 11000, CategoryID: 1}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	auditErr := apierror.InternalServerError("error recording audit entry")
+	as := auditmocks.Service{}
+	as.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(auditErr)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &discountmocks.Service{}, &couponmocks.Service{}, emptyInventory(), &as, emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	_, err := s.CreateProduct(context.Background(), pr)
+
+	assert.Equal(t, auditErr, err)
+	dbmock.AssertCalled(t, "WithTransaction", mock.Anything, mock.Anything)
+}
+
+func TestCreateProduct_PublishesProduct(t *testing.T) {
+	pr := product.ProductRequest{SKU: "1234", Name: "Test product", Price: 11000, CategoryID: 1}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	ep := eventsmocks.Publisher{}
+	ep.On("Publish", mock.Anything, events.ProductCreated, pr.ToProduct()).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), &ep)
+
+	_, err := s.CreateProduct(context.Background(), pr)
+
+	assert.NoError(t, err)
+	ep.AssertExpectations(t)
+}
+
+func TestCreateProduct_ErrorPublishingRollsBack(t *testing.T) {
+	pr := product.ProductRequest{SKU: "1234", Name: "Test product", Price: 11000, CategoryID: 1, Stock: 2}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	publishErr := apierror.InternalServerError("error publishing event")
+	ep := eventsmocks.Publisher{}
+	ep.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(publishErr)
+	is := inventorymocks.Service{}
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, &is, auditmocks.AnyService(), emptyPriceHistory(), &ep)
+
+	_, err := s.CreateProduct(context.Background(), pr)
+
+	assert.Equal(t, publishErr, err)
+	is.AssertNotCalled(t, "AdjustStock", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListProducts_LowestPrice30d(t *testing.T) {
+	variantPrice := 12000
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]product.Product) = []product.Product{
+			{SKU: "1234", Price: 11000, Variants: []product.Variant{{SKU: "1234-42"}, {SKU: "1234-43", Price: &variantPrice}}},
+		}
+	}).Return(nil)
+
+	phs := pricehistorymocks.Service{}
+	phs.On("GetLowestPrices", mock.Anything, []string{"1234", "1234-42", "1234-43"}).
+		Return(map[string]pricehistory.LowestPrice{"1234": {Price: 9000}, "1234-42": {Price: 9500}}, nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), &phs, eventsmocks.AnyPublisher())
+
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 9000, *result[0].Price.LowestPrice30d)
+	assert.Equal(t, 9500, *result[0].Variants[0].Price.LowestPrice30d)
+	assert.Nil(t, result[0].Variants[1].Price.LowestPrice30d)
+}
+
+func TestListProducts_ErrorGettingLowestPrices(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	historyErr := apierror.InternalServerError("error getting price history")
+	phs := pricehistorymocks.Service{}
+	phs.On("GetLowestPrices", mock.Anything, mock.Anything).Return(map[string]pricehistory.LowestPrice{}, historyErr)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), &phs, eventsmocks.AnyPublisher())
+
+	_, err := s.ListProducts(context.Background(), product.ListOptions{})
+
+	assert.Equal(t, historyErr, err)
+}
+
+func TestCreateProduct_RecordsDiscountedPrices(t *testing.T) {
+	pr := product.ProductRequest{
+		SKU: "1234", Name: "Test product", Price: 10000, CategoryID: 1,
+		Variants: []product.VariantRequest{{SKU: "1234-42"}},
+	}
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
+		&discount.CategoryDiscount{GeneralDiscount: discount.GeneralDiscount{Percentage: 30, Target: "1"}},
+	}, nil)
+	phs := pricehistorymocks.Service{}
+	phs.On("RecordPrices", mock.Anything, map[string]int{"1234": 7000, "1234-42": 7000}).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), &phs, eventsmocks.AnyPublisher())
+
+	_, err := s.CreateProduct(context.Background(), pr)
+
+	assert.NoError(t, err)
+	phs.AssertExpectations(t)
+}
+
+func TestRefreshPriceHistory(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]product.Product) = []product.Product{
+			{SKU: "1234", Price: 10000, CategoryID: 1},
+			{SKU: "5678", Price: 20000, CategoryID: 2},
+		}
+	}).Return(nil)
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{
+		&discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{Percentage: 50, Target: "5678"}},
+	}, nil)
+	phs := pricehistorymocks.Service{}
+	phs.On("RecordPrices", mock.Anything, map[string]int{"1234": 10000, "5678": 10000}).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), &phs, eventsmocks.AnyPublisher())
+
+	err := s.RefreshPriceHistory(context.Background(), discount.GeneralDiscount{DiscountTypeID: discount.GENERAL, Percentage: 10})
+
+	assert.NoError(t, err)
+	phs.AssertExpectations(t)
+	// the whole catalog
+	dbmock.AssertCalled(t, "GetPage", mock.Anything, mock.Anything, mock.Anything, []database.Filter(nil))
+}
+
+func TestRefreshPriceHistory_OnlyTheTargets(t *testing.T) {
+	for name, test := range map[string]struct {
+		changed discount.GeneralDiscount
+		filter  database.Filter
+	}{
+		"category": {
+			changed: discount.GeneralDiscount{DiscountTypeID: discount.CATEGORY, Target: "2"},
+			filter:  product.NewCategoryFilter("2", "="),
+		},
+		"variant": {
+			changed: discount.GeneralDiscount{DiscountTypeID: discount.SKU, Target: "1234-42"},
+			filter:  product.NewSKUFilter([]string{"1234-42", "1234"}),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dbmock := dbmocks.Database{}
+			dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				*args.Get(1).(*[]product.Variant) = []product.Variant{{SKU: "1234-42", ParentSKU: "1234"}}
+			}).Return(nil)
+			dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, []database.Filter{test.filter}).Run(func(args mock.Arguments) {
+				*args.Get(1).(*[]product.Product) = []product.Product{{SKU: "5678", Price: 20000, CategoryID: 2}}
+			}).Return(nil)
+			phs := pricehistorymocks.Service{}
+			phs.On("RecordPrices", mock.Anything, map[string]int{"5678": 20000}).Return(nil)
+
+			s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), &phs, eventsmocks.AnyPublisher())
+
+			assert.NoError(t, s.RefreshPriceHistory(context.Background(), test.changed))
+			phs.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefreshPriceHistory_NoListPriceChanged(t *testing.T) {
+	for name, changed := range map[string]discount.GeneralDiscount{
+		"coupon only": {DiscountTypeID: discount.GENERAL, Percentage: 10, CouponOnly: true},
+		"basket":      {DiscountTypeID: discount.SPEND_THRESHOLD, Percentage: 10, MinSpend: 50000},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dbmock := dbmocks.Database{}
+			s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+			assert.NoError(t, s.RefreshPriceHistory(context.Background(), changed))
+			dbmock.AssertNotCalled(t, "GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRefreshPriceHistory_ErrorGettingProducts(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	err := s.RefreshPriceHistory(context.Background(), discount.GeneralDiscount{DiscountTypeID: discount.GENERAL})
+
+	assert.EqualError(t, err, "Failed to get products from database")
+}
+
+func TestListProducts_UpdatedAt(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 10, 0, 0, 0, time.UTC) }
+
+	p := product.Product{SKU: "1234", Name: "Test product", CategoryID: 1, Price: 11000}
+	p.UpdatedAt = day(1)
+	p.Category.UpdatedAt = day(2)
+	// the discount does not apply to the product, but could have
+	d := &discount.SkuDiscount{GeneralDiscount: discount.GeneralDiscount{ID: 1, Percentage: 10, Target: "9999"}}
+	d.UpdatedAt = day(3)
+
+	ds := discountmocks.Service{}
+	ds.On("GetApplicableDiscounts", mock.Anything).Return([]discount.Discount{d}, nil)
+
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		if h, ok := args.Get(1).(*[]product.Product); ok {
+			*h = []product.Product{p}
+		}
+	}).Return(nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, &ds, &couponmocks.Service{}, emptyInventory(), auditmocks.AnyService(), emptyPriceHistory(), eventsmocks.AnyPublisher())
+
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, day(3), result[0].UpdatedAt)
+}
+
+func TestListProducts_UpdatedAtFollowsStockAndLowestPrices(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 10, 0, 0, 0, time.UTC) }
+
+	p := product.Product{SKU: "1234", Price: 11000, Variants: []product.Variant{{SKU: "1234-42"}}}
+	p.UpdatedAt = day(1)
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]product.Product) = []product.Product{p}
+	}).Return(nil)
+
+	is := inventorymocks.Service{}
+	is.On("GetStocks", mock.Anything, mock.Anything).Return(map[string]inventory.StockLevel{
+		"1234-42": {SKU: "1234-42", Available: 2, UpdatedAt: day(3)},
+	}, nil)
+	phs := pricehistorymocks.Service{}
+	phs.On("GetLowestPrices", mock.Anything, mock.Anything).Return(map[string]pricehistory.LowestPrice{
+		"1234": {Price: 11000, ChangedAt: day(2)},
+	}, nil)
+
+	s := product.NewService(&dbmock, &loggermocks.NoopLogger{}, noDiscounts(), &couponmocks.Service{}, &is, auditmocks.AnyService(), &phs, eventsmocks.AnyPublisher())
+
+	result, err := s.ListProducts(context.Background(), product.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, day(3), result[0].UpdatedAt)
+
+	phs.ExpectedCalls = nil
+	phs.On("GetLowestPrices", mock.Anything, mock.Anything).Return(map[string]pricehistory.LowestPrice{
+		"1234-42": {Price: 11000, ChangedAt: day(4)},
+	}, nil)
+
+	result, err = s.ListProducts(context.Background(), product.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, day(4), result[0].UpdatedAt)
+}
+
+func TestListProducts_MoreProductsThanBindVariables(t *testing.T) {
+	db := sqlitetest.NewDB(t, &product.Product{}, &product.Variant{}, &category.Category{}, &discount.DiscountType{}, &discount.GeneralDiscount{},
+		&inventory.StockLevel{}, &pricehistory.CurrentPrice{}, &pricehistory.PricePeriod{})
+	l := &loggermocks.NoopLogger{}
+	ep := eventsmocks.AnyPublisher()
+	ph := pricehistory.NewService(db, l, ep, time.Now)
+	// SQLite takes 32766 bind variables at most per statement, the variants are preloaded with one per product
+	seedCatalog(t, db, ph, 40_000, 1)
+	s := product.NewService(db, l, noDiscounts(), &couponmocks.Service{}, inventory.NewService(db, l, ep), auditmocks.AnyService(), ph, ep)
+
+	products, err := s.ListProducts(context.Background(), product.ListOptions{})
+
+	assert.NoError(t, err)
+	assert.Len(t, products, 40_000)
+	assert.Equal(t, "039999", products[len(products)-1].SKU)
+	assert.NoError(t, s.RefreshPriceHistory(context.Background(), discount.GeneralDiscount{DiscountTypeID: discount.GENERAL}))
+}
+
+// BenchmarkListProducts lists catalogs of growing size from a SQLite database, along with their
+// stock and price history, mostly with SKU discounts. With the discounts indexed the time per
+// product stays flat instead of growing with the discounts.
+func BenchmarkListProducts(b *testing.B) {
+	for _, size := range []struct{ products, discounts int }{
+		{1_000, 500},
+		{10_000, 5_000},
+		{100_000, 50_000},
+	} {
+		db := sqlitetest.NewDB(b, &product.Product{}, &product.Variant{}, &category.Category{}, &discount.DiscountType{}, &discount.GeneralDiscount{},
+			&inventory.StockLevel{}, &pricehistory.CurrentPrice{}, &pricehistory.PricePeriod{})
+		l := &loggermocks.NoopLogger{}
+		ep := eventsmocks.AnyPublisher()
+		ph := pricehistory.NewService(db, l, ep, time.Now)
+		seedCatalog(b, db, ph, size.products, size.discounts)
+
+		ds := discount.NewService(db, l, auditmocks.AnyService(), ep)
+		s := product.NewService(db, l, ds, &couponmocks.Service{}, inventory.NewService(db, l, ep), auditmocks.AnyService(), ph, ep)
+
+		b.Run(fmt.Sprintf("%d_products_%d_discounts", size.products, size.discounts), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				result, err := s.ListProducts(context.Background(), product.ListOptions{})
+				if err != nil || len(result) != size.products {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// seedCatalog saves the products, in 100 categories with a discount each, the rest of the
+// discounts on SKUs, along with the stock and price of every product
+func seedCatalog(tb testing.TB, db database.Database, ph pricehistory.Service, products, discounts int) {
+	tb.Helper()
+	ctx := context.Background()
+
+	rows := []interface{}{
+		&[]discount.DiscountType{{ID: discount.CATEGORY, Type: "category"}, {ID: discount.SKU, Type: "sku"}, {ID: discount.GENERAL, Type: "general"}},
+	}
+	categories := make([]category.Category, 100)
+	for i := range categories {
+		categories[i] = category.Category{ID: i + 1, Name: fmt.Sprintf("Category %d", i+1)}
+	}
+	rows = append(rows, &categories)
+
+	all := make([]product.Product, products)
+	stock := make([]inventory.StockLevel, products)
+	prices := map[string]int{}
+	for i := range all {
+		all[i] = product.Product{SKU: fmt.Sprintf("%06d", i), Name: "Test product", CategoryID: i%100 + 1, Price: 11000}
+		stock[i] = inventory.StockLevel{SKU: all[i].SKU, Available: i % 5}
+		prices[all[i].SKU] = 11000
+	}
+	for _, chunk := range database.Chunk(all, database.MaxInValues) {
+		rows = append(rows, &chunk)
+	}
+	for _, chunk := range database.Chunk(stock, database.MaxInValues) {
+		rows = append(rows, &chunk)
+	}
+
+	general := []discount.GeneralDiscount{{ID: 1, DiscountTypeID: discount.GENERAL, Percentage: 5}}
+	for i := 1; i < discounts; i++ {
+		d := discount.GeneralDiscount{ID: i + 1, DiscountTypeID: discount.SKU, Target: fmt.Sprintf("%06d", i*2), Percentage: i%50 + 1}
+		// every category has a discount of its own
+		if i <= 100 {
+			d.DiscountTypeID = discount.CATEGORY
+			d.Target = fmt.Sprint(i)
+		}
+		general = append(general, d)
+	}
+	for _, chunk := range database.Chunk(general, database.MaxInValues) {
+		rows = append(rows, &chunk)
+	}
+
+	err := db.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, r := range rows {
+			if err := db.Save(ctx, "", r); err != nil {
+				return err
+			}
+		}
+		return ph.RecordPrices(ctx, prices)
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,290 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mytheresa/internal/config"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/metrics"
+	"mytheresa/pkg/events"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxResponseBody is how much of the answer of a receiver is read, so its connection can be reused
+const maxResponseBody = 64 << 10
+
+type dispatcher struct {
+	service Service
+	logger  logger.Logger
+	client  *http.Client
+	conf    config.EventsConfig
+
+	mu sync.Mutex
+	// workers holds the webhooks with a worker delivering their pending events, true when it was
+	// woken up since it last looked for them
+	workers map[int]bool
+}
+
+// DeliverEvents sends the events pending for every webhook, as stored by QueueDeliveries, until
+// ctx is cancelled or the bus is closed, then waits for the deliveries under way. The ones left
+// by a previous run are resumed right away, and the events of the bus only wake the workers up,
+// along with a check every OutboxPollInterval. Each webhook gets the events in the order they
+// were published, one at a time: an event is retried until it's accepted or every attempt was
+// made before the next one is sent, while a slow receiver doesn't hold the others back. An
+// event is kept pending, its attempts and next retry included, until then, so the ones not
+// delivered when ctx is cancelled are sent again by the next run.
+func DeliverEvents(ctx context.Context, bus events.Subscriber, s Service, l logger.Logger, conf config.EventsConfig) {
+	d := &dispatcher{
+		service: s,
+		logger:  l,
+		client:  newClient(conf),
+		conf:    conf,
+		workers: map[int]bool{},
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	interval := conf.OutboxPollInterval.Duration
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sub, err := bus.Subscribe(nil, "")
+		if err != nil {
+			l.Info(context.Background(), "Webhook dispatcher stopped")
+			return
+		}
+		// what was pending before subscribing, or queued while dropped for falling behind
+		d.wakeAll(ctx, &wg)
+
+		for open := true; open; {
+			select {
+			case <-ctx.Done():
+				bus.Unsubscribe(sub)
+				l.Info(context.Background(), "Webhook dispatcher stopped")
+				return
+			case <-ticker.C:
+				d.wakeAll(ctx, &wg)
+			case _, ok := <-sub.Events():
+				if !ok {
+					open = false
+					break
+				}
+				d.wakeAll(ctx, &wg)
+			}
+		}
+	}
+}
+
+// newClient sends the deliveries, refusing to connect to loopback, link-local and private
+// addresses unless they are allowed, whatever the name of the webhook resolves to
+func newClient(conf config.EventsConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !conf.WebhookAllowPrivateHosts {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refusePrivate}
+		transport.DialContext = dialer.DialContext
+		// through a proxy the address checked would be the proxy's
+		transport.Proxy = nil
+	}
+	return &http.Client{Timeout: conf.WebhookTimeout.Duration, Transport: transport}
+}
+
+// refusePrivate stops connections to the addresses webhooks can't be registered on
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+		return fmt.Errorf("refusing to connect to %s, not a public address", host)
+	}
+	return nil
+}
+
+// wakeAll has every webhook looked at for pending events
+func (d *dispatcher) wakeAll(ctx context.Context, wg *sync.WaitGroup) {
+	webhooks, err := d.service.GetWebhooks(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			d.logger.WithError(err).Error(ctx, "error getting webhooks, their pending events are delivered later")
+		}
+		return
+	}
+	for _, w := range webhooks {
+		d.wake(ctx, wg, w)
+	}
+}
+
+// wake starts a worker for the webhook when it has none, or has the one running look for pending
+// events again before stopping
+func (d *dispatcher) wake(ctx context.Context, wg *sync.WaitGroup, w Webhook) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, working := d.workers[w.ID]; working {
+		d.workers[w.ID] = true
+		return
+	}
+	d.workers[w.ID] = false
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.work(ctx, w)
+	}()
+}
+
+// idle stops the worker of the webhook, unless it was woken up since it last looked for pending
+// events and has to look again
+func (d *dispatcher) idle(webhookID int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.workers[webhookID] {
+		d.workers[webhookID] = false
+		return false
+	}
+	delete(d.workers, webhookID)
+	return true
+}
+
+// stop stops the worker of the webhook whatever happened meanwhile, the next wake up or check
+// starting another one
+func (d *dispatcher) stop(webhookID int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.workers, webhookID)
+}
+
+// work delivers the events pending for the webhook in turn, until there are none left
+func (d *dispatcher) work(ctx context.Context, w Webhook) {
+	for {
+		pending, found, err := d.service.NextPendingDelivery(ctx, w.ID)
+		if err != nil {
+			d.stop(w.ID)
+			return
+		}
+		if !found {
+			if d.idle(w.ID) {
+				return
+			}
+			continue
+		}
+		if wait := time.Until(pending.NextAttemptAt); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				d.stop(w.ID)
+				return
+			case <-timer.C:
+			}
+			// looked up again once due, the webhook may have been deleted meanwhile
+			continue
+		}
+		if !d.deliver(ctx, w, pending) {
+			d.stop(w.ID)
+			return
+		}
+	}
+}
+
+// deliver makes the next attempt of the pending event, then removes it when it's accepted or out
+// of attempts, or saves when to retry, waiting twice as long after each failure. It returns false
+// when the worker must stop, ctx being cancelled or the database failing, the event being left
+// as it was to be resumed later.
+func (d *dispatcher) deliver(ctx context.Context, w Webhook, pending PendingDelivery) bool {
+	delivered := d.attempt(ctx, w, pending, pending.Attempts+1)
+	if ctx.Err() != nil {
+		return false
+	}
+	pending.Attempts++
+
+	if !delivered && pending.Attempts < d.conf.WebhookMaxAttempts {
+		pending.NextAttemptAt = time.Now().UTC().Add(d.backoff(pending.Attempts))
+		return d.service.SavePendingDelivery(ctx, pending) == nil
+	}
+	if !delivered {
+		d.logger.WithField("webhook", w.ID).WithField("event", pending.EventID).
+			Warn(ctx, "Giving up delivering event to webhook")
+	}
+	// sent again when not removed, receivers can tell by its idempotency key
+	return d.service.RemovePendingDelivery(ctx, pending.ID) == nil
+}
+
+// backoff is the wait after the failed attempts, WebhookBackoff doubled after every one but the
+// first, up to WebhookMaxBackoff
+func (d *dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.conf.WebhookBackoff.Duration
+	for i := 1; i < attempts && backoff < d.conf.WebhookMaxBackoff.Duration; i++ {
+		backoff = 2 * backoff
+	}
+	return min(backoff, d.conf.WebhookMaxBackoff.Duration)
+}
+
+// attempt sends the event to the webhook once and records how it went, unless ctx was cancelled
+// meanwhile as that's not the receiver's failure
+func (d *dispatcher) attempt(ctx context.Context, w Webhook, pending PendingDelivery, attempt int) bool {
+	start := time.Now()
+	delivery := Delivery{
+		WebhookID: w.ID,
+		EventID:   pending.EventID,
+		EventType: pending.EventType,
+		Attempt:   attempt,
+		CreatedAt: start.UTC(),
+	}
+
+	status, err := d.post(ctx, w, pending, start.Unix())
+	delivery.DurationMS = time.Since(start).Milliseconds()
+	delivery.StatusCode = status
+	delivery.Delivered = err == nil
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		delivery.Error = err.Error()
+	}
+
+	metrics.CountWebhookDelivery(delivery.Delivered)
+	if err := d.service.RecordDelivery(ctx, delivery); err != nil {
+		d.logger.WithField("webhook", w.ID).WithField("event", pending.EventID).WithError(err).
+			Error(ctx, "error recording webhook delivery")
+	}
+	return delivery.Delivered
+}
+
+// post sends the signed event and returns the status answered, an error unless it's a 2xx
+func (d *dispatcher) post(ctx context.Context, w Webhook, pending PendingDelivery, timestamp int64) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(pending.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, pending.EventID)
+	req.Header.Set(IdempotencyKeyHeader, pending.IdempotencyKey)
+	req.Header.Set(EventTypeHeader, string(pending.EventType))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, "sha256="+Sign(w.Secret, timestamp, pending.Body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBody))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook answered %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
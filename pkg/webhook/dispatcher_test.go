@@ -0,0 +1,341 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mytheresa/internal/config"
+	"mytheresa/internal/database"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/events"
+	"mytheresa/pkg/webhook"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fastRetries = config.EventsConfig{
+	WebhookTimeout:     config.Duration{Duration: time.Second},
+	WebhookMaxAttempts: 3,
+	WebhookBackoff:     config.Duration{Duration: time.Millisecond},
+	WebhookMaxBackoff:  config.Duration{Duration: 2 * time.Millisecond},
+	// the receivers listen on loopback
+	WebhookAllowPrivateHosts: true,
+}
+
+// receiver answers the deliveries with the statuses given in turn, then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	got      chan struct{}
+}
+
+func newReceiver(statuses ...int) *receiver {
+	return &receiver{statuses: statuses, got: make(chan struct{}, 10)}
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	rc.mu.Unlock()
+
+	w.WriteHeader(status)
+	rc.got <- struct{}{}
+}
+
+// wait blocks until the receiver got n more deliveries
+func (rc *receiver) wait(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-rc.got:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d deliveries out of %d", i, n)
+		}
+	}
+}
+
+// signalling tells when the dispatcher subscribed, as it only gets the events published after
+type signalling struct {
+	*events.Bus
+	subscribed chan struct{}
+}
+
+func (s signalling) Subscribe(types []events.Type, after string) (*events.Subscription, error) {
+	sub, err := s.Bus.Subscribe(types, after)
+	select {
+	case s.subscribed <- struct{}{}:
+	default:
+	}
+	return sub, err
+}
+
+// newBus stores the deliveries of the events relayed for the webhooks of s
+func newBus(db database.Database, s webhook.Service) *events.Bus {
+	bus := events.NewBus(db, &loggermocks.NoopLogger{}, time.Now)
+	bus.AddRelayListener(s.QueueDeliveries)
+	return bus
+}
+
+// startDispatcher delivers the events of the bus until the test ends
+func startDispatcher(t *testing.T, bus *events.Bus, s webhook.Service) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sb := signalling{Bus: bus, subscribed: make(chan struct{}, 1)}
+	done := make(chan struct{})
+	go func() {
+		webhook.DeliverEvents(ctx, sb, s, &loggermocks.NoopLogger{}, fastRetries)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	<-sb.subscribed
+}
+
+func TestDeliverEvents_SignedAndRetried(t *testing.T) {
+	db := newSQLiteDB(t)
+	s := webhook.NewService(db, &loggermocks.NoopLogger{}, true)
+	bus := newBus(db, s)
+	rc := newReceiver(http.StatusServiceUnavailable)
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	ctx := context.Background()
+	w, err := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: srv.URL, Types: []events.Type{events.PriceChanged}})
+	assert.NoError(t, err)
+	startDispatcher(t, bus, s)
+
+	assert.NoError(t, bus.Publish(ctx, events.PriceChanged, map[string]int{"price": 100}))
+	_, err = bus.Relay(ctx)
+	assert.NoError(t, err)
+	rc.wait(t, 2)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	assert.Len(t, rc.requests, 2)
+	r, body := rc.requests[1], rc.bodies[1]
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, "price.changed", r.Header.Get(webhook.EventTypeHeader))
+	timestamp, err := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256="+webhook.Sign(w.Secret, timestamp, body), r.Header.Get(webhook.SignatureHeader))
+	assert.Contains(t, string(body), `"data":{"price":100}`)
+	// the same event on every attempt
+	assert.Equal(t, rc.requests[0].Header.Get(webhook.EventIDHeader), r.Header.Get(webhook.EventIDHeader))
+	assert.NotEmpty(t, r.Header.Get(webhook.IdempotencyKeyHeader))
+	assert.Equal(t, rc.requests[0].Header.Get(webhook.IdempotencyKeyHeader), r.Header.Get(webhook.IdempotencyKeyHeader))
+
+	assert.Eventually(t, func() bool {
+		deliveries, _ := s.GetDeliveries(ctx, w.ID)
+		return len(deliveries) == 2
+	}, time.Second, time.Millisecond)
+	deliveries, _ := s.GetDeliveries(ctx, w.ID)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].StatusCode)
+	assert.Equal(t, "webhook answered 503 Service Unavailable", deliveries[0].Error)
+	assert.False(t, deliveries[0].Delivered)
+	assert.Equal(t, 2, deliveries[1].Attempt)
+	assert.True(t, deliveries[1].Delivered)
+}
+
+func TestDeliverEvents_InOrder(t *testing.T) {
+	db := newSQLiteDB(t)
+	s := webhook.NewService(db, &loggermocks.NoopLogger{}, true)
+	bus := newBus(db, s)
+	// the first event needs a retry, the next ones still wait for it
+	rc := newReceiver(http.StatusServiceUnavailable)
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	ctx := context.Background()
+	_, err := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: srv.URL, Types: []events.Type{events.PriceChanged}})
+	assert.NoError(t, err)
+	startDispatcher(t, bus, s)
+
+	for price := 1; price <= 3; price++ {
+		assert.NoError(t, bus.Publish(ctx, events.PriceChanged, map[string]int{"price": price}))
+	}
+	_, err = bus.Relay(ctx)
+	assert.NoError(t, err)
+	rc.wait(t, 4)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var prices []string
+	for _, body := range rc.bodies {
+		var event struct {
+			Data struct {
+				Price int `json:"price"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &event))
+		prices = append(prices, strconv.Itoa(event.Data.Price))
+	}
+	assert.Equal(t, []string{"1", "1", "2", "3"}, prices)
+}
+
+func TestDeliverEvents_ResumesTheEventsLeftWhenStopped(t *testing.T) {
+	db := newSQLiteDB(t)
+	s := webhook.NewService(db, &loggermocks.NoopLogger{}, true)
+	bus := newBus(db, s)
+	rc := newReceiver(http.StatusServiceUnavailable)
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	ctx := context.Background()
+	w, _ := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: srv.URL, Types: []events.Type{events.PriceChanged}})
+	conf := fastRetries
+	conf.WebhookBackoff = config.Duration{Duration: time.Hour}
+	conf.WebhookMaxBackoff = config.Duration{Duration: time.Hour}
+	dispatchCtx, cancel := context.WithCancel(ctx)
+	sb := signalling{Bus: bus, subscribed: make(chan struct{}, 1)}
+	done := make(chan struct{})
+	go func() {
+		webhook.DeliverEvents(dispatchCtx, sb, s, &loggermocks.NoopLogger{}, conf)
+		close(done)
+	}()
+	<-sb.subscribed
+
+	for price := 1; price <= 2; price++ {
+		assert.NoError(t, bus.Publish(ctx, events.PriceChanged, map[string]int{"price": price}))
+	}
+	_, err := bus.Relay(ctx)
+	assert.NoError(t, err)
+	// the first event failed and waits an hour for its retry
+	rc.wait(t, 1)
+	var pending webhook.PendingDelivery
+	assert.Eventually(t, func() bool {
+		pending, _, _ = s.NextPendingDelivery(ctx, w.ID)
+		return pending.Attempts == 1
+	}, time.Second, time.Millisecond)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), pending.NextAttemptAt, time.Minute)
+	cancel()
+	<-done
+
+	// both events are still pending after the restart, the first one resumed once due
+	pending.NextAttemptAt = time.Now().UTC()
+	assert.NoError(t, s.SavePendingDelivery(ctx, pending))
+	startDispatcher(t, newBus(db, s), s)
+	rc.wait(t, 2)
+
+	assert.Eventually(t, func() bool {
+		_, found, _ := s.NextPendingDelivery(ctx, w.ID)
+		return !found
+	}, time.Second, time.Millisecond)
+	deliveries, _ := s.GetDeliveries(ctx, w.ID)
+	assert.Len(t, deliveries, 3)
+	assert.Equal(t, deliveries[0].EventID, deliveries[1].EventID)
+	assert.Equal(t, 2, deliveries[1].Attempt)
+	assert.True(t, deliveries[1].Delivered)
+	assert.NotEqual(t, deliveries[1].EventID, deliveries[2].EventID)
+	assert.Equal(t, 1, deliveries[2].Attempt)
+	assert.True(t, deliveries[2].Delivered)
+}
+
+func TestDeliverEvents_GivesUp(t *testing.T) {
+	db := newSQLiteDB(t)
+	s := webhook.NewService(db, &loggermocks.NoopLogger{}, true)
+	bus := newBus(db, s)
+	rc := newReceiver(http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	ctx := context.Background()
+	w, _ := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: srv.URL, Types: []events.Type{events.DiscountCreated}})
+	startDispatcher(t, bus, s)
+
+	assert.NoError(t, bus.Publish(ctx, events.DiscountCreated, map[string]int{"id": 1}))
+	_, err := bus.Relay(ctx)
+	assert.NoError(t, err)
+	rc.wait(t, 3)
+
+	assert.Eventually(t, func() bool {
+		deliveries, _ := s.GetDeliveries(ctx, w.ID)
+		_, pending, _ := s.NextPendingDelivery(ctx, w.ID)
+		return len(deliveries) == 3 && !pending
+	}, time.Second, time.Millisecond)
+	// no fourth attempt
+	select {
+	case <-rc.got:
+		t.Fatal("delivered after the last attempt")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestDeliverEvents_RefusesPrivateAddresses(t *testing.T) {
+	db := newSQLiteDB(t)
+	s := webhook.NewService(db, &loggermocks.NoopLogger{}, true)
+	bus := newBus(db, s)
+	rc := newReceiver()
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	ctx := context.Background()
+	// registered by name, which only resolves to a loopback address when delivering
+	u, _ := url.Parse(srv.URL)
+	w, err := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: "http://localhost:" + u.Port()})
+	assert.NoError(t, err)
+	conf := fastRetries
+	conf.WebhookMaxAttempts = 1
+	conf.WebhookAllowPrivateHosts = false
+	dispatchCtx, cancel := context.WithCancel(ctx)
+	sb := signalling{Bus: bus, subscribed: make(chan struct{}, 1)}
+	done := make(chan struct{})
+	go func() {
+		webhook.DeliverEvents(dispatchCtx, sb, s, &loggermocks.NoopLogger{}, conf)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	<-sb.subscribed
+
+	assert.NoError(t, bus.Publish(ctx, events.PriceChanged, map[string]int{"price": 1}))
+	_, err = bus.Relay(ctx)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		deliveries, _ := s.GetDeliveries(ctx, w.ID)
+		return len(deliveries) == 1
+	}, time.Second, time.Millisecond)
+	deliveries, _ := s.GetDeliveries(ctx, w.ID)
+	assert.False(t, deliveries[0].Delivered)
+	assert.Contains(t, deliveries[0].Error, "not a public address")
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	assert.Empty(t, rc.requests)
+}
+
+func TestDeliverEvents_StopsWhenBusCloses(t *testing.T) {
+	db := newSQLiteDB(t)
+	sb := signalling{Bus: events.NewBus(db, &loggermocks.NoopLogger{}, time.Now), subscribed: make(chan struct{}, 1)}
+	done := make(chan struct{})
+	go func() {
+		webhook.DeliverEvents(context.Background(), sb, webhook.NewService(db, &loggermocks.NoopLogger{}, true), &loggermocks.NoopLogger{}, fastRetries)
+		close(done)
+	}()
+
+	<-sb.subscribed
+	sb.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatcher didn't stop once the bus was closed")
+	}
+}
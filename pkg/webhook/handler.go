@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler interface {
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	GetWebhooks(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	GetDeliveries(w http.ResponseWriter, r *http.Request)
+}
+
+type handler struct {
+	service Service
+	logger  logger.Logger
+}
+
+func NewHandler(service Service, logger logger.Logger) Handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// CreateWebhook godoc
+// @Summary Register a webhook
+// @Description Register a URL getting the catalog changes as POST requests signed with the secret of the webhook,
+// @Description only shown in this response. The X-Webhook-Signature header is "sha256=" and the hex HMAC-SHA256
+// @Description of the X-Webhook-Timestamp header, a dot and the body. Deliveries answered without a 2xx are retried.
+// @Description Events are sent in the order they were published. URLs on loopback, link-local or private addresses are refused.
+// @Accept  json
+// @Produce  json
+// @Param webhook body WebhookRequest true "Webhook details"
+// @Success 201 {object} WebhookResponse
+// @Failure 400 {object} apierror.ApiError "Wrong body"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/webhook [post]
+func (h *handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var webhook WebhookRequest
+	err := json.NewDecoder(r.Body).Decode(&webhook)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error decoding request while trying to create webhook")
+		response.RespondWithError(w, apierror.BadRequest("Wrong body"))
+		return
+	}
+
+	created, err := h.service.CreateWebhook(ctx, webhook)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error creating webhook")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusCreated, created.ToWebhookResponse(true))
+}
+
+// GetWebhooks godoc
+// @Summary List the webhooks
+// @Description List the registered webhooks, without their secrets
+// @Produce  json
+// @Success 200 {array} WebhookResponse
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/webhooks [get]
+func (h *handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	webhooks, err := h.service.GetWebhooks(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error(ctx, "Error getting webhooks")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	res := []WebhookResponse{}
+	for _, webhook := range webhooks {
+		res = append(res, webhook.ToWebhookResponse(false))
+	}
+	response.RespondWithData(w, http.StatusOK, res)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Stop sending events to a webhook, its delivery log is kept
+// @Produce  json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} WebhookResponse
+// @Failure 400 {object} apierror.ApiError "Wrong webhook ID"
+// @Failure 404 {object} apierror.ApiError "Webhook not found"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/webhook/{id} [delete]
+func (h *handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		response.RespondWithError(w, apierror.BadRequest("Wrong webhook ID"))
+		return
+	}
+
+	deleted, err := h.service.DeleteWebhook(ctx, id)
+	if err != nil {
+		h.logger.WithField("id", id).WithError(err).Error(ctx, "Error deleting webhook")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, deleted.ToWebhookResponse(false))
+}
+
+// GetDeliveries godoc
+// @Summary List the deliveries of a webhook
+// @Description List every attempt to send an event to a webhook, oldest first, with the answer of the receiver
+// @Produce  json
+// @Param id path int true "Webhook ID"
+// @Success 200 {array} Delivery
+// @Failure 400 {object} apierror.ApiError "Wrong webhook ID"
+// @Failure 404 {object} apierror.ApiError "Webhook not found"
+// @Failure 500 {object} apierror.ApiError "Internal server error"
+// @Failure 401 {object} apierror.ApiError "Authentication required"
+// @Failure 403 {object} apierror.ApiError "Missing permission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /v1/webhook/{id}/deliveries [get]
+func (h *handler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		response.RespondWithError(w, apierror.BadRequest("Wrong webhook ID"))
+		return
+	}
+
+	deliveries, err := h.service.GetDeliveries(ctx, id)
+	if err != nil {
+		h.logger.WithField("id", id).WithError(err).Error(ctx, "Error getting webhook deliveries")
+		response.RespondWithError(w, err)
+		return
+	}
+
+	response.RespondWithData(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,93 @@
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/events"
+	"mytheresa/pkg/webhook"
+	webhookmocks "mytheresa/pkg/webhook/mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var registered = webhook.Webhook{ID: 1, URL: "https://example.com/hooks", Secret: "0123456789abcdef", Types: "price.changed"}
+
+func TestNewHandler(t *testing.T) {
+	h := webhook.NewHandler(&webhookmocks.Service{}, &loggermocks.NoopLogger{})
+
+	assert.NotNil(t, h)
+}
+
+func TestHandlerCreateWebhook_ShowsSecret(t *testing.T) {
+	req := webhook.WebhookRequest{URL: registered.URL, Types: []events.Type{events.PriceChanged}}
+	ws := webhookmocks.Service{}
+	ws.On("CreateWebhook", mock.Anything, req).Return(registered, nil)
+	h := webhook.NewHandler(&ws, &loggermocks.NoopLogger{})
+
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	h.CreateWebhook(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var res webhook.WebhookResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.Equal(t, registered.ToWebhookResponse(true), res)
+}
+
+func TestHandlerCreateWebhook_WrongBody(t *testing.T) {
+	h := webhook.NewHandler(&webhookmocks.Service{}, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte("invalid body")))
+	w := httptest.NewRecorder()
+
+	h.CreateWebhook(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerGetWebhooks_HidesSecrets(t *testing.T) {
+	ws := webhookmocks.Service{}
+	ws.On("GetWebhooks", mock.Anything).Return([]webhook.Webhook{registered}, nil)
+	h := webhook.NewHandler(&ws, &loggermocks.NoopLogger{})
+
+	r := httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+	w := httptest.NewRecorder()
+
+	h.GetWebhooks(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), registered.Secret)
+}
+
+func TestHandlerDeleteWebhook_NotFound(t *testing.T) {
+	ws := webhookmocks.Service{}
+	ws.On("DeleteWebhook", mock.Anything, 2).Return(webhook.Webhook{}, apierror.NotFound("Webhook not found"))
+	h := webhook.NewHandler(&ws, &loggermocks.NoopLogger{})
+
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/webhook/2", nil), map[string]string{"id": "2"})
+	w := httptest.NewRecorder()
+
+	h.DeleteWebhook(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandlerGetDeliveries_WrongID(t *testing.T) {
+	h := webhook.NewHandler(&webhookmocks.Service{}, &loggermocks.NoopLogger{})
+
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/webhook/first/deliveries", nil), map[string]string{"id": "first"})
+	w := httptest.NewRecorder()
+
+	h.GetDeliveries(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
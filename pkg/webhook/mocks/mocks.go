@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"context"
+	"mytheresa/pkg/events"
+	"mytheresa/pkg/webhook"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type Service struct {
+	mock.Mock
+}
+
+func (s *Service) CreateWebhook(ctx context.Context, req webhook.WebhookRequest) (webhook.Webhook, error) {
+	args := s.Called(ctx, req)
+	return args.Get(0).(webhook.Webhook), args.Error(1)
+}
+
+func (s *Service) GetWebhooks(ctx context.Context) ([]webhook.Webhook, error) {
+	args := s.Called(ctx)
+	webhooks, _ := args.Get(0).([]webhook.Webhook)
+	return webhooks, args.Error(1)
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, id int) (webhook.Webhook, error) {
+	args := s.Called(ctx, id)
+	return args.Get(0).(webhook.Webhook), args.Error(1)
+}
+
+func (s *Service) GetDeliveries(ctx context.Context, webhookID int) ([]webhook.Delivery, error) {
+	args := s.Called(ctx, webhookID)
+	deliveries, _ := args.Get(0).([]webhook.Delivery)
+	return deliveries, args.Error(1)
+}
+
+func (s *Service) RecordDelivery(ctx context.Context, d webhook.Delivery) error {
+	args := s.Called(ctx, d)
+	return args.Error(0)
+}
+
+func (s *Service) QueueDeliveries(ctx context.Context, relayed []events.Event) error {
+	args := s.Called(ctx, relayed)
+	return args.Error(0)
+}
+
+func (s *Service) NextPendingDelivery(ctx context.Context, webhookID int) (webhook.PendingDelivery, bool, error) {
+	args := s.Called(ctx, webhookID)
+	return args.Get(0).(webhook.PendingDelivery), args.Bool(1), args.Error(2)
+}
+
+func (s *Service) SavePendingDelivery(ctx context.Context, p webhook.PendingDelivery) error {
+	args := s.Called(ctx, p)
+	return args.Error(0)
+}
+
+func (s *Service) RemovePendingDelivery(ctx context.Context, id int) error {
+	args := s.Called(ctx, id)
+	return args.Error(0)
+}
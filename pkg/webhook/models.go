@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/pkg/events"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent along with every delivery
+const (
+	EventIDHeader   = "X-Webhook-Event-Id"
+	EventTypeHeader = "X-Webhook-Event"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+	// IdempotencyKeyHeader is the same every time an event is sent, so receivers can skip it
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// minSecretLength keeps the signatures from being guessed
+const minSecretLength = 16
+
+// Webhook gets the events of its types as signed POST requests
+type Webhook struct {
+	ID     int    `gorm:"primaryKey"`
+	URL    string `gorm:"not null"`
+	Secret string `gorm:"not null"`
+	// Types are comma separated, every type of event when empty
+	Types     string    `gorm:"not null;default:''"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// Wants tells whether events of the type are sent to the webhook
+func (w Webhook) Wants(t events.Type) bool {
+	if w.Types == "" {
+		return true
+	}
+	for _, wanted := range strings.Split(w.Types, ",") {
+		if events.Type(wanted) == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Webhook) GetIdentifier() string {
+	return strconv.Itoa(w.ID)
+}
+
+// ToWebhookResponse hides the secret unless withSecret, it's only shown once on creation
+func (w Webhook) ToWebhookResponse(withSecret bool) WebhookResponse {
+	res := WebhookResponse{
+		ID:        w.ID,
+		URL:       w.URL,
+		Types:     []events.Type{},
+		CreatedAt: w.CreatedAt,
+	}
+	if w.Types != "" {
+		for _, t := range strings.Split(w.Types, ",") {
+			res.Types = append(res.Types, events.Type(t))
+		}
+	}
+	if withSecret {
+		res.Secret = w.Secret
+	}
+	return res
+}
+
+// WebhookRequest represents the body for registering a webhook
+// @Description WebhookRequest registers a URL getting the events of the types, every type when none is given.
+// @Description A secret signing the payloads is generated when none is given.
+type WebhookRequest struct {
+	URL    string        `json:"url" example:"https://search.example.com/hooks/catalog"`
+	Secret string        `json:"secret,omitempty" example:"2f1c0b8e9a7d4c3b2a1f0e9d8c7b6a59"`
+	Types  []events.Type `json:"types,omitempty" example:"product.created,price.changed"`
+}
+
+// Validate checks the URL can be sent events, the types exist and the secret is long enough.
+// URLs on loopback, link-local or private addresses are refused unless allowPrivateHosts, so
+// webhooks can't reach the services next to the server.
+func (r WebhookRequest) Validate(allowPrivateHosts bool) error {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apierror.BadRequest("url must be an absolute http or https URL")
+	}
+	if !allowPrivateHosts && privateHost(u.Hostname()) {
+		return apierror.BadRequest("url must not be on a loopback, link-local or private address")
+	}
+	var types []string
+	for _, t := range r.Types {
+		types = append(types, string(t))
+	}
+	if _, err := events.ParseTypes(strings.Join(types, ",")); err != nil {
+		return apierror.BadRequest(err.Error())
+	}
+	if r.Secret != "" && len(r.Secret) < minSecretLength {
+		return apierror.BadRequest(fmt.Sprintf("secret must have at least %d characters", minSecretLength))
+	}
+	return nil
+}
+
+// privateHost tells whether the host is localhost or an address not reachable from the internet.
+// Names resolving to such addresses are refused when delivering, see refusePrivate.
+func privateHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && privateIP(ip)
+}
+
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// WebhookResponse represents a registered webhook
+// @Description WebhookResponse is a registered webhook, its secret only shown when created
+type WebhookResponse struct {
+	ID        int           `json:"id" example:"1"`
+	URL       string        `json:"url" example:"https://search.example.com/hooks/catalog"`
+	Types     []events.Type `json:"types" example:"product.created,price.changed"`
+	Secret    string        `json:"secret,omitempty" example:"2f1c0b8e9a7d4c3b2a1f0e9d8c7b6a59"`
+	CreatedAt time.Time     `json:"created_at" example:"2025-01-02T15:04:05Z"`
+}
+
+// Delivery is an attempt to send an event to a webhook
+type Delivery struct {
+	ID        int         `gorm:"primaryKey" json:"id" example:"1"`
+	WebhookID int         `gorm:"not null;index" json:"webhook_id" example:"1"`
+	EventID   string      `gorm:"not null" json:"event_id" example:"42"`
+	EventType events.Type `gorm:"not null" json:"event_type" example:"price.changed"`
+	Attempt   int         `gorm:"not null" json:"attempt" example:"1"`
+	// StatusCode is the answer of the receiver, 0 when there was none
+	StatusCode int    `json:"status_code" example:"200"`
+	Error      string `json:"error,omitempty" example:"context deadline exceeded"`
+	// Delivered is set when the receiver answered with a 2xx
+	Delivered  bool      `gorm:"not null" json:"delivered" example:"true"`
+	DurationMS int64     `json:"duration_ms" example:"12"`
+	CreatedAt  time.Time `gorm:"not null" json:"created_at" example:"2025-01-02T15:04:05Z"`
+}
+
+func (d *Delivery) GetIdentifier() string {
+	return strconv.Itoa(d.ID)
+}
+
+// PendingDelivery is an event still to be sent to a webhook, stored along with the removal of the
+// event from the outbox and kept until the event is accepted or out of attempts, so deliveries
+// under way or waiting for a retry are resumed after a restart
+type PendingDelivery struct {
+	ID             int         `gorm:"primaryKey"`
+	WebhookID      int         `gorm:"not null;index"`
+	EventID        string      `gorm:"not null"`
+	EventType      events.Type `gorm:"not null"`
+	IdempotencyKey string      `gorm:"not null"`
+	// Body is the event as sent, the same on every attempt
+	Body []byte `gorm:"not null"`
+	// Attempts is how many times the event was sent already
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null"`
+}
+
+func (p *PendingDelivery) GetIdentifier() string {
+	return strconv.Itoa(p.ID)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp and the body joined by a dot, sent
+// as "sha256=<signature>" in the X-Webhook-Signature header. Receivers compute it again with
+// the secret of the webhook and the X-Webhook-Timestamp header to check where it comes from.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookFilter struct {
+	field   string
+	Value   interface{}
+	Operand string
+}
+
+func (f *webhookFilter) GetColumnName() string {
+	return f.field
+}
+
+func (f *webhookFilter) GetValue() interface{} {
+	return f.Value
+}
+
+func (f *webhookFilter) GetOperand() string {
+	return f.Operand
+}
+
+func NewIDFilter(id int) database.Filter {
+	return &webhookFilter{
+		field:   "id",
+		Value:   id,
+		Operand: "=",
+	}
+}
+
+func NewWebhookIDFilter(id int) database.Filter {
+	return &webhookFilter{
+		field:   "webhook_id",
+		Value:   id,
+		Operand: "=",
+	}
+}
@@ -0,0 +1,106 @@
+package webhook_test
+
+import (
+	"mytheresa/internal/apierror"
+	"mytheresa/pkg/events"
+	"mytheresa/pkg/webhook"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookRequest_Validate(t *testing.T) {
+	tests := map[string]struct {
+		req          webhook.WebhookRequest
+		allowPrivate bool
+		err          string
+	}{
+		"valid": {
+			req: webhook.WebhookRequest{URL: "https://example.com/hooks", Types: []events.Type{events.PriceChanged}},
+		},
+		"valid with secret": {
+			req: webhook.WebhookRequest{URL: "http://hooks.example.com:9000", Secret: "0123456789abcdef"},
+		},
+		"private host allowed": {
+			req:          webhook.WebhookRequest{URL: "http://localhost:9000"},
+			allowPrivate: true,
+		},
+		"localhost": {
+			req: webhook.WebhookRequest{URL: "http://localhost:9000"},
+			err: "url must not be on a loopback, link-local or private address",
+		},
+		"loopback": {
+			req: webhook.WebhookRequest{URL: "http://127.0.0.1:8080/v1/products"},
+			err: "url must not be on a loopback, link-local or private address",
+		},
+		"ipv6 loopback": {
+			req: webhook.WebhookRequest{URL: "http://[::1]/hooks"},
+			err: "url must not be on a loopback, link-local or private address",
+		},
+		"link-local": {
+			req: webhook.WebhookRequest{URL: "http://169.254.169.254/latest/meta-data"},
+			err: "url must not be on a loopback, link-local or private address",
+		},
+		"private": {
+			req: webhook.WebhookRequest{URL: "https://10.0.0.12/hooks"},
+			err: "url must not be on a loopback, link-local or private address",
+		},
+		"unspecified": {
+			req: webhook.WebhookRequest{URL: "http://0.0.0.0:8080"},
+			err: "url must not be on a loopback, link-local or private address",
+		},
+		"relative url": {
+			req: webhook.WebhookRequest{URL: "/hooks"},
+			err: "url must be an absolute http or https URL",
+		},
+		"other scheme": {
+			req: webhook.WebhookRequest{URL: "ftp://example.com/hooks"},
+			err: "url must be an absolute http or https URL",
+		},
+		"unknown type": {
+			req: webhook.WebhookRequest{URL: "https://example.com/hooks", Types: []events.Type{"product.deleted"}},
+			err: "unknown event type product.deleted",
+		},
+		"short secret": {
+			req: webhook.WebhookRequest{URL: "https://example.com/hooks", Secret: "secret"},
+			err: "secret must have at least 16 characters",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.req.Validate(tt.allowPrivate)
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, apierror.BadRequest(tt.err), err)
+		})
+	}
+}
+
+func TestWebhook_Wants(t *testing.T) {
+	all := webhook.Webhook{}
+	prices := webhook.Webhook{Types: "price.changed,discount.created"}
+
+	assert.True(t, all.Wants(events.ProductCreated))
+	assert.True(t, prices.Wants(events.DiscountCreated))
+	assert.False(t, prices.Wants(events.ProductCreated))
+}
+
+func TestWebhook_ToWebhookResponse(t *testing.T) {
+	w := webhook.Webhook{ID: 1, URL: "https://example.com/hooks", Secret: "0123456789abcdef", Types: "price.changed"}
+
+	assert.Equal(t, "0123456789abcdef", w.ToWebhookResponse(true).Secret)
+	res := w.ToWebhookResponse(false)
+	assert.Empty(t, res.Secret)
+	assert.Equal(t, []events.Type{events.PriceChanged}, res.Types)
+	assert.Equal(t, []events.Type{}, webhook.Webhook{}.ToWebhookResponse(false).Types)
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac 0123456789abcdef
+	assert.Equal(t, "d5f5834972cbc6cf5590800c46ccaa0cd6c16f19c0c73dbdf9b4c56390cbc2a3",
+		webhook.Sign("0123456789abcdef", 1700000000, []byte(`{"id":"1"}`)))
+}
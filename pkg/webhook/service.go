@@ -0,0 +1,242 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	"mytheresa/internal/logger"
+	"mytheresa/internal/tracing"
+	"mytheresa/pkg/events"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Service interface {
+	CreateWebhook(ctx context.Context, req WebhookRequest) (Webhook, error)
+	GetWebhooks(ctx context.Context) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) (Webhook, error)
+	GetDeliveries(ctx context.Context, webhookID int) ([]Delivery, error)
+	RecordDelivery(ctx context.Context, d Delivery) error
+	// QueueDeliveries stores the events to be sent to every webhook wanting them, meant as the
+	// events.RelayListener of the bus so they're stored along with their removal from the outbox
+	QueueDeliveries(ctx context.Context, relayed []events.Event) error
+	// NextPendingDelivery returns the oldest event still to be sent to the webhook, false when
+	// there's none
+	NextPendingDelivery(ctx context.Context, webhookID int) (PendingDelivery, bool, error)
+	SavePendingDelivery(ctx context.Context, p PendingDelivery) error
+	RemovePendingDelivery(ctx context.Context, id int) error
+}
+
+type service struct {
+	db                database.Database
+	logger            logger.Logger
+	allowPrivateHosts bool
+}
+
+// NewService registers the webhooks, the ones on loopback, link-local or private addresses
+// only when allowPrivateHosts
+func NewService(db database.Database, logger logger.Logger, allowPrivateHosts bool) Service {
+	return &service{
+		db:                db,
+		logger:            logger,
+		allowPrivateHosts: allowPrivateHosts,
+	}
+}
+
+// CreateWebhook registers the webhook, generating its secret when the request has none
+func (s *service) CreateWebhook(ctx context.Context, req WebhookRequest) (Webhook, error) {
+	ctx, span := tracing.Start(ctx, "webhook.CreateWebhook")
+	defer span.End()
+
+	if err := req.Validate(s.allowPrivateHosts); err != nil {
+		return Webhook{}, err
+	}
+
+	webhook := Webhook{URL: req.URL, Secret: req.Secret, CreatedAt: time.Now().UTC()}
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			s.logger.WithError(err).Error(ctx, "error generating webhook secret")
+			return Webhook{}, apierror.InternalServerError("error creating webhook")
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+	var types []string
+	for _, t := range req.Types {
+		types = append(types, string(t))
+	}
+	webhook.Types = strings.Join(types, ",")
+
+	if err := s.db.Save(ctx, webhook.GetIdentifier(), &webhook); err != nil {
+		s.logger.WithError(err).Error(ctx, "error creating webhook")
+		return Webhook{}, apierror.InternalServerError("error creating webhook")
+	}
+	return webhook, nil
+}
+
+func (s *service) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	ctx, span := tracing.Start(ctx, "webhook.GetWebhooks")
+	defer span.End()
+
+	webhooks := []Webhook{}
+	if err := s.db.GetWithFilters(ctx, &webhooks); err != nil {
+		s.logger.WithError(err).Error(ctx, "error getting webhooks")
+		return nil, apierror.InternalServerError("error getting webhooks")
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook stops the deliveries to the webhook, dropping the ones pending, its delivery log
+// is kept
+func (s *service) DeleteWebhook(ctx context.Context, id int) (Webhook, error) {
+	ctx, span := tracing.Start(ctx, "webhook.DeleteWebhook")
+	defer span.End()
+
+	webhook, err := s.getWebhook(ctx, id)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		affected, err := s.db.Delete(ctx, &Webhook{}, NewIDFilter(id))
+		if err != nil {
+			s.logger.WithField("id", id).WithError(err).Error(ctx, "error deleting webhook")
+			return apierror.InternalServerError("error deleting webhook")
+		}
+		if affected == 0 {
+			return apierror.NotFound("Webhook not found")
+		}
+		if _, err := s.db.Delete(ctx, &PendingDelivery{}, NewWebhookIDFilter(id)); err != nil {
+			s.logger.WithField("id", id).WithError(err).Error(ctx, "error deleting pending webhook deliveries")
+			return apierror.InternalServerError("error deleting webhook")
+		}
+		return nil
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// GetDeliveries returns the delivery attempts to a webhook, oldest first
+func (s *service) GetDeliveries(ctx context.Context, webhookID int) ([]Delivery, error) {
+	ctx, span := tracing.Start(ctx, "webhook.GetDeliveries")
+	defer span.End()
+
+	if _, err := s.getWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries := []Delivery{}
+	if err := s.db.GetWithFilters(ctx, &deliveries, NewWebhookIDFilter(webhookID)); err != nil {
+		s.logger.WithField("webhook", webhookID).WithError(err).Error(ctx, "error getting webhook deliveries")
+		return nil, apierror.InternalServerError("error getting webhook deliveries")
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+func (s *service) RecordDelivery(ctx context.Context, d Delivery) error {
+	if err := s.db.Save(ctx, d.GetIdentifier(), &d); err != nil {
+		s.logger.WithField("webhook", d.WebhookID).WithError(err).Error(ctx, "error recording webhook delivery")
+		return apierror.InternalServerError("error recording webhook delivery")
+	}
+	return nil
+}
+
+// QueueDeliveries stores a pending delivery of every event for each webhook wanting it, due right
+// away. An event that can't be encoded is logged and skipped, as it never could be.
+func (s *service) QueueDeliveries(ctx context.Context, relayed []events.Event) error {
+	ctx, span := tracing.Start(ctx, "webhook.QueueDeliveries")
+	defer span.End()
+
+	webhooks, err := s.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, event := range relayed {
+		body, err := json.Marshal(event)
+		if err != nil {
+			s.logger.WithField("event", event.ID).WithError(err).Error(ctx, "error encoding event, event not delivered")
+			continue
+		}
+		for _, w := range webhooks {
+			if !w.Wants(event.Type) {
+				continue
+			}
+			pending := PendingDelivery{
+				WebhookID:      w.ID,
+				EventID:        event.ID,
+				EventType:      event.Type,
+				IdempotencyKey: event.IdempotencyKey,
+				Body:           body,
+				NextAttemptAt:  now,
+			}
+			if err := s.db.Save(ctx, pending.GetIdentifier(), &pending); err != nil {
+				s.logger.WithField("webhook", w.ID).WithField("event", event.ID).WithError(err).Error(ctx, "error queueing webhook delivery")
+				return apierror.InternalServerError("error queueing webhook delivery")
+			}
+		}
+	}
+	return nil
+}
+
+func (s *service) NextPendingDelivery(ctx context.Context, webhookID int) (PendingDelivery, bool, error) {
+	ctx, span := tracing.Start(ctx, "webhook.NextPendingDelivery")
+	defer span.End()
+
+	var pending []PendingDelivery
+	page := database.Page{OrderBy: "id", Limit: 1}
+	if err := s.db.GetPage(ctx, &pending, page, NewWebhookIDFilter(webhookID)); err != nil {
+		s.logger.WithField("webhook", webhookID).WithError(err).Error(ctx, "error getting pending webhook delivery")
+		return PendingDelivery{}, false, apierror.InternalServerError("error getting pending webhook delivery")
+	}
+	if len(pending) == 0 {
+		return PendingDelivery{}, false, nil
+	}
+	return pending[0], true, nil
+}
+
+// SavePendingDelivery updates the attempts of a pending delivery, unless it was removed meanwhile
+// along with its webhook
+func (s *service) SavePendingDelivery(ctx context.Context, p PendingDelivery) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		// replaced, keeping its ID so it's still delivered before the ones queued after it
+		removed, err := s.db.Delete(ctx, &PendingDelivery{}, NewIDFilter(p.ID))
+		if err == nil && removed > 0 {
+			err = s.db.Save(ctx, p.GetIdentifier(), &p)
+		}
+		if err != nil {
+			s.logger.WithField("webhook", p.WebhookID).WithField("event", p.EventID).WithError(err).Error(ctx, "error saving pending webhook delivery")
+			return apierror.InternalServerError("error saving pending webhook delivery")
+		}
+		return nil
+	})
+}
+
+func (s *service) RemovePendingDelivery(ctx context.Context, id int) error {
+	if _, err := s.db.Delete(ctx, &PendingDelivery{}, NewIDFilter(id)); err != nil {
+		s.logger.WithField("id", id).WithError(err).Error(ctx, "error removing pending webhook delivery")
+		return apierror.InternalServerError("error removing pending webhook delivery")
+	}
+	return nil
+}
+
+func (s *service) getWebhook(ctx context.Context, id int) (Webhook, error) {
+	var webhooks []Webhook
+	if err := s.db.GetWithFilters(ctx, &webhooks, NewIDFilter(id)); err != nil {
+		s.logger.WithField("id", id).WithError(err).Error(ctx, "error getting webhook")
+		return Webhook{}, apierror.InternalServerError("error getting webhook")
+	}
+	if len(webhooks) == 0 {
+		return Webhook{}, apierror.NotFound("Webhook not found")
+	}
+	return webhooks[0], nil
+}
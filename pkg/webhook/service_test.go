@@ -0,0 +1,169 @@
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"mytheresa/internal/apierror"
+	"mytheresa/internal/database"
+	dbmocks "mytheresa/internal/database/mocks"
+	"mytheresa/internal/database/sqlite/sqlitetest"
+	loggermocks "mytheresa/internal/logger/mocks"
+	"mytheresa/pkg/events"
+	"mytheresa/pkg/webhook"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newSQLiteDB(t *testing.T) database.Database {
+	return sqlitetest.NewDB(t, &webhook.Webhook{}, &webhook.Delivery{}, &webhook.PendingDelivery{}, &events.OutboxEvent{})
+}
+
+func TestNewService(t *testing.T) {
+	s := webhook.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, false)
+
+	assert.NotNil(t, s)
+}
+
+func TestCreateWebhook_GeneratesSecret(t *testing.T) {
+	s := webhook.NewService(newSQLiteDB(t), &loggermocks.NoopLogger{}, false)
+
+	w, err := s.CreateWebhook(context.Background(), webhook.WebhookRequest{
+		URL:   "https://example.com/hooks",
+		Types: []events.Type{events.PriceChanged, events.DiscountCreated},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, w.ID)
+	assert.Len(t, w.Secret, 64)
+	assert.Equal(t, "price.changed,discount.created", w.Types)
+
+	webhooks, err := s.GetWebhooks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []webhook.Webhook{w}, webhooks)
+}
+
+func TestCreateWebhook_Invalid(t *testing.T) {
+	s := webhook.NewService(&dbmocks.Database{}, &loggermocks.NoopLogger{}, false)
+
+	_, err := s.CreateWebhook(context.Background(), webhook.WebhookRequest{URL: "example.com"})
+
+	assert.Equal(t, apierror.BadRequest("url must be an absolute http or https URL"), err)
+
+	_, err = s.CreateWebhook(context.Background(), webhook.WebhookRequest{URL: "http://169.254.169.254/latest"})
+	assert.Equal(t, apierror.BadRequest("url must not be on a loopback, link-local or private address"), err)
+}
+
+func TestCreateWebhook_ErrorSaving(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+	s := webhook.NewService(&dbmock, &loggermocks.NoopLogger{}, false)
+
+	_, err := s.CreateWebhook(context.Background(), webhook.WebhookRequest{URL: "https://example.com/hooks"})
+
+	assert.EqualError(t, err, "error creating webhook")
+}
+
+func TestDeleteWebhook_KeepsDeliveries(t *testing.T) {
+	s := webhook.NewService(newSQLiteDB(t), &loggermocks.NoopLogger{}, false)
+	ctx := context.Background()
+	w, _ := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: "https://example.com/hooks"})
+	assert.NoError(t, s.RecordDelivery(ctx, webhook.Delivery{WebhookID: w.ID, EventID: "1", EventType: events.ProductCreated, Attempt: 1}))
+	assert.NoError(t, s.RecordDelivery(ctx, webhook.Delivery{WebhookID: w.ID, EventID: "1", EventType: events.ProductCreated, Attempt: 2, Delivered: true}))
+
+	deliveries, err := s.GetDeliveries(ctx, w.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, 1, deliveries[0].Attempt)
+	assert.True(t, deliveries[1].Delivered)
+
+	deleted, err := s.DeleteWebhook(ctx, w.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, w, deleted)
+
+	_, err = s.DeleteWebhook(ctx, w.ID)
+	assert.Equal(t, apierror.NotFound("Webhook not found"), err)
+	_, err = s.GetDeliveries(ctx, w.ID)
+	assert.Equal(t, apierror.NotFound("Webhook not found"), err)
+}
+
+func TestDeleteWebhook_DropsPendingDeliveries(t *testing.T) {
+	s := webhook.NewService(newSQLiteDB(t), &loggermocks.NoopLogger{}, false)
+	ctx := context.Background()
+	w, _ := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: "https://example.com/hooks"})
+	assert.NoError(t, s.QueueDeliveries(ctx, []events.Event{{ID: "1", Type: events.ProductCreated}}))
+	pending, _, _ := s.NextPendingDelivery(ctx, w.ID)
+
+	_, err := s.DeleteWebhook(ctx, w.ID)
+	assert.NoError(t, err)
+
+	_, found, err := s.NextPendingDelivery(ctx, w.ID)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	// not brought back by the worker retrying it
+	pending.Attempts = 1
+	assert.NoError(t, s.SavePendingDelivery(ctx, pending))
+	_, found, _ = s.NextPendingDelivery(ctx, w.ID)
+	assert.False(t, found)
+}
+
+func TestQueueDeliveries(t *testing.T) {
+	s := webhook.NewService(newSQLiteDB(t), &loggermocks.NoopLogger{}, false)
+	ctx := context.Background()
+	prices, _ := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: "https://example.com/prices", Types: []events.Type{events.PriceChanged}})
+	all, _ := s.CreateWebhook(ctx, webhook.WebhookRequest{URL: "https://example.com/all"})
+
+	relayed := []events.Event{
+		{ID: "1", IdempotencyKey: "a", Type: events.ProductCreated, Data: []byte(`{"id":1}`)},
+		{ID: "2", IdempotencyKey: "b", Type: events.PriceChanged, Data: []byte(`{"price":100}`)},
+	}
+	assert.NoError(t, s.QueueDeliveries(ctx, relayed))
+
+	pending, found, err := s.NextPendingDelivery(ctx, prices.ID)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "2", pending.EventID)
+	assert.Equal(t, events.PriceChanged, pending.EventType)
+	assert.Equal(t, "b", pending.IdempotencyKey)
+	assert.Contains(t, string(pending.Body), `"data":{"price":100}`)
+	assert.Zero(t, pending.Attempts)
+	assert.False(t, pending.NextAttemptAt.After(time.Now()))
+
+	// oldest first, the next one once removed
+	pending, _, _ = s.NextPendingDelivery(ctx, all.ID)
+	assert.Equal(t, "1", pending.EventID)
+	pending.Attempts = 1
+	pending.NextAttemptAt = time.Now().Add(time.Hour).UTC()
+	assert.NoError(t, s.SavePendingDelivery(ctx, pending))
+	retried, _, _ := s.NextPendingDelivery(ctx, all.ID)
+	assert.Equal(t, 1, retried.Attempts)
+	assert.WithinDuration(t, pending.NextAttemptAt, retried.NextAttemptAt, time.Second)
+	assert.NoError(t, s.RemovePendingDelivery(ctx, pending.ID))
+	pending, _, _ = s.NextPendingDelivery(ctx, all.ID)
+	assert.Equal(t, "2", pending.EventID)
+}
+
+func TestQueueDeliveries_ErrorSaving(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*[]webhook.Webhook) = []webhook.Webhook{{ID: 1}}
+	}).Return(nil)
+	dbmock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+	s := webhook.NewService(&dbmock, &loggermocks.NoopLogger{}, false)
+
+	err := s.QueueDeliveries(context.Background(), []events.Event{{ID: "1", Type: events.ProductCreated}})
+
+	assert.EqualError(t, err, "error queueing webhook delivery")
+}
+
+func TestGetWebhooks_ErrorGettingFromDB(t *testing.T) {
+	dbmock := dbmocks.Database{}
+	dbmock.On("GetWithFilters", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("some DB error"))
+	s := webhook.NewService(&dbmock, &loggermocks.NoopLogger{}, false)
+
+	_, err := s.GetWebhooks(context.Background())
+
+	assert.EqualError(t, err, "error getting webhooks")
+}